@@ -17,9 +17,28 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// DefaultUninstallGracePeriod is the default time the uninstallation
+	// waits for the workloads using GPUs to complete.
+	DefaultUninstallGracePeriod = 10 * time.Minute
+	// DefaultUninstallForceDeadline is the default time after the deletion of
+	// the GPUAddon when the remaining components are force-cleaned.
+	DefaultUninstallForceDeadline = 30 * time.Minute
+	// DefaultConsolePluginReplicas is the default number of replicas of the
+	// console plugin.
+	DefaultConsolePluginReplicas = 2
+	// DefaultIdleGPUFor is the default time a pod holding GPUs has to be idle
+	// for before the idle GPU policy applies to it.
+	DefaultIdleGPUFor = 4 * time.Hour
+)
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -27,8 +46,12 @@ import (
 type GPUAddonSpec struct {
 
 	//+kubebuilder:default:=true
+	//+kubebuilder:validation:Optional
 	// If enabled, addon will deploy the GPU console plugin.
-	ConsolePluginEnabled bool `json:"console_plugin_enabled,omitempty"`
+	ConsolePluginEnabled bool `json:"console_plugin_enabled"`
+	//+kubebuilder:validation:Optional
+	// ConsolePlugin configures the deployment of the GPU console plugin.
+	ConsolePlugin *ConsolePluginSpec `json:"console_plugin,omitempty"`
 	// Optional NVAIE pullsecret
 	NVAIEPullSecret string `json:"nvaie_pullsecret,omitempty"`
 	//+kubebuilder:validation:Optional
@@ -38,6 +61,233 @@ type GPUAddonSpec struct {
 	// - a semantic version, e.g. 515.48.07 or
 	// - a container image digest, e.g. sha256:<digest>
 	DriverVersion string `json:"driver_version,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=none;single;mixed
+	// MIGStrategy is the MIG strategy the GPU operator applies to the GPU
+	// nodes. Defaults to single.
+	MIGStrategy string `json:"mig_strategy,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Uninstall configures the uninstallation of the add-on.
+	Uninstall *UninstallSpec `json:"uninstall,omitempty"`
+	//+kubebuilder:validation:Optional
+	// IdleGPUPolicy detects the pods which hold GPUs without using them. It
+	// is disabled when not set.
+	IdleGPUPolicy *IdleGPUPolicySpec `json:"idle_gpu_policy,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GPUNodeIsolation steers the pods requesting GPUs to the GPU nodes and
+	// keeps the other pods off them. It is disabled when not set.
+	GPUNodeIsolation *GPUNodeIsolationSpec `json:"gpu_node_isolation,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Autoscaling configures the cluster autoscaler for the GPU MachineSets.
+	// It is disabled when not set.
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GPUTypes map the GPU products and PCI device IDs of the GPU nodes to
+	// the stable GPU type they are labeled with in gpu-type. They take
+	// precedence over the built-in ones.
+	GPUTypes []GPUTypeMapping `json:"gpu_types,omitempty"`
+}
+
+// ConsolePluginSpec configures the deployment of the GPU console plugin.
+type ConsolePluginSpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Replicas is the number of console plugin pods. Defaults to 2.
+	Replicas *int32 `json:"replicas,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Resources are the compute resources of the console plugin container.
+	// Defaults to requests of 10m CPU and 100Mi memory, and limits of 20m
+	// CPU and 200Mi memory.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	//+kubebuilder:validation:Optional
+	// NodeSelector constrains the nodes the console plugin pods run on.
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Tolerations are the tolerations of the console plugin pods.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Image overrides the console plugin image, which defaults to the one
+	// of the add-on release.
+	Image string `json:"image,omitempty"`
+}
+
+// UninstallSpec configures how the add-on is uninstalled.
+// +kubebuilder:validation:XValidation:rule="duration(has(self.grace_period) ? self.grace_period : '10m') < duration(has(self.force_deadline) ? self.force_deadline : '30m')",message="grace_period must be shorter than force_deadline"
+type UninstallSpec struct {
+	//+kubebuilder:validation:Optional
+	// GracePeriod is how long the uninstallation waits for the workloads
+	// using GPUs to complete before the GPU stack is removed. Defaults to
+	// 10m.
+	GracePeriod *metav1.Duration `json:"grace_period,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ForceDeadline is how long after the deletion of the GPUAddon, i.e. the
+	// start of the teardown once the GPU workloads no longer hold it back,
+	// the components which are still not deleted are force-cleaned, e.g. by
+	// removing their finalizers. It must be longer than the grace period.
+	// It also bounds the wait of the block uninstall mode. Defaults to 30m.
+	ForceDeadline *metav1.Duration `json:"force_deadline,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Detach keeps the GPU stack, i.e. the NodeFeatureDiscovery CR, the GPU
+	// operator Subscription and the ClusterPolicy, running when the add-on
+	// is uninstalled, so that a self-managed GPU operator can take it over.
+	// Their add-on owner references and labels are removed, and only the
+	// add-on own components, i.e. the console plugin, the monitoring stack
+	// and the add-on CSV, are deleted. The Subscription is pointed to the
+	// certified operators catalog. In the add-on namespace of the managed
+	// add-on, which is deleted along with it, the GPU stack is uninstalled
+	// rather than detached, as reported by the UninstallDetached condition.
+	Detach bool `json:"detach,omitempty"`
+}
+
+// IdleGPUPolicySpec configures the detection of the pods which hold GPUs
+// without using them. The idle pods are labeled, get an event and are counted
+// in the nvidia_gpuaddon_idle_gpu_pods metric, which an alert fires on. The
+// namespaces can opt in to have the idle pods evicted or their workload
+// scaled down with the idle-gpu-action label.
+type IdleGPUPolicySpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=100
+	// UtilizationThreshold is the GPU utilization, in percent, below which a
+	// GPU is idle. Defaults to 5.
+	UtilizationThreshold *int32 `json:"utilization_threshold,omitempty"`
+	//+kubebuilder:validation:Optional
+	// IdleFor is how long all the GPUs of a pod have to be idle for before
+	// the pod is reported. Defaults to 4h.
+	IdleFor *metav1.Duration `json:"idle_for,omitempty"`
+}
+
+// GPUNodeIsolationSpec configures the isolation of the GPU nodes. The pods
+// requesting GPUs or MIG devices are given the toleration of the GPU node
+// taint by a mutating webhook, and optionally a node selector for a GPU
+// product.
+type GPUNodeIsolationSpec struct {
+	//+kubebuilder:validation:Optional
+	// TaintGPUNodes taints the GPU nodes, as labeled by NFD, with the
+	// nvidia.com/gpu NoSchedule taint, so that only the pods requesting GPUs
+	// and the GPU stack are scheduled on them. The pods already running on
+	// the GPU nodes are not evicted.
+	TaintGPUNodes bool `json:"taint_gpu_nodes,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GPUProduct is the GPU product, as labeled by the GPU feature discovery
+	// in nvidia.com/gpu.product, the pods requesting GPUs are scheduled on,
+	// e.g. NVIDIA-A100-SXM4-40GB. A pod can request another product with the
+	// gpu-product annotation. The pods are scheduled on any GPU node when
+	// neither is set.
+	GPUProduct string `json:"gpu_product,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ExemptNamespaces are the namespaces whose pods are not mutated. The
+	// add-on namespace is always exempt.
+	ExemptNamespaces []string `json:"exempt_namespaces,omitempty"`
+}
+
+// AutoscalingSpec configures the cluster autoscaler for the GPU MachineSets.
+// The MachineSets whose nodes have NVIDIA GPUs, or which are annotated with
+// their number of GPUs with the gpu-count annotation, are given the capacity
+// annotations the cluster autoscaler needs to scale them from zero, and
+// their nodes are labeled with their GPU type, as in gpu-type, in
+// cluster-api/accelerator. The capacity annotations and labels set otherwise,
+// e.g. by the Machine API provider, are left alone.
+type AutoscalingSpec struct {
+	//+kubebuilder:validation:Optional
+	// GPULimits are the GPU resource limits of the default
+	// ClusterAutoscaler, i.e. the minimum and maximum number of GPUs of each
+	// type in the cluster. Only the limits set by the add-on are updated or
+	// removed, the ones set by the cluster admins are left alone.
+	GPULimits []GPULimit `json:"gpu_limits,omitempty"`
+}
+
+// GPULimit is the minimum and maximum number of GPUs of a type in the cluster.
+type GPULimit struct {
+	//+kubebuilder:validation:MinLength=1
+	// Type is the GPU type, as labeled in gpu-type and
+	// cluster-api/accelerator on the nodes, e.g. a100-40gb.
+	Type string `json:"type"`
+	//+kubebuilder:validation:Minimum=0
+	// Min is the minimum number of GPUs of the type.
+	Min int32 `json:"min"`
+	//+kubebuilder:validation:Minimum=0
+	// Max is the maximum number of GPUs of the type.
+	Max int32 `json:"max"`
+}
+
+// GPUTypeMapping maps GPU products and PCI device IDs to a stable GPU type.
+type GPUTypeMapping struct {
+	//+kubebuilder:validation:Pattern:="^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$"
+	//+kubebuilder:validation:MaxLength=63
+	// Type is the GPU type, e.g. a100-40gb.
+	Type string `json:"type"`
+	//+kubebuilder:validation:Optional
+	// Products are the GPU products, as labeled by the GPU feature discovery
+	// in nvidia.com/gpu.product, e.g. NVIDIA-A100-SXM4-40GB. They match
+	// regardless of the case, of an NVIDIA- prefix and of a MIG suffix.
+	Products []string `json:"products,omitempty"`
+	//+kubebuilder:validation:Optional
+	// PCIDeviceIDs are the PCI device IDs of the GPUs, e.g. 20b0, as labeled
+	// by NFD in pci-10de_<device ID>.present.
+	PCIDeviceIDs []string `json:"pci_device_ids,omitempty"`
+}
+
+// IdleGPUUtilizationThreshold returns the configured idle GPU utilization
+// threshold or its default.
+func (s *GPUAddonSpec) IdleGPUUtilizationThreshold() int32 {
+	if s.IdleGPUPolicy == nil || s.IdleGPUPolicy.UtilizationThreshold == nil {
+		return DefaultIdleUtilizationThreshold
+	}
+	return *s.IdleGPUPolicy.UtilizationThreshold
+}
+
+// IdleGPUFor returns how long the GPUs of a pod have to be idle for, or its
+// default.
+func (s *GPUAddonSpec) IdleGPUFor() time.Duration {
+	if s.IdleGPUPolicy == nil || s.IdleGPUPolicy.IdleFor == nil {
+		return DefaultIdleGPUFor
+	}
+	return s.IdleGPUPolicy.IdleFor.Duration
+}
+
+// UninstallGracePeriod returns the configured uninstall grace period or its
+// default.
+func (s *GPUAddonSpec) UninstallGracePeriod() time.Duration {
+	if s.Uninstall == nil || s.Uninstall.GracePeriod == nil {
+		return DefaultUninstallGracePeriod
+	}
+	return s.Uninstall.GracePeriod.Duration
+}
+
+// UninstallForceDeadline returns the configured uninstall force deadline or
+// its default.
+func (s *GPUAddonSpec) UninstallForceDeadline() time.Duration {
+	if s.Uninstall == nil || s.Uninstall.ForceDeadline == nil {
+		return DefaultUninstallForceDeadline
+	}
+	return s.Uninstall.ForceDeadline.Duration
+}
+
+// ValidateUninstall returns an error if the uninstall grace period is not
+// shorter than the force deadline. It is enforced at admission too, but only
+// by the clusters which support CRD validation rules.
+func (s *GPUAddonSpec) ValidateUninstall() error {
+	if s.UninstallGracePeriod() >= s.UninstallForceDeadline() {
+		return fmt.Errorf("grace_period %s must be shorter than force_deadline %s",
+			s.UninstallGracePeriod(), s.UninstallForceDeadline())
+	}
+	return nil
+}
+
+// UninstallDetach returns whether the GPU stack is detached rather than
+// deleted when the add-on is uninstalled.
+func (s *GPUAddonSpec) UninstallDetach() bool {
+	return s.Uninstall != nil && s.Uninstall.Detach
+}
+
+// ConsolePluginReplicas returns the configured number of console plugin
+// replicas or its default.
+func (s *GPUAddonSpec) ConsolePluginReplicas() int32 {
+	if s.ConsolePlugin == nil || s.ConsolePlugin.Replicas == nil {
+		return DefaultConsolePluginReplicas
+	}
+	return *s.ConsolePlugin.Replicas
 }
 
 // GPUAddonStatus defines the observed state of GPUAddon
@@ -46,6 +296,155 @@ type GPUAddonStatus struct {
 	Phase GPUAddonPhase `json:"phase"`
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions"`
+	// Uninstall reports the progress of the add-on uninstallation.
+	Uninstall *UninstallStatus `json:"uninstall,omitempty"`
+	// IdleGPUs reports the pods detected by the idle GPU policy.
+	IdleGPUs *IdleGPUStatus `json:"idle_gpus,omitempty"`
+	// Autoscaling reports the GPU MachineSets prepared for the cluster
+	// autoscaler.
+	Autoscaling *AutoscalingStatus `json:"autoscaling,omitempty"`
+}
+
+// AutoscalingStatus reports the GPU MachineSets prepared for the cluster
+// autoscaler, and its GPU resource limits.
+type AutoscalingStatus struct {
+	// GPUMachineSets are the MachineSets which can be scaled from zero.
+	GPUMachineSets []GPUMachineSet `json:"gpu_machine_sets,omitempty"`
+	// GPULimitsApplied reports whether the GPU resource limits are set on
+	// the ClusterAutoscaler.
+	GPULimitsApplied bool `json:"gpu_limits_applied"`
+	// Message details why the autoscaling is not fully configured, e.g.
+	// when the ClusterAutoscaler does not exist.
+	Message string `json:"message,omitempty"`
+}
+
+// GPUMachineSet is a MachineSet whose machines have GPUs.
+type GPUMachineSet struct {
+	// Name is the name of the MachineSet.
+	Name string `json:"name"`
+	// GPUs is the number of GPUs of each machine.
+	GPUs int32 `json:"gpus"`
+	// GPUType is the GPU type the nodes are labeled with, if known.
+	GPUType string `json:"gpu_type,omitempty"`
+}
+
+// IdleGPUStatus reports the pods detected by the idle GPU policy, and the
+// actions taken on them.
+type IdleGPUStatus struct {
+	// LastCheckTime is when the idle pods were last checked.
+	LastCheckTime metav1.Time `json:"last_check_time"`
+	// Error is why the last check of the idle pods failed, e.g. the add-on
+	// Prometheus denying the queries.
+	Error string `json:"error,omitempty"`
+	// IdlePods is the number of pods which hold idle GPUs.
+	IdlePods int32 `json:"idle_pods"`
+	// Decisions are the latest decisions of the idle GPU policy, most
+	// recent last.
+	Decisions []IdleGPUDecision `json:"decisions,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=label;evict;scale-down;skip
+type IdleGPUAction string
+
+const (
+	// IdleGPUActionLabel labels the idle pod and records an event.
+	IdleGPUActionLabel IdleGPUAction = "label"
+	// IdleGPUActionEvict evicts the idle pod.
+	IdleGPUActionEvict IdleGPUAction = "evict"
+	// IdleGPUActionScaleDown scales the workload owning the idle pod down to
+	// zero replicas.
+	IdleGPUActionScaleDown IdleGPUAction = "scale-down"
+	// IdleGPUActionSkip reports an opted-in action which could not be taken.
+	IdleGPUActionSkip IdleGPUAction = "skip"
+)
+
+// IdleGPUDecision is an action the idle GPU policy took on an idle pod.
+type IdleGPUDecision struct {
+	// Time is when the action was taken.
+	Time metav1.Time `json:"time"`
+	// Namespace is the namespace of the idle pod.
+	Namespace string `json:"namespace"`
+	// Pod is the name of the idle pod.
+	Pod string `json:"pod"`
+	// Action is the action taken.
+	Action IdleGPUAction `json:"action"`
+	// Message details the action, e.g. the workload scaled down.
+	Message string `json:"message,omitempty"`
+}
+
+// UninstallStatus reports the progress of the add-on uninstallation.
+type UninstallStatus struct {
+	// StartTime is when the uninstallation was requested.
+	StartTime metav1.Time `json:"start_time"`
+	// Mode is the uninstall mode in effect.
+	Mode UninstallMode `json:"mode,omitempty"`
+	// GPUPods is the number of pods which still use GPUs.
+	GPUPods int32 `json:"gpu_pods"`
+	// GPUVirtualMachines is the number of virtual machines which still use
+	// GPUs.
+	GPUVirtualMachines int32 `json:"gpu_virtual_machines"`
+	// GPUWorkloads lists the workloads which still use GPUs. The list is
+	// truncated, while the uninstall report ConfigMap lists all of them.
+	GPUWorkloads []GPUWorkload `json:"gpu_workloads,omitempty"`
+	// Forced reports whether the remaining components have been
+	// force-cleaned after the force deadline.
+	Forced bool `json:"forced,omitempty"`
+	// Components reports the deletion progress of each add-on component.
+	Components []ComponentUninstallStatus `json:"components,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=wait;block;drain;force
+type UninstallMode string
+
+const (
+	// UninstallModeWait waits for the GPU workloads to complete for up to
+	// the grace period before uninstalling. This is the default.
+	UninstallModeWait UninstallMode = "wait"
+	// UninstallModeBlock doesn't uninstall until all the GPU workloads have
+	// completed, regardless of the grace period. The block is bounded by the
+	// force deadline, measured from the start of the uninstallation, after
+	// which the add-on is uninstalled regardless of the GPU workloads.
+	UninstallModeBlock UninstallMode = "block"
+	// UninstallModeDrain evicts the GPU pods and then waits for the GPU
+	// workloads to complete for up to the grace period.
+	UninstallModeDrain UninstallMode = "drain"
+	// UninstallModeForce uninstalls right away, regardless of the GPU
+	// workloads.
+	UninstallModeForce UninstallMode = "force"
+)
+
+// GPUWorkload is a workload which uses GPUs.
+type GPUWorkload struct {
+	// Kind is either Pod or VirtualMachineInstance.
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the workload.
+	Namespace string `json:"namespace"`
+	// Name is the name of the workload.
+	Name string `json:"name"`
+	// NodeName is the node the workload runs on, if scheduled.
+	NodeName string `json:"node_name,omitempty"`
+}
+
+// ComponentUninstallStatus reports the deletion progress of an add-on
+// component.
+type ComponentUninstallStatus struct {
+	// Name is the name of the component.
+	Name string `json:"name"`
+	// Deleted reports whether all the resources of the component are gone.
+	Deleted bool `json:"deleted"`
+	// Detached reports whether the resources of the component have been
+	// detached from the add-on rather than deleted.
+	Detached bool `json:"detached,omitempty"`
+	// CleanedNodes is the number of nodes whose labels, annotations and
+	// taints left behind by the component have been removed.
+	CleanedNodes int32 `json:"cleaned_nodes,omitempty"`
+	// CleanedNodeNames lists the nodes counted in CleanedNodes, so that a
+	// node cleaned up again is not counted twice.
+	CleanedNodeNames []string `json:"cleaned_node_names,omitempty"`
+	// FailedNodes lists the nodes which could not be cleaned up yet.
+	FailedNodes []string `json:"failed_nodes,omitempty"`
+	// Message describes why the component is not deleted yet.
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:validation:Enum=Failed;Idle;Installing;Ready;Updating;Uninstalling
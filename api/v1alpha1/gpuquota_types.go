@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GPUQuotaSpec defines the desired state of GPUQuota
+type GPUQuotaSpec struct {
+	// NamespaceSelector selects the namespaces the quota applies to. An empty
+	// selector selects all the namespaces.
+	NamespaceSelector metav1.LabelSelector `json:"namespace_selector"`
+	// Hard is the GPU resources each of the selected namespaces can request
+	// at most, e.g. requests.nvidia.com/gpu or requests.nvidia.com/mig-1g.5gb.
+	// Only the requests of the GPU and MIG resources can be limited.
+	Hard corev1.ResourceList `json:"hard"`
+}
+
+// GPUQuotaStatus defines the observed state of GPUQuota
+type GPUQuotaStatus struct {
+	// Conditions represent the latest available observations of an object's state
+	Conditions []metav1.Condition `json:"conditions"`
+	// ObservedGeneration is the generation of the spec the ResourceQuotas
+	// were last reconciled for.
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// Hard is the sum of the hard limits of the selected namespaces.
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	// Used is the sum of the GPU resources requested by the selected
+	// namespaces.
+	Used corev1.ResourceList `json:"used,omitempty"`
+	// Namespaces is the GPU quota usage of each of the selected namespaces.
+	Namespaces []NamespaceGPUQuota `json:"namespaces,omitempty"`
+}
+
+// NamespaceGPUQuota is the GPU quota usage of a namespace, as reported by its
+// ResourceQuota.
+type NamespaceGPUQuota struct {
+	// Namespace is the name of the namespace.
+	Namespace string `json:"namespace"`
+	// Hard is the enforced hard limits of the namespace.
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+	// Used is the GPU resources requested by the namespace.
+	Used corev1.ResourceList `json:"used,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=gpuquotas
+
+// GPUQuota is the Schema for the gpuquotas API. It limits the GPU resources
+// each of the selected namespaces can request with a ResourceQuota.
+type GPUQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GPUQuotaSpec   `json:"spec,omitempty"`
+	Status GPUQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GPUQuotaList contains a list of GPUQuota.
+type GPUQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GPUQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GPUQuota{}, &GPUQuotaList{})
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultUsageReportWindow is the default window the GPU usage is
+	// reported over.
+	DefaultUsageReportWindow = "24h"
+	// DefaultUsageReportInterval is the default interval the GPU usage
+	// report is refreshed at.
+	DefaultUsageReportInterval = "1h"
+	// DefaultIdleUtilizationThreshold is the default average utilization, in
+	// percent, below which a pod holding GPUs is reported as idle.
+	DefaultIdleUtilizationThreshold = 5
+)
+
+// GPUUsageReportSpec defines the desired state of GPUUsageReport
+type GPUUsageReportSpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="24h"
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?$"
+	// Window is how far back the GPU usage is reported over, e.g. 7d.
+	Window string `json:"window,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="1h"
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?$"
+	// Interval is how often the report is refreshed.
+	Interval string `json:"interval,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:=5
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=100
+	// IdleUtilizationThreshold is the average GPU utilization, in percent,
+	// over the window below which a pod holding GPUs is reported as idle.
+	IdleUtilizationThreshold *int32 `json:"idle_utilization_threshold,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Export exports the report as CSV, e.g. for chargeback.
+	Export *UsageReportExportSpec `json:"export,omitempty"`
+}
+
+// UsageReportExportSpec defines where a GPU usage report is exported to.
+type UsageReportExportSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	// ConfigMapName is the name of the ConfigMap the report is written to,
+	// in the namespace of the GPUUsageReport, as CSV in its usage.csv key.
+	ConfigMapName string `json:"config_map_name"`
+}
+
+// UsageReportWindow returns the window of the report, defaulting to
+// DefaultUsageReportWindow.
+func (s *GPUUsageReportSpec) UsageReportWindow() string {
+	if s.Window == "" {
+		return DefaultUsageReportWindow
+	}
+	return s.Window
+}
+
+// UsageReportInterval returns the refresh interval of the report, defaulting
+// to DefaultUsageReportInterval.
+func (s *GPUUsageReportSpec) UsageReportInterval() string {
+	if s.Interval == "" {
+		return DefaultUsageReportInterval
+	}
+	return s.Interval
+}
+
+// IdleThreshold returns the idle utilization threshold of the report,
+// defaulting to DefaultIdleUtilizationThreshold.
+func (s *GPUUsageReportSpec) IdleThreshold() int32 {
+	if s.IdleUtilizationThreshold == nil {
+		return DefaultIdleUtilizationThreshold
+	}
+	return *s.IdleUtilizationThreshold
+}
+
+// GPUUsageReportStatus defines the observed state of GPUUsageReport
+type GPUUsageReportStatus struct {
+	// Conditions represent the latest available observations of an object's state
+	Conditions []metav1.Condition `json:"conditions"`
+	// ObservedGeneration is the generation of the spec the report was
+	// generated for.
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// StartTime is the start of the reported window.
+	StartTime *metav1.Time `json:"start_time,omitempty"`
+	// EndTime is the end of the reported window, when the report was last
+	// generated.
+	EndTime *metav1.Time `json:"end_time,omitempty"`
+	// Namespaces is the GPU usage of the namespaces which held GPUs during
+	// the window.
+	Namespaces []NamespaceGPUUsage `json:"namespaces,omitempty"`
+}
+
+// NamespaceGPUUsage is the GPU usage of a namespace over the reported window.
+type NamespaceGPUUsage struct {
+	// Namespace is the name of the namespace.
+	Namespace string `json:"namespace"`
+	// AllocatedGPUHours is the number of GPU hours allocated to the pods of
+	// the namespace, e.g. 12.50. It is the larger of the GPU hours sampled
+	// by the DCGM exporter and of the GPU hours allocated by Kubernetes to
+	// the running pods.
+	AllocatedGPUHours string `json:"allocated_gpu_hours"`
+	// AllocatedGPUs is the number of GPUs and MIG devices allocated by
+	// Kubernetes to the running pods of the namespace at the end of the
+	// window.
+	AllocatedGPUs int32 `json:"allocated_gpus"`
+	// AverageUtilization is the average utilization, in percent, of the GPUs
+	// allocated to the pods of the namespace.
+	AverageUtilization int32 `json:"average_utilization"`
+	// IdlePods are the pods holding GPUs with an average utilization below
+	// the idle threshold.
+	IdlePods []string `json:"idle_pods,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.spec.window`
+//+kubebuilder:printcolumn:name="Generated",type=date,JSONPath=`.status.end_time`
+
+// GPUUsageReport is the Schema for the gpuusagereports API. It reports the
+// GPUs allocated to each namespace, and how much they are used.
+type GPUUsageReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GPUUsageReportSpec   `json:"spec,omitempty"`
+	Status GPUUsageReportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GPUUsageReportList contains a list of GPUUsageReport.
+type GPUUsageReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GPUUsageReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GPUUsageReport{}, &GPUUsageReportList{})
+}
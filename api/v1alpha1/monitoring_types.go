@@ -17,17 +17,398 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // MonitoringSpec defines the desired monitoring configuration of the NVIDIA GPU Add-on.
 type MonitoringSpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:=Dedicated
+	// Mode is the monitoring stack the add-on metrics and alerts go to.
+	// Prometheus sizing, remote write and federation only apply to the
+	// Dedicated mode.
+	Mode MonitoringMode `json:"mode,omitempty"`
+	//+kubebuilder:validation:Optional
+	// PagerDuty configures the routing of the add-on alerts to PagerDuty.
+	PagerDuty *PagerDutySpec `json:"pagerduty,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$"
+	// Retention is how long the add-on Prometheus retains metrics, e.g. 15d.
+	Retention string `json:"retention,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(0|([0-9]*[.])?[0-9]+((K|M|G|T|E|P)i?)?B)$"
+	// RetentionSize is the maximum size of the metrics retained by the add-on
+	// Prometheus, e.g. 10GB.
+	RetentionSize string `json:"retention_size,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Prometheus sizes the add-on Prometheus.
+	Prometheus *PrometheusSpec `json:"prometheus,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Receivers declares where the add-on alerts can be routed to, on top of
+	// the managed PagerDuty and Dead Man's Snitch receivers.
+	Receivers []ReceiverSpec `json:"receivers,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Routes routes the add-on alerts to the declared receivers. They are
+	// evaluated in order after the managed routes.
+	Routes []RouteSpec `json:"routes,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GPUAlerts tunes the thresholds of the GPU and add-on health alerts.
+	GPUAlerts *GPUAlertsSpec `json:"gpu_alerts,omitempty"`
+	//+kubebuilder:validation:Optional
+	// RemoteWrite declares the endpoints the add-on Prometheus writes the
+	// metrics to, e.g. a central observability stack.
+	RemoteWrite []RemoteWriteSpec `json:"remote_write,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Federation exposes an allow-list of the add-on metrics for federation.
+	Federation *FederationSpec `json:"federation,omitempty"`
+}
+
+// RemoteWriteSpec declares a remote write endpoint. At most one of its
+// authentication methods can be set. The referenced Secrets must be in the
+// namespace of the Monitoring CR.
+type RemoteWriteSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	// Name identifies the endpoint in the status.
+	Name string `json:"name"`
+	//+kubebuilder:validation:Pattern:="^https?://"
+	// URL is the URL of the endpoint.
+	URL string `json:"url"`
+	//+kubebuilder:validation:Optional
+	// BasicAuth authenticates to the endpoint with a username and password.
+	BasicAuth *RemoteWriteBasicAuth `json:"basic_auth,omitempty"`
+	//+kubebuilder:validation:Optional
+	// BearerToken is the Secret key holding the bearer token authenticating
+	// to the endpoint.
+	BearerToken *corev1.SecretKeySelector `json:"bearer_token,omitempty"`
+	//+kubebuilder:validation:Optional
+	// TLS configures the TLS connection to the endpoint.
+	TLS *RemoteWriteTLS `json:"tls,omitempty"`
+	//+kubebuilder:validation:Optional
+	// WriteRelabelConfigs relabel the series before they are written, e.g. to
+	// drop high-cardinality series.
+	WriteRelabelConfigs []RelabelConfig `json:"write_relabel_configs,omitempty"`
+}
+
+// RemoteWriteBasicAuth is the basic authentication of a remote write endpoint.
+type RemoteWriteBasicAuth struct {
+	// Username is the Secret key holding the username.
+	Username corev1.SecretKeySelector `json:"username"`
+	// Password is the Secret key holding the password.
+	Password corev1.SecretKeySelector `json:"password"`
+}
+
+// RemoteWriteTLS is the TLS configuration of a remote write endpoint.
+type RemoteWriteTLS struct {
+	//+kubebuilder:validation:Optional
+	// CA is the Secret key holding the CA certificate of the endpoint.
+	CA *corev1.SecretKeySelector `json:"ca,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Cert is the Secret key holding the client certificate. Requires Key.
+	Cert *corev1.SecretKeySelector `json:"cert,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Key is the Secret key holding the client key. Requires Cert.
+	Key *corev1.SecretKeySelector `json:"key,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ServerName is the server name of the endpoint certificate.
+	ServerName string `json:"server_name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// InsecureSkipVerify disables the verification of the endpoint
+	// certificate.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// RelabelConfig relabels the series written to a remote write endpoint, see
+// https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+type RelabelConfig struct {
+	//+kubebuilder:validation:Optional
+	// SourceLabels are the labels whose values are concatenated and matched
+	// against Regex.
+	SourceLabels []string `json:"source_labels,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Regex is the regular expression the source label values are matched
+	// against. Defaults to (.*).
+	Regex string `json:"regex,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=replace;keep;drop;labelkeep;labeldrop;labelmap
+	// Action is the relabeling action. Defaults to replace.
+	Action string `json:"action,omitempty"`
+	//+kubebuilder:validation:Optional
+	// TargetLabel is the label the replace action writes to.
+	TargetLabel string `json:"target_label,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Replacement is the value the replace action writes. Defaults to $1.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// FederationSpec exposes the add-on metrics for federation.
+type FederationSpec struct {
+	// Enabled creates a ServiceMonitor in the add-on namespace scraping the
+	// /federate endpoint of the add-on Prometheus, for a Prometheus monitoring
+	// that namespace to pick up.
+	Enabled bool `json:"enabled"`
+	//+kubebuilder:validation:Optional
+	// Metrics are the names of the federated metrics. Defaults to the GPU
+	// utilization and the add-on health metrics.
+	Metrics []MetricName `json:"metrics,omitempty"`
+}
+
+// MetricName is the name of a Prometheus metric.
+// +kubebuilder:validation:Pattern:="^[a-zA-Z_:][a-zA-Z0-9_:]*$"
+type MetricName string
+
+// GPUAlertsSpec tunes the thresholds of the GPU health alerts, which are based
+// on the DCGM exporter metrics, and of the add-on health alerts.
+type GPUAlertsSpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// TemperatureThreshold is the GPU temperature in degrees Celsius from
+	// which a GPU is alerted on as running hot. Defaults to 85.
+	TemperatureThreshold *int32 `json:"temperature_threshold,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// ThermalThrottlingFor is how long a GPU has to be thermally throttled,
+	// or to stay at or above the temperature threshold, before alerting.
+	// Defaults to 5m.
+	ThermalThrottlingFor string `json:"thermal_throttling_for,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// ECCDoubleBitErrorsThreshold is the number of ECC double-bit errors in
+	// 10 minutes above which a GPU is alerted on. Defaults to 0.
+	ECCDoubleBitErrorsThreshold *int32 `json:"ecc_double_bit_errors_threshold,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// NVLinkErrorsThreshold is the number of NVLink CRC, recovery or replay
+	// errors in 10 minutes above which a GPU is alerted on. Defaults to 0.
+	NVLinkErrorsThreshold *int32 `json:"nvlink_errors_threshold,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// NotReadyFor is how long the GPU driver or the GPU operator validations
+	// have to be failing on a node before alerting. Defaults to 15m.
+	NotReadyFor string `json:"not_ready_for,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// InstallingFor is how long the GPUAddon can stay in the Installing phase
+	// before alerting. Defaults to 30m.
+	InstallingFor string `json:"installing_for,omitempty"`
+}
+
+// MonitoringMode is the monitoring stack the add-on metrics and alerts go to.
+// +kubebuilder:validation:Enum=Dedicated;UserWorkload;Platform
+type MonitoringMode string
+
+const (
+	// MonitoringModeDedicated deploys a Prometheus and an Alertmanager for
+	// the add-on.
+	MonitoringModeDedicated MonitoringMode = "Dedicated"
+	// MonitoringModeUserWorkload uses the OpenShift user workload monitoring,
+	// which has to be enabled, and routes the alerts with an
+	// AlertmanagerConfig, which requires the user AlertmanagerConfigs to be
+	// enabled. The GPU metrics are queried from the Thanos Querier of the
+	// cluster monitoring, as in the Platform mode.
+	MonitoringModeUserWorkload MonitoringMode = "UserWorkload"
+	// MonitoringModePlatform uses the OpenShift platform monitoring, by
+	// labeling the add-on namespace for cluster monitoring. The alerts are
+	// routed by the platform Alertmanager configuration.
+	MonitoringModePlatform MonitoringMode = "Platform"
+)
+
+// MonitoringMode returns the configured monitoring mode or its default.
+func (s *MonitoringSpec) MonitoringMode() MonitoringMode {
+	if s.Mode == "" {
+		return MonitoringModeDedicated
+	}
+	return s.Mode
+}
+
+// PrometheusSpec sizes the add-on Prometheus.
+type PrometheusSpec struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Replicas is the number of Prometheus replicas. Defaults to 1.
+	Replicas *int32 `json:"replicas,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Resources are the compute resources of the Prometheus container.
+	// Defaults to the recommendation for the number of GPU nodes, see the
+	// prometheus_sizing status.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	//+kubebuilder:validation:Optional
+	// VolumeClaimTemplate is the PersistentVolumeClaim the metrics are stored
+	// in. The metrics are stored in an emptyDir, and are thus lost when the
+	// Prometheus pod restarts, unless it is set.
+	VolumeClaimTemplate *corev1.PersistentVolumeClaimSpec `json:"volume_claim_template,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// ScrapeInterval is the interval between scrapes. Defaults to 30s.
+	ScrapeInterval string `json:"scrape_interval,omitempty"`
+}
+
+// ReceiverSpec declares a notification integration. Exactly one of its
+// integrations must be set. The referenced Secrets must be in the namespace of
+// the Monitoring CR.
+type ReceiverSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	// Name is the unique name routes refer to the receiver with.
+	Name string `json:"name"`
+	//+kubebuilder:validation:Optional
+	// PagerDuty sends the alerts to PagerDuty.
+	PagerDuty *PagerDutyReceiver `json:"pagerduty,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Slack sends the alerts to a Slack channel.
+	Slack *SlackReceiver `json:"slack,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Email sends the alerts by email.
+	Email *EmailReceiver `json:"email,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Webhook posts the alerts to a generic webhook.
+	Webhook *WebhookReceiver `json:"webhook,omitempty"`
+	//+kubebuilder:validation:Optional
+	// OpsGenie sends the alerts to OpsGenie.
+	OpsGenie *OpsGenieReceiver `json:"opsgenie,omitempty"`
+}
+
+// PagerDutyReceiver sends the alerts to PagerDuty. Exactly one of RoutingKey
+// and ServiceKey must be set.
+type PagerDutyReceiver struct {
+	//+kubebuilder:validation:Optional
+	// RoutingKey selects the Secret key holding the Events API v2
+	// integration key.
+	RoutingKey *corev1.SecretKeySelector `json:"routing_key,omitempty"`
+	//+kubebuilder:validation:Optional
+	// ServiceKey selects the Secret key holding the Prometheus integration
+	// service key.
+	ServiceKey *corev1.SecretKeySelector `json:"service_key,omitempty"`
+}
+
+// SlackReceiver sends the alerts to a Slack channel.
+type SlackReceiver struct {
+	// APIURL selects the Secret key holding the Slack webhook URL.
+	APIURL corev1.SecretKeySelector `json:"api_url"`
+	//+kubebuilder:validation:Optional
+	// Channel is the channel or user to send the alerts to. Defaults to the
+	// channel of the webhook.
+	Channel string `json:"channel,omitempty"`
+}
+
+// EmailReceiver sends the alerts by email.
+type EmailReceiver struct {
+	//+kubebuilder:validation:MinLength=1
+	// To is the address to send the alerts to.
+	To string `json:"to"`
+	//+kubebuilder:validation:MinLength=1
+	// From is the sender address.
+	From string `json:"from"`
+	//+kubebuilder:validation:MinLength=1
+	// Smarthost is the SMTP host and port to send the emails through, e.g.
+	// smtp.example.com:587.
+	Smarthost string `json:"smarthost"`
+	//+kubebuilder:validation:Optional
+	// AuthUsername is the username to authenticate with.
+	AuthUsername string `json:"auth_username,omitempty"`
+	//+kubebuilder:validation:Optional
+	// AuthPassword selects the Secret key holding the password to
+	// authenticate with.
+	AuthPassword *corev1.SecretKeySelector `json:"auth_password,omitempty"`
+}
+
+// WebhookReceiver posts the alerts to a generic webhook.
+type WebhookReceiver struct {
+	// URL selects the Secret key holding the webhook URL.
+	URL corev1.SecretKeySelector `json:"url"`
+}
+
+// OpsGenieReceiver sends the alerts to OpsGenie.
+type OpsGenieReceiver struct {
+	// APIKey selects the Secret key holding the OpsGenie API key.
+	APIKey corev1.SecretKeySelector `json:"api_key"`
+	//+kubebuilder:validation:Optional
+	// APIURL is the OpsGenie API URL, e.g. to use the EU instance.
+	APIURL string `json:"api_url,omitempty"`
+}
+
+// RouteSpec routes the alerts matching all of its matchers to a receiver.
+// A route without matchers routes all the alerts.
+type RouteSpec struct {
+	//+kubebuilder:validation:MinLength=1
+	// Receiver is the name of the declared receiver to route the alerts to.
+	Receiver string `json:"receiver"`
+	//+kubebuilder:validation:Optional
+	// AlertNames matches the alerts with any of the given names.
+	AlertNames []string `json:"alert_names,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Severities matches the alerts with any of the given severities, e.g.
+	// critical or warning.
+	Severities []string `json:"severities,omitempty"`
+	//+kubebuilder:validation:Optional
+	// GroupBy lists the labels the alerts are grouped by. Defaults to
+	// alertname.
+	GroupBy []string `json:"group_by,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// GroupWait is how long to wait before sending the first notification
+	// of a group. Defaults to 30s.
+	GroupWait string `json:"group_wait,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// GroupInterval is how long to wait before notifying about new alerts of
+	// a group. Defaults to 5m.
+	GroupInterval string `json:"group_interval,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern:="^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$"
+	// RepeatInterval is how long to wait before repeating a notification.
+	// Defaults to 12h.
+	RepeatInterval string `json:"repeat_interval,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Continue keeps matching the alerts against the next routes.
+	Continue bool `json:"continue,omitempty"`
+}
+
+// PagerDutySpec defines where the add-on alerts are routed to in PagerDuty.
+type PagerDutySpec struct {
+	//+kubebuilder:validation:Optional
+	// SecretName is the name of the Secret holding the PagerDuty routing key
+	// in its PAGERDUTY_KEY field. Defaults to the operator configuration.
+	SecretName string `json:"secret_name,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Alerts are the names of the alerts routed to PagerDuty. Defaults to the
+	// add-on health alerts.
+	Alerts []string `json:"alerts,omitempty"`
 }
 
 // MonitoringStatus defines the observed state of Monitoring
 type MonitoringStatus struct {
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions"`
+	// PrometheusSizing is the recommended sizing of the add-on Prometheus.
+	PrometheusSizing *PrometheusSizingStatus `json:"prometheus_sizing,omitempty"`
+	// RemoteWrite reports the health of the remote write endpoints.
+	RemoteWrite []RemoteWriteStatus `json:"remote_write,omitempty"`
+}
+
+// RemoteWriteStatus is the health of a remote write endpoint, as reported by
+// the add-on Prometheus.
+type RemoteWriteStatus struct {
+	// Name is the name of the endpoint.
+	Name string `json:"name"`
+	// SamplesPending is the number of samples waiting to be written.
+	SamplesPending int64 `json:"samples_pending"`
+	// SamplesFailed is the number of samples which failed to be written
+	// since Prometheus started.
+	SamplesFailed int64 `json:"samples_failed"`
+	// SamplesSent is the number of samples written since Prometheus started.
+	SamplesSent int64 `json:"samples_sent"`
+	// LastCheckTime is when the health was last checked.
+	LastCheckTime metav1.Time `json:"last_check_time"`
+}
+
+// PrometheusSizingStatus is the recommended sizing of the add-on Prometheus for
+// the number of GPU nodes it scrapes.
+type PrometheusSizingStatus struct {
+	// GPUNodes is the number of GPU nodes scraped.
+	GPUNodes int32 `json:"gpu_nodes"`
+	// RecommendedResources are the recommended compute resources of the
+	// Prometheus container.
+	RecommendedResources corev1.ResourceRequirements `json:"recommended_resources"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+// machineSetResyncInterval is how often the MachineSets are checked, as they
+// are not watched.
+const machineSetResyncInterval = 5 * time.Minute
+
+// AutoscalingReconciler prepares the GPU MachineSets for the cluster
+// autoscaler to scale them from zero, and sets its GPU resource limits, as
+// configured by the autoscaling section of the GPUAddon.
+type AutoscalingReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the Machine API and ClusterAutoscaler resources, which
+	// are neither in the namespace of the manager cache nor typed.
+	APIReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=machine.openshift.io,resources=machinesets,verbs=get;list;patch
+//+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list
+//+kubebuilder:rbac:groups=autoscaling.openshift.io,resources=clusterautoscalers,verbs=get;patch
+
+func (r *AutoscalingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// The Machine API is only served by OpenShift.
+	if !common.IsOpenShift() {
+		return ctrl.Result{}, nil
+	}
+
+	g := &addonv1alpha1.GPUAddon{}
+	if err := r.Get(ctx, req.NamespacedName, g); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not get GPUAddon CR: %w", err)
+	}
+
+	enabled := g.DeletionTimestamp.IsZero() && g.Spec.Autoscaling != nil
+
+	status := &addonv1alpha1.AutoscalingStatus{}
+	machineSets, err := r.reconcileMachineSets(ctx, enabled)
+	if err != nil {
+		if !meta.IsNoMatchError(err) {
+			return ctrl.Result{}, err
+		}
+		status.Message = "The Machine API is not available"
+	}
+	status.GPUMachineSets = machineSets
+
+	// The GPU limits set previously are removed once disabled.
+	var limits []addonv1alpha1.GPULimit
+	if enabled {
+		limits = g.Spec.Autoscaling.GPULimits
+	}
+
+	message, err := r.reconcileGPULimits(ctx, limits)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !enabled {
+		return ctrl.Result{}, r.patchStatus(ctx, g, nil)
+	}
+
+	if len(limits) > 0 {
+		status.GPULimitsApplied = message == ""
+		if message != "" {
+			status.Message = message
+		}
+	}
+
+	if err := r.patchStatus(ctx, g, status); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: machineSetResyncInterval}, nil
+}
+
+func (r *AutoscalingReconciler) patchStatus(
+	ctx context.Context,
+	g *addonv1alpha1.GPUAddon,
+	status *addonv1alpha1.AutoscalingStatus) error {
+
+	if equality.Semantic.DeepEqual(g.Status.Autoscaling, status) {
+		return nil
+	}
+
+	patch := client.MergeFrom(g.DeepCopy())
+	g.Status.Autoscaling = status
+	if err := r.Status().Patch(ctx, g, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AutoscalingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toGPUAddon := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("autoscaling").
+		// The status updates do not trigger a new reconciliation.
+		For(&addonv1alpha1.GPUAddon{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// The GPU MachineSets are detected from the labels of their nodes,
+		// once labeled by NFD and the GPU feature discovery.
+		Watches(&source.Kind{Type: &corev1.Node{}}, toGPUAddon,
+			builder.WithPredicates(common.GPUNodeLabelsChanged)).
+		Complete(health.Reconciles.Track("autoscaling", r))
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("Autoscaling Reconcile", func() {
+	common.ProcessConfig()
+
+	newGPUAddon := func(autoscaling *addonv1alpha1.AutoscalingSpec) *addonv1alpha1.GPUAddon {
+		return &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      common.GlobalConfig.AddonID,
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUAddonSpec{
+				Autoscaling: autoscaling,
+			},
+		}
+	}
+
+	reconcile := func(r *AutoscalingReconciler, g *addonv1alpha1.GPUAddon) ctrl.Result {
+		res, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(g)})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(g), g)).ShouldNot(HaveOccurred())
+		return res
+	}
+
+	getMachineSet := func(r *AutoscalingReconciler, name string) *unstructured.Unstructured {
+		ms := &unstructured.Unstructured{}
+		ms.SetGroupVersionKind(machineSetListGVK.GroupVersion().WithKind("MachineSet"))
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: machineAPINamespace}, ms)).
+			ShouldNot(HaveOccurred())
+		return ms
+	}
+
+	acceleratorOf := func(ms *unstructured.Unstructured) string {
+		label, _, _ := unstructured.NestedString(ms.Object,
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel)
+		return label
+	}
+
+	It("should set the capacity of the MachineSets with GPU nodes", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		r := newTestAutoscalingReconciler(g,
+			newMachineSet("gpu-workers", nil),
+			newMachineSet("workers", nil),
+			newMachine("gpu-workers-abcde", "gpu-workers"),
+			newMachine("workers-fghij", "workers"),
+			newNode("gpu-node", "gpu-workers-abcde", 4, "NVIDIA-A100-SXM4-40GB"),
+			newNode("cpu-node", "workers-fghij", 0, ""))
+
+		res := reconcile(r, g)
+		Expect(res.RequeueAfter).To(Equal(machineSetResyncInterval))
+
+		ms := getMachineSet(r, "gpu-workers")
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(machineGPUAnnotation, "4"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(capacityGPUCountAnnotation, "4"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(capacityGPUTypeAnnotation, "nvidia.com/gpu"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(ScaleFromZeroAnnotation(), "true"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(OwnedCapacityAnnotation(),
+			"capacity.cluster-autoscaler.kubernetes.io/gpu-count,capacity.cluster-autoscaler.kubernetes.io/gpu-type,"+
+				"cluster-api/accelerator,machine.openshift.io/GPU"))
+		Expect(acceleratorOf(ms)).To(Equal("NVIDIA-A100-SXM4-40GB"))
+
+		Expect(getMachineSet(r, "workers").GetAnnotations()).To(BeEmpty())
+
+		Expect(g.Status.Autoscaling).ToNot(BeNil())
+		Expect(g.Status.Autoscaling.GPUMachineSets).To(ConsistOf(addonv1alpha1.GPUMachineSet{
+			Name:    "gpu-workers",
+			GPUs:    4,
+			GPUType: "NVIDIA-A100-SXM4-40GB",
+		}))
+	})
+
+	It("should not overwrite the capacity set otherwise", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		ms := newMachineSet("gpu-workers", map[string]string{machineGPUAnnotation: "4"})
+		Expect(unstructured.SetNestedField(ms.Object, "custom",
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel)).To(Succeed())
+		r := newTestAutoscalingReconciler(g, ms,
+			newMachine("gpu-workers-abcde", "gpu-workers"),
+			newNode("gpu-node", "gpu-workers-abcde", 4, "NVIDIA-A100-SXM4-40GB"))
+
+		reconcile(r, g)
+
+		ms = getMachineSet(r, "gpu-workers")
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(machineGPUAnnotation, "4"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(capacityGPUCountAnnotation, "4"))
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(OwnedCapacityAnnotation(),
+			"capacity.cluster-autoscaler.kubernetes.io/gpu-count,capacity.cluster-autoscaler.kubernetes.io/gpu-type"))
+		Expect(acceleratorOf(ms)).To(Equal("custom"))
+	})
+
+	It("should prefer the stable GPU type of the GPU nodes", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		node := newNode("gpu-node", "gpu-workers-abcde", 4, "NVIDIA-A100-SXM4-40GB")
+		node.Labels[common.GPUTypeLabel()] = "a100-40gb"
+		r := newTestAutoscalingReconciler(g,
+			newMachineSet("gpu-workers", nil),
+			newMachine("gpu-workers-abcde", "gpu-workers"),
+			node)
+
+		reconcile(r, g)
+
+		Expect(acceleratorOf(getMachineSet(r, "gpu-workers"))).To(Equal("a100-40gb"))
+		Expect(g.Status.Autoscaling.GPUMachineSets).To(ConsistOf(addonv1alpha1.GPUMachineSet{
+			Name:    "gpu-workers",
+			GPUs:    4,
+			GPUType: "a100-40gb",
+		}))
+	})
+
+	It("should keep the capacity of the MachineSets scaled down to zero", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		ms := newMachineSet("gpu-workers", map[string]string{
+			ScaleFromZeroAnnotation():  "true",
+			OwnedCapacityAnnotation():  "capacity.cluster-autoscaler.kubernetes.io/gpu-count,cluster-api/accelerator",
+			machineGPUAnnotation:       "2",
+			capacityGPUCountAnnotation: "2",
+			capacityGPUTypeAnnotation:  "nvidia.com/gpu",
+		})
+		Expect(unstructured.SetNestedField(ms.Object, "Tesla-T4",
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel)).To(Succeed())
+		r := newTestAutoscalingReconciler(g, ms)
+
+		reconcile(r, g)
+
+		Expect(getMachineSet(r, "gpu-workers").GetAnnotations()).To(HaveKeyWithValue(capacityGPUCountAnnotation, "2"))
+		Expect(g.Status.Autoscaling.GPUMachineSets).To(ConsistOf(addonv1alpha1.GPUMachineSet{
+			Name:    "gpu-workers",
+			GPUs:    2,
+			GPUType: "Tesla-T4",
+		}))
+	})
+
+	It("should set the capacity of the annotated MachineSets", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		r := newTestAutoscalingReconciler(g,
+			newMachineSet("gpu-workers", map[string]string{
+				GPUCountAnnotation(): "8",
+				GPUTypeAnnotation():  "NVIDIA-H100-80GB-HBM3",
+			}),
+			newMachineSet("invalid", map[string]string{GPUCountAnnotation(): "many"}))
+
+		reconcile(r, g)
+
+		ms := getMachineSet(r, "gpu-workers")
+		Expect(ms.GetAnnotations()).To(HaveKeyWithValue(machineGPUAnnotation, "8"))
+		Expect(acceleratorOf(ms)).To(Equal("NVIDIA-H100-80GB-HBM3"))
+
+		Expect(getMachineSet(r, "invalid").GetAnnotations()).ToNot(HaveKey(machineGPUAnnotation))
+	})
+
+	It("should remove the capacity it set once disabled", func() {
+		g := newGPUAddon(nil)
+		g.Status.Autoscaling = &addonv1alpha1.AutoscalingStatus{}
+		// The machine.openshift.io/GPU annotation is set by the Machine API
+		// provider.
+		managed := newMachineSet("managed", map[string]string{
+			ScaleFromZeroAnnotation(): "true",
+			OwnedCapacityAnnotation(): "capacity.cluster-autoscaler.kubernetes.io/gpu-count," +
+				"capacity.cluster-autoscaler.kubernetes.io/gpu-type,cluster-api/accelerator",
+			machineGPUAnnotation:       "2",
+			capacityGPUCountAnnotation: "2",
+			capacityGPUTypeAnnotation:  "nvidia.com/gpu",
+		})
+		Expect(unstructured.SetNestedField(managed.Object, "Tesla-T4",
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel)).To(Succeed())
+		r := newTestAutoscalingReconciler(g, managed,
+			newMachineSet("unmanaged", map[string]string{machineGPUAnnotation: "1"}))
+
+		res := reconcile(r, g)
+		Expect(res.RequeueAfter).To(BeZero())
+
+		ms := getMachineSet(r, "managed")
+		Expect(ms.GetAnnotations()).To(Equal(map[string]string{machineGPUAnnotation: "2"}))
+		Expect(acceleratorOf(ms)).To(BeEmpty())
+
+		Expect(getMachineSet(r, "unmanaged").GetAnnotations()).To(HaveKeyWithValue(machineGPUAnnotation, "1"))
+		Expect(g.Status.Autoscaling).To(BeNil())
+	})
+
+	It("should set the GPU limits of the ClusterAutoscaler", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{
+			GPULimits: []addonv1alpha1.GPULimit{{Type: "Tesla-T4", Min: 0, Max: 16}},
+		})
+		ca := &unstructured.Unstructured{}
+		ca.SetGroupVersionKind(clusterAutoscalerGVK)
+		ca.SetName(clusterAutoscalerName)
+		Expect(unstructured.SetNestedField(ca.Object, int64(100), "spec", "resourceLimits", "maxNodesTotal")).To(Succeed())
+		r := newTestAutoscalingReconciler(g, ca)
+
+		reconcile(r, g)
+
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: clusterAutoscalerName}, ca)).ShouldNot(HaveOccurred())
+		gpus, _, _ := unstructured.NestedSlice(ca.Object, "spec", "resourceLimits", "gpus")
+		Expect(gpus).To(ConsistOf(map[string]interface{}{"type": "Tesla-T4", "min": int64(0), "max": int64(16)}))
+		maxNodes, _, _ := unstructured.NestedInt64(ca.Object, "spec", "resourceLimits", "maxNodesTotal")
+		Expect(maxNodes).To(Equal(int64(100)))
+
+		Expect(g.Status.Autoscaling.GPULimitsApplied).To(BeTrue())
+	})
+
+	It("should only update the GPU limits it set", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{
+			GPULimits: []addonv1alpha1.GPULimit{
+				{Type: "Tesla-T4", Min: 0, Max: 16},
+				{Type: "a100-40gb", Min: 0, Max: 8},
+			},
+		})
+		ca := newClusterAutoscaler(map[string]string{GPULimitsAnnotation(): "Tesla-T4,l4"},
+			map[string]interface{}{"type": "Tesla-T4", "min": int64(0), "max": int64(4)},
+			map[string]interface{}{"type": "l4", "min": int64(0), "max": int64(4)},
+			map[string]interface{}{"type": "a100-40gb", "min": int64(1), "max": int64(2)},
+			map[string]interface{}{"type": "h100", "min": int64(0), "max": int64(2)})
+		r := newTestAutoscalingReconciler(g, ca)
+
+		reconcile(r, g)
+
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: clusterAutoscalerName}, ca)).ShouldNot(HaveOccurred())
+		gpus, _, _ := unstructured.NestedSlice(ca.Object, "spec", "resourceLimits", "gpus")
+		Expect(gpus).To(ConsistOf(
+			map[string]interface{}{"type": "Tesla-T4", "min": int64(0), "max": int64(16)},
+			map[string]interface{}{"type": "a100-40gb", "min": int64(1), "max": int64(2)},
+			map[string]interface{}{"type": "h100", "min": int64(0), "max": int64(2)}))
+		Expect(ca.GetAnnotations()).To(HaveKeyWithValue(GPULimitsAnnotation(), "Tesla-T4"))
+
+		Expect(g.Status.Autoscaling.GPULimitsApplied).To(BeFalse())
+		Expect(g.Status.Autoscaling.Message).To(ContainSubstring("a100-40gb"))
+	})
+
+	It("should remove the GPU limits it set once disabled", func() {
+		g := newGPUAddon(nil)
+		ca := newClusterAutoscaler(map[string]string{GPULimitsAnnotation(): "Tesla-T4"},
+			map[string]interface{}{"type": "Tesla-T4", "min": int64(0), "max": int64(16)},
+			map[string]interface{}{"type": "h100", "min": int64(0), "max": int64(2)})
+		r := newTestAutoscalingReconciler(g, ca)
+
+		reconcile(r, g)
+
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: clusterAutoscalerName}, ca)).ShouldNot(HaveOccurred())
+		gpus, _, _ := unstructured.NestedSlice(ca.Object, "spec", "resourceLimits", "gpus")
+		Expect(gpus).To(ConsistOf(map[string]interface{}{"type": "h100", "min": int64(0), "max": int64(2)}))
+		Expect(ca.GetAnnotations()).ToNot(HaveKey(GPULimitsAnnotation()))
+	})
+
+	It("should remove the GPU limits it set once not given anymore", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{})
+		ca := newClusterAutoscaler(map[string]string{GPULimitsAnnotation(): "Tesla-T4"},
+			map[string]interface{}{"type": "Tesla-T4", "min": int64(0), "max": int64(16)})
+		r := newTestAutoscalingReconciler(g, ca)
+
+		reconcile(r, g)
+
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: clusterAutoscalerName}, ca)).ShouldNot(HaveOccurred())
+		_, found, _ := unstructured.NestedSlice(ca.Object, "spec", "resourceLimits", "gpus")
+		Expect(found).To(BeFalse())
+		Expect(ca.GetAnnotations()).ToNot(HaveKey(GPULimitsAnnotation()))
+	})
+
+	It("should report the missing ClusterAutoscaler", func() {
+		g := newGPUAddon(&addonv1alpha1.AutoscalingSpec{
+			GPULimits: []addonv1alpha1.GPULimit{{Type: "Tesla-T4", Min: 0, Max: 16}},
+		})
+		r := newTestAutoscalingReconciler(g)
+
+		reconcile(r, g)
+
+		Expect(g.Status.Autoscaling.GPULimitsApplied).To(BeFalse())
+		Expect(g.Status.Autoscaling.Message).To(ContainSubstring("the ClusterAutoscaler does not exist"))
+	})
+})
+
+func newMachineSet(name string, annotations map[string]string) *unstructured.Unstructured {
+	ms := &unstructured.Unstructured{}
+	ms.SetGroupVersionKind(machineSetListGVK.GroupVersion().WithKind("MachineSet"))
+	ms.SetName(name)
+	ms.SetNamespace(machineAPINamespace)
+	ms.SetAnnotations(annotations)
+	Expect(unstructured.SetNestedField(ms.Object, int64(0), "spec", "replicas")).To(Succeed())
+	return ms
+}
+
+func newClusterAutoscaler(annotations map[string]string, gpus ...interface{}) *unstructured.Unstructured {
+	ca := &unstructured.Unstructured{}
+	ca.SetGroupVersionKind(clusterAutoscalerGVK)
+	ca.SetName(clusterAutoscalerName)
+	ca.SetAnnotations(annotations)
+	Expect(unstructured.SetNestedSlice(ca.Object, gpus, "spec", "resourceLimits", "gpus")).To(Succeed())
+	return ca
+}
+
+func newMachine(name, machineSet string) *unstructured.Unstructured {
+	m := &unstructured.Unstructured{}
+	m.SetGroupVersionKind(machineListGVK.GroupVersion().WithKind("Machine"))
+	m.SetName(name)
+	m.SetNamespace(machineAPINamespace)
+	m.SetLabels(map[string]string{machineSetLabel: machineSet})
+	return m
+}
+
+func newNode(name, machine string, gpus int64, product string) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{machineAnnotation: machineAPINamespace + "/" + machine},
+		},
+	}
+	if gpus > 0 {
+		node.Labels = map[string]string{
+			common.GPUNodeLabel:    "true",
+			common.GPUProductLabel: product,
+		}
+		node.Status.Capacity = corev1.ResourceList{
+			common.GPUResourceName: *resource.NewQuantity(gpus, resource.DecimalSI),
+		}
+	}
+	return node
+}
+
+func newTestAutoscalingReconciler(objs ...runtime.Object) *AutoscalingReconciler {
+	s := scheme.Scheme
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &AutoscalingReconciler{
+		Client:    c,
+		Scheme:    s,
+		APIReader: c,
+	}
+}
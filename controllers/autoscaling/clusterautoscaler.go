@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// clusterAutoscalerName is the name of the ClusterAutoscaler, as the cluster
+// autoscaler operator only deploys the one named default.
+const clusterAutoscalerName = "default"
+
+// The ClusterAutoscaler types of the cluster autoscaler operator are not
+// vendored.
+var clusterAutoscalerGVK = schema.GroupVersionKind{
+	Group:   "autoscaling.openshift.io",
+	Version: "v1",
+	Kind:    "ClusterAutoscaler",
+}
+
+// GPULimitsAnnotation is the ClusterAutoscaler annotation which records the
+// GPU types whose resource limits are set by the add-on, as a comma separated
+// list. The limits of the other GPU types are left alone.
+func GPULimitsAnnotation() string {
+	return fmt.Sprintf("%s/gpu-limits", common.GlobalConfig.AddonID)
+}
+
+// reconcileGPULimits sets the given GPU resource limits on the
+// ClusterAutoscaler, and removes the ones it previously set which are not
+// given anymore. The limits of the GPU types it did not set are kept. The
+// ClusterAutoscaler is not created, as it enables the autoscaling of the
+// whole cluster. It returns why the limits could not be set, if so.
+func (r *AutoscalingReconciler) reconcileGPULimits(ctx context.Context, limits []addonv1alpha1.GPULimit) (string, error) {
+	ca := &unstructured.Unstructured{}
+	ca.SetGroupVersionKind(clusterAutoscalerGVK)
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: clusterAutoscalerName}, ca); err != nil {
+		if meta.IsNoMatchError(err) || k8serrors.IsNotFound(err) {
+			return "The GPU limits are not set as the ClusterAutoscaler does not exist", nil
+		}
+		return "", fmt.Errorf("failed to get ClusterAutoscaler: %w", err)
+	}
+
+	owned := map[string]bool{}
+	for _, t := range strings.Split(ca.GetAnnotations()[GPULimitsAnnotation()], ",") {
+		if t != "" {
+			owned[t] = true
+		}
+	}
+
+	desired := map[string]bool{}
+	for _, l := range limits {
+		desired[l.Type] = true
+	}
+
+	// The limits set by the add-on are replaced, the others are kept, even
+	// when their GPU type is given.
+	gpus := []interface{}{}
+	kept := map[string]bool{}
+	current, _, _ := unstructured.NestedSlice(ca.Object, "spec", "resourceLimits", "gpus")
+	for _, c := range current {
+		limit, _ := c.(map[string]interface{})
+		gpuType, _, _ := unstructured.NestedString(limit, "type")
+		if owned[gpuType] {
+			continue
+		}
+		gpus = append(gpus, c)
+		if desired[gpuType] {
+			kept[gpuType] = true
+		}
+	}
+
+	ownedTypes := []string{}
+	skipped := []string{}
+	for _, l := range limits {
+		if kept[l.Type] {
+			skipped = append(skipped, l.Type)
+			continue
+		}
+		gpus = append(gpus, map[string]interface{}{
+			"type": l.Type,
+			"min":  int64(l.Min),
+			"max":  int64(l.Max),
+		})
+		ownedTypes = append(ownedTypes, l.Type)
+	}
+
+	original := ca.DeepCopy()
+
+	annotations := ca.GetAnnotations()
+	if len(ownedTypes) > 0 {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[GPULimitsAnnotation()] = strings.Join(ownedTypes, ",")
+	} else {
+		delete(annotations, GPULimitsAnnotation())
+	}
+	ca.SetAnnotations(annotations)
+
+	if len(gpus) > 0 {
+		if err := unstructured.SetNestedSlice(ca.Object, gpus, "spec", "resourceLimits", "gpus"); err != nil {
+			return "", err
+		}
+	} else {
+		unstructured.RemoveNestedField(ca.Object, "spec", "resourceLimits", "gpus")
+	}
+
+	message := ""
+	if len(skipped) > 0 {
+		message = fmt.Sprintf("The GPU limits of %s are left as set on the ClusterAutoscaler", strings.Join(skipped, ", "))
+	}
+
+	if equality.Semantic.DeepEqual(original, ca) {
+		return message, nil
+	}
+
+	if err := r.Patch(ctx, ca, client.MergeFrom(original)); err != nil {
+		return "", fmt.Errorf("failed to patch ClusterAutoscaler: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Set the GPU limits of the ClusterAutoscaler", "limits", len(ownedTypes))
+
+	return message, nil
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// machineAPINamespace is the namespace of the MachineSets and Machines.
+const machineAPINamespace = "openshift-machine-api"
+
+const (
+	// The annotations of the MachineSets the cluster autoscaler reads the
+	// capacity of their machines from when scaling them from zero.
+	machineGPUAnnotation       = "machine.openshift.io/GPU"
+	capacityGPUCountAnnotation = "capacity.cluster-autoscaler.kubernetes.io/gpu-count"
+	capacityGPUTypeAnnotation  = "capacity.cluster-autoscaler.kubernetes.io/gpu-type"
+
+	// acceleratorLabel is the node label the cluster autoscaler identifies
+	// the GPU type of the nodes with, as in its GPU resource limits.
+	acceleratorLabel = "cluster-api/accelerator"
+
+	// machineAnnotation is the node annotation referencing its Machine, as
+	// <namespace>/<name>.
+	machineAnnotation = "machine.openshift.io/machine"
+	// machineSetLabel is the Machine label referencing its MachineSet.
+	machineSetLabel = "machine.openshift.io/cluster-api-machineset"
+)
+
+// The Machine API is only served by OpenShift, and its types are not vendored.
+var (
+	machineSetListGVK = schema.GroupVersionKind{
+		Group:   "machine.openshift.io",
+		Version: "v1beta1",
+		Kind:    "MachineSetList",
+	}
+	machineListGVK = schema.GroupVersionKind{
+		Group:   "machine.openshift.io",
+		Version: "v1beta1",
+		Kind:    "MachineList",
+	}
+)
+
+// GPUCountAnnotation is the MachineSet annotation which declares the number
+// of GPUs of its machines, e.g. when it has never had any node.
+func GPUCountAnnotation() string {
+	return fmt.Sprintf("%s/gpu-count", common.GlobalConfig.AddonID)
+}
+
+// GPUTypeAnnotation is the MachineSet annotation which declares the GPU type
+// of its machines.
+func GPUTypeAnnotation() string {
+	return fmt.Sprintf("%s/gpu-type", common.GlobalConfig.AddonID)
+}
+
+// ScaleFromZeroAnnotation is the annotation of the MachineSets whose capacity
+// annotations are managed by the add-on.
+func ScaleFromZeroAnnotation() string {
+	return fmt.Sprintf("%s/scale-from-zero", common.GlobalConfig.AddonID)
+}
+
+// OwnedCapacityAnnotation is the MachineSet annotation which records the
+// capacity annotations and the GPU type label set by the add-on, as a comma
+// separated list of their keys. The ones set otherwise, e.g. by the cluster
+// admins or the Machine API provider, are left alone.
+func OwnedCapacityAnnotation() string {
+	return fmt.Sprintf("%s/owned-capacity", common.GlobalConfig.AddonID)
+}
+
+// detectGPUMachineSets returns the GPU MachineSets detected from their GPU
+// nodes, by name. The number of GPUs is the one allocatable by the GPU
+// operator, and the GPU type is the stable one labeled in gpu-type, or else
+// the product labeled by the GPU feature discovery.
+func (r *AutoscalingReconciler) detectGPUMachineSets(ctx context.Context) (map[string]addonv1alpha1.GPUMachineSet, error) {
+	machines := &unstructured.UnstructuredList{}
+	machines.SetGroupVersionKind(machineListGVK)
+	if err := r.APIReader.List(ctx, machines, client.InNamespace(machineAPINamespace)); err != nil {
+		return nil, fmt.Errorf("failed to list Machines: %w", err)
+	}
+
+	machineSets := map[string]string{}
+	for _, m := range machines.Items {
+		if ms := m.GetLabels()[machineSetLabel]; ms != "" {
+			machineSets[m.GetName()] = ms
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{common.GPUNodeLabel: "true"}); err != nil {
+		return nil, fmt.Errorf("failed to list the GPU nodes: %w", err)
+	}
+
+	detected := map[string]addonv1alpha1.GPUMachineSet{}
+	for _, node := range nodes.Items {
+		machine := strings.TrimPrefix(node.Annotations[machineAnnotation], machineAPINamespace+"/")
+		name, ok := machineSets[machine]
+		if !ok {
+			continue
+		}
+
+		// The GPUs are only allocatable once the GPU operator is ready on
+		// the node.
+		gpus := node.Status.Capacity.Name(common.GPUResourceName, "").Value()
+		if gpus == 0 {
+			continue
+		}
+
+		gpuType := node.Labels[common.GPUTypeLabel()]
+		if gpuType == "" {
+			gpuType = node.Labels[common.GPUProductLabel]
+		}
+
+		if ms, ok := detected[name]; !ok || int32(gpus) > ms.GPUs {
+			detected[name] = addonv1alpha1.GPUMachineSet{
+				Name:    name,
+				GPUs:    int32(gpus),
+				GPUType: gpuType,
+			}
+		}
+	}
+
+	return detected, nil
+}
+
+// reconcileMachineSets sets the capacity annotations on the GPU MachineSets
+// if enabled, or removes the ones it set otherwise. It returns the GPU
+// MachineSets, sorted by name, or a no match error if the Machine API is not
+// served.
+func (r *AutoscalingReconciler) reconcileMachineSets(ctx context.Context, enabled bool) ([]addonv1alpha1.GPUMachineSet, error) {
+	machineSets := &unstructured.UnstructuredList{}
+	machineSets.SetGroupVersionKind(machineSetListGVK)
+	if err := r.APIReader.List(ctx, machineSets, client.InNamespace(machineAPINamespace)); err != nil {
+		// The Machine API is not served, e.g. on the clusters with user
+		// provisioned infrastructure.
+		if meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list MachineSets: %w", err)
+	}
+
+	detected := map[string]addonv1alpha1.GPUMachineSet{}
+	if enabled {
+		var err error
+		if detected, err = r.detectGPUMachineSets(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	gpuMachineSets := []addonv1alpha1.GPUMachineSet{}
+	for i := range machineSets.Items {
+		ms := &machineSets.Items[i]
+		original := ms.DeepCopy()
+
+		gpuMachineSet, ok := declaredGPUMachineSet(ctx, ms, detected[ms.GetName()])
+		if enabled && ok {
+			if err := setCapacity(ms, gpuMachineSet); err != nil {
+				return nil, err
+			}
+			gpuMachineSets = append(gpuMachineSets, gpuMachineSet)
+		} else if ms.GetAnnotations()[ScaleFromZeroAnnotation()] == "true" {
+			unsetCapacity(ms)
+		}
+
+		if equality.Semantic.DeepEqual(original, ms) {
+			continue
+		}
+
+		if err := r.Patch(ctx, ms, client.MergeFrom(original)); err != nil {
+			return nil, fmt.Errorf("failed to patch MachineSet %s: %w", ms.GetName(), err)
+		}
+
+		log.FromContext(ctx).Info("Reconciled MachineSet capacity", "machineset", ms.GetName(), "enabled", enabled && ok)
+	}
+
+	sort.Slice(gpuMachineSets, func(i, j int) bool {
+		return gpuMachineSets[i].Name < gpuMachineSets[j].Name
+	})
+
+	return gpuMachineSets, nil
+}
+
+// declaredGPUMachineSet returns the GPU capacity of the given MachineSet, as
+// declared by its annotations, or else as detected from its nodes, or else
+// as previously set. It returns false if the MachineSet has no GPUs.
+func declaredGPUMachineSet(
+	ctx context.Context,
+	ms *unstructured.Unstructured,
+	detected addonv1alpha1.GPUMachineSet) (addonv1alpha1.GPUMachineSet, bool) {
+
+	annotations := ms.GetAnnotations()
+	gpuMachineSet := addonv1alpha1.GPUMachineSet{
+		Name:    ms.GetName(),
+		GPUs:    detected.GPUs,
+		GPUType: detected.GPUType,
+	}
+
+	// The capacity previously set is kept once the MachineSet is scaled
+	// down to zero, as its nodes are gone.
+	if gpuMachineSet.GPUs == 0 && annotations[ScaleFromZeroAnnotation()] == "true" {
+		if gpus, err := strconv.ParseInt(annotations[capacityGPUCountAnnotation], 10, 32); err == nil {
+			gpuMachineSet.GPUs = int32(gpus)
+		}
+		gpuMachineSet.GPUType, _, _ = unstructured.NestedString(ms.Object,
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel)
+	}
+
+	if count, ok := annotations[GPUCountAnnotation()]; ok {
+		gpus, err := strconv.ParseInt(count, 10, 32)
+		if err != nil || gpus < 0 {
+			log.FromContext(ctx).Info("Ignoring invalid GPU count annotation", "machineset", ms.GetName(), "value", count)
+		} else {
+			gpuMachineSet.GPUs = int32(gpus)
+		}
+	}
+
+	if gpuType, ok := annotations[GPUTypeAnnotation()]; ok {
+		gpuMachineSet.GPUType = gpuType
+	}
+
+	return gpuMachineSet, gpuMachineSet.GPUs > 0
+}
+
+// setCapacity sets the capacity annotations of the given GPU MachineSet, and
+// the GPU type label of its nodes, unless they were set otherwise.
+func setCapacity(ms *unstructured.Unstructured, gpuMachineSet addonv1alpha1.GPUMachineSet) error {
+	annotations := ms.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	owned := ownedCapacity(ms)
+
+	for _, a := range []struct{ key, value string }{
+		{machineGPUAnnotation, strconv.Itoa(int(gpuMachineSet.GPUs))},
+		{capacityGPUCountAnnotation, strconv.Itoa(int(gpuMachineSet.GPUs))},
+		{capacityGPUTypeAnnotation, string(common.GPUResourceName)},
+	} {
+		if _, ok := annotations[a.key]; ok && !owned[a.key] {
+			continue
+		}
+		annotations[a.key] = a.value
+		owned[a.key] = true
+	}
+
+	// Only the nodes of the new machines get the label.
+	_, labeled, _ := unstructured.NestedString(ms.Object,
+		"spec", "template", "spec", "metadata", "labels", acceleratorLabel)
+	if gpuMachineSet.GPUType != "" && (!labeled || owned[acceleratorLabel]) {
+		if err := unstructured.SetNestedField(ms.Object, gpuMachineSet.GPUType,
+			"spec", "template", "spec", "metadata", "labels", acceleratorLabel); err != nil {
+			return err
+		}
+		owned[acceleratorLabel] = true
+	}
+
+	keys := make([]string, 0, len(owned))
+	for k := range owned {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	annotations[ScaleFromZeroAnnotation()] = "true"
+	annotations[OwnedCapacityAnnotation()] = strings.Join(keys, ",")
+	ms.SetAnnotations(annotations)
+
+	return nil
+}
+
+// unsetCapacity removes the capacity annotations and the GPU type label set
+// by the add-on on the given MachineSet.
+func unsetCapacity(ms *unstructured.Unstructured) {
+	owned := ownedCapacity(ms)
+
+	annotations := ms.GetAnnotations()
+	for k := range owned {
+		delete(annotations, k)
+	}
+	delete(annotations, ScaleFromZeroAnnotation())
+	delete(annotations, OwnedCapacityAnnotation())
+	ms.SetAnnotations(annotations)
+
+	if owned[acceleratorLabel] {
+		unstructured.RemoveNestedField(ms.Object, "spec", "template", "spec", "metadata", "labels", acceleratorLabel)
+	}
+}
+
+// ownedCapacity returns the keys of the capacity annotations and of the GPU
+// type label set by the add-on on the given MachineSet.
+func ownedCapacity(ms *unstructured.Unstructured) map[string]bool {
+	owned := map[string]bool{}
+	for _, k := range strings.Split(ms.GetAnnotations()[OwnedCapacityAnnotation()], ",") {
+		if k != "" {
+			owned[k] = true
+		}
+	}
+	return owned
+}
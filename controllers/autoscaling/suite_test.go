@@ -1,4 +1,4 @@
-package configmap
+package autoscaling
 
 import (
 	"testing"
@@ -9,5 +9,5 @@ import (
 
 func TestSuite(t *testing.T) {
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "ConfigMap Controller Suite")
+	RunSpecs(t, "Autoscaling Suite")
 }
@@ -0,0 +1,315 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/version"
+)
+
+const ParametersValidCondition = "ParametersValid"
+
+// BootstrapReconciler makes sure the GPUAddon and Monitoring CRs of the
+// add-on exist while the add-on is installed. Their specs are derived from
+// the managed add-on parameters Secret, whose changes are propagated to them.
+type BootstrapReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons/status;monitorings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch
+
+// Reconcile creates the GPUAddon and Monitoring CRs if they don't exist and
+// applies the changed add-on parameters to them if they do.
+func (r *BootstrapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	uninstalling, err := common.IsUninstallRequested(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if uninstalling {
+		logger.Info("Add-on is being uninstalled, skipping bootstrap")
+		return ctrl.Result{}, nil
+	}
+
+	params, err := r.getParameters(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileGPUAddon(ctx, params); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileMonitoring(ctx, params); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BootstrapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toBootstrap := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{bootstrapRequest()}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("bootstrap").
+		For(&addonv1alpha1.GPUAddon{}, builder.WithPredicates(isAddonResource(common.GlobalConfig.AddonID))).
+		Watches(&source.Kind{Type: &addonv1alpha1.Monitoring{}}, toBootstrap,
+			builder.WithPredicates(isAddonResource(common.GlobalConfig.AddonID))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, toBootstrap,
+			builder.WithPredicates(isAddonResource(ParametersSecretName()))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, toBootstrap,
+			builder.WithPredicates(isAddonResource(common.GlobalConfig.AddonID))).
+		// There may be nothing to watch yet on a fresh install, thus the
+		// bootstrap is always triggered once on start.
+		Watches(source.Func(func(
+			ctx context.Context,
+			_ handler.EventHandler,
+			q workqueue.RateLimitingInterface,
+			_ ...predicate.Predicate) error {
+
+			q.Add(bootstrapRequest())
+			return nil
+		}), &handler.Funcs{}).
+		Complete(health.Reconciles.Track("bootstrap", r))
+}
+
+func (r *BootstrapReconciler) getParameters(ctx context.Context) (Parameters, error) {
+	s := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{
+		Name:      ParametersSecretName(),
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}, s)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return Parameters{}, nil
+		}
+		return nil, fmt.Errorf("failed to get add-on parameters Secret %s: %w", ParametersSecretName(), err)
+	}
+
+	return NewParameters(s.Data), nil
+}
+
+func (r *BootstrapReconciler) reconcileGPUAddon(ctx context.Context, params Parameters) error {
+	logger := log.FromContext(ctx, "Reconcile Step", "GPUAddon CR")
+
+	valid, invalid := params.GPUAddon().Validate()
+
+	gpuAddon := &addonv1alpha1.GPUAddon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, gpuAddon, func() error {
+		if !gpuAddon.DeletionTimestamp.IsZero() {
+			return nil
+		}
+
+		setVersionLabel(&gpuAddon.ObjectMeta)
+
+		applied := Parameters{}
+		if gpuAddon.ResourceVersion == "" {
+			gpuAddon.Spec = defaultGPUAddonSpec()
+		} else if v, ok := gpuAddon.Annotations[appliedParametersAnnotation()]; ok {
+			decoded, err := DecodeParameters(v)
+			if err != nil {
+				return err
+			}
+			applied = decoded
+		} else {
+			applied = valid.AppliedToGPUAddon(&gpuAddon.Spec)
+		}
+
+		valid.ApplyToGPUAddon(&gpuAddon.Spec, applied)
+
+		return setAppliedParameters(&gpuAddon.ObjectMeta, valid)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile GPUAddon CR %s in %s: %w", gpuAddon.Name, gpuAddon.Namespace, err)
+	}
+
+	logger.Info("GPUAddon reconciled successfully",
+		"name", gpuAddon.Name,
+		"namespace", gpuAddon.Namespace,
+		"result", res)
+
+	patch := client.MergeFrom(gpuAddon.DeepCopy())
+	meta.SetStatusCondition(&gpuAddon.Status.Conditions, getParametersValidCondition(invalid))
+
+	if err := r.Status().Patch(ctx, gpuAddon, patch); err != nil {
+		return fmt.Errorf("failed to patch GPUAddon status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *BootstrapReconciler) reconcileMonitoring(ctx context.Context, params Parameters) error {
+	logger := log.FromContext(ctx, "Reconcile Step", "Monitoring CR")
+
+	valid, invalid := params.Monitoring().Validate()
+
+	m := &addonv1alpha1.Monitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, m, func() error {
+		if !m.DeletionTimestamp.IsZero() {
+			return nil
+		}
+
+		setVersionLabel(&m.ObjectMeta)
+
+		applied := Parameters{}
+		if m.ResourceVersion == "" {
+			m.Spec = addonv1alpha1.MonitoringSpec{}
+		} else if v, ok := m.Annotations[appliedParametersAnnotation()]; ok {
+			decoded, err := DecodeParameters(v)
+			if err != nil {
+				return err
+			}
+			applied = decoded
+		} else {
+			applied = valid.AppliedToMonitoring(&m.Spec)
+		}
+
+		valid.ApplyToMonitoring(&m.Spec, applied)
+
+		return setAppliedParameters(&m.ObjectMeta, valid)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile Monitoring CR %s in %s: %w", m.Name, m.Namespace, err)
+	}
+
+	logger.Info("Monitoring reconciled successfully",
+		"name", m.Name,
+		"namespace", m.Namespace,
+		"result", res)
+
+	patch := client.MergeFrom(m.DeepCopy())
+	meta.SetStatusCondition(&m.Status.Conditions, getParametersValidCondition(invalid))
+
+	if err := r.Status().Patch(ctx, m, patch); err != nil {
+		return fmt.Errorf("failed to patch Monitoring status: %w", err)
+	}
+
+	return nil
+}
+
+// defaultGPUAddonSpec returns the spec of a GPUAddon created without any
+// parameters.
+func defaultGPUAddonSpec() addonv1alpha1.GPUAddonSpec {
+	return addonv1alpha1.GPUAddonSpec{
+		ConsolePluginEnabled: true,
+	}
+}
+
+// setVersionLabel sets the add-on version label, keeping any other labels.
+func setVersionLabel(om *metav1.ObjectMeta) {
+	if om.Labels == nil {
+		om.Labels = map[string]string{}
+	}
+
+	om.Labels[fmt.Sprintf("%v-version", common.GlobalConfig.AddonID)] = version.Version()
+}
+
+// appliedParametersAnnotation is the annotation which records the
+// parameters last applied to an add-on CR, so that only the parameters
+// changed since then override the CR spec.
+func appliedParametersAnnotation() string {
+	return fmt.Sprintf("%s/applied-parameters", common.GlobalConfig.AddonID)
+}
+
+func setAppliedParameters(om *metav1.ObjectMeta, params Parameters) error {
+	encoded, err := params.Encode()
+	if err != nil {
+		return err
+	}
+
+	if om.Annotations == nil {
+		om.Annotations = map[string]string{}
+	}
+	om.Annotations[appliedParametersAnnotation()] = encoded
+
+	return nil
+}
+
+func getParametersValidCondition(invalid field.ErrorList) metav1.Condition {
+	if len(invalid) > 0 {
+		return common.NewCondition(
+			ParametersValidCondition,
+			metav1.ConditionFalse,
+			"InvalidParameters",
+			invalid.ToAggregate().Error())
+	}
+
+	return common.NewCondition(
+		ParametersValidCondition,
+		metav1.ConditionTrue,
+		"ValidParameters",
+		"All add-on parameters are valid")
+}
+
+func bootstrapRequest() reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+}
+
+func isAddonResource(name string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == name && o.GetNamespace() == common.GlobalConfig.AddonNamespace
+	})
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/version"
+)
+
+var _ = Describe("BootstrapReconciler", func() {
+	common.ProcessConfig()
+
+	key := types.NamespacedName{
+		Name:      common.GlobalConfig.AddonID,
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}
+	versionLabel := fmt.Sprintf("%v-version", common.GlobalConfig.AddonID)
+
+	Context("GPUAddon", func() {
+		It("should create the GPUAddon CR if it does not exist", func() {
+			r := newTestBootstrapReconciler()
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Labels[versionLabel]).To(Equal(version.Version()))
+			Expect(g.Spec.ConsolePluginEnabled).To(BeTrue())
+		})
+
+		It("should derive the GPUAddon spec from the add-on parameters", func() {
+			r := newTestBootstrapReconciler(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ParametersSecretName(),
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+				Data: map[string][]byte{
+					ParameterConsolePluginEnabled: []byte("false"),
+					ParameterDriverVersion:        []byte("515.48.07"),
+				},
+			})
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Spec.ConsolePluginEnabled).To(BeFalse())
+			Expect(g.Spec.DriverVersion).To(Equal("515.48.07"))
+		})
+
+		It("should merge labels and spec into an existing GPUAddon CR", func() {
+			existing := &addonv1alpha1.GPUAddon{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              key.Name,
+					Namespace:         key.Namespace,
+					CreationTimestamp: metav1.Now(),
+					Labels: map[string]string{
+						"user-label": "value",
+						versionLabel: "0.0.1",
+					},
+				},
+				Spec: addonv1alpha1.GPUAddonSpec{
+					ConsolePluginEnabled: false,
+					DriverVersion:        "470.141.03",
+				},
+			}
+			r := newTestBootstrapReconciler(existing, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ParametersSecretName(),
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+				Data: map[string][]byte{
+					ParameterDriverVersion:   []byte("515.48.07"),
+					ParameterNVAIEPullSecret: []byte("nvaie"),
+				},
+			})
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Labels).To(HaveKeyWithValue("user-label", "value"))
+			Expect(g.Labels).To(HaveKeyWithValue(versionLabel, version.Version()))
+			Expect(g.Spec.ConsolePluginEnabled).To(BeFalse())
+			Expect(g.Spec.DriverVersion).To(Equal("470.141.03"))
+			Expect(g.Spec.NVAIEPullSecret).To(Equal("nvaie"))
+		})
+
+		It("should report invalid parameters and apply the valid ones", func() {
+			r := newTestBootstrapReconciler(newParametersSecret(map[string][]byte{
+				ParameterDriverVersion: []byte("515.48.07"),
+				ParameterMIGStrategy:   []byte("all"),
+			}))
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Spec.DriverVersion).To(Equal("515.48.07"))
+			Expect(g.Spec.MIGStrategy).To(BeEmpty())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, ParametersValidCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal("InvalidParameters"))
+			Expect(c.Message).To(ContainSubstring(ParameterMIGStrategy))
+		})
+
+		It("should propagate parameter changes after install", func() {
+			s := newParametersSecret(map[string][]byte{
+				ParameterDriverVersion: []byte("510.73.08"),
+				ParameterMIGStrategy:   []byte("single"),
+			})
+			r := newTestBootstrapReconciler(s)
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(g.Status.Conditions, ParametersValidCondition)).To(BeTrue())
+
+			// A user change of a field whose parameter is unchanged is kept.
+			g.Spec.MIGStrategy = "mixed"
+			Expect(r.Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+			s.Data[ParameterDriverVersion] = []byte("515.48.07")
+			Expect(r.Update(context.TODO(), s)).ShouldNot(HaveOccurred())
+
+			_, err = r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Spec.DriverVersion).To(Equal("515.48.07"))
+			Expect(g.Spec.MIGStrategy).To(Equal("mixed"))
+		})
+
+		It("should not recreate the GPUAddon CR while the add-on is uninstalled", func() {
+			r := newTestBootstrapReconciler(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.GlobalConfig.AddonID,
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+			})
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = r.Get(context.TODO(), key, &addonv1alpha1.GPUAddon{})
+			Expect(err).Should(HaveOccurred())
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+			err = r.Get(context.TODO(), key, &addonv1alpha1.Monitoring{})
+			Expect(err).Should(HaveOccurred())
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should bootstrap the add-on once the uninstallation is cancelled", func() {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       common.GlobalConfig.AddonID,
+					Namespace:  common.GlobalConfig.AddonNamespace,
+					Finalizers: []string{"test"},
+				},
+			}
+			r := newTestBootstrapReconciler(cm)
+			Expect(r.Delete(context.TODO(), cm)).ShouldNot(HaveOccurred())
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(r.Get(context.TODO(), key, &addonv1alpha1.GPUAddon{})).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("Monitoring", func() {
+		It("should create the Monitoring CR if it does not exist", func() {
+			r := newTestBootstrapReconciler()
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m := &addonv1alpha1.Monitoring{}
+			Expect(r.Get(context.TODO(), key, m)).ShouldNot(HaveOccurred())
+			Expect(m.Labels[versionLabel]).To(Equal(version.Version()))
+		})
+
+		It("should derive the Monitoring spec from the add-on parameters", func() {
+			r := newTestBootstrapReconciler(newParametersSecret(map[string][]byte{
+				ParameterPagerDutySecretName: []byte("pagerduty"),
+				ParameterMonitoringRetention: []byte("30d"),
+			}))
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m := &addonv1alpha1.Monitoring{}
+			Expect(r.Get(context.TODO(), key, m)).ShouldNot(HaveOccurred())
+			Expect(m.Spec.PagerDuty.SecretName).To(Equal("pagerduty"))
+			Expect(m.Spec.Retention).To(Equal("30d"))
+			Expect(meta.IsStatusConditionTrue(m.Status.Conditions, ParametersValidCondition)).To(BeTrue())
+		})
+
+		It("should keep the labels of an existing Monitoring CR", func() {
+			r := newTestBootstrapReconciler(&addonv1alpha1.Monitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+					Labels: map[string]string{
+						"user-label": "value",
+					},
+				},
+			})
+
+			_, err := r.Reconcile(context.TODO(), bootstrapRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			m := &addonv1alpha1.Monitoring{}
+			Expect(r.Get(context.TODO(), key, m)).ShouldNot(HaveOccurred())
+			Expect(m.Labels).To(HaveKeyWithValue("user-label", "value"))
+			Expect(m.Labels).To(HaveKeyWithValue(versionLabel, version.Version()))
+		})
+	})
+})
+
+func newParametersSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ParametersSecretName(),
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+		Data: data,
+	}
+}
+
+func newTestBootstrapReconciler(objs ...runtime.Object) *BootstrapReconciler {
+	s := scheme.Scheme
+
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &BootstrapReconciler{
+		Client: c,
+		Scheme: s,
+	}
+}
@@ -0,0 +1,276 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// The keys of the managed add-on parameters Secret.
+const (
+	ParameterConsolePluginEnabled = "console_plugin_enabled"
+	ParameterDriverVersion        = "driver_version"
+	ParameterNVAIEPullSecret      = "nvaie_pullsecret"
+	ParameterMIGStrategy          = "mig_strategy"
+	ParameterPagerDutySecretName  = "pagerduty_secret_name"
+	ParameterMonitoringRetention  = "monitoring_retention"
+)
+
+var (
+	driverVersionRegex = regexp.MustCompile(`^([0-9]+\.[0-9]+(\.[0-9]+)?|sha256:[a-f0-9]{64})$`)
+	durationRegex      = regexp.MustCompile(`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`)
+	migStrategies      = []string{"none", "single", "mixed"}
+)
+
+// parameter describes how an add-on parameter is validated and applied to
+// the add-on CRs. A parameter applies either to the GPUAddon or to the
+// Monitoring spec, and its getter returns the value of the field it sets,
+// or an empty string if the field is unset.
+type parameter struct {
+	validate          func(value string) error
+	applyToGPUAddon   func(spec *addonv1alpha1.GPUAddonSpec, value string)
+	fromGPUAddon      func(spec *addonv1alpha1.GPUAddonSpec) string
+	applyToMonitoring func(spec *addonv1alpha1.MonitoringSpec, value string)
+	fromMonitoring    func(spec *addonv1alpha1.MonitoringSpec) string
+}
+
+// schema holds all the supported add-on parameters keyed by parameter ID.
+var schema = map[string]parameter{
+	ParameterConsolePluginEnabled: {
+		validate: func(v string) error {
+			_, err := strconv.ParseBool(v)
+			return err
+		},
+		applyToGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec, v string) {
+			spec.ConsolePluginEnabled, _ = strconv.ParseBool(v)
+		},
+		fromGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec) string {
+			return strconv.FormatBool(spec.ConsolePluginEnabled)
+		},
+	},
+	ParameterDriverVersion: {
+		validate: matches(driverVersionRegex, "a driver version, e.g. 515.48.07, or an image digest, e.g. sha256:<digest>"),
+		applyToGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec, v string) {
+			spec.DriverVersion = v
+		},
+		fromGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec) string {
+			return spec.DriverVersion
+		},
+	},
+	ParameterNVAIEPullSecret: {
+		validate: isDNS1123Subdomain,
+		applyToGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec, v string) {
+			spec.NVAIEPullSecret = v
+		},
+		fromGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec) string {
+			return spec.NVAIEPullSecret
+		},
+	},
+	ParameterMIGStrategy: {
+		validate: func(v string) error {
+			if !common.SliceContainsString(migStrategies, v) {
+				return fmt.Errorf("must be one of %v", migStrategies)
+			}
+			return nil
+		},
+		applyToGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec, v string) {
+			spec.MIGStrategy = v
+		},
+		fromGPUAddon: func(spec *addonv1alpha1.GPUAddonSpec) string {
+			return spec.MIGStrategy
+		},
+	},
+	ParameterPagerDutySecretName: {
+		validate: isDNS1123Subdomain,
+		applyToMonitoring: func(spec *addonv1alpha1.MonitoringSpec, v string) {
+			if spec.PagerDuty == nil {
+				spec.PagerDuty = &addonv1alpha1.PagerDutySpec{}
+			}
+			spec.PagerDuty.SecretName = v
+		},
+		fromMonitoring: func(spec *addonv1alpha1.MonitoringSpec) string {
+			if spec.PagerDuty == nil {
+				return ""
+			}
+			return spec.PagerDuty.SecretName
+		},
+	},
+	ParameterMonitoringRetention: {
+		validate: matches(durationRegex, "a duration, e.g. 15d"),
+		applyToMonitoring: func(spec *addonv1alpha1.MonitoringSpec, v string) {
+			spec.Retention = v
+		},
+		fromMonitoring: func(spec *addonv1alpha1.MonitoringSpec) string {
+			return spec.Retention
+		},
+	},
+}
+
+// Parameters are the add-on parameters provided by the installer, keyed by
+// parameter ID.
+type Parameters map[string]string
+
+// ParametersSecretName returns the name of the Secret which holds the
+// managed add-on parameters.
+func ParametersSecretName() string {
+	return fmt.Sprintf("addon-%s-parameters", common.GlobalConfig.AddonID)
+}
+
+// NewParameters returns the parameters contained in the given Secret data.
+// Unknown parameters are ignored.
+func NewParameters(data map[string][]byte) Parameters {
+	p := Parameters{}
+	for k, v := range data {
+		if _, ok := schema[k]; ok {
+			p[k] = string(v)
+		}
+	}
+
+	return p
+}
+
+// Validate splits the parameters into valid and invalid ones. The errors of
+// the invalid parameters are sorted by parameter ID.
+func (p Parameters) Validate() (Parameters, field.ErrorList) {
+	valid := Parameters{}
+	errs := field.ErrorList{}
+
+	for _, id := range p.ids() {
+		if err := schema[id].validate(p[id]); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath(id), p[id], err.Error()))
+			continue
+		}
+		valid[id] = p[id]
+	}
+
+	return valid, errs
+}
+
+// GPUAddon returns the parameters which apply to the GPUAddon spec.
+func (p Parameters) GPUAddon() Parameters {
+	return p.filter(func(param parameter) bool { return param.applyToGPUAddon != nil })
+}
+
+// Monitoring returns the parameters which apply to the Monitoring spec.
+func (p Parameters) Monitoring() Parameters {
+	return p.filter(func(param parameter) bool { return param.applyToMonitoring != nil })
+}
+
+// ApplyToGPUAddon sets the GPUAddon spec fields of the parameters which have
+// changed since the given previously applied parameters. Fields whose
+// parameter hasn't changed are left as is, so that user changes are kept.
+func (p Parameters) ApplyToGPUAddon(spec *addonv1alpha1.GPUAddonSpec, applied Parameters) {
+	for _, id := range p.changedSince(applied) {
+		if apply := schema[id].applyToGPUAddon; apply != nil {
+			apply(spec, p[id])
+		}
+	}
+}
+
+// ApplyToMonitoring sets the Monitoring spec fields of the parameters which
+// have changed since the given previously applied parameters.
+func (p Parameters) ApplyToMonitoring(spec *addonv1alpha1.MonitoringSpec, applied Parameters) {
+	for _, id := range p.changedSince(applied) {
+		if apply := schema[id].applyToMonitoring; apply != nil {
+			apply(spec, p[id])
+		}
+	}
+}
+
+// AppliedToGPUAddon returns the parameters which can be considered applied
+// to a GPUAddon spec which predates the tracking of the applied parameters,
+// i.e. the ones whose field is already set.
+func (p Parameters) AppliedToGPUAddon(spec *addonv1alpha1.GPUAddonSpec) Parameters {
+	return p.filter(func(param parameter) bool {
+		return param.fromGPUAddon != nil && param.fromGPUAddon(spec) != ""
+	})
+}
+
+// AppliedToMonitoring returns the parameters which can be considered applied
+// to a Monitoring spec which predates the tracking of the applied parameters.
+func (p Parameters) AppliedToMonitoring(spec *addonv1alpha1.MonitoringSpec) Parameters {
+	return p.filter(func(param parameter) bool {
+		return param.fromMonitoring != nil && param.fromMonitoring(spec) != ""
+	})
+}
+
+// Encode returns the JSON representation of the parameters.
+func (p Parameters) Encode() (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode parameters: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// DecodeParameters parses the JSON representation of parameters. An empty
+// string decodes to no parameters.
+func DecodeParameters(s string) (Parameters, error) {
+	p := Parameters{}
+	if s == "" {
+		return p, nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil, fmt.Errorf("failed to decode parameters: %w", err)
+	}
+
+	return p, nil
+}
+
+func (p Parameters) changedSince(applied Parameters) []string {
+	changed := []string{}
+	for _, id := range p.ids() {
+		if v, ok := applied[id]; !ok || v != p[id] {
+			changed = append(changed, id)
+		}
+	}
+
+	return changed
+}
+
+func (p Parameters) filter(f func(parameter) bool) Parameters {
+	filtered := Parameters{}
+	for id, v := range p {
+		if param, ok := schema[id]; ok && f(param) {
+			filtered[id] = v
+		}
+	}
+
+	return filtered
+}
+
+func (p Parameters) ids() []string {
+	ids := make([]string, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func matches(re *regexp.Regexp, description string) func(string) error {
+	return func(v string) error {
+		if !re.MatchString(v) {
+			return fmt.Errorf("must be %s", description)
+		}
+		return nil
+	}
+}
+
+func isDNS1123Subdomain(v string) error {
+	if errs := validation.IsDNS1123Subdomain(v); len(errs) > 0 {
+		return fmt.Errorf("must be a valid resource name: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package bootstrap
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("Parameters", func() {
+	common.ProcessConfig()
+
+	It("should name the parameters Secret after the add-on", func() {
+		Expect(ParametersSecretName()).To(Equal("addon-nvidia-gpu-addon-parameters"))
+	})
+
+	It("should ignore unknown parameters", func() {
+		p := NewParameters(map[string][]byte{
+			ParameterDriverVersion: []byte("515.48.07"),
+			"unknown":              []byte("value"),
+		})
+
+		Expect(p).To(Equal(Parameters{ParameterDriverVersion: "515.48.07"}))
+	})
+
+	Context("Validate", func() {
+		It("should accept valid parameters", func() {
+			p := Parameters{
+				ParameterConsolePluginEnabled: "false",
+				ParameterDriverVersion:        "515.48.07",
+				ParameterNVAIEPullSecret:      "nvaie",
+				ParameterMIGStrategy:          "mixed",
+				ParameterPagerDutySecretName:  "pagerduty",
+				ParameterMonitoringRetention:  "15d",
+			}
+
+			valid, errs := p.Validate()
+			Expect(errs).To(BeEmpty())
+			Expect(valid).To(Equal(p))
+		})
+
+		It("should report the invalid parameters and keep the valid ones", func() {
+			p := Parameters{
+				ParameterConsolePluginEnabled: "maybe",
+				ParameterDriverVersion:        "515.48.07",
+				ParameterMIGStrategy:          "all",
+				ParameterMonitoringRetention:  "two weeks",
+				ParameterNVAIEPullSecret:      "Not_A_Name",
+			}
+
+			valid, errs := p.Validate()
+			Expect(valid).To(Equal(Parameters{ParameterDriverVersion: "515.48.07"}))
+			Expect(errs).To(HaveLen(4))
+			Expect(errs[0].Field).To(Equal(ParameterConsolePluginEnabled))
+			Expect(errs[1].Field).To(Equal(ParameterMIGStrategy))
+			Expect(errs[2].Field).To(Equal(ParameterMonitoringRetention))
+			Expect(errs[3].Field).To(Equal(ParameterNVAIEPullSecret))
+		})
+	})
+
+	Context("GPUAddon and Monitoring", func() {
+		It("should split the parameters by the CR they apply to", func() {
+			p := Parameters{
+				ParameterDriverVersion:       "515.48.07",
+				ParameterMonitoringRetention: "15d",
+			}
+
+			Expect(p.GPUAddon()).To(Equal(Parameters{ParameterDriverVersion: "515.48.07"}))
+			Expect(p.Monitoring()).To(Equal(Parameters{ParameterMonitoringRetention: "15d"}))
+		})
+	})
+
+	Context("ApplyToGPUAddon", func() {
+		It("should apply all parameters when none has been applied", func() {
+			spec := addonv1alpha1.GPUAddonSpec{ConsolePluginEnabled: true}
+			p := Parameters{
+				ParameterConsolePluginEnabled: "false",
+				ParameterDriverVersion:        "515.48.07",
+				ParameterNVAIEPullSecret:      "nvaie",
+				ParameterMIGStrategy:          "single",
+			}
+
+			p.ApplyToGPUAddon(&spec, Parameters{})
+
+			Expect(spec).To(Equal(addonv1alpha1.GPUAddonSpec{
+				ConsolePluginEnabled: false,
+				DriverVersion:        "515.48.07",
+				NVAIEPullSecret:      "nvaie",
+				MIGStrategy:          "single",
+			}))
+		})
+
+		It("should only apply the parameters which have changed", func() {
+			spec := addonv1alpha1.GPUAddonSpec{
+				DriverVersion: "470.141.03",
+				MIGStrategy:   "mixed",
+			}
+			p := Parameters{
+				ParameterDriverVersion: "515.48.07",
+				ParameterMIGStrategy:   "single",
+			}
+
+			p.ApplyToGPUAddon(&spec, Parameters{
+				ParameterDriverVersion: "510.73.08",
+				ParameterMIGStrategy:   "single",
+			})
+
+			Expect(spec.DriverVersion).To(Equal("515.48.07"))
+			Expect(spec.MIGStrategy).To(Equal("mixed"))
+		})
+	})
+
+	Context("ApplyToMonitoring", func() {
+		It("should apply the monitoring parameters", func() {
+			spec := addonv1alpha1.MonitoringSpec{}
+			p := Parameters{
+				ParameterPagerDutySecretName: "pagerduty",
+				ParameterMonitoringRetention: "30d",
+			}
+
+			p.ApplyToMonitoring(&spec, Parameters{})
+
+			Expect(spec.PagerDuty).ToNot(BeNil())
+			Expect(spec.PagerDuty.SecretName).To(Equal("pagerduty"))
+			Expect(spec.Retention).To(Equal("30d"))
+		})
+	})
+
+	Context("AppliedToGPUAddon", func() {
+		It("should consider the parameters of the set fields applied", func() {
+			spec := addonv1alpha1.GPUAddonSpec{DriverVersion: "470.141.03"}
+			p := Parameters{
+				ParameterDriverVersion:   "515.48.07",
+				ParameterNVAIEPullSecret: "nvaie",
+			}
+
+			Expect(p.AppliedToGPUAddon(&spec)).To(Equal(Parameters{ParameterDriverVersion: "515.48.07"}))
+		})
+	})
+
+	Context("Encode and DecodeParameters", func() {
+		It("should round-trip the parameters", func() {
+			p := Parameters{
+				ParameterDriverVersion: "515.48.07",
+				ParameterMIGStrategy:   "single",
+			}
+
+			encoded, err := p.Encode()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			decoded, err := DecodeParameters(encoded)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).To(Equal(p))
+		})
+
+		It("should decode an empty string to no parameters", func() {
+			decoded, err := DecodeParameters("")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(decoded).To(BeEmpty())
+		})
+
+		It("should fail to decode malformed parameters", func() {
+			_, err := DecodeParameters("{")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
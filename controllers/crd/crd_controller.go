@@ -0,0 +1,217 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+// Watch is a controller watch on a resource of an optional API. It is only
+// started once the CRD providing its Capability is established.
+type Watch struct {
+	Capability common.Capability
+	Source     source.Source
+	// SourceFor returns the source to watch given the established CRD, e.g.
+	// to watch the version it serves. It is used when Source is nil.
+	SourceFor func(crd *apiextensionsv1.CustomResourceDefinition) source.Source
+	Handler   handler.EventHandler
+}
+
+type registration struct {
+	controller controller.Controller
+	object     client.Object
+	events     chan event.GenericEvent
+	watches    []Watch
+	started    []bool
+}
+
+// CRDReconciler watches the CustomResourceDefinitions of the optional APIs
+// the add-on depends on and keeps common.Capabilities up to date. When a CRD
+// becomes established, it starts the watches registered for it and triggers
+// a reconciliation of the dependent controllers.
+type CRDReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	mu            sync.Mutex
+	registrations []*registration
+}
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+
+// Register adds the given watches to the controller as soon as their
+// capabilities become available. Whenever the availability of one of these
+// capabilities changes, the given object is enqueued in the controller.
+func (r *CRDReconciler) Register(c controller.Controller, obj client.Object, watches ...Watch) error {
+	// A single pending event is enough to trigger a reconciliation, hence
+	// the events channel is buffered and never blocks the sender.
+	events := make(chan event.GenericEvent, 1)
+
+	if err := c.Watch(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{}); err != nil {
+		return fmt.Errorf("failed to watch for capability changes: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.registrations = append(r.registrations, &registration{
+		controller: c,
+		object:     obj,
+		events:     events,
+		watches:    watches,
+		started:    make([]bool, len(watches)),
+	})
+
+	return nil
+}
+
+// Reconcile records whether the capability provided by the requested CRD is
+// available and starts the watches which depend on it.
+func (r *CRDReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	capability := common.Capability(req.Name)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err := r.Get(ctx, req.NamespacedName, crd)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to get CRD %s: %w", req.Name, err)
+	}
+
+	available := err == nil && crd.DeletionTimestamp.IsZero() && isEstablished(crd)
+	wasAvailable := common.Capabilities.IsAvailable(capability)
+
+	common.Capabilities.SetAvailable(capability, available)
+
+	if available {
+		if err := r.startWatches(capability, crd); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if available != wasAvailable {
+		logger.Info("Capability availability changed", "capability", capability, "available", available)
+		r.notify(capability)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CRDReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiextensionsv1.CustomResourceDefinition{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return r.isRegistered(common.Capability(o.GetName()))
+		})).
+		Complete(health.Reconciles.Track("customresourcedefinition", r))
+}
+
+// startWatches starts the not yet started watches for the given capability.
+// Watches cannot be removed from a controller, so they are kept running even
+// if their CRD is removed later on.
+func (r *CRDReconciler) startWatches(capability common.Capability, crd *apiextensionsv1.CustomResourceDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reg := range r.registrations {
+		for i, w := range reg.watches {
+			if w.Capability != capability || reg.started[i] {
+				continue
+			}
+
+			src := w.Source
+			if src == nil {
+				src = w.SourceFor(crd)
+			}
+
+			if err := reg.controller.Watch(src, w.Handler); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", capability, err)
+			}
+			reg.started[i] = true
+		}
+	}
+
+	return nil
+}
+
+func (r *CRDReconciler) notify(capability common.Capability) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reg := range r.registrations {
+		if !reg.dependsOn(capability) {
+			continue
+		}
+
+		select {
+		case reg.events <- event.GenericEvent{Object: reg.object}:
+		default:
+			// A reconciliation is already pending.
+		}
+	}
+}
+
+func (r *CRDReconciler) isRegistered(capability common.Capability) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reg := range r.registrations {
+		if reg.dependsOn(capability) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (reg *registration) dependsOn(capability common.Capability) bool {
+	for _, w := range reg.watches {
+		if w.Capability == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEstablished(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == apiextensionsv1.Established {
+			return c.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+
+	return false
+}
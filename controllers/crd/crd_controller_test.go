@@ -0,0 +1,230 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("CRDReconciler", Ordered, func() {
+	common.ProcessConfig()
+
+	capability := common.CapabilityClusterPolicy
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: string(capability),
+		},
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: string(capability),
+		},
+	}
+
+	gpuAddon := &addonv1alpha1.GPUAddon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	var c *fakeController
+	var events <-chan event.GenericEvent
+
+	BeforeEach(func() {
+		common.SetCapabilitiesAvailable(false)
+	})
+
+	newRegisteredReconciler := func(objs ...runtime.Object) *CRDReconciler {
+		r := newTestCRDReconciler(objs...)
+		c = &fakeController{}
+
+		err := r.Register(c, gpuAddon, Watch{
+			Capability: capability,
+			Source:     &source.Kind{Type: &apiextensionsv1.CustomResourceDefinition{}},
+			Handler:    &handler.EnqueueRequestForObject{},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(c.sources).To(HaveLen(1))
+
+		events = c.sources[0].(*source.Channel).Source
+
+		return r
+	}
+
+	Context("when the CRD does not exist", func() {
+		It("should keep the capability unavailable", func() {
+			r := newRegisteredReconciler()
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(common.Capabilities.IsAvailable(capability)).To(BeFalse())
+			Expect(c.sources).To(HaveLen(1))
+			Expect(events).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the CRD is not established", func() {
+		It("should keep the capability unavailable", func() {
+			r := newRegisteredReconciler(crd)
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(common.Capabilities.IsAvailable(capability)).To(BeFalse())
+			Expect(c.sources).To(HaveLen(1))
+		})
+	})
+
+	Context("when the CRD is established", func() {
+		established := crd.DeepCopy()
+		established.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+			{
+				Type:   apiextensionsv1.Established,
+				Status: apiextensionsv1.ConditionTrue,
+			},
+		}
+
+		It("should make the capability available and start the watches once", func() {
+			r := newRegisteredReconciler(established)
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(common.Capabilities.IsAvailable(capability)).To(BeTrue())
+			Expect(c.sources).To(HaveLen(2))
+
+			var e event.GenericEvent
+			Expect(events).Should(Receive(&e))
+			Expect(e.Object.GetName()).To(Equal(gpuAddon.Name))
+			Expect(e.Object.GetNamespace()).To(Equal(gpuAddon.Namespace))
+
+			_, err = r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(c.sources).To(HaveLen(2))
+			Expect(events).ShouldNot(Receive())
+		})
+
+		It("should start the watches with the source for the CRD", func() {
+			r := newTestCRDReconciler(established)
+			c = &fakeController{}
+
+			src := &source.Kind{Type: &apiextensionsv1.CustomResourceDefinition{}}
+			var got *apiextensionsv1.CustomResourceDefinition
+			err := r.Register(c, gpuAddon, Watch{
+				Capability: capability,
+				SourceFor: func(crd *apiextensionsv1.CustomResourceDefinition) source.Source {
+					got = crd
+					return src
+				},
+				Handler: &handler.EnqueueRequestForObject{},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(c.sources).To(HaveLen(2))
+			Expect(c.sources[1]).To(BeIdenticalTo(src))
+			Expect(got).ToNot(BeNil())
+			Expect(got.Name).To(Equal(established.Name))
+		})
+
+		It("should make the capability unavailable when the CRD is removed", func() {
+			r := newRegisteredReconciler(established)
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(events).Should(Receive())
+
+			Expect(r.Delete(context.TODO(), established)).To(Succeed())
+
+			_, err = r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(common.Capabilities.IsAvailable(capability)).To(BeFalse())
+			Expect(events).Should(Receive())
+		})
+	})
+
+	Context("isRegistered", func() {
+		It("should only match the registered capabilities", func() {
+			r := newRegisteredReconciler()
+
+			Expect(r.isRegistered(capability)).To(BeTrue())
+			Expect(r.isRegistered(common.CapabilityPrometheus)).To(BeFalse())
+		})
+	})
+})
+
+// fakeController records the sources it has been asked to watch.
+type fakeController struct {
+	sources []source.Source
+}
+
+func (c *fakeController) Reconcile(context.Context, reconcile.Request) (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+func (c *fakeController) Watch(src source.Source, _ handler.EventHandler, _ ...predicate.Predicate) error {
+	c.sources = append(c.sources, src)
+	return nil
+}
+
+func (c *fakeController) Start(context.Context) error {
+	return nil
+}
+
+func (c *fakeController) GetLogger() logr.Logger {
+	return logr.Discard()
+}
+
+func newTestCRDReconciler(objs ...runtime.Object) *CRDReconciler {
+	s := scheme.Scheme
+
+	Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &CRDReconciler{
+		Client: c,
+		Scheme: s,
+	}
+}
@@ -19,11 +19,25 @@ import (
 
 const (
 	ClusterPolicyDeployedCondition = "ClusterPolicyDeployed"
+	// ClusterPolicyReadyCondition reports whether the GPU operator reports
+	// all the components of the ClusterPolicy as ready.
+	ClusterPolicyReadyCondition = "ClusterPolicyReady"
+
+	containerdConfigPath = "/etc/containerd/config.toml"
+	containerdSocketPath = "/run/containerd/containerd.sock"
 )
 
 type ClusterPolicyResourceReconciler struct{}
 
 var _ ResourceReconciler = &ClusterPolicyResourceReconciler{}
+var _ Detacher = &ClusterPolicyResourceReconciler{}
+var _ NodeCleaner = &ClusterPolicyResourceReconciler{}
+var _ CapabilityDependentReconciler = &ClusterPolicyResourceReconciler{}
+var _ ForceDeleter = &ClusterPolicyResourceReconciler{}
+
+func (r *ClusterPolicyResourceReconciler) Name() string {
+	return "ClusterPolicy"
+}
 
 func (r *ClusterPolicyResourceReconciler) Reconcile(
 	ctx context.Context,
@@ -64,6 +78,7 @@ func (r *ClusterPolicyResourceReconciler) Reconcile(
 	}
 
 	conditions = append(conditions, r.getDeployedConditionCreateSuccess())
+	conditions = append(conditions, r.getReadyCondition(cp))
 
 	logger.Info("ClusterPolicy reconciled successfully",
 		"name", cp.Name,
@@ -99,6 +114,18 @@ func (r *ClusterPolicyResourceReconciler) setDesiredClusterPolicy(
 		Enabled: &enabled,
 	}
 
+	// The Driver Toolkit is only available on OpenShift, while vanilla
+	// Kubernetes clusters are expected to run containerd.
+	if !common.IsOpenShift() {
+		cp.Spec.Operator.DefaultRuntime = gpuv1.Containerd
+		cp.Spec.Operator.UseOpenShiftDriverToolkit = &disabled
+
+		cp.Spec.Toolkit.Env = []corev1.EnvVar{
+			{Name: "CONTAINERD_CONFIG", Value: containerdConfigPath},
+			{Name: "CONTAINERD_SOCKET", Value: containerdSocketPath},
+		}
+	}
+
 	cp.Spec.DCGM = gpuv1.DCGMSpec{
 		Enabled: &enabled,
 	}
@@ -121,6 +148,10 @@ func (r *ClusterPolicyResourceReconciler) setDesiredClusterPolicy(
 		Strategy: gpuv1.MIGStrategySingle,
 	}
 
+	if gpuAddon.Spec.MIGStrategy != "" {
+		cp.Spec.MIG.Strategy = gpuv1.MIGStrategy(gpuAddon.Spec.MIGStrategy)
+	}
+
 	cp.Spec.Validator = gpuv1.ValidatorSpec{
 		Env: []corev1.EnvVar{
 			{Name: "WITH_WORKLOAD", Value: "true"},
@@ -154,6 +185,14 @@ func (r *ClusterPolicyResourceReconciler) setDesiredClusterPolicy(
 	return nil
 }
 
+func (r *ClusterPolicyResourceReconciler) RequiredCapabilities() []common.Capability {
+	return []common.Capability{common.CapabilityClusterPolicy}
+}
+
+func (r *ClusterPolicyResourceReconciler) ConditionType() string {
+	return ClusterPolicyDeployedCondition
+}
+
 func (r *ClusterPolicyResourceReconciler) Delete(ctx context.Context, c client.Client) (bool, error) {
 	cp := &gpuv1.ClusterPolicy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -171,6 +210,54 @@ func (r *ClusterPolicyResourceReconciler) Delete(ctx context.Context, c client.C
 	return false, nil
 }
 
+// ForceDelete removes the finalizers of the ClusterPolicy CR, which are left
+// behind if the GPU operator is gone.
+func (r *ClusterPolicyResourceReconciler) ForceDelete(ctx context.Context, c client.Client) error {
+	cp := &gpuv1.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: common.GlobalConfig.ClusterPolicyName,
+		},
+	}
+
+	if err := removeFinalizers(ctx, c, cp); err != nil {
+		return fmt.Errorf("failed to remove ClusterPolicy %s finalizers: %w", cp.Name, err)
+	}
+
+	return nil
+}
+
+// NodeCleanup returns what the GPU operator operands leave behind on the
+// nodes.
+func (r *ClusterPolicyResourceReconciler) NodeCleanup() NodeCleanup {
+	return NodeCleanup{
+		// The GPU feature discovery, MIG manager and driver upgrade labels,
+		// e.g. nvidia.com/gpu.product, nvidia.com/mig.config and
+		// nvidia.com/gpu-driver-upgrade-state.
+		LabelPrefixes: []string{"nvidia.com/"},
+		// The driver upgrade annotations, e.g.
+		// nvidia.com/gpu-driver-upgrade-enabled.
+		AnnotationPrefixes: []string{"nvidia.com/"},
+		// Only the driver upgrade taints, as the nvidia.com/gpu taint
+		// is usually applied by the cluster administrator.
+		TaintPrefixes: []string{"nvidia.com/gpu-driver-upgrade"},
+	}
+}
+
+// Detach hands the ClusterPolicy CR over.
+func (r *ClusterPolicyResourceReconciler) Detach(ctx context.Context, c client.Client) error {
+	cp := &gpuv1.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: common.GlobalConfig.ClusterPolicyName,
+		},
+	}
+
+	if err := detach(ctx, c, cp); err != nil {
+		return fmt.Errorf("failed to detach ClusterPolicy %s: %w", cp.Name, err)
+	}
+
+	return nil
+}
+
 func (r *ClusterPolicyResourceReconciler) getDeployedConditionFetchFailed() metav1.Condition {
 	return common.NewCondition(
 		ClusterPolicyDeployedCondition,
@@ -194,3 +281,29 @@ func (r *ClusterPolicyResourceReconciler) getDeployedConditionCreateSuccess() me
 		"CreateCrSuccess",
 		"ClusterPolicy deployed successfully")
 }
+
+// getReadyCondition reports whether the GPU operator reports the given
+// ClusterPolicy as ready. Its state is empty until the GPU operator first
+// reconciles it.
+func (r *ClusterPolicyResourceReconciler) getReadyCondition(cp *gpuv1.ClusterPolicy) metav1.Condition {
+	switch cp.Status.State {
+	case gpuv1.Ready:
+		return common.NewCondition(
+			ClusterPolicyReadyCondition,
+			metav1.ConditionTrue,
+			"ClusterPolicyReady",
+			"ClusterPolicy is ready")
+	case "":
+		return common.NewCondition(
+			ClusterPolicyReadyCondition,
+			metav1.ConditionFalse,
+			"ClusterPolicyPending",
+			"ClusterPolicy has not been reconciled by the GPU operator yet")
+	default:
+		return common.NewCondition(
+			ClusterPolicyReadyCondition,
+			metav1.ConditionFalse,
+			"ClusterPolicyNotReady",
+			fmt.Sprintf("ClusterPolicy is in the %s state", cp.Status.State))
+	}
+}
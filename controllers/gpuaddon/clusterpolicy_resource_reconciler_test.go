@@ -22,6 +22,7 @@ import (
 	gpuv1 "github.com/NVIDIA/gpu-operator/api/v1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -65,9 +66,11 @@ var _ = Describe("ClusterPolicy Resource Reconcile", Ordered, func() {
 
 				cond, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
 				Expect(err).ShouldNot(HaveOccurred())
-				Expect(cond).To(HaveLen(1))
+				Expect(cond).To(HaveLen(2))
 				Expect(cond[0].Type).To(Equal(ClusterPolicyDeployedCondition))
 				Expect(cond[0].Status).To(Equal(metav1.ConditionTrue))
+				Expect(cond[1].Type).To(Equal(ClusterPolicyReadyCondition))
+				Expect(cond[1].Status).To(Equal(metav1.ConditionFalse))
 
 				err = c.Get(context.TODO(), types.NamespacedName{
 					Name: common.GlobalConfig.ClusterPolicyName,
@@ -94,9 +97,11 @@ var _ = Describe("ClusterPolicy Resource Reconcile", Ordered, func() {
 
 				cond, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
 				Expect(err).ShouldNot(HaveOccurred())
-				Expect(cond).To(HaveLen(1))
+				Expect(cond).To(HaveLen(2))
 				Expect(cond[0].Type).To(Equal(ClusterPolicyDeployedCondition))
 				Expect(cond[0].Status).To(Equal(metav1.ConditionTrue))
+				Expect(cond[1].Type).To(Equal(ClusterPolicyReadyCondition))
+				Expect(cond[1].Status).To(Equal(metav1.ConditionFalse))
 
 				err = c.Get(context.TODO(), types.NamespacedName{
 					Name: common.GlobalConfig.ClusterPolicyName,
@@ -109,6 +114,115 @@ var _ = Describe("ClusterPolicy Resource Reconcile", Ordered, func() {
 			})
 		})
 
+		Context("with the ClusterPolicy state reported by the GPU operator", func() {
+			It("should report whether the ClusterPolicy is ready", func() {
+				gpuAddon.Spec = addonv1alpha1.GPUAddonSpec{}
+
+				existing := &gpuv1.ClusterPolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: common.GlobalConfig.ClusterPolicyName,
+					},
+					Status: gpuv1.ClusterPolicyStatus{State: gpuv1.NotReady},
+				}
+
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					WithRuntimeObjects(existing).
+					Build()
+
+				cond, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(common.ContainCondition(cond, ClusterPolicyDeployedCondition, "True")).To(BeTrue())
+				Expect(common.ContainCondition(cond, ClusterPolicyReadyCondition, "False")).To(BeTrue())
+				Expect(cond[1].Message).To(ContainSubstring("notReady"))
+
+				Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(existing), existing)).To(Succeed())
+				existing.Status.State = gpuv1.Ready
+				Expect(c.Status().Update(context.TODO(), existing)).To(Succeed())
+
+				cond, err = rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(common.ContainCondition(cond, ClusterPolicyReadyCondition, "True")).To(BeTrue())
+			})
+		})
+
+		Context("with a GPUAddon.MIGStrategy defined", func() {
+			It("should create the ClusterPolicy with the GPUAddon.MIGStrategy", func() {
+				gpuAddon.Spec = addonv1alpha1.GPUAddonSpec{
+					MIGStrategy: "mixed",
+				}
+
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					WithRuntimeObjects().
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Name: common.GlobalConfig.ClusterPolicyName,
+				}, &cp)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cp.Spec.MIG.Strategy).To(Equal(gpuv1.MIGStrategyMixed))
+			})
+		})
+
+		Context("on an OpenShift cluster", func() {
+			It("should use CRI-O and the OpenShift Driver Toolkit", func() {
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Name: common.GlobalConfig.ClusterPolicyName,
+				}, &cp)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cp.Spec.Operator.DefaultRuntime).To(Equal(gpuv1.CRIO))
+				Expect(*cp.Spec.Operator.UseOpenShiftDriverToolkit).To(BeTrue())
+				Expect(cp.Spec.Toolkit.Env).To(BeEmpty())
+			})
+		})
+
+		Context("on a Kubernetes cluster", func() {
+			BeforeEach(func() {
+				common.ClusterPlatform = common.PlatformKubernetes
+			})
+
+			AfterEach(func() {
+				common.ClusterPlatform = common.PlatformOpenShift
+			})
+
+			It("should use containerd and not the OpenShift Driver Toolkit", func() {
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Name: common.GlobalConfig.ClusterPolicyName,
+				}, &cp)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cp.Spec.Operator.DefaultRuntime).To(Equal(gpuv1.Containerd))
+				Expect(*cp.Spec.Operator.UseOpenShiftDriverToolkit).To(BeFalse())
+				Expect(cp.Spec.Toolkit.Env).To(ContainElement(corev1.EnvVar{
+					Name:  "CONTAINERD_SOCKET",
+					Value: "/run/containerd/containerd.sock",
+				}))
+			})
+		})
 	})
 
 	Context("Delete", func() {
@@ -0,0 +1,164 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const ConsolePluginAvailableCondition = "ConsolePluginAvailable"
+
+func (r *ConsolePluginResourceReconciler) reconcileConsolePluginPodDisruptionBudget(
+	ctx context.Context,
+	c client.Client,
+	gpuAddon *addonv1alpha1.GPUAddon) error {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin PodDisruptionBudget")
+
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      consolePluginName,
+			Namespace: gpuAddon.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, c, pdb, func() error {
+		return r.setDesiredConsolePluginPodDisruptionBudget(c, pdb, gpuAddon)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	logger.Info("ConsolePlugin PodDisruptionBudget reconciled successfully",
+		"name", pdb.Name,
+		"namespace", pdb.Namespace,
+		"result", res)
+
+	return nil
+}
+
+func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginPodDisruptionBudget(
+	c client.Client,
+	pdb *policyv1.PodDisruptionBudget,
+	gpuAddon *addonv1alpha1.GPUAddon) error {
+
+	if pdb == nil {
+		return errors.New("poddisruptionbudget cannot be nil")
+	}
+
+	// A single replica can still be evicted, so that it never blocks the
+	// drain of its node.
+	maxUnavailable := intstr.FromInt(1)
+	pdb.Spec = policyv1.PodDisruptionBudgetSpec{
+		MaxUnavailable: &maxUnavailable,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app": consolePluginName,
+			},
+		},
+	}
+
+	return ctrl.SetControllerReference(gpuAddon, pdb, c.Scheme())
+}
+
+func (r *ConsolePluginResourceReconciler) deleteConsolePluginPodDisruptionBudget(ctx context.Context, c client.Client) (bool, error) {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.GlobalConfig.AddonNamespace,
+			Name:      consolePluginName,
+		},
+	}
+
+	if err := c.Delete(ctx, pdb); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to delete ConsolePlugin PodDisruptionBudget %s: %w", pdb.Name, err)
+	}
+
+	return false, nil
+}
+
+// getAvailableCondition reports whether the console plugin Deployment has
+// its minimum number of replicas available.
+func (r *ConsolePluginResourceReconciler) getAvailableCondition(
+	ctx context.Context,
+	c client.Client,
+	gpuAddon *addonv1alpha1.GPUAddon) (metav1.Condition, error) {
+
+	dp := &appsv1.Deployment{}
+	err := c.Get(ctx, types.NamespacedName{
+		Name:      consolePluginName,
+		Namespace: gpuAddon.Namespace,
+	}, dp)
+	if err != nil {
+		return metav1.Condition{}, fmt.Errorf("failed to get ConsolePlugin Deployment %s: %w", consolePluginName, err)
+	}
+
+	for _, cond := range dp.Status.Conditions {
+		if cond.Type != appsv1.DeploymentAvailable {
+			continue
+		}
+		if cond.Status == corev1.ConditionTrue {
+			return common.NewCondition(
+				ConsolePluginAvailableCondition,
+				metav1.ConditionTrue,
+				"Available",
+				fmt.Sprintf("%d of %d replicas are available", dp.Status.AvailableReplicas, *dp.Spec.Replicas)), nil
+		}
+		return common.NewCondition(
+			ConsolePluginAvailableCondition,
+			metav1.ConditionFalse,
+			cond.Reason,
+			cond.Message), nil
+	}
+
+	return common.NewCondition(
+		ConsolePluginAvailableCondition,
+		metav1.ConditionFalse,
+		"Deploying",
+		"The ConsolePlugin Deployment does not report its availability yet"), nil
+}
+
+// getAvailableConditionNotDeployed reports that the console plugin is not
+// deployed. The availability is unknown rather than false, so that the
+// add-on is not reported as installing.
+func (r *ConsolePluginResourceReconciler) getAvailableConditionNotDeployed(reason string) metav1.Condition {
+	return common.NewCondition(
+		ConsolePluginAvailableCondition,
+		metav1.ConditionUnknown,
+		reason,
+		"ConsolePlugin is not deployed")
+}
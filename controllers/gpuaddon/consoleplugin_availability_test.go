@@ -0,0 +1,200 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("ConsolePlugin availability", func() {
+	common.ProcessConfig()
+
+	rrec := &ConsolePluginResourceReconciler{}
+	clusterVersion := &configv1.ClusterVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "version",
+		},
+		Status: configv1.ClusterVersionStatus{
+			History: []configv1.UpdateHistory{
+				{
+					State:   configv1.CompletedUpdate,
+					Version: "4.11.0",
+				},
+			},
+		},
+	}
+	console := &operatorv1.Console{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+	}
+
+	s := scheme.Scheme
+	Expect(configv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(operatorv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(appsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(policyv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	newGPUAddon := func(spec *addonv1alpha1.ConsolePluginSpec) *addonv1alpha1.GPUAddon {
+		return &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUAddonSpec{
+				ConsolePluginEnabled: true,
+				ConsolePlugin:        spec,
+			},
+		}
+	}
+
+	newClient := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clusterVersion, console).Build()
+	}
+
+	key := types.NamespacedName{Name: consolePluginName, Namespace: common.GlobalConfig.AddonNamespace}
+
+	It("should deploy highly available replicas by default", func() {
+		c := newClient()
+
+		_, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(nil))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		dp := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+		Expect(*dp.Spec.Replicas).To(Equal(int32(addonv1alpha1.DefaultConsolePluginReplicas)))
+
+		podSpec := dp.Spec.Template.Spec
+		Expect(podSpec.Containers[0].Image).To(Equal(common.GlobalConfig.ConsolePluginImage))
+		Expect(podSpec.Containers[0].ReadinessProbe.HTTPGet.Path).To(Equal("/plugin-manifest.json"))
+		Expect(podSpec.Containers[0].LivenessProbe.HTTPGet.Scheme).To(Equal(corev1.URISchemeHTTPS))
+		Expect(podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+		Expect(podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey).
+			To(Equal(corev1.LabelHostname))
+
+		pdb := &policyv1.PodDisruptionBudget{}
+		Expect(c.Get(context.TODO(), key, pdb)).ShouldNot(HaveOccurred())
+		Expect(pdb.Spec.MaxUnavailable.IntValue()).To(Equal(1))
+		Expect(pdb.Spec.Selector.MatchLabels).To(Equal(dp.Spec.Selector.MatchLabels))
+
+		deleted, err := rrec.Delete(context.TODO(), c)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+
+		err = c.Get(context.TODO(), key, &policyv1.PodDisruptionBudget{})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should apply the console plugin spec", func() {
+		c := newClient()
+		resources := &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("100m"),
+			},
+		}
+		tolerations := []corev1.Toleration{{
+			Key:      "node-role.kubernetes.io/infra",
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}}
+
+		_, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(&addonv1alpha1.ConsolePluginSpec{
+			Replicas:     pointer.Int32(3),
+			Resources:    resources,
+			NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+			Tolerations:  tolerations,
+			Image:        "quay.io/example/console-plugin:test",
+		}))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		dp := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+		Expect(*dp.Spec.Replicas).To(Equal(int32(3)))
+
+		podSpec := dp.Spec.Template.Spec
+		Expect(podSpec.Containers[0].Image).To(Equal("quay.io/example/console-plugin:test"))
+		Expect(podSpec.Containers[0].Resources.Limits.Cpu().String()).To(Equal("100m"))
+		Expect(podSpec.Containers[0].Resources.Requests).To(BeEmpty())
+		Expect(podSpec.NodeSelector).To(HaveKey("node-role.kubernetes.io/infra"))
+		Expect(podSpec.Tolerations).To(Equal(tolerations))
+	})
+
+	It("should report the availability of the Deployment", func() {
+		c := newClient()
+		gpuAddon := newGPUAddon(nil)
+
+		conditions, err := rrec.Reconcile(context.TODO(), c, gpuAddon)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(conditions).To(ContainElement(And(
+			HaveField("Type", ConsolePluginAvailableCondition),
+			HaveField("Status", metav1.ConditionFalse),
+			HaveField("Reason", "Deploying"))))
+
+		dp := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+		dp.Status.AvailableReplicas = 1
+		dp.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:    appsv1.DeploymentAvailable,
+			Status:  corev1.ConditionFalse,
+			Reason:  "MinimumReplicasUnavailable",
+			Message: "Deployment does not have minimum availability.",
+		}}
+		Expect(c.Status().Update(context.TODO(), dp)).ShouldNot(HaveOccurred())
+
+		conditions, err = rrec.Reconcile(context.TODO(), c, gpuAddon)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(conditions).To(ContainElement(And(
+			HaveField("Type", ConsolePluginAvailableCondition),
+			HaveField("Status", metav1.ConditionFalse),
+			HaveField("Reason", "MinimumReplicasUnavailable"))))
+
+		Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+		dp.Status.AvailableReplicas = 2
+		dp.Status.Conditions[0].Status = corev1.ConditionTrue
+		dp.Status.Conditions[0].Reason = "MinimumReplicasAvailable"
+		Expect(c.Status().Update(context.TODO(), dp)).ShouldNot(HaveOccurred())
+
+		conditions, err = rrec.Reconcile(context.TODO(), c, gpuAddon)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(conditions).To(ContainElement(And(
+			HaveField("Type", ConsolePluginAvailableCondition),
+			HaveField("Status", metav1.ConditionTrue),
+			HaveField("Message", "2 of 2 replicas are available"))))
+	})
+})
@@ -0,0 +1,257 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	consolePluginNginxConfigMapName = "console-plugin-nvidia-gpu-nginx-conf"
+
+	consolePluginNginxConfigKey = "nginx.conf"
+
+	consolePluginServingCertSecretName = "plugin-serving-cert"
+)
+
+// consolePluginConfigHashAnnotation is the annotation which records the hash
+// of the nginx configuration and of the serving certificate, so that the
+// Deployment rolls when either changes.
+func consolePluginConfigHashAnnotation() string {
+	return fmt.Sprintf("%s/config-hash", common.GlobalConfig.AddonID)
+}
+
+var consolePluginNginxTemplate = template.Must(template.New(consolePluginNginxConfigKey).Parse(`error_log /dev/stdout info;
+events {}
+http {
+    access_log         /dev/stdout;
+    include            /etc/nginx/mime.types;
+    default_type       application/octet-stream;
+    keepalive_timeout  65;
+    server_tokens      off;
+    server {
+        listen              {{ .Port }} ssl;
+        listen              [::]:{{ .Port }} ssl;
+        ssl_certificate     /var/serving-cert/tls.crt;
+        ssl_certificate_key /var/serving-cert/tls.key;
+        ssl_protocols       {{ .Protocols }};
+{{- if .Ciphers }}
+        ssl_ciphers         {{ .Ciphers }};
+        ssl_prefer_server_ciphers on;
+{{- end }}
+        root                /usr/share/nginx/html;
+    }
+}
+`))
+
+// tlsProtocols are the nginx protocols enabled from each minimum TLS version.
+var tlsProtocols = map[configv1.TLSProtocolVersion]string{
+	configv1.VersionTLS10: "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3",
+	configv1.VersionTLS11: "TLSv1.1 TLSv1.2 TLSv1.3",
+	configv1.VersionTLS12: "TLSv1.2 TLSv1.3",
+	configv1.VersionTLS13: "TLSv1.3",
+}
+
+func (r *ConsolePluginResourceReconciler) reconcileConsolePluginNginxConfigMap(
+	ctx context.Context,
+	c client.Client,
+	gpuAddon *addonv1alpha1.GPUAddon) error {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin nginx ConfigMap")
+
+	profile, err := apiServerTLSProfile(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      consolePluginNginxConfigMapName,
+			Namespace: gpuAddon.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, c, cm, func() error {
+		return r.setDesiredConsolePluginNginxConfigMap(c, cm, gpuAddon, profile)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	logger.Info("ConsolePlugin nginx ConfigMap reconciled successfully",
+		"name", cm.Name,
+		"namespace", cm.Namespace,
+		"minTLSVersion", profile.MinTLSVersion,
+		"result", res)
+
+	return nil
+}
+
+func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginNginxConfigMap(
+	c client.Client,
+	cm *corev1.ConfigMap,
+	gpuAddon *addonv1alpha1.GPUAddon,
+	profile *configv1.TLSProfileSpec) error {
+
+	if cm == nil {
+		return errors.New("configmap cannot be nil")
+	}
+
+	config, err := renderConsolePluginNginxConfig(profile)
+	if err != nil {
+		return err
+	}
+
+	cm.Data = map[string]string{
+		consolePluginNginxConfigKey: config,
+	}
+
+	return ctrl.SetControllerReference(gpuAddon, cm, c.Scheme())
+}
+
+// renderConsolePluginNginxConfig renders the nginx configuration of the
+// console plugin with the given TLS profile.
+func renderConsolePluginNginxConfig(profile *configv1.TLSProfileSpec) (string, error) {
+	protocols, ok := tlsProtocols[profile.MinTLSVersion]
+	if !ok {
+		return "", fmt.Errorf("unsupported minimum TLS version %q", profile.MinTLSVersion)
+	}
+
+	// The TLS 1.3 cipher suites are not configurable in nginx and are always
+	// enabled along with the protocol.
+	ciphers := []string{}
+	for _, cipher := range profile.Ciphers {
+		if !strings.HasPrefix(cipher, "TLS_") {
+			ciphers = append(ciphers, cipher)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := consolePluginNginxTemplate.Execute(&buf, struct {
+		Port      int32
+		Protocols string
+		Ciphers   string
+	}{
+		Port:      9443,
+		Protocols: protocols,
+		Ciphers:   strings.Join(ciphers, ":"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render the nginx configuration: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// apiServerTLSProfile returns the TLS security profile of the cluster
+// APIServer, which defaults to the Intermediate one.
+func apiServerTLSProfile(ctx context.Context, c client.Client) (*configv1.TLSProfileSpec, error) {
+	apiServer := &configv1.APIServer{}
+	err := c.Get(ctx, client.ObjectKey{Name: "cluster"}, apiServer)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get APIServer cluster: %w", err)
+	}
+
+	profile := apiServer.Spec.TLSSecurityProfile
+	switch {
+	case profile == nil:
+		return configv1.TLSProfiles[configv1.TLSProfileIntermediateType], nil
+	case profile.Type == configv1.TLSProfileCustomType && profile.Custom != nil:
+		return &profile.Custom.TLSProfileSpec, nil
+	case configv1.TLSProfiles[profile.Type] != nil:
+		return configv1.TLSProfiles[profile.Type], nil
+	default:
+		return configv1.TLSProfiles[configv1.TLSProfileIntermediateType], nil
+	}
+}
+
+// consolePluginConfigHash returns the hash of the nginx configuration and of
+// the serving certificate of the console plugin. The serving certificate may
+// not be issued yet.
+func consolePluginConfigHash(ctx context.Context, c client.Client, namespace string) (string, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{
+		Name:      consolePluginNginxConfigMapName,
+		Namespace: namespace,
+	}, cm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get ConfigMap %s: %w", consolePluginNginxConfigMapName, err)
+	}
+
+	secret := &corev1.Secret{}
+	err = c.Get(ctx, types.NamespacedName{
+		Name:      consolePluginServingCertSecretName,
+		Namespace: namespace,
+	}, secret)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get Secret %s: %w", consolePluginServingCertSecretName, err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(cm.Data[consolePluginNginxConfigKey]))
+
+	keys := []string{}
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(secret.Data[k])
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (r *ConsolePluginResourceReconciler) deleteConsolePluginNginxConfigMap(ctx context.Context, c client.Client) (bool, error) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.GlobalConfig.AddonNamespace,
+			Name:      consolePluginNginxConfigMapName,
+		},
+	}
+
+	if err := c.Delete(ctx, cm); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to delete ConsolePlugin nginx ConfigMap %s: %w", cm.Name, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("ConsolePlugin nginx configuration", func() {
+	common.ProcessConfig()
+
+	Context("Render", func() {
+		It("should follow the Intermediate TLS profile", func() {
+			config, err := renderConsolePluginNginxConfig(configv1.TLSProfiles[configv1.TLSProfileIntermediateType])
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(config).To(ContainSubstring("ssl_protocols       TLSv1.2 TLSv1.3;"))
+			Expect(config).To(ContainSubstring("ssl_ciphers         ECDHE-ECDSA-AES128-GCM-SHA256:"))
+			Expect(config).ToNot(ContainSubstring("TLS_AES_128_GCM_SHA256"))
+		})
+
+		It("should leave the TLS 1.3 cipher suites to nginx", func() {
+			config, err := renderConsolePluginNginxConfig(configv1.TLSProfiles[configv1.TLSProfileModernType])
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(config).To(ContainSubstring("ssl_protocols       TLSv1.3;"))
+			Expect(config).ToNot(ContainSubstring("ssl_ciphers"))
+		})
+
+		It("should reject an unknown minimum TLS version", func() {
+			_, err := renderConsolePluginNginxConfig(&configv1.TLSProfileSpec{MinTLSVersion: "VersionTLS14"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Reconcile", func() {
+		rrec := &ConsolePluginResourceReconciler{}
+		gpuAddon := &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUAddonSpec{
+				ConsolePluginEnabled: true,
+			},
+		}
+		clusterVersion := &configv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "version",
+			},
+			Status: configv1.ClusterVersionStatus{
+				History: []configv1.UpdateHistory{
+					{
+						State:   configv1.CompletedUpdate,
+						Version: "4.11.0",
+					},
+				},
+			},
+		}
+		console := &operatorv1.Console{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster",
+			},
+		}
+		apiServer := &configv1.APIServer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster",
+			},
+			Spec: configv1.APIServerSpec{
+				TLSSecurityProfile: &configv1.TLSSecurityProfile{
+					Type: configv1.TLSProfileCustomType,
+					Custom: &configv1.CustomTLSProfile{
+						TLSProfileSpec: configv1.TLSProfileSpec{
+							Ciphers:       []string{"ECDHE-RSA-AES256-GCM-SHA384"},
+							MinTLSVersion: configv1.VersionTLS12,
+						},
+					},
+				},
+			},
+		}
+		servingCert := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      consolePluginServingCertSecretName,
+				Namespace: gpuAddon.Namespace,
+			},
+			Data: map[string][]byte{
+				"tls.crt": []byte("crt"),
+				"tls.key": []byte("key"),
+			},
+		}
+
+		s := scheme.Scheme
+		Expect(configv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(operatorv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(policyv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(appsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+		key := types.NamespacedName{Name: consolePluginName, Namespace: gpuAddon.Namespace}
+
+		It("should render the nginx ConfigMap from the APIServer TLS profile and mount it", func() {
+			c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clusterVersion, console, apiServer).Build()
+
+			_, err := rrec.Reconcile(context.TODO(), c, gpuAddon)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{
+				Name:      consolePluginNginxConfigMapName,
+				Namespace: gpuAddon.Namespace,
+			}, cm)).ShouldNot(HaveOccurred())
+			Expect(cm.Data[consolePluginNginxConfigKey]).To(ContainSubstring("ssl_ciphers         ECDHE-RSA-AES256-GCM-SHA384;"))
+
+			dp := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+			Expect(dp.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("VolumeSource.ConfigMap.LocalObjectReference.Name",
+				consolePluginNginxConfigMapName)))
+			Expect(dp.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "nginx-conf",
+				ReadOnly:  true,
+				MountPath: "/etc/nginx/nginx.conf",
+				SubPath:   consolePluginNginxConfigKey,
+			}))
+
+			svc := &corev1.Service{}
+			Expect(c.Get(context.TODO(), key, svc)).ShouldNot(HaveOccurred())
+			Expect(svc.Annotations).To(HaveKeyWithValue("service.beta.openshift.io/serving-cert-secret-name",
+				consolePluginServingCertSecretName))
+		})
+
+		It("should roll the Deployment when the serving certificate or the configuration changes", func() {
+			c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clusterVersion, console, servingCert).Build()
+
+			hash := func() string {
+				_, err := rrec.Reconcile(context.TODO(), c, gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				dp := &appsv1.Deployment{}
+				Expect(c.Get(context.TODO(), key, dp)).ShouldNot(HaveOccurred())
+				Expect(dp.Spec.Template.Annotations).To(HaveKey(consolePluginConfigHashAnnotation()))
+				return dp.Spec.Template.Annotations[consolePluginConfigHashAnnotation()]
+			}
+
+			initial := hash()
+			Expect(hash()).To(Equal(initial))
+
+			secret := &corev1.Secret{}
+			Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(servingCert), secret)).ShouldNot(HaveOccurred())
+			secret.Data["tls.crt"] = []byte("renewed")
+			Expect(c.Update(context.TODO(), secret)).ShouldNot(HaveOccurred())
+
+			renewed := hash()
+			Expect(renewed).ToNot(Equal(initial))
+
+			custom := apiServer.DeepCopy()
+			custom.ResourceVersion = ""
+			Expect(c.Create(context.TODO(), custom)).ShouldNot(HaveOccurred())
+			Expect(hash()).ToNot(Equal(renewed))
+		})
+	})
+})
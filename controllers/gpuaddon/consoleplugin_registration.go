@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	ConsolePluginRegisteredCondition = "ConsolePluginRegistered"
+
+	clusterConsoleName = "cluster"
+)
+
+// consolePluginRegistrationOptOutAnnotation is the annotation which opts the
+// cluster Console out of the registration of the console plugin, for clusters
+// where the admins manage the enabled plugins themselves.
+func consolePluginRegistrationOptOutAnnotation() string {
+	return fmt.Sprintf("%s/skip-console-plugin-registration", common.GlobalConfig.AddonID)
+}
+
+// clusterConsolePredicate filters the cluster Console events down to the
+// changes of its plugins or of the registration opt-out.
+var clusterConsolePredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldConsole, ok := e.ObjectOld.(*operatorv1.Console)
+		if !ok {
+			return false
+		}
+		newConsole, ok := e.ObjectNew.(*operatorv1.Console)
+		if !ok {
+			return false
+		}
+		return !reflect.DeepEqual(oldConsole.Spec.Plugins, newConsole.Spec.Plugins) ||
+			registrationOptedOut(oldConsole) != registrationOptedOut(newConsole)
+	},
+}
+
+func registrationOptedOut(console *operatorv1.Console) bool {
+	return console.Annotations[consolePluginRegistrationOptOutAnnotation()] == "true"
+}
+
+// reconcileClusterConsole adds the console plugin to the plugins enabled in
+// the cluster Console, or removes it, unless the cluster Console opted out.
+func (r *ConsolePluginResourceReconciler) reconcileClusterConsole(
+	ctx context.Context,
+	c client.Client,
+	register bool) (metav1.Condition, error) {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin Cluster Console")
+	console := &operatorv1.Console{}
+	err := c.Get(ctx, client.ObjectKey{
+		Name: clusterConsoleName,
+	}, console)
+
+	if err != nil {
+		if !register && k8serrors.IsNotFound(err) {
+			return r.getRegisteredConditionNotDeployed("Disabled"), nil
+		}
+		return metav1.Condition{}, err
+	}
+
+	registered := common.SliceContainsString(console.Spec.Plugins, consolePluginName)
+
+	if registrationOptedOut(console) {
+		logger.Info("ConsolePlugin registration is managed by the cluster admins",
+			"name", console.Name,
+			"registered", registered)
+
+		return r.getRegisteredConditionOptedOut(registered), nil
+	}
+
+	patched := console.DeepCopy()
+
+	if register && !registered {
+		patched.Spec.Plugins = append(patched.Spec.Plugins, consolePluginName)
+	} else if !register && registered {
+		patched.Spec.Plugins = common.SliceRemoveString(patched.Spec.Plugins, consolePluginName)
+	}
+
+	// The plugins are patched as a whole, hence the optimistic lock keeps the
+	// changes made meanwhile by the admins or other operators. On conflict,
+	// the returned error requeues the GPUAddon, which is then reconciled with
+	// the updated plugins.
+	if !reflect.DeepEqual(patched.Spec.Plugins, console.Spec.Plugins) {
+		patch := client.MergeFromWithOptions(console, client.MergeFromWithOptimisticLock{})
+		if err := c.Patch(ctx, patched, patch); err != nil {
+			if k8serrors.IsConflict(err) {
+				logger.Info("Cluster Console changed meanwhile, requeuing", "name", console.Name)
+			}
+			return metav1.Condition{}, fmt.Errorf("failed to patch Console %s: %w", console.Name, err)
+		}
+	}
+
+	logger.Info("ConsolePlugin Cluster Console reconciled successfully",
+		"name", patched.Name,
+		"plugins", patched.Spec.Plugins)
+
+	if !register {
+		return r.getRegisteredConditionNotDeployed("Disabled"), nil
+	}
+
+	return common.NewCondition(
+		ConsolePluginRegisteredCondition,
+		metav1.ConditionTrue,
+		"Registered",
+		"ConsolePlugin is enabled in the cluster Console"), nil
+}
+
+// getRegisteredConditionOptedOut reports the registration of the console
+// plugin made by the cluster admins. A missing registration is not an error
+// of the add-on, hence its status is unknown rather than false.
+func (r *ConsolePluginResourceReconciler) getRegisteredConditionOptedOut(registered bool) metav1.Condition {
+	if registered {
+		return common.NewCondition(
+			ConsolePluginRegisteredCondition,
+			metav1.ConditionTrue,
+			"ManagedExternally",
+			"ConsolePlugin is enabled in the cluster Console by the cluster admins")
+	}
+
+	return common.NewCondition(
+		ConsolePluginRegisteredCondition,
+		metav1.ConditionUnknown,
+		"ManagedExternally",
+		"ConsolePlugin registration is managed by the cluster admins and it is not enabled in the cluster Console")
+}
+
+func (r *ConsolePluginResourceReconciler) getRegisteredConditionNotDeployed(reason string) metav1.Condition {
+	return common.NewCondition(
+		ConsolePluginRegisteredCondition,
+		metav1.ConditionUnknown,
+		reason,
+		"ConsolePlugin is not deployed")
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("ConsolePlugin registration", func() {
+	common.ProcessConfig()
+
+	rrec := &ConsolePluginResourceReconciler{}
+	clusterVersion := &configv1.ClusterVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "version",
+		},
+		Status: configv1.ClusterVersionStatus{
+			History: []configv1.UpdateHistory{
+				{
+					State:   configv1.CompletedUpdate,
+					Version: "4.11.0",
+				},
+			},
+		},
+	}
+
+	s := scheme.Scheme
+	Expect(configv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(operatorv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(appsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(policyv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	newGPUAddon := func(enabled bool) *addonv1alpha1.GPUAddon {
+		return &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUAddonSpec{
+				ConsolePluginEnabled: enabled,
+			},
+		}
+	}
+
+	newConsole := func(optOut bool, plugins ...string) *operatorv1.Console {
+		console := &operatorv1.Console{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster",
+			},
+			Spec: operatorv1.ConsoleSpec{
+				Plugins: plugins,
+			},
+		}
+		if optOut {
+			console.Annotations = map[string]string{
+				consolePluginRegistrationOptOutAnnotation(): "true",
+			}
+		}
+		return console
+	}
+
+	getPlugins := func(c client.Client) []string {
+		console := &operatorv1.Console{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "cluster"}, console)).ShouldNot(HaveOccurred())
+		return console.Spec.Plugins
+	}
+
+	registeredCondition := func(conditions []metav1.Condition) metav1.Condition {
+		for _, condition := range conditions {
+			if condition.Type == ConsolePluginRegisteredCondition {
+				return condition
+			}
+		}
+		Fail("the ConsolePluginRegistered condition is not reported")
+		return metav1.Condition{}
+	}
+
+	It("should re-add the plugin removed from the cluster Console", func() {
+		c := fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(clusterVersion, newConsole(false, "other-plugin")).
+			Build()
+
+		conditions, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(true))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(getPlugins(c)).To(Equal([]string{"other-plugin", consolePluginName}))
+
+		condition := registeredCondition(conditions)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("Registered"))
+	})
+
+	It("should remove the plugin from the cluster Console when disabled", func() {
+		c := fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(clusterVersion, newConsole(false, consolePluginName, "other-plugin")).
+			Build()
+
+		conditions, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(false))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(getPlugins(c)).To(Equal([]string{"other-plugin"}))
+
+		condition := registeredCondition(conditions)
+		Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(condition.Reason).To(Equal("Disabled"))
+	})
+
+	It("should not overwrite the plugins changed meanwhile", func() {
+		c := &racingConsoleClient{
+			Client: fake.NewClientBuilder().WithScheme(s).
+				WithRuntimeObjects(newConsole(false, "other-plugin")).
+				Build(),
+			plugin: "late-plugin",
+		}
+
+		_, err := rrec.reconcileClusterConsole(context.TODO(), c, true)
+		Expect(k8serrors.IsConflict(err)).To(BeTrue())
+		Expect(getPlugins(c)).To(Equal([]string{"other-plugin", "late-plugin"}))
+
+		_, err = rrec.reconcileClusterConsole(context.TODO(), c, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(getPlugins(c)).To(Equal([]string{"other-plugin", "late-plugin", consolePluginName}))
+	})
+
+	It("should unregister the plugin when it is deleted", func() {
+		c := fake.NewClientBuilder().WithScheme(s).
+			WithRuntimeObjects(newConsole(false, consolePluginName)).
+			Build()
+
+		_, err := rrec.Delete(context.TODO(), c)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(getPlugins(c)).To(BeEmpty())
+	})
+
+	Context("when the cluster Console opted out", func() {
+		It("should not register the plugin", func() {
+			c := fake.NewClientBuilder().WithScheme(s).
+				WithRuntimeObjects(clusterVersion, newConsole(true, "other-plugin")).
+				Build()
+
+			conditions, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(true))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(getPlugins(c)).To(Equal([]string{"other-plugin"}))
+
+			condition := registeredCondition(conditions)
+			Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+			Expect(condition.Reason).To(Equal("ManagedExternally"))
+		})
+
+		It("should report the registration made by the admins", func() {
+			c := fake.NewClientBuilder().WithScheme(s).
+				WithRuntimeObjects(clusterVersion, newConsole(true, consolePluginName)).
+				Build()
+
+			conditions, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(true))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			condition := registeredCondition(conditions)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal("ManagedExternally"))
+		})
+
+		It("should not unregister the plugin when disabled", func() {
+			c := fake.NewClientBuilder().WithScheme(s).
+				WithRuntimeObjects(clusterVersion, newConsole(true, consolePluginName)).
+				Build()
+
+			_, err := rrec.Reconcile(context.TODO(), c, newGPUAddon(false))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(getPlugins(c)).To(Equal([]string{consolePluginName}))
+		})
+	})
+
+	Context("Watch predicate", func() {
+		It("should only accept the changes of the plugins and of the opt-out", func() {
+			old := newConsole(false, consolePluginName)
+
+			updated := old.DeepCopy()
+			updated.Spec.LogLevel = operatorv1.Debug
+			Expect(clusterConsolePredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: updated})).To(BeFalse())
+
+			updated = old.DeepCopy()
+			updated.Spec.Plugins = nil
+			Expect(clusterConsolePredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: updated})).To(BeTrue())
+
+			Expect(clusterConsolePredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: newConsole(true, consolePluginName)})).
+				To(BeTrue())
+		})
+	})
+})
+
+// racingConsoleClient adds a plugin to the cluster Console right after the
+// first time it is read, as if it was changed meanwhile.
+type racingConsoleClient struct {
+	client.Client
+	plugin string
+	raced  bool
+}
+
+func (c *racingConsoleClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.Client.Get(ctx, key, obj); err != nil {
+		return err
+	}
+
+	if _, ok := obj.(*operatorv1.Console); !ok || c.raced {
+		return nil
+	}
+	c.raced = true
+
+	console := obj.DeepCopyObject().(*operatorv1.Console)
+	console.Spec.Plugins = append(console.Spec.Plugins, c.plugin)
+	return c.Client.Update(ctx, console)
+}
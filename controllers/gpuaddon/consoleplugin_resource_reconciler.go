@@ -22,12 +22,14 @@ import (
 	"fmt"
 
 	consolev1alpha1 "github.com/openshift/api/console/v1alpha1"
-	operatorv1 "github.com/openshift/api/operator/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
@@ -35,8 +37,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
 )
 
@@ -45,12 +49,33 @@ const (
 
 	consolePluginName = "console-plugin-nvidia-gpu"
 
+	consolePluginDisplayName = "NVIDIA GPU"
+
+	// consolePluginPrometheusProxyAlias is the alias of the proxy to the
+	// add-on Prometheus, which the console exposes on
+	// /api/proxy/plugin/console-plugin-nvidia-gpu/prometheus/.
+	consolePluginPrometheusProxyAlias = "prometheus"
+
 	ocpVersion4_10 = "4.10"
 )
 
+// consolePluginV1 is the ConsolePlugin API served from OpenShift 4.12 on. The
+// vendored OpenShift API does not provide its types, so it is handled as
+// unstructured.
+var consolePluginV1 = schema.GroupVersionKind{
+	Group:   consolev1alpha1.GroupName,
+	Version: "v1",
+	Kind:    "ConsolePlugin",
+}
+
 type ConsolePluginResourceReconciler struct{}
 
 var _ ResourceReconciler = &ConsolePluginResourceReconciler{}
+var _ CapabilityDependentReconciler = &ConsolePluginResourceReconciler{}
+
+func (r *ConsolePluginResourceReconciler) Name() string {
+	return "ConsolePlugin"
+}
 
 func (r *ConsolePluginResourceReconciler) Reconcile(
 	ctx context.Context,
@@ -60,6 +85,20 @@ func (r *ConsolePluginResourceReconciler) Reconcile(
 	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin")
 	conditions := []metav1.Condition{}
 
+	if !common.IsOpenShift() {
+		conditions = append(conditions,
+			r.getDeployedConditionPlatformNotSupported(),
+			r.getAvailableConditionNotDeployed("NotSupported"),
+			r.getRegisteredConditionNotDeployed("NotSupported"))
+
+		logger.Info("ConsolePlugin will not be reconciled as the cluster is not OpenShift",
+			"name", consolePluginName,
+			"namespace", gpuAddon.Namespace,
+			"platform", common.ClusterPlatform)
+
+		return conditions, nil
+	}
+
 	supported, err := common.IsOpenShiftVersionAtLeast(client, ocpVersion4_10)
 	if err != nil {
 		conditions = append(conditions, r.getDeployedConditionFailed(err))
@@ -67,7 +106,10 @@ func (r *ConsolePluginResourceReconciler) Reconcile(
 	}
 
 	if !supported {
-		conditions = append(conditions, r.getDeployedConditionNotSupported())
+		conditions = append(conditions,
+			r.getDeployedConditionNotSupported(),
+			r.getAvailableConditionNotDeployed("NotSupported"),
+			r.getRegisteredConditionNotDeployed("NotSupported"))
 
 		logger.Info("ConsolePlugin will not be reconciled as OpenShift version is <4.10",
 			"name", consolePluginName,
@@ -83,7 +125,10 @@ func (r *ConsolePluginResourceReconciler) Reconcile(
 			return conditions, err
 		}
 
-		conditions = append(conditions, r.getDeployedConditionSuccess())
+		conditions = append(conditions,
+			r.getDeployedConditionSuccess(),
+			r.getAvailableConditionNotDeployed("Disabled"),
+			r.getRegisteredConditionNotDeployed("Disabled"))
 
 		logger.Info("ConsolePlugin reconciled successfully",
 			"name", consolePluginName,
@@ -93,11 +138,21 @@ func (r *ConsolePluginResourceReconciler) Reconcile(
 		return conditions, nil
 	}
 
+	if err := r.reconcileConsolePluginNginxConfigMap(ctx, client, gpuAddon); err != nil {
+		conditions = append(conditions, r.getDeployedConditionFailed(err))
+		return conditions, err
+	}
+
 	if err := r.reconcileConsolePluginDeployment(ctx, client, gpuAddon); err != nil {
 		conditions = append(conditions, r.getDeployedConditionFailed(err))
 		return conditions, err
 	}
 
+	if err := r.reconcileConsolePluginPodDisruptionBudget(ctx, client, gpuAddon); err != nil {
+		conditions = append(conditions, r.getDeployedConditionFailed(err))
+		return conditions, err
+	}
+
 	if err := r.reconcileConsolePluginService(ctx, client, gpuAddon); err != nil {
 		conditions = append(conditions, r.getDeployedConditionFailed(err))
 		return conditions, err
@@ -108,19 +163,50 @@ func (r *ConsolePluginResourceReconciler) Reconcile(
 		return conditions, err
 	}
 
-	if err := r.patchClusterConsole(ctx, client, gpuAddon); err != nil {
+	registered, err := r.reconcileClusterConsole(ctx, client, true)
+	if err != nil {
+		conditions = append(conditions, r.getDeployedConditionFailed(err))
+		return conditions, err
+	}
+
+	available, err := r.getAvailableCondition(ctx, client, gpuAddon)
+	if err != nil {
 		conditions = append(conditions, r.getDeployedConditionFailed(err))
 		return conditions, err
 	}
 
-	conditions = append(conditions, r.getDeployedConditionSuccess())
+	conditions = append(conditions, r.getDeployedConditionSuccess(), available, registered)
 
 	return conditions, nil
 }
 
+func (r *ConsolePluginResourceReconciler) RequiredCapabilities() []common.Capability {
+	// The ConsolePlugin is not deployed at all on non-OpenShift clusters.
+	if !common.IsOpenShift() {
+		return nil
+	}
+
+	return []common.Capability{common.CapabilityConsolePlugin}
+}
+
+func (r *ConsolePluginResourceReconciler) ConditionType() string {
+	return ConsolePluginDeployedCondition
+}
+
 func (r *ConsolePluginResourceReconciler) Delete(ctx context.Context, c client.Client) (bool, error) {
+	// The ConsolePlugin components are never deployed outside of OpenShift.
+	if !common.IsOpenShift() {
+		return true, nil
+	}
+
+	// The plugin is unregistered first, so that the console does not try
+	// to load it once its components are deleted.
+	if _, err := r.reconcileClusterConsole(ctx, c, false); err != nil {
+		return false, err
+	}
+
 	var err error
-	deleted := make([]bool, 3)
+	deleted := make([]bool, 5)
 
 	deleted[0], err = r.deleteConsolePluginCR(ctx, c)
 	if err != nil {
@@ -137,6 +223,16 @@ func (r *ConsolePluginResourceReconciler) Delete(ctx context.Context, c client.C
 		return false, err
 	}
 
+	deleted[3], err = r.deleteConsolePluginNginxConfigMap(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
+	deleted[4], err = r.deleteConsolePluginPodDisruptionBudget(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
 	for i := range deleted {
 		if !deleted[i] {
 			return false, nil
@@ -152,28 +248,23 @@ func (r *ConsolePluginResourceReconciler) reconcileConsolePluginCR(
 	gpuAddon *addonv1alpha1.GPUAddon) error {
 
 	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin CR")
-	existingCP := &consolev1alpha1.ConsolePlugin{}
-	err := c.Get(ctx, client.ObjectKey{
-		Name: consolePluginName,
-	}, existingCP)
 
-	exists := !k8serrors.IsNotFound(err)
-	if err != nil && !k8serrors.IsNotFound(err) {
+	version, err := consolePluginAPIVersion(ctx, c)
+	if err != nil {
 		return err
 	}
 
-	cp := &consolev1alpha1.ConsolePlugin{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: consolePluginName,
-		},
+	// The Prometheus proxy follows the monitoring mode, as the add-on
+	// Prometheus only exists in the Dedicated mode.
+	prometheus, err := monitoring.GetQueryService(ctx, c)
+	if err != nil {
+		return err
 	}
 
-	if exists {
-		cp = existingCP
-	}
+	cp := newConsolePlugin(version)
 
-	res, err := controllerutil.CreateOrPatch(context.TODO(), c, cp, func() error {
-		return r.setDesiredConsolePlugin(cp, gpuAddon)
+	res, err := controllerutil.CreateOrPatch(ctx, c, cp, func() error {
+		return r.setDesiredConsolePlugin(cp, gpuAddon, prometheus)
 	})
 
 	if err != nil {
@@ -181,43 +272,63 @@ func (r *ConsolePluginResourceReconciler) reconcileConsolePluginCR(
 	}
 
 	logger.Info("ConsolePlugin CR reconciled successfully",
-		"name", cp.Name,
+		"name", cp.GetName(),
+		"version", version,
 		"result", res)
 
 	return nil
 }
 
-func (r *ConsolePluginResourceReconciler) patchClusterConsole(
-	ctx context.Context,
-	c client.Client,
-	gpuAddon *addonv1alpha1.GPUAddon) error {
-
-	logger := log.FromContext(ctx, "Reconcile Step", "ConsolePlugin Patch Cluster Console")
-	console := &operatorv1.Console{}
+// consolePluginAPIVersion returns the version of the ConsolePlugin API to use,
+// that is v1 when the API server serves it and v1alpha1 otherwise.
+func consolePluginAPIVersion(ctx context.Context, c client.Client) (string, error) {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
 	err := c.Get(ctx, client.ObjectKey{
-		Name: "cluster",
-	}, console)
+		Name: string(common.CapabilityConsolePlugin),
+	}, crd)
 
+	if k8serrors.IsNotFound(err) {
+		return consolev1alpha1.GroupVersion.Version, nil
+	}
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to get CRD %s: %w", common.CapabilityConsolePlugin, err)
 	}
 
-	patched := console.DeepCopy()
-
-	exists := common.SliceContainsString(patched.Spec.Plugins, consolePluginName)
-	if !exists {
-		patched.Spec.Plugins = append(patched.Spec.Plugins, consolePluginName)
+	return servedConsolePluginVersion(crd), nil
+}
 
-		if err := c.Patch(ctx, patched, client.MergeFrom(console)); err != nil {
-			return err
+// servedConsolePluginVersion returns the version of the ConsolePlugin API to
+// use given its CRD.
+func servedConsolePluginVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Name == consolePluginV1.Version && v.Served {
+			return consolePluginV1.Version
 		}
 	}
 
-	logger.Info("ConsolePlugin Cluster Console reconciled successfully",
-		"name", patched.Name,
-		"plugins", patched.Spec.Plugins)
+	return consolev1alpha1.GroupVersion.Version
+}
 
-	return nil
+// consolePluginSource returns the source of the ConsolePlugins of the version
+// served by the given CRD.
+func consolePluginSource(crd *apiextensionsv1.CustomResourceDefinition) source.Source {
+	return &source.Kind{Type: newConsolePlugin(servedConsolePluginVersion(crd))}
+}
+
+// newConsolePlugin returns an empty ConsolePlugin of the given API version.
+func newConsolePlugin(version string) client.Object {
+	if version == consolePluginV1.Version {
+		cp := &unstructured.Unstructured{}
+		cp.SetGroupVersionKind(consolePluginV1)
+		cp.SetName(consolePluginName)
+		return cp
+	}
+
+	return &consolev1alpha1.ConsolePlugin{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: consolePluginName,
+		},
+	}
 }
 
 func (r *ConsolePluginResourceReconciler) reconcileConsolePluginDeployment(
@@ -248,8 +359,13 @@ func (r *ConsolePluginResourceReconciler) reconcileConsolePluginDeployment(
 		dp = existingDP
 	}
 
+	hash, err := consolePluginConfigHash(ctx, client, gpuAddon.Namespace)
+	if err != nil {
+		return err
+	}
+
 	res, err := controllerutil.CreateOrPatch(context.TODO(), client, dp, func() error {
-		return r.setDesiredConsolePluginDeployment(client, dp, gpuAddon)
+		return r.setDesiredConsolePluginDeployment(client, dp, gpuAddon, hash)
 	})
 
 	if err != nil {
@@ -311,7 +427,8 @@ func (r *ConsolePluginResourceReconciler) reconcileConsolePluginService(
 func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 	client client.Client,
 	dp *appsv1.Deployment,
-	gpuAddon *addonv1alpha1.GPUAddon) error {
+	gpuAddon *addonv1alpha1.GPUAddon,
+	configHash string) error {
 
 	if dp == nil {
 		return errors.New("deployment cannot be nil")
@@ -325,6 +442,7 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 
 	twentyFivePercent := intstr.FromString("25%")
 	dp.Spec = appsv1.DeploymentSpec{
+		Replicas: pointer.Int32(gpuAddon.Spec.ConsolePluginReplicas()),
 		Strategy: appsv1.DeploymentStrategy{
 			Type: appsv1.RollingUpdateDeploymentStrategyType,
 			RollingUpdate: &appsv1.RollingUpdateDeployment{
@@ -341,6 +459,9 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 	dp.Spec.Template = corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: labels,
+			Annotations: map[string]string{
+				consolePluginConfigHashAnnotation(): configHash,
+			},
 		},
 	}
 
@@ -350,7 +471,7 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 			Name: "plugin-serving-cert",
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName:  "plugin-serving-cert",
+					SecretName:  consolePluginServingCertSecretName,
 					DefaultMode: &defaultMode,
 				},
 			},
@@ -360,7 +481,7 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: "nginx-conf",
+						Name: consolePluginNginxConfigMapName,
 					},
 					DefaultMode: &defaultMode,
 				},
@@ -372,6 +493,9 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 		Name: consolePluginName,
 	}
 	consolePluginContainer.Image = common.GlobalConfig.ConsolePluginImage
+	if spec := gpuAddon.Spec.ConsolePlugin; spec != nil && spec.Image != "" {
+		consolePluginContainer.Image = spec.Image
+	}
 	consolePluginContainer.ImagePullPolicy = corev1.PullAlways
 	consolePluginContainer.Ports = []corev1.ContainerPort{
 		{
@@ -389,6 +513,30 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 			corev1.ResourceMemory: resource.MustParse("200Mi"),
 		},
 	}
+	if spec := gpuAddon.Spec.ConsolePlugin; spec != nil && spec.Resources != nil {
+		consolePluginContainer.Resources = *spec.Resources.DeepCopy()
+	}
+
+	// The console loads the plugin manifest, thus the plugin is ready once
+	// nginx serves it.
+	probe := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   "/plugin-manifest.json",
+			Port:   intstr.FromInt(9443),
+			Scheme: corev1.URISchemeHTTPS,
+		},
+	}
+	consolePluginContainer.ReadinessProbe = &corev1.Probe{
+		ProbeHandler:     probe,
+		PeriodSeconds:    10,
+		FailureThreshold: 3,
+	}
+	consolePluginContainer.LivenessProbe = &corev1.Probe{
+		ProbeHandler:        probe,
+		InitialDelaySeconds: 10,
+		PeriodSeconds:       20,
+		FailureThreshold:    3,
+	}
 
 	consolePluginContainer.SecurityContext = &corev1.SecurityContext{
 		AllowPrivilegeEscalation: pointer.Bool(false),
@@ -400,6 +548,12 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 			ReadOnly:  true,
 			MountPath: "/var/serving-cert",
 		},
+		{
+			Name:      "nginx-conf",
+			ReadOnly:  true,
+			MountPath: "/etc/nginx/nginx.conf",
+			SubPath:   consolePluginNginxConfigKey,
+		},
 	}
 	containers := []corev1.Container{
 		consolePluginContainer,
@@ -410,32 +564,111 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginDeployment(
 		Volumes:       volumes,
 		RestartPolicy: corev1.RestartPolicyAlways,
 		DNSPolicy:     corev1.DNSClusterFirst,
+		// The replicas are spread over the nodes, so that draining a node
+		// does not make the console plugin unavailable.
+		Affinity: &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: labels,
+						},
+						TopologyKey: corev1.LabelHostname,
+					},
+				}},
+			},
+		},
+	}
+	if spec := gpuAddon.Spec.ConsolePlugin; spec != nil {
+		dp.Spec.Template.Spec.NodeSelector = spec.NodeSelector
+		dp.Spec.Template.Spec.Tolerations = spec.Tolerations
 	}
 
 	return ctrl.SetControllerReference(gpuAddon, dp, client.Scheme())
 }
 
 func (r *ConsolePluginResourceReconciler) setDesiredConsolePlugin(
-	cp *consolev1alpha1.ConsolePlugin,
-	gpuAddon *addonv1alpha1.GPUAddon) error {
+	cp client.Object,
+	gpuAddon *addonv1alpha1.GPUAddon,
+	prometheus monitoring.QueryService) error {
+
+	switch cp := cp.(type) {
+	case *consolev1alpha1.ConsolePlugin:
+		if cp == nil {
+			return errors.New("consoleplugin cannot be nil")
+		}
 
-	if cp == nil {
-		return errors.New("consoleplugin cannot be nil")
-	}
+		// The v1alpha1 API has no i18n settings.
+		cp.Spec = consolev1alpha1.ConsolePluginSpec{
+			DisplayName: consolePluginDisplayName,
+			Service: consolev1alpha1.ConsolePluginService{
+				Name:      consolePluginName,
+				Namespace: gpuAddon.Namespace,
+				Port:      9443,
+				BasePath:  "/",
+			},
+			Proxy: []consolev1alpha1.ConsolePluginProxy{
+				{
+					Type:  consolev1alpha1.ProxyTypeService,
+					Alias: consolePluginPrometheusProxyAlias,
+					Service: consolev1alpha1.ConsolePluginProxyServiceConfig{
+						Name:      prometheus.Name,
+						Namespace: prometheus.Namespace,
+						Port:      prometheus.Port,
+					},
+					Authorize: true,
+				},
+			},
+		}
+	case *unstructured.Unstructured:
+		if cp == nil {
+			return errors.New("consoleplugin cannot be nil")
+		}
 
-	cp.Spec = consolev1alpha1.ConsolePluginSpec{
-		DisplayName: "Console Plugin NVIDIA GPU Template",
-		Service: consolev1alpha1.ConsolePluginService{
-			Name:      consolePluginName,
-			Namespace: gpuAddon.Namespace,
-			Port:      9443,
-			BasePath:  "/",
-		},
+		return unstructured.SetNestedField(cp.Object, desiredConsolePluginV1Spec(gpuAddon, prometheus), "spec")
+	default:
+		return fmt.Errorf("unexpected consoleplugin type %T", cp)
 	}
 
 	return nil
 }
 
+// desiredConsolePluginV1Spec returns the spec of the v1 ConsolePlugin. The
+// Prometheus proxy forwards the token of the console user, which kube-rbac-proxy
+// authorizes against the API server.
+func desiredConsolePluginV1Spec(gpuAddon *addonv1alpha1.GPUAddon, prometheus monitoring.QueryService) map[string]interface{} {
+	return map[string]interface{}{
+		"displayName": consolePluginDisplayName,
+		"backend": map[string]interface{}{
+			"type": "Service",
+			"service": map[string]interface{}{
+				"name":      consolePluginName,
+				"namespace": gpuAddon.Namespace,
+				"port":      int64(9443),
+				"basePath":  "/",
+			},
+		},
+		"proxy": []interface{}{
+			map[string]interface{}{
+				"alias":         consolePluginPrometheusProxyAlias,
+				"authorization": "UserToken",
+				"endpoint": map[string]interface{}{
+					"type": "Service",
+					"service": map[string]interface{}{
+						"name":      prometheus.Name,
+						"namespace": prometheus.Namespace,
+						"port":      int64(prometheus.Port),
+					},
+				},
+			},
+		},
+		"i18n": map[string]interface{}{
+			"loadType": "Preload",
+		},
+	}
+}
+
 func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginService(
 	client client.Client,
 	s *corev1.Service,
@@ -446,7 +679,7 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginService(
 	}
 
 	s.ObjectMeta.Annotations = map[string]string{
-		"service.alpha.openshift.io/serving-cert-secret-name": "plugin-serving-cert",
+		"service.beta.openshift.io/serving-cert-secret-name": consolePluginServingCertSecretName,
 	}
 
 	s.Spec = corev1.ServiceSpec{
@@ -468,17 +701,18 @@ func (r *ConsolePluginResourceReconciler) setDesiredConsolePluginService(
 }
 
 func (r *ConsolePluginResourceReconciler) deleteConsolePluginCR(ctx context.Context, c client.Client) (bool, error) {
-	cp := &consolev1alpha1.ConsolePlugin{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: consolePluginName,
-		},
+	version, err := consolePluginAPIVersion(ctx, c)
+	if err != nil {
+		return false, err
 	}
 
+	cp := newConsolePlugin(version)
+
 	if err := c.Delete(ctx, cp); err != nil {
 		if k8serrors.IsNotFound(err) {
 			return true, nil
 		}
-		return false, fmt.Errorf("failed to delete ConsolePlugin CR %s: %w", cp.Name, err)
+		return false, fmt.Errorf("failed to delete ConsolePlugin CR %s: %w", cp.GetName(), err)
 	}
 
 	return false, nil
@@ -543,3 +777,11 @@ func (r *ConsolePluginResourceReconciler) getDeployedConditionNotSupported() met
 		"NotSupported",
 		"ConsolePlugin is not supported when OpenShift version <4.10")
 }
+
+func (r *ConsolePluginResourceReconciler) getDeployedConditionPlatformNotSupported() metav1.Condition {
+	return common.NewCondition(
+		ConsolePluginDeployedCondition,
+		metav1.ConditionTrue,
+		"NotSupported",
+		fmt.Sprintf("ConsolePlugin is not supported on %s clusters", common.ClusterPlatform))
+}
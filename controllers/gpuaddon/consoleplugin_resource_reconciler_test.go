@@ -25,11 +25,15 @@ import (
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -74,6 +78,9 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 		Expect(appsv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 		Expect(corev1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 		Expect(configv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(apiextensionsv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(policyv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(addonv1alpha1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 
 		var cp consolev1alpha1.ConsolePlugin
 		var dp appsv1.Deployment
@@ -124,8 +131,54 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 				Expect(err).Should(HaveOccurred())
 				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 
-				Expect(conditions).To(HaveLen(1))
+				Expect(conditions).To(HaveLen(3))
 				Expect(conditions[0].Reason).To(Equal("NotSupported"))
+				Expect(conditions[1].Type).To(Equal(ConsolePluginAvailableCondition))
+				Expect(conditions[1].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[1].Reason).To(Equal("NotSupported"))
+				Expect(conditions[2].Type).To(Equal(ConsolePluginRegisteredCondition))
+				Expect(conditions[2].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[2].Reason).To(Equal("NotSupported"))
+			})
+		})
+
+		Context("on a Kubernetes cluster", func() {
+			BeforeEach(func() {
+				common.ClusterPlatform = common.PlatformKubernetes
+			})
+
+			AfterEach(func() {
+				common.ClusterPlatform = common.PlatformOpenShift
+			})
+
+			It("should not reconcile the ConsolePlugin components", func() {
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					Build()
+
+				conditions, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Namespace: gpuAddon.Namespace,
+					Name:      "console-plugin-nvidia-gpu",
+				}, &dp)
+				Expect(err).Should(HaveOccurred())
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+				Expect(conditions).To(HaveLen(3))
+				Expect(conditions[0].Reason).To(Equal("NotSupported"))
+				Expect(conditions[1].Type).To(Equal(ConsolePluginAvailableCondition))
+				Expect(conditions[1].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[1].Reason).To(Equal("NotSupported"))
+				Expect(conditions[2].Type).To(Equal(ConsolePluginRegisteredCondition))
+				Expect(conditions[2].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[2].Reason).To(Equal("NotSupported"))
+
+				deleted, err := rrec.Delete(context.TODO(), c)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deleted).To(BeTrue())
 			})
 		})
 
@@ -160,9 +213,26 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 					Name: "console-plugin-nvidia-gpu",
 				}, &cp)
 				Expect(err).ShouldNot(HaveOccurred())
+				Expect(cp.Spec.DisplayName).To(Equal("NVIDIA GPU"))
+				Expect(cp.Spec.Proxy).To(ConsistOf(consolev1alpha1.ConsolePluginProxy{
+					Type:  consolev1alpha1.ProxyTypeService,
+					Alias: "prometheus",
+					Service: consolev1alpha1.ConsolePluginProxyServiceConfig{
+						Name:      "gpuaddon-prometheus-service",
+						Namespace: common.GlobalConfig.AddonNamespace,
+						Port:      9339,
+					},
+					Authorize: true,
+				}))
 
-				Expect(conditions).To(HaveLen(1))
+				Expect(conditions).To(HaveLen(3))
 				Expect(conditions[0].Reason).To(Equal("Success"))
+				Expect(conditions[1].Type).To(Equal(ConsolePluginAvailableCondition))
+				Expect(conditions[1].Status).To(Equal(metav1.ConditionFalse))
+				Expect(conditions[1].Reason).To(Equal("Deploying"))
+				Expect(conditions[2].Type).To(Equal(ConsolePluginRegisteredCondition))
+				Expect(conditions[2].Status).To(Equal(metav1.ConditionTrue))
+				Expect(conditions[2].Reason).To(Equal("Registered"))
 
 				err = c.Get(context.TODO(), client.ObjectKey{
 					Name: "cluster",
@@ -190,6 +260,131 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 					Expect(console.Spec.Plugins[0]).To(Equal("console-plugin-nvidia-gpu"))
 				})
 			})
+
+			Context("and the add-on Prometheus is not deployed", func() {
+				It("should proxy the Thanos Querier of the cluster monitoring", func() {
+					c := fake.
+						NewClientBuilder().
+						WithScheme(scheme).
+						WithRuntimeObjects(clusterVersion, &operatorv1.Console{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "cluster",
+							},
+						}, &addonv1alpha1.Monitoring{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      common.GlobalConfig.AddonID,
+								Namespace: common.GlobalConfig.AddonNamespace,
+							},
+							Spec: addonv1alpha1.MonitoringSpec{
+								Mode: addonv1alpha1.MonitoringModeUserWorkload,
+							},
+						}).
+						Build()
+
+					_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+					Expect(err).ShouldNot(HaveOccurred())
+
+					err = c.Get(context.TODO(), client.ObjectKey{
+						Name: "console-plugin-nvidia-gpu",
+					}, &cp)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(cp.Spec.Proxy).To(HaveLen(1))
+					Expect(cp.Spec.Proxy[0].Service).To(Equal(consolev1alpha1.ConsolePluginProxyServiceConfig{
+						Name:      "thanos-querier",
+						Namespace: "openshift-monitoring",
+						Port:      9091,
+					}))
+				})
+			})
+		})
+
+		Context("when the v1 API is served", func() {
+			crd := &apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: string(common.CapabilityConsolePlugin),
+				},
+				Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+					Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+						{Name: "v1", Served: true, Storage: true},
+						{Name: "v1alpha1", Served: true},
+					},
+				},
+			}
+
+			newV1ConsolePlugin := func() *unstructured.Unstructured {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(consolePluginV1)
+				return u
+			}
+
+			It("should create a v1 ConsolePlugin", func() {
+				gpuAddon.Spec = addonv1alpha1.GPUAddonSpec{
+					ConsolePluginEnabled: true,
+				}
+
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					WithRuntimeObjects(clusterVersion, crd, &operatorv1.Console{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "cluster",
+						},
+					}).
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				u := newV1ConsolePlugin()
+				err = c.Get(context.TODO(), client.ObjectKey{
+					Name: "console-plugin-nvidia-gpu",
+				}, u)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(u.Object).To(HaveKeyWithValue("spec", HaveKeyWithValue("displayName", "NVIDIA GPU")))
+
+				service, _, _ := unstructured.NestedMap(u.Object, "spec", "backend", "service")
+				Expect(service).To(HaveKeyWithValue("name", "console-plugin-nvidia-gpu"))
+
+				loadType, _, _ := unstructured.NestedString(u.Object, "spec", "i18n", "loadType")
+				Expect(loadType).To(Equal("Preload"))
+
+				proxies, _, _ := unstructured.NestedSlice(u.Object, "spec", "proxy")
+				Expect(proxies).To(HaveLen(1))
+				proxy := proxies[0].(map[string]interface{})
+				Expect(proxy).To(HaveKeyWithValue("alias", "prometheus"))
+				Expect(proxy).To(HaveKeyWithValue("authorization", "UserToken"))
+
+				port, _, _ := unstructured.NestedInt64(proxy, "endpoint", "service", "port")
+				Expect(port).To(Equal(int64(9339)))
+
+				err = c.Get(context.TODO(), client.ObjectKey{
+					Name: "console-plugin-nvidia-gpu",
+				}, &cp)
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+				deleted, err := rrec.Delete(context.TODO(), c)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deleted).To(BeFalse())
+
+				err = c.Get(context.TODO(), client.ObjectKey{
+					Name: "console-plugin-nvidia-gpu",
+				}, newV1ConsolePlugin())
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+			})
+
+			It("should watch the v1 ConsolePlugins", func() {
+				src, ok := consolePluginSource(crd).(*source.Kind)
+				Expect(ok).To(BeTrue())
+				Expect(src.Type.GetObjectKind().GroupVersionKind()).To(Equal(consolePluginV1))
+
+				alpha := crd.DeepCopy()
+				alpha.Spec.Versions[0].Served = false
+
+				src, ok = consolePluginSource(alpha).(*source.Kind)
+				Expect(ok).To(BeTrue())
+				Expect(src.Type).To(BeAssignableToTypeOf(&consolev1alpha1.ConsolePlugin{}))
+			})
 		})
 
 		Context("when disabled", func() {
@@ -227,8 +422,14 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 				Expect(err).Should(HaveOccurred())
 				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 
-				Expect(conditions).To(HaveLen(1))
+				Expect(conditions).To(HaveLen(3))
 				Expect(conditions[0].Reason).To(Equal("Success"))
+				Expect(conditions[1].Type).To(Equal(ConsolePluginAvailableCondition))
+				Expect(conditions[1].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[1].Reason).To(Equal("Disabled"))
+				Expect(conditions[2].Type).To(Equal(ConsolePluginRegisteredCondition))
+				Expect(conditions[2].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[2].Reason).To(Equal("Disabled"))
 			})
 
 			It("should delete the ConsolePlugin components when previously enabled", func() {
@@ -283,8 +484,14 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 				Expect(err).Should(HaveOccurred())
 				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 
-				Expect(conditions).To(HaveLen(1))
+				Expect(conditions).To(HaveLen(3))
 				Expect(conditions[0].Reason).To(Equal("Success"))
+				Expect(conditions[1].Type).To(Equal(ConsolePluginAvailableCondition))
+				Expect(conditions[1].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[1].Reason).To(Equal("Disabled"))
+				Expect(conditions[2].Type).To(Equal(ConsolePluginRegisteredCondition))
+				Expect(conditions[2].Status).To(Equal(metav1.ConditionUnknown))
+				Expect(conditions[2].Reason).To(Equal("Disabled"))
 			})
 		})
 	})
@@ -313,6 +520,8 @@ var _ = Describe("ConsolePlugin Resource Reconcile", Ordered, func() {
 
 		scheme := scheme.Scheme
 		Expect(consolev1alpha1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(apiextensionsv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(policyv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 
 		It("should delete the ConsolePlugin components", func() {
 			c := fake.
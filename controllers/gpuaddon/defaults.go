@@ -16,6 +16,12 @@ limitations under the License.
 
 package gpuaddon
 
+const (
+	// DefaultGPUOperatorChannel is the GPU Operator channel subscribed to on
+	// clusters without an OpenShift version, e.g. vanilla Kubernetes.
+	DefaultGPUOperatorChannel = "v22.9"
+)
+
 var (
 	OpenShiftGPUOperatorCompatibilityMatrix = map[string][]string{
 		"4.9": []string{
@@ -20,32 +20,51 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
-	consolev1alpha1 "github.com/openshift/api/console/v1alpha1"
+	gpuv1 "github.com/NVIDIA/gpu-operator/api/v1"
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
 	nfdv1 "github.com/openshift/cluster-nfd-operator/api/v1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/crd"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
 )
 
 // GPUAddonReconciler reconciles a GPUAddon object
 type GPUAddonReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIReader reads the add-on namespace, which is not available in the
+	// namespaced cache of the manager.
+	APIReader client.Reader
 }
 
+// uninstallRequeueInterval is how often the deletion progress of the add-on
+// components is checked during the uninstallation.
+const uninstallRequeueInterval = 10 * time.Second
+
 // List of other resources managed by this operator.
 var resourceOrderedReconcilers = []ResourceReconciler{
 	&NFDResourceReconciler{},
@@ -57,15 +76,23 @@ var resourceOrderedReconcilers = []ResourceReconciler{
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons/finalizers,verbs=update
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
 //+kubebuilder:rbac:groups=nvidia.com,resources=clusterpolicies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nfd.openshift.io,namespace=system,resources=nodefeaturediscoveries,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operators.coreos.com,namespace=system,resources=clusterserviceversions,verbs=get;list;watch;delete
 //+kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups=operators.coreos.com,namespace=system,resources=subscriptions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=operators.coreos.com,resources=catalogsources,verbs=get
 //+kubebuilder:rbac:groups=console.openshift.io,resources=consoleplugins,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operator.openshift.io,resources=consoles,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups=apps,namespace=system,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",namespace=system,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=config.openshift.io,resources=apiservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policy,namespace=system,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -92,17 +119,7 @@ func (r *GPUAddonReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	if !gpuAddon.ObjectMeta.DeletionTimestamp.IsZero() {
 		logger.Info(fmt.Sprintf("GPUAddon CR %v/%v marked for deletion", req.Namespace, req.Name))
 		if controllerutil.ContainsFinalizer(&gpuAddon, common.GlobalConfig.AddonID) {
-
-			err := r.removeOwnedResources(ctx)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-
-			controllerutil.RemoveFinalizer(&gpuAddon, common.GlobalConfig.AddonID)
-
-			if err := r.Update(ctx, &gpuAddon); err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
-			}
+			return r.uninstall(ctx, &gpuAddon)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -113,6 +130,13 @@ func (r *GPUAddonReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	}
 
 	for _, rr := range resourceOrderedReconcilers {
+		if missing := missingCapabilities(rr); len(missing) > 0 {
+			logger.Info("Skipping reconciliation due to missing capabilities", "capabilities", missing)
+			cd := rr.(CapabilityDependentReconciler)
+			addonConditions = append(addonConditions, common.NewCapabilityMissingCondition(cd.ConditionType(), missing))
+			continue
+		}
+
 		conditions, err := rr.Reconcile(ctx, r.Client, &gpuAddon)
 		addonConditions = append(addonConditions, conditions...)
 		if err != nil {
@@ -124,22 +148,118 @@ func (r *GPUAddonReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return ctrl.Result{}, r.patchStatus(ctx, gpuAddon, addonConditions, nil)
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *GPUAddonReconciler) SetupWithManager(mgr ctrl.Manager) (controller.Controller, error) {
-	return ctrl.NewControllerManagedBy(mgr).
+// SetupWithManager sets up the controller with the Manager. The watches on
+// resources of optional APIs are registered with the given CRD reconciler, so
+// that they are only started once their CRDs are established.
+func (r *GPUAddonReconciler) SetupWithManager(mgr ctrl.Manager, crds *crd.CRDReconciler) error {
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&addonv1alpha1.GPUAddon{}).
-		Owns(&operatorsv1alpha1.Subscription{}).
-		Owns(&nfdv1.NodeFeatureDiscovery{}).
-		Owns(&consolev1alpha1.ConsolePlugin{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
-		Build(r)
+		Owns(&corev1.ConfigMap{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Build(health.Reconciles.Track("gpuaddon", r))
+	if err != nil {
+		return err
+	}
+
+	gpuAddon := &addonv1alpha1.GPUAddon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	toGPUAddon := func(name string) handler.EventHandler {
+		return handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			if o.GetName() != name {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(gpuAddon)}}
+		})
+	}
+
+	// The serving certificate of the console plugin is issued by the service
+	// CA operator, and it is hashed in the console plugin Deployment.
+	if err := c.Watch(&source.Kind{Type: &corev1.Secret{}}, toGPUAddon(consolePluginServingCertSecretName)); err != nil {
+		return err
+	}
+
+	ownedByGPUAddon := &handler.EnqueueRequestForOwner{
+		OwnerType:    &addonv1alpha1.GPUAddon{},
+		IsController: true,
+	}
+
+	watches := []crd.Watch{
+		{
+			// OLM, thus the Subscription API, may not be available on plain
+			// Kubernetes clusters.
+			Capability: common.CapabilitySubscription,
+			Source:     &source.Kind{Type: &operatorsv1alpha1.Subscription{}},
+			Handler:    ownedByGPUAddon,
+		},
+		{
+			Capability: common.CapabilityNodeFeatureDiscovery,
+			Source:     &source.Kind{Type: &nfdv1.NodeFeatureDiscovery{}},
+			Handler:    ownedByGPUAddon,
+		},
+		{
+			// The ClusterPolicy is cluster-scoped, thus it cannot be owned by
+			// the namespaced GPUAddon.
+			Capability: common.CapabilityClusterPolicy,
+			Source:     &source.Kind{Type: &gpuv1.ClusterPolicy{}},
+			Handler:    toGPUAddon(common.GlobalConfig.ClusterPolicyName),
+		},
+	}
+
+	if common.IsOpenShift() {
+		// The console plugin follows the TLS security profile of the cluster.
+		if err := c.Watch(&source.Kind{Type: &configv1.APIServer{}}, toGPUAddon("cluster")); err != nil {
+			return err
+		}
+
+		// The Prometheus proxy of the console plugin follows the monitoring
+		// mode.
+		if err := c.Watch(
+			&source.Kind{Type: &addonv1alpha1.Monitoring{}},
+			toGPUAddon(common.GlobalConfig.AddonID),
+			predicate.GenerationChangedPredicate{}); err != nil {
+			return err
+		}
+
+		// The console plugin is re-registered when it is removed from the
+		// cluster Console, e.g. by an admin or another operator.
+		if err := c.Watch(
+			&source.Kind{Type: &operatorv1.Console{}},
+			toGPUAddon(clusterConsoleName),
+			clusterConsolePredicate); err != nil {
+			return err
+		}
+
+		// The ConsolePlugin is cluster-scoped, thus it cannot be owned by
+		// the namespaced GPUAddon. The served version is watched, as the
+		// ConsolePlugin is created with it.
+		watches = append(watches, crd.Watch{
+			Capability: common.CapabilityConsolePlugin,
+			SourceFor:  consolePluginSource,
+			Handler:    toGPUAddon(consolePluginName),
+		})
+	}
+
+	return crds.Register(c, gpuAddon, watches...)
 }
 
 func (r *GPUAddonReconciler) patchStatus(ctx context.Context, gpuAddon addonv1alpha1.GPUAddon, conditions []metav1.Condition, err error) error {
 	patch := client.MergeFrom(gpuAddon.DeepCopy())
-	gpuAddon.Status.Conditions = conditions
-	if err != nil {
+	// Conditions are merged rather than replaced, as other controllers, e.g.
+	// the bootstrap one, report their own conditions on the GPUAddon too.
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&gpuAddon.Status.Conditions, condition)
+	}
+	if gpuAddon.Status.Uninstall != nil {
+		// The uninstallation is waiting for the GPU workloads to complete.
+		gpuAddon.Status.Phase = addonv1alpha1.GPUAddonPhaseUninstalling
+	} else if err != nil {
 		gpuAddon.Status.Phase = addonv1alpha1.GPUAddonPhaseFailed
 	} else {
 		for _, condition := range conditions {
@@ -161,6 +281,7 @@ func (r *GPUAddonReconciler) patchStatus(ctx context.Context, gpuAddon addonv1al
 	if patchErr != nil {
 		return fmt.Errorf("failed to patch status: %w", patchErr)
 	}
+	recordStatusMetrics(gpuAddon.Status)
 	return err
 }
 
@@ -178,34 +299,225 @@ func (r *GPUAddonReconciler) registerFinilizerIfNeeded(ctx context.Context, gpuA
 	return nil
 }
 
-func (r *GPUAddonReconciler) removeOwnedResources(ctx context.Context) error {
-	deleted := make([]bool, len(resourceOrderedReconcilers))
+// uninstall deletes the resources owned by the GPUAddon and reports the
+// deletion progress of each component in the GPUAddon status. Once all of
+// them are gone, or once the force deadline has passed, the add-on CSV and
+// the GPUAddon finalizer are removed.
+func (r *GPUAddonReconciler) uninstall(ctx context.Context, gpuAddon *addonv1alpha1.GPUAddon) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	patch := client.MergeFrom(gpuAddon.DeepCopy())
+
+	if gpuAddon.Status.Uninstall == nil {
+		// The GPUAddon has been deleted directly rather than through the
+		// uninstall flow.
+		gpuAddon.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+			StartTime: *gpuAddon.DeletionTimestamp,
+		}
+	}
+	status := gpuAddon.Status.Uninstall
+	gpuAddon.Status.Phase = addonv1alpha1.GPUAddonPhaseUninstalling
+
+	// The force deadline starts with the teardown, i.e. once the GPUAddon is
+	// deleted, so that the time spent waiting for the GPU workloads doesn't
+	// cut the graceful teardown short.
+	deadline := gpuAddon.DeletionTimestamp.Add(gpuAddon.Spec.UninstallForceDeadline())
+	force := !time.Now().Before(deadline)
+
+	detach, err := r.uninstallDetach(ctx, gpuAddon)
+	if err != nil {
+		return ctrl.Result{}, r.patchUninstallStatus(ctx, gpuAddon, patch, err)
+	}
+
+	components, err := r.removeOwnedResources(ctx, force, detach)
+	addCleanedNodes(components, status.Components)
+	status.Components = components
+	if err != nil {
+		return ctrl.Result{}, r.patchUninstallStatus(ctx, gpuAddon, patch, err)
+	}
+
+	if !allComponentsDeleted(components) {
+		if !force {
+			logger.Info("Waiting for the add-on components to be deleted", "forceDeadline", deadline)
+			return ctrl.Result{RequeueAfter: uninstallRequeueAfter(deadline)},
+				r.patchUninstallStatus(ctx, gpuAddon, patch, nil)
+		}
+
+		logger.Info("Force deadline has passed, force-cleaned the remaining add-on components")
+		status.Forced = true
+	}
+
+	if err := r.removeSelfCsv(ctx); err != nil {
+		return ctrl.Result{}, r.patchUninstallStatus(ctx, gpuAddon, patch, err)
+	}
+
+	if err := r.patchUninstallStatus(ctx, gpuAddon, patch, nil); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	controllerutil.RemoveFinalizer(gpuAddon, common.GlobalConfig.AddonID)
+
+	if err := r.Update(ctx, gpuAddon); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// uninstallDetach returns whether the GPU stack is detached rather than
+// deleted. It cannot be detached from the managed add-on namespace, which is
+// deleted along with the add-on.
+func (r *GPUAddonReconciler) uninstallDetach(ctx context.Context, gpuAddon *addonv1alpha1.GPUAddon) (bool, error) {
+	if !gpuAddon.Spec.UninstallDetach() {
+		return false, nil
+	}
+
+	managed, err := common.IsManagedAddonNamespace(ctx, r.APIReader)
+	if err != nil {
+		return false, err
+	}
+
+	return !managed, nil
+}
+
+// removeOwnedResources deletes the resources of all components in reverse
+// order and returns their deletion progress. When forced, the components
+// which are not deleted yet are force-deleted and failures don't stop the
+// deletion of the other components. Once deleted, the nodes are cleaned up of
+// what the component operands left behind. When detached, the components which
+// support it are detached from the add-on rather than deleted.
+func (r *GPUAddonReconciler) removeOwnedResources(ctx context.Context, force, detach bool) ([]addonv1alpha1.ComponentUninstallStatus, error) {
+	components := make([]addonv1alpha1.ComponentUninstallStatus, len(resourceOrderedReconcilers))
 
 	for i := len(resourceOrderedReconcilers) - 1; i >= 0; i-- {
-		removed, err := resourceOrderedReconcilers[i].Delete(ctx, r.Client)
+		rr := resourceOrderedReconcilers[i]
+		components[i].Name = rr.Name()
+
+		// There can be no resources of an API which is not available.
+		if len(missingCapabilities(rr)) > 0 {
+			components[i].Deleted = true
+			continue
+		}
+
+		if d, ok := rr.(Detacher); ok && detach {
+			if err := d.Detach(ctx, r.Client); err != nil {
+				components[i].Message = err.Error()
+				return components, err
+			}
+			components[i].Detached = true
+			components[i].Message = "Detached from the add-on"
+			continue
+		}
+
+		removed, err := rr.Delete(ctx, r.Client)
 		if err != nil {
-			return err
+			components[i].Message = err.Error()
+			if !force {
+				return components, err
+			}
 		}
-		deleted[i] = removed
+		components[i].Deleted = removed
+
+		if nc, ok := rr.(NodeCleaner); ok && removed {
+			cleaned, failed, err := r.cleanNodes(ctx, nc.NodeCleanup())
+			if err != nil {
+				components[i].Message = err.Error()
+				if !force {
+					return components, err
+				}
+			}
+			components[i].CleanedNodeNames = cleaned
+			components[i].CleanedNodes = int32(len(cleaned))
+			if len(failed) > 0 {
+				components[i].FailedNodes = failed
+				components[i].Message = fmt.Sprintf("Failed to clean up %d nodes", len(failed))
+			}
+		}
+
+		if removed || !force {
+			if !removed {
+				components[i].Message = "Waiting for the resources to be deleted"
+			}
+			continue
+		}
+
+		fd, ok := rr.(ForceDeleter)
+		if !ok {
+			components[i].Message = "Left behind after the force deadline"
+			continue
+		}
+
+		if err := fd.ForceDelete(ctx, r.Client); err != nil {
+			components[i].Message = fmt.Sprintf("Failed to force-delete: %v", err)
+			continue
+		}
+		components[i].Message = "Force-deleted after the force deadline"
 	}
 
-	for i := range deleted {
-		if !deleted[i] {
-			return fmt.Errorf("not all resources have been deleted yet, won't remove add-on CSV")
+	return components, nil
+}
+
+func (r *GPUAddonReconciler) patchUninstallStatus(ctx context.Context, gpuAddon *addonv1alpha1.GPUAddon, patch client.Patch, err error) error {
+	if patchErr := r.Status().Patch(ctx, gpuAddon, patch); patchErr != nil {
+		return fmt.Errorf("failed to patch uninstall status: %w", patchErr)
+	}
+	recordStatusMetrics(gpuAddon.Status)
+	return err
+}
+
+// addCleanedNodes adds the nodes cleaned up by the previous reconciliations to
+// the given component statuses, as only the nodes left to clean up are
+// counted on each reconciliation. A node cleaned up again, e.g. after being
+// labeled again by an operand still running, is only counted once.
+func addCleanedNodes(components, previous []addonv1alpha1.ComponentUninstallStatus) {
+	for i := range components {
+		for _, p := range previous {
+			if p.Name != components[i].Name {
+				continue
+			}
+
+			names := sets.NewString(components[i].CleanedNodeNames...)
+			names.Insert(p.CleanedNodeNames...)
+			if names.Len() > 0 {
+				components[i].CleanedNodeNames = names.List()
+			}
+			components[i].CleanedNodes = int32(names.Len())
 		}
 	}
+}
 
-	if err := r.removeSelfCsv(ctx); err != nil {
-		return err
+func allComponentsDeleted(components []addonv1alpha1.ComponentUninstallStatus) bool {
+	for _, c := range components {
+		if (!c.Deleted && !c.Detached) || len(c.FailedNodes) > 0 {
+			return false
+		}
 	}
+	return true
+}
 
-	return nil
+// uninstallRequeueAfter returns when to check the deletion progress again,
+// which is never later than the given force deadline.
+func uninstallRequeueAfter(deadline time.Time) time.Duration {
+	untilDeadline := time.Until(deadline)
+	switch {
+	case untilDeadline < time.Second:
+		return time.Second
+	case untilDeadline < uninstallRequeueInterval:
+		return untilDeadline
+	default:
+		return uninstallRequeueInterval
+	}
 }
 
 func (r *GPUAddonReconciler) removeSelfCsv(ctx context.Context) error {
 	logger := log.FromContext(ctx).WithValues("Reconcile Step", "Addon CSV Deletion")
 	logger.Info("Cleanup Reconcile | Delete own CSV")
 
+	// Without OLM, the add-on has not been installed from a CSV.
+	if !common.Capabilities.IsAvailable(common.CapabilitySubscription) {
+		return nil
+	}
+
 	addonCsv, err := common.GetCsvWithPrefix(r.Client, common.GlobalConfig.AddonNamespace, common.GlobalConfig.AddonID)
 	if err != nil {
 		return err
@@ -2,6 +2,7 @@ package gpuaddon
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"time"
 
@@ -13,7 +14,10 @@ import (
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -92,6 +96,93 @@ var _ = Describe("GPUAddon Reconcile", Ordered, func() {
 		})
 	})
 
+	Context("Creation Reconcile with missing capabilities", func() {
+		gpuAddon, r := prepareClusterForGPUAddonCreateTest()
+
+		BeforeAll(func() {
+			common.Capabilities.SetAvailable(common.CapabilityClusterPolicy, false)
+		})
+
+		g := &addonv1alpha1.GPUAddon{}
+
+		It("should not error", func() {
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: gpuAddon.Namespace,
+					Name:      gpuAddon.Name,
+				},
+			}
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = r.Client.Get(context.TODO(), types.NamespacedName{
+				Namespace: gpuAddon.Namespace,
+				Name:      gpuAddon.Name,
+			}, g)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should not create the ClusterPolicy CR", func() {
+			clusterPolicyCr := &gpuv1.ClusterPolicy{}
+			err := r.Client.Get(context.TODO(), types.NamespacedName{
+				Name: common.GlobalConfig.ClusterPolicyName,
+			}, clusterPolicyCr)
+			Expect(err).Should(HaveOccurred())
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should report the missing capability", func() {
+			Expect(common.ContainCondition(g.Status.Conditions, "ClusterPolicyDeployed", "False")).To(BeTrue())
+			Expect(common.ContainCondition(g.Status.Conditions, "NodeFeatureDiscoveryDeployed", "True")).To(BeTrue())
+
+			for _, c := range g.Status.Conditions {
+				if c.Type == "ClusterPolicyDeployed" {
+					Expect(c.Reason).To(Equal(common.CapabilityMissingConditionReason))
+				}
+			}
+			Expect(g.Status.Phase).To(Equal(addonv1alpha1.GPUAddonPhaseInstalling))
+		})
+
+		AfterAll(func() {
+			common.SetCapabilitiesAvailable(true)
+		})
+	})
+
+	Context("Creation Reconcile without OLM", func() {
+		gpuAddon, r := prepareClusterForGPUAddonCreateTest()
+
+		BeforeAll(func() {
+			common.Capabilities.SetAvailable(common.CapabilitySubscription, false)
+		})
+
+		It("should report the missing OLM Subscription API", func() {
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: gpuAddon.Namespace,
+					Name:      gpuAddon.Name,
+				},
+			}
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Client.Get(context.TODO(), req.NamespacedName, g)).ShouldNot(HaveOccurred())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, SubscriptionDeployedCondition)
+			Expect(c).NotTo(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal(common.CapabilityMissingConditionReason))
+			Expect(c.Message).To(ContainSubstring(string(common.CapabilitySubscription)))
+			Expect(common.ContainCondition(g.Status.Conditions, "ClusterPolicyDeployed", "True")).To(BeTrue())
+		})
+
+		AfterAll(func() {
+			common.SetCapabilitiesAvailable(true)
+		})
+	})
+
 	Context("Delete reconcile", func() {
 		gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
 
@@ -102,11 +193,39 @@ var _ = Describe("GPUAddon Reconcile", Ordered, func() {
 			},
 		}
 
-		_, err := r.Reconcile(context.TODO(), req)
+		res, err := r.Reconcile(context.TODO(), req)
 
-		It("should return an error", func() {
-			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).Should(ContainSubstring("not all resources have been deleted"))
+		uninstalling := &addonv1alpha1.GPUAddon{}
+		getErr := r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), uninstalling)
+
+		It("should requeue until all resources are deleted", func() {
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(uninstallRequeueInterval))
+		})
+
+		It("should report the uninstall progress of each component", func() {
+			Expect(getErr).ShouldNot(HaveOccurred())
+			Expect(uninstalling.Status.Phase).To(Equal(addonv1alpha1.GPUAddonPhaseUninstalling))
+			Expect(uninstalling.Status.Uninstall).ToNot(BeNil())
+			Expect(uninstalling.Status.Uninstall.Forced).To(BeFalse())
+			Expect(uninstalling.Status.Uninstall.Components).To(ConsistOf(
+				addonv1alpha1.ComponentUninstallStatus{
+					Name:    "NodeFeatureDiscovery",
+					Message: "Waiting for the resources to be deleted",
+				},
+				addonv1alpha1.ComponentUninstallStatus{
+					Name:    "Subscription",
+					Message: "Waiting for the resources to be deleted",
+				},
+				addonv1alpha1.ComponentUninstallStatus{
+					Name:    "ClusterPolicy",
+					Message: "Waiting for the resources to be deleted",
+				},
+				addonv1alpha1.ComponentUninstallStatus{
+					Name:    "ConsolePlugin",
+					Deleted: true,
+				},
+			))
 		})
 
 		It("should delete the GPUAddon CSV", func() {
@@ -164,6 +283,227 @@ var _ = Describe("GPUAddon Reconcile", Ordered, func() {
 	})
 })
 
+var _ = Describe("GPUAddon forced uninstall", func() {
+	It("should force-clean the remaining components after the force deadline", func() {
+		gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
+
+		g := &addonv1alpha1.GPUAddon{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{
+			ForceDeadline: &metav1.Duration{Duration: time.Minute},
+		}
+		deleted := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+		g.DeletionTimestamp = &deleted
+		Expect(r.Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+			StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+		}
+		Expect(r.Status().Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		nfd := &nfdv1.NodeFeatureDiscovery{}
+		nfdKey := types.NamespacedName{
+			Name:      common.GlobalConfig.NfdCrName,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}
+		Expect(r.Get(context.TODO(), nfdKey, nfd)).ShouldNot(HaveOccurred())
+		nfd.Finalizers = []string{"foreground-deletion"}
+		Expect(r.Update(context.TODO(), nfd)).ShouldNot(HaveOccurred())
+
+		res, err := r.Reconcile(context.TODO(), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(gpuAddon),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeZero())
+
+		err = r.Get(context.TODO(), nfdKey, nfd)
+		if err == nil {
+			Expect(nfd.Finalizers).To(BeEmpty())
+		} else {
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		}
+
+		err = r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)
+		Expect(err).Should(HaveOccurred())
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("GPUAddon uninstall after a long grace period", func() {
+	It("should not force-clean the components before the force deadline from the deletion", func() {
+		gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
+
+		g := &addonv1alpha1.GPUAddon{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{
+			GracePeriod:   &metav1.Duration{Duration: time.Hour},
+			ForceDeadline: &metav1.Duration{Duration: 5 * time.Minute},
+		}
+		Expect(r.Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		// The uninstallation started before the grace period, which is
+		// longer than the force deadline, expired.
+		g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+			StartTime: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		}
+		Expect(r.Status().Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		nfd := &nfdv1.NodeFeatureDiscovery{}
+		nfdKey := types.NamespacedName{
+			Name:      common.GlobalConfig.NfdCrName,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}
+		Expect(r.Get(context.TODO(), nfdKey, nfd)).ShouldNot(HaveOccurred())
+		nfd.Finalizers = []string{"foreground-deletion"}
+		Expect(r.Update(context.TODO(), nfd)).ShouldNot(HaveOccurred())
+
+		res, err := r.Reconcile(context.TODO(), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(gpuAddon),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.RequeueAfter).NotTo(BeZero())
+
+		Expect(r.Get(context.TODO(), nfdKey, nfd)).ShouldNot(HaveOccurred())
+		Expect(nfd.Finalizers).To(ConsistOf("foreground-deletion"))
+
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		Expect(g.Status.Uninstall.Forced).To(BeFalse())
+	})
+
+	It("should reject a grace period which is not shorter than the force deadline", func() {
+		spec := addonv1alpha1.GPUAddonSpec{
+			Uninstall: &addonv1alpha1.UninstallSpec{
+				GracePeriod:   &metav1.Duration{Duration: time.Hour},
+				ForceDeadline: &metav1.Duration{Duration: 5 * time.Minute},
+			},
+		}
+		Expect(spec.ValidateUninstall()).Should(HaveOccurred())
+
+		spec.Uninstall.GracePeriod = nil
+		Expect(spec.ValidateUninstall()).Should(HaveOccurred())
+
+		spec.Uninstall.ForceDeadline = nil
+		Expect(spec.ValidateUninstall()).ShouldNot(HaveOccurred())
+	})
+})
+
+var _ = Describe("GPUAddon detach uninstall", func() {
+	It("should detach the GPU stack and keep it running", func() {
+		gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
+
+		g := &addonv1alpha1.GPUAddon{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{Detach: true}
+		Expect(r.Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		addonOwnerRef := metav1.OwnerReference{
+			APIVersion: addonv1alpha1.GroupVersion.String(),
+			Kind:       "GPUAddon",
+			Name:       gpuAddon.Name,
+			UID:        gpuAddon.UID,
+		}
+		otherOwnerRef := metav1.OwnerReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       "other",
+			UID:        types.UID("other-uid"),
+		}
+		labels := map[string]string{
+			fmt.Sprintf("%s-version", common.GlobalConfig.AddonID): "1.0.0",
+			"user-label": "value",
+		}
+
+		nfd := &nfdv1.NodeFeatureDiscovery{}
+		nfdKey := types.NamespacedName{
+			Name:      common.GlobalConfig.NfdCrName,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}
+		subscription := &operatorsv1alpha1.Subscription{}
+		subscriptionKey := types.NamespacedName{
+			Name:      "gpu-operator-certified",
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}
+		clusterPolicy := &gpuv1.ClusterPolicy{}
+		clusterPolicyKey := types.NamespacedName{
+			Name: common.GlobalConfig.ClusterPolicyName,
+		}
+
+		stack := map[types.NamespacedName]client.Object{
+			nfdKey:           nfd,
+			subscriptionKey:  subscription,
+			clusterPolicyKey: clusterPolicy,
+		}
+		for key, obj := range stack {
+			Expect(r.Get(context.TODO(), key, obj)).ShouldNot(HaveOccurred())
+			obj.SetOwnerReferences([]metav1.OwnerReference{addonOwnerRef, otherOwnerRef})
+			obj.SetLabels(labels)
+			Expect(r.Update(context.TODO(), obj)).ShouldNot(HaveOccurred())
+		}
+
+		res, err := r.Reconcile(context.TODO(), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(gpuAddon),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeZero())
+
+		for key, obj := range stack {
+			Expect(r.Get(context.TODO(), key, obj)).ShouldNot(HaveOccurred())
+			Expect(obj.GetDeletionTimestamp()).To(BeNil())
+			Expect(obj.GetOwnerReferences()).To(ConsistOf(otherOwnerRef))
+			Expect(obj.GetLabels()).To(Equal(map[string]string{"user-label": "value"}))
+		}
+
+		Expect(r.Get(context.TODO(), subscriptionKey, subscription)).ShouldNot(HaveOccurred())
+		Expect(subscription.Spec.CatalogSource).To(Equal("certified-operators"))
+		Expect(subscription.Spec.CatalogSourceNamespace).To(Equal("openshift-marketplace"))
+		Expect(subscription.Spec.Package).To(Equal("gpu-operator-certified"))
+
+		_, err = common.GetCsvWithPrefix(r.Client, common.GlobalConfig.AddonNamespace, common.GlobalConfig.AddonID)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+		_, err = common.GetCsvWithPrefix(r.Client, common.GlobalConfig.AddonNamespace, "gpu-operator-certified")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		err = r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)
+		Expect(err).Should(HaveOccurred())
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should delete the GPU stack in the managed add-on namespace", func() {
+		gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
+
+		g := &addonv1alpha1.GPUAddon{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{Detach: true}
+		Expect(r.Update(context.TODO(), g)).ShouldNot(HaveOccurred())
+
+		Expect(r.Create(context.TODO(), &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: common.GlobalConfig.AddonNamespace,
+				Labels: map[string]string{
+					"api.openshift.com/addon-" + common.GlobalConfig.AddonID: "true",
+				},
+			},
+		})).ShouldNot(HaveOccurred())
+
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(gpuAddon),
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(gpuAddon), g)).ShouldNot(HaveOccurred())
+		for _, c := range g.Status.Uninstall.Components {
+			Expect(c.Detached).To(BeFalse(), c.Name)
+		}
+
+		err = r.Get(context.TODO(), types.NamespacedName{
+			Name:      "gpu-operator-certified",
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}, &operatorsv1alpha1.Subscription{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+})
+
 func prepareClusterForGPUAddonCreateTest() (*addonv1alpha1.GPUAddon, *GPUAddonReconciler) {
 	gpuAddon := &addonv1alpha1.GPUAddon{}
 	gpuAddon.Name = "TestAddon"
@@ -225,6 +565,11 @@ func prepareClusterForGPUAddonDeletionTest() (*addonv1alpha1.GPUAddon, *GPUAddon
 			Namespace:       gpuAddon.Namespace,
 			OwnerReferences: []metav1.OwnerReference{ownerRef},
 		},
+		Spec: &operatorsv1alpha1.SubscriptionSpec{
+			CatalogSource:          "addon-nvidia-gpu-addon-catalog",
+			CatalogSourceNamespace: common.GlobalConfig.AddonNamespace,
+			Package:                "gpu-operator-certified",
+		},
 	}
 
 	r := newTestGPUAddonReconciler(gpuAddon, gpuaddoncsv, nfd, subscription, clusterPolicy)
@@ -243,6 +588,8 @@ func newTestGPUAddonReconciler(objs ...runtime.Object) *GPUAddonReconciler {
 	Expect(nfdv1.AddToScheme(s)).ShouldNot(HaveOccurred())
 	Expect(configv1.AddToScheme(s)).ShouldNot(HaveOccurred())
 	Expect(appsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(policyv1.AddToScheme(s)).ShouldNot(HaveOccurred())
 
 	clusterVersion := &configv1.ClusterVersion{
 		ObjectMeta: metav1.ObjectMeta{
@@ -269,10 +616,22 @@ func newTestGPUAddonReconciler(objs ...runtime.Object) *GPUAddonReconciler {
 
 	objs = append(objs, gpuOperatorCsv)
 
+	catalogSource := &operatorsv1alpha1.CatalogSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "addon-nvidia-gpu-addon-catalog",
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	objs = append(objs, catalogSource)
+
+	common.SetCapabilitiesAvailable(true)
+
 	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
 
 	return &GPUAddonReconciler{
-		Client: c,
-		Scheme: s,
+		Client:    c,
+		Scheme:    s,
+		APIReader: c,
 	}
 }
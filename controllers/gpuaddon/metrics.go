@@ -19,6 +19,8 @@ package gpuaddon
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
 )
 
 var (
@@ -29,10 +31,56 @@ var (
 		},
 		[]string{},
 	)
+
+	Condition = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_condition",
+			Help: "Reports the conditions of the NVIDIA GPUAddon",
+		},
+		[]string{"type", "status", "reason"},
+	)
+
+	Phase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_phase",
+			Help: "Reports the phase of the NVIDIA GPUAddon, 1 for the current phase and 0 for the others",
+		},
+		[]string{"phase"},
+	)
 )
 
+var gpuAddonPhases = []addonv1alpha1.GPUAddonPhase{
+	addonv1alpha1.GPUAddonPhaseFailed,
+	addonv1alpha1.GPUAddonPhaseIdle,
+	addonv1alpha1.GPUAddonPhaseInstalling,
+	addonv1alpha1.GPUAddonPhaseReady,
+	addonv1alpha1.GPUAddonPhaseUpdating,
+	addonv1alpha1.GPUAddonPhaseUninstalling,
+}
+
 func init() {
 	metrics.Registry.MustRegister(
 		SubscriptionInstalled,
+		Condition,
+		Phase,
 	)
 }
+
+// recordStatusMetrics exports the conditions and the phase of the given
+// GPUAddon status.
+func recordStatusMetrics(status addonv1alpha1.GPUAddonStatus) {
+	// The conditions are reset so that the series of a previous status or
+	// reason do not linger.
+	Condition.Reset()
+	for _, c := range status.Conditions {
+		Condition.WithLabelValues(c.Type, string(c.Status), c.Reason).Set(1)
+	}
+
+	for _, phase := range gpuAddonPhases {
+		value := 0.0
+		if phase == status.Phase {
+			value = 1
+		}
+		Phase.WithLabelValues(string(phase)).Set(value)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("GPUAddon status metrics", func() {
+	It("should export the conditions and the phase", func() {
+		recordStatusMetrics(addonv1alpha1.GPUAddonStatus{
+			Phase: addonv1alpha1.GPUAddonPhaseInstalling,
+			Conditions: []metav1.Condition{
+				{Type: ClusterPolicyReadyCondition, Status: metav1.ConditionFalse, Reason: "ClusterPolicyNotReady"},
+			},
+		})
+
+		recordStatusMetrics(addonv1alpha1.GPUAddonStatus{
+			Phase: addonv1alpha1.GPUAddonPhaseReady,
+			Conditions: []metav1.Condition{
+				{Type: ClusterPolicyReadyCondition, Status: metav1.ConditionTrue, Reason: "ClusterPolicyReady"},
+				{Type: SubscriptionDeployedCondition, Status: metav1.ConditionTrue, Reason: "SubscriptionReady"},
+			},
+		})
+
+		Expect(testutil.CollectAndCount(Condition)).To(Equal(2))
+		Expect(testutil.ToFloat64(Condition.WithLabelValues(
+			ClusterPolicyReadyCondition, "True", "ClusterPolicyReady"))).To(Equal(1.0))
+
+		Expect(testutil.CollectAndCount(Phase)).To(Equal(len(gpuAddonPhases)))
+		Expect(testutil.ToFloat64(Phase.WithLabelValues("Ready"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(Phase.WithLabelValues("Installing"))).To(Equal(0.0))
+	})
+})
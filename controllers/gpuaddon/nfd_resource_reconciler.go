@@ -21,12 +21,44 @@ import (
 const (
 	NFDDeployedCondition = "NodeFeatureDiscoveryDeployed"
 
-	workerConfig = "core:\n  sleepInterval: 60s\nsources:\n  pci:\n    deviceClassWhitelist:\n    - \"0200\"\n    - \"03\"\n    - \"12\"\n    deviceLabelFields:\n    - \"vendor\"\n"
+	// workerConfig labels the nodes with their PCI devices by vendor, e.g.
+	// pci-10de.present, which the GPU operator selects the GPU nodes with.
+	// The NVIDIA display controllers are also labeled by vendor and device
+	// IDs, e.g. pci-10de_20b0.present, which the GPU types are mapped from.
+	workerConfig = `core:
+  sleepInterval: 60s
+sources:
+  pci:
+    deviceClassWhitelist:
+    - "0200"
+    - "03"
+    - "12"
+    deviceLabelFields:
+    - "vendor"
+  custom:
+  - name: "nvidia-pci-devices"
+    labelsTemplate: |
+      {{ range .pci.device }}pci-{{ .vendor }}_{{ .device }}.present=true
+      {{ end }}
+    matchFeatures:
+    - feature: pci.device
+      matchExpressions:
+        vendor: {op: In, value: ["10de"]}
+        class: {op: In, value: ["0300", "0302"]}
+`
 )
 
 type NFDResourceReconciler struct{}
 
 var _ ResourceReconciler = &NFDResourceReconciler{}
+var _ Detacher = &NFDResourceReconciler{}
+var _ NodeCleaner = &NFDResourceReconciler{}
+var _ CapabilityDependentReconciler = &NFDResourceReconciler{}
+var _ ForceDeleter = &NFDResourceReconciler{}
+
+func (r *NFDResourceReconciler) Name() string {
+	return "NodeFeatureDiscovery"
+}
 
 func (r *NFDResourceReconciler) Reconcile(
 	ctx context.Context,
@@ -100,6 +132,14 @@ func (r *NFDResourceReconciler) setDesiredNFD(
 	return ctrl.SetControllerReference(gpuAddon, nfd, client.Scheme())
 }
 
+func (r *NFDResourceReconciler) RequiredCapabilities() []common.Capability {
+	return []common.Capability{common.CapabilityNodeFeatureDiscovery}
+}
+
+func (r *NFDResourceReconciler) ConditionType() string {
+	return NFDDeployedCondition
+}
+
 func (r *NFDResourceReconciler) Delete(ctx context.Context, c client.Client) (bool, error) {
 	nfd := &nfdv1.NodeFeatureDiscovery{
 		ObjectMeta: metav1.ObjectMeta{
@@ -118,6 +158,48 @@ func (r *NFDResourceReconciler) Delete(ctx context.Context, c client.Client) (bo
 	return false, nil
 }
 
+// ForceDelete removes the finalizers of the NodeFeatureDiscovery CR, which
+// are left behind if the NFD operator is gone.
+func (r *NFDResourceReconciler) ForceDelete(ctx context.Context, c client.Client) error {
+	nfd := &nfdv1.NodeFeatureDiscovery{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.GlobalConfig.AddonNamespace,
+			Name:      common.GlobalConfig.NfdCrName,
+		},
+	}
+
+	if err := removeFinalizers(ctx, c, nfd); err != nil {
+		return fmt.Errorf("failed to remove NodeFeatureDiscovery %s finalizers: %w", nfd.Name, err)
+	}
+
+	return nil
+}
+
+// NodeCleanup returns what the NFD operands leave behind on the nodes.
+func (r *NFDResourceReconciler) NodeCleanup() NodeCleanup {
+	return NodeCleanup{
+		LabelPrefixes:      []string{"feature.node.kubernetes.io/"},
+		AnnotationPrefixes: []string{"nfd.node.kubernetes.io/"},
+		TaintPrefixes:      []string{"feature.node.kubernetes.io/"},
+	}
+}
+
+// Detach hands the NodeFeatureDiscovery CR over.
+func (r *NFDResourceReconciler) Detach(ctx context.Context, c client.Client) error {
+	nfd := &nfdv1.NodeFeatureDiscovery{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.GlobalConfig.AddonNamespace,
+			Name:      common.GlobalConfig.NfdCrName,
+		},
+	}
+
+	if err := detach(ctx, c, nfd); err != nil {
+		return fmt.Errorf("failed to detach NodeFeatureDiscovery %s: %w", nfd.Name, err)
+	}
+
+	return nil
+}
+
 func (r *NFDResourceReconciler) getDeployedConditionFetchFailed() metav1.Condition {
 	return common.NewCondition(
 		NFDDeployedCondition,
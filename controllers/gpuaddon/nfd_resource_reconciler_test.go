@@ -18,6 +18,8 @@ package gpuaddon
 
 import (
 	"context"
+	"strings"
+	"text/template"
 
 	nfdv1 "github.com/openshift/cluster-nfd-operator/api/v1"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
@@ -25,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -68,6 +71,37 @@ var _ = Describe("NFD Resource Reconcile", Ordered, func() {
 			}, &nfd)
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+
+		It("should label the PCI devices by vendor and the NVIDIA GPUs by device", func() {
+			var config struct {
+				Sources struct {
+					PCI struct {
+						DeviceLabelFields []string `json:"deviceLabelFields"`
+					} `json:"pci"`
+					Custom []struct {
+						LabelsTemplate string `json:"labelsTemplate"`
+					} `json:"custom"`
+				} `json:"sources"`
+			}
+			Expect(yaml.Unmarshal([]byte(nfd.Spec.WorkerConfig.ConfigData), &config)).To(Succeed())
+			Expect(config.Sources.PCI.DeviceLabelFields).To(Equal([]string{"vendor"}))
+			Expect(config.Sources.Custom).To(HaveLen(1))
+
+			tmpl, err := template.New("labels").Parse(config.Sources.Custom[0].LabelsTemplate)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var labels strings.Builder
+			Expect(tmpl.Execute(&labels, map[string]interface{}{
+				"pci": map[string]interface{}{
+					"device": []map[string]string{
+						{"vendor": "10de", "device": "20b0"},
+						{"vendor": "10de", "device": "2330"},
+					},
+				},
+			})).To(Succeed())
+			Expect(strings.Fields(labels.String())).
+				To(Equal([]string{"pci-10de_20b0.present=true", "pci-10de_2330.present=true"}))
+		})
 	})
 
 	Context("Delete", func() {
@@ -0,0 +1,108 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// cleanNodes removes the labels, annotations and taints matching the given
+// cleanup prefixes from all the nodes. It returns the names of the nodes it
+// cleaned up and of the nodes it failed to clean up.
+func (r *GPUAddonReconciler) cleanNodes(ctx context.Context, cleanup NodeCleanup) ([]string, []string, error) {
+	logger := log.FromContext(ctx).WithValues("Reconcile Step", "Node Cleanup")
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	cleaned := []string{}
+	failed := []string{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+
+		// The patch fails on a conflicting change, e.g. to the taints, and
+		// the node is cleaned up again on the next reconciliation.
+		patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		if !cleanNode(node, cleanup) {
+			continue
+		}
+
+		if err := r.Patch(ctx, node, patch); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			logger.Info("Failed to clean up node", "node", node.Name, "error", err.Error())
+			failed = append(failed, node.Name)
+			continue
+		}
+
+		logger.Info("Cleaned up node", "node", node.Name)
+		cleaned = append(cleaned, node.Name)
+	}
+
+	return cleaned, failed, nil
+}
+
+// cleanNode removes the labels, annotations and taints matching the given
+// cleanup prefixes from the given node and returns whether it changed.
+func cleanNode(node *corev1.Node, cleanup NodeCleanup) bool {
+	changed := false
+
+	for k := range node.Labels {
+		if hasAnyPrefix(k, cleanup.LabelPrefixes) {
+			delete(node.Labels, k)
+			changed = true
+		}
+	}
+
+	for k := range node.Annotations {
+		if hasAnyPrefix(k, cleanup.AnnotationPrefixes) {
+			delete(node.Annotations, k)
+			changed = true
+		}
+	}
+
+	taints := []corev1.Taint{}
+	for _, t := range node.Spec.Taints {
+		if hasAnyPrefix(t.Key, cleanup.TaintPrefixes) {
+			changed = true
+			continue
+		}
+		taints = append(taints, t)
+	}
+	node.Spec.Taints = taints
+
+	return changed
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
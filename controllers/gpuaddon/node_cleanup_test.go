@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuaddon
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("Node cleanup", func() {
+	newGPUNode := func(name string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"kubernetes.io/hostname":                      name,
+					"nvidia.com/gpu.present":                      "true",
+					"nvidia.com/mig.config":                       "all-disabled",
+					"nvidia.com/gpu-driver-upgrade-state":         "upgrade-done",
+					"feature.node.kubernetes.io/pci-10de.present": "true",
+				},
+				Annotations: map[string]string{
+					"nvidia.com/gpu-driver-upgrade-enabled": "true",
+					"nfd.node.kubernetes.io/feature-labels": "pci-10de.present",
+					"machine.openshift.io/machine":          "openshift-machine-api/" + name,
+				},
+			},
+			Spec: corev1.NodeSpec{
+				Taints: []corev1.Taint{
+					{Key: "nvidia.com/gpu", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "nvidia.com/gpu-driver-upgrade", Effect: corev1.TaintEffectNoSchedule},
+				},
+			},
+		}
+	}
+
+	Context("cleanNode", func() {
+		It("should remove only what matches the cleanup prefixes", func() {
+			node := newGPUNode("gpu-node")
+
+			Expect(cleanNode(node, (&ClusterPolicyResourceReconciler{}).NodeCleanup())).To(BeTrue())
+
+			Expect(node.Labels).To(Equal(map[string]string{
+				"kubernetes.io/hostname":                      "gpu-node",
+				"feature.node.kubernetes.io/pci-10de.present": "true",
+			}))
+			Expect(node.Annotations).To(Equal(map[string]string{
+				"nfd.node.kubernetes.io/feature-labels": "pci-10de.present",
+				"machine.openshift.io/machine":          "openshift-machine-api/gpu-node",
+			}))
+			Expect(node.Spec.Taints).To(ConsistOf(corev1.Taint{
+				Key:    "nvidia.com/gpu",
+				Effect: corev1.TaintEffectNoSchedule,
+			}))
+		})
+
+		It("should report an already clean node as unchanged", func() {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "cpu-node",
+					Labels: map[string]string{"kubernetes.io/hostname": "cpu-node"},
+				},
+			}
+
+			Expect(cleanNode(node, (&NFDResourceReconciler{}).NodeCleanup())).To(BeFalse())
+		})
+	})
+
+	Context("GPUAddon deletion", func() {
+		It("should clean up the GPU operator and NFD leftovers on the nodes", func() {
+			gpuAddon, r := prepareClusterForGPUAddonDeletionTest()
+
+			for _, node := range []*corev1.Node{newGPUNode("gpu-node-1"), newGPUNode("gpu-node-2")} {
+				Expect(r.Create(context.TODO(), node)).ShouldNot(HaveOccurred())
+			}
+			Expect(r.Create(context.TODO(), &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "cpu-node"},
+			})).ShouldNot(HaveOccurred())
+
+			req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(gpuAddon)}
+
+			// The first reconciliation requests the deletion of the GPU
+			// stack, the second one finds it gone and cleans up the nodes.
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.TODO(), req)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+
+			for _, name := range []string{"gpu-node-1", "gpu-node-2"} {
+				node := &corev1.Node{}
+				Expect(r.Get(context.TODO(), types.NamespacedName{Name: name}, node)).ShouldNot(HaveOccurred())
+				Expect(node.Labels).To(Equal(map[string]string{"kubernetes.io/hostname": name}))
+				Expect(node.Annotations).To(HaveLen(1))
+				Expect(node.Spec.Taints).To(HaveLen(1))
+			}
+		})
+	})
+
+	Context("addCleanedNodes", func() {
+		It("should add the nodes cleaned up by the previous reconciliations", func() {
+			components := []addonv1alpha1.ComponentUninstallStatus{
+				{Name: "NodeFeatureDiscovery", CleanedNodes: 1, CleanedNodeNames: []string{"node-3"}},
+				{Name: "ClusterPolicy"},
+			}
+
+			addCleanedNodes(components, []addonv1alpha1.ComponentUninstallStatus{
+				{Name: "NodeFeatureDiscovery", CleanedNodes: 2, CleanedNodeNames: []string{"node-1", "node-2"}},
+				{Name: "ClusterPolicy", CleanedNodes: 3, CleanedNodeNames: []string{"node-1", "node-2", "node-3"}},
+			})
+
+			Expect(components[0].CleanedNodes).To(Equal(int32(3)))
+			Expect(components[0].CleanedNodeNames).To(Equal([]string{"node-1", "node-2", "node-3"}))
+			Expect(components[1].CleanedNodes).To(Equal(int32(3)))
+		})
+
+		It("should count a node cleaned up again only once", func() {
+			components := []addonv1alpha1.ComponentUninstallStatus{
+				{Name: "NodeFeatureDiscovery", CleanedNodes: 1, CleanedNodeNames: []string{"node-1"}},
+			}
+
+			addCleanedNodes(components, []addonv1alpha1.ComponentUninstallStatus{
+				{Name: "NodeFeatureDiscovery", CleanedNodes: 2, CleanedNodeNames: []string{"node-1", "node-2"}},
+			})
+
+			Expect(components[0].CleanedNodes).To(Equal(int32(2)))
+			Expect(components[0].CleanedNodeNames).To(Equal([]string{"node-1", "node-2"}))
+		})
+	})
+})
@@ -2,14 +2,116 @@ package gpuaddon
 
 import (
 	"context"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
 )
 
+const gpuAddonKind = "GPUAddon"
+
 type ResourceReconciler interface {
+	// Name returns the name of the component the reconciler manages, as
+	// reported in the uninstall status.
+	Name() string
 	Reconcile(ctx context.Context, client client.Client, gpuAddon *addonv1alpha1.GPUAddon) ([]metav1.Condition, error)
 	Delete(ctx context.Context, client client.Client) (bool, error)
 }
+
+// ForceDeleter is implemented by the resource reconcilers whose resources
+// can get stuck in deletion, e.g. because of a finalizer whose controller is
+// already gone. ForceDelete removes whatever blocks their deletion.
+type ForceDeleter interface {
+	ForceDelete(ctx context.Context, client client.Client) error
+}
+
+// CapabilityDependentReconciler is implemented by the resource reconcilers
+// which manage resources of optional APIs. They are disabled until all of
+// their required capabilities are available.
+type CapabilityDependentReconciler interface {
+	RequiredCapabilities() []common.Capability
+	ConditionType() string
+}
+
+// missingCapabilities returns the capabilities required by the given resource
+// reconciler which are not available yet.
+func missingCapabilities(rr ResourceReconciler) []common.Capability {
+	cd, ok := rr.(CapabilityDependentReconciler)
+	if !ok {
+		return nil
+	}
+
+	return common.Capabilities.Missing(cd.RequiredCapabilities()...)
+}
+
+// NodeCleaner is implemented by the resource reconcilers whose operands
+// label, annotate or taint the nodes. Once their resources are deleted, what
+// the operands left behind on the nodes is removed.
+type NodeCleaner interface {
+	NodeCleanup() NodeCleanup
+}
+
+// NodeCleanup lists the prefixes of the node labels, annotations and taint
+// keys left behind by the operands of a component.
+type NodeCleanup struct {
+	LabelPrefixes      []string
+	AnnotationPrefixes []string
+	TaintPrefixes      []string
+}
+
+// Detacher is implemented by the resource reconcilers of the GPU stack,
+// which is kept running when the add-on is uninstalled in detach mode.
+// Detach hands the resources over by removing their add-on owner references
+// and labels.
+type Detacher interface {
+	Detach(ctx context.Context, client client.Client) error
+}
+
+// detach removes the GPUAddon owner references and the add-on labels of the
+// given object, if it still exists.
+func detach(ctx context.Context, c client.Client, obj client.Object) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == gpuAddonKind && strings.HasPrefix(ref.APIVersion, addonv1alpha1.GroupVersion.Group+"/") {
+			continue
+		}
+		ownerRefs = append(ownerRefs, ref)
+	}
+	obj.SetOwnerReferences(ownerRefs)
+
+	labels := obj.GetLabels()
+	for k := range labels {
+		if common.IsAddonLabel(k) {
+			delete(labels, k)
+		}
+	}
+	obj.SetLabels(labels)
+
+	return client.IgnoreNotFound(c.Patch(ctx, obj, patch))
+}
+
+// removeFinalizers removes all the finalizers of the given object, if it
+// still exists.
+func removeFinalizers(ctx context.Context, c client.Client, obj client.Object) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if len(obj.GetFinalizers()) == 0 {
+		return nil
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	obj.SetFinalizers(nil)
+
+	return client.IgnoreNotFound(c.Patch(ctx, obj, patch))
+}
@@ -39,11 +39,30 @@ const (
 
 	packageName      = "gpu-operator-certified"
 	subscriptionName = "gpu-operator-certified"
+
+	addonCatalogSource = "addon-nvidia-gpu-addon-catalog"
+
+	// kubernetesCatalogSource and kubernetesCatalogSourceNamespace are the
+	// OperatorHub.io catalog which OLM installs on plain Kubernetes clusters.
+	kubernetesCatalogSource          = "operatorhubio-catalog"
+	kubernetesCatalogSourceNamespace = "olm"
+
+	// detachedCatalogSource and detachedCatalogSourceNamespace point a
+	// detached Subscription to the certified operators catalog, which is
+	// not removed with the add-on.
+	detachedCatalogSource          = "certified-operators"
+	detachedCatalogSourceNamespace = "openshift-marketplace"
 )
 
 type SubscriptionResourceReconciler struct{}
 
 var _ ResourceReconciler = &SubscriptionResourceReconciler{}
+var _ Detacher = &SubscriptionResourceReconciler{}
+var _ CapabilityDependentReconciler = &SubscriptionResourceReconciler{}
+
+func (r *SubscriptionResourceReconciler) Name() string {
+	return "Subscription"
+}
 
 func (r *SubscriptionResourceReconciler) Reconcile(
 	ctx context.Context,
@@ -65,6 +84,16 @@ func (r *SubscriptionResourceReconciler) Reconcile(
 		return conditions, err
 	}
 
+	catalog := gpuOperatorCatalogSource()
+	if err := client.Get(ctx, catalog, &operatorsv1alpha1.CatalogSource{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			conditions = append(conditions, r.getDeployedConditionCatalogSourceNotFound(catalog))
+			return conditions, fmt.Errorf("CatalogSource %s not found in %s", catalog.Name, catalog.Namespace)
+		}
+		conditions = append(conditions, r.getDeployedConditionFetchFailed())
+		return conditions, fmt.Errorf("failed to get CatalogSource %s: %w", catalog.Name, err)
+	}
+
 	s := &operatorsv1alpha1.Subscription{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: gpuAddon.Namespace,
@@ -83,7 +112,7 @@ func (r *SubscriptionResourceReconciler) Reconcile(
 	}
 
 	res, err := controllerutil.CreateOrPatch(context.TODO(), client, s, func() error {
-		return r.setDesiredSubscription(client, s, gpuAddon)
+		return r.setDesiredSubscription(client, s, gpuAddon, catalog)
 	})
 
 	if err != nil {
@@ -101,26 +130,57 @@ func (r *SubscriptionResourceReconciler) Reconcile(
 	return conditions, nil
 }
 
+// gpuOperatorCatalogSource returns the CatalogSource the GPU operator is
+// subscribed from: the configured one, or else the add-on catalog on OpenShift
+// and the OperatorHub.io catalog on other platforms.
+func gpuOperatorCatalogSource() types.NamespacedName {
+	catalog := types.NamespacedName{
+		Name:      addonCatalogSource,
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}
+	if !common.IsOpenShift() {
+		catalog = types.NamespacedName{
+			Name:      kubernetesCatalogSource,
+			Namespace: kubernetesCatalogSourceNamespace,
+		}
+	}
+
+	if common.GlobalConfig.GpuOperatorCatalogSource != "" {
+		catalog.Name = common.GlobalConfig.GpuOperatorCatalogSource
+	}
+	if common.GlobalConfig.GpuOperatorCatalogSourceNamespace != "" {
+		catalog.Namespace = common.GlobalConfig.GpuOperatorCatalogSourceNamespace
+	}
+
+	return catalog
+}
+
 func (r *SubscriptionResourceReconciler) setDesiredSubscription(
 	client client.Client,
 	s *operatorsv1alpha1.Subscription,
-	gpuAddon *addonv1alpha1.GPUAddon) error {
+	gpuAddon *addonv1alpha1.GPUAddon,
+	catalog types.NamespacedName) error {
 
 	if s == nil {
 		return errors.New("subscription cannot be nil")
 	}
 
-	ocpVersion, err := common.GetOpenShiftVersion(client)
-	if err != nil {
-		return err
-	}
+	channel := DefaultGPUOperatorChannel
 
-	lastIndex := len(OpenShiftGPUOperatorCompatibilityMatrix[ocpVersion]) - 1
+	if common.IsOpenShift() {
+		ocpVersion, err := common.GetOpenShiftVersion(client)
+		if err != nil {
+			return err
+		}
+
+		lastIndex := len(OpenShiftGPUOperatorCompatibilityMatrix[ocpVersion]) - 1
+		channel = OpenShiftGPUOperatorCompatibilityMatrix[ocpVersion][lastIndex]
+	}
 
 	s.Spec = &operatorsv1alpha1.SubscriptionSpec{
-		CatalogSource:          "addon-nvidia-gpu-addon-catalog",
-		CatalogSourceNamespace: common.GlobalConfig.AddonNamespace,
-		Channel:                OpenShiftGPUOperatorCompatibilityMatrix[ocpVersion][lastIndex],
+		CatalogSource:          catalog.Name,
+		CatalogSourceNamespace: catalog.Namespace,
+		Channel:                channel,
 		Package:                packageName,
 		InstallPlanApproval:    operatorsv1alpha1.ApprovalAutomatic,
 	}
@@ -170,6 +230,55 @@ func (r *SubscriptionResourceReconciler) Delete(ctx context.Context, c client.Cl
 	return true, nil
 }
 
+// Detach hands the GPU operator Subscription over. The GPU operator CSV is
+// owned by OLM and is left as is. On OpenShift, the Subscription is pointed
+// to the certified operators catalog since the add-on catalog is removed
+// with the add-on.
+func (r *SubscriptionResourceReconciler) Detach(ctx context.Context, c client.Client) error {
+	s := &operatorsv1alpha1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: common.GlobalConfig.AddonNamespace,
+			Name:      subscriptionName,
+		},
+	}
+
+	if err := detach(ctx, c, s); err != nil {
+		return fmt.Errorf("failed to detach Subscription %s: %w", s.Name, err)
+	}
+
+	if !common.IsOpenShift() {
+		return nil
+	}
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(s), s); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if s.Spec == nil || s.Spec.CatalogSource != addonCatalogSource {
+		return nil
+	}
+
+	patch := client.MergeFrom(s.DeepCopy())
+	s.Spec.CatalogSource = detachedCatalogSource
+	s.Spec.CatalogSourceNamespace = detachedCatalogSourceNamespace
+
+	if err := c.Patch(ctx, s, patch); err != nil {
+		return fmt.Errorf("failed to point Subscription %s to the %s catalog: %w", s.Name, detachedCatalogSource, err)
+	}
+
+	return nil
+}
+
+// RequiredCapabilities returns the OLM Subscription API, so that the GPU
+// operator is not subscribed to on clusters without OLM.
+func (r *SubscriptionResourceReconciler) RequiredCapabilities() []common.Capability {
+	return []common.Capability{common.CapabilitySubscription}
+}
+
+func (r *SubscriptionResourceReconciler) ConditionType() string {
+	return SubscriptionDeployedCondition
+}
+
 func (r *SubscriptionResourceReconciler) getDeployedConditionFetchFailed() metav1.Condition {
 	return common.NewCondition(
 		SubscriptionDeployedCondition,
@@ -178,6 +287,14 @@ func (r *SubscriptionResourceReconciler) getDeployedConditionFetchFailed() metav
 		"Failed to fetch Subscription CR")
 }
 
+func (r *SubscriptionResourceReconciler) getDeployedConditionCatalogSourceNotFound(catalog types.NamespacedName) metav1.Condition {
+	return common.NewCondition(
+		SubscriptionDeployedCondition,
+		metav1.ConditionFalse,
+		"CatalogSourceNotFound",
+		fmt.Sprintf("CatalogSource %s not found in %s", catalog.Name, catalog.Namespace))
+}
+
 func (r *SubscriptionResourceReconciler) getDeployedConditionCreateFailed() metav1.Condition {
 	return common.NewCondition(
 		SubscriptionDeployedCondition,
@@ -62,13 +62,23 @@ var _ = Describe("Subscription Resource Reconcile", Ordered, func() {
 		Expect(operatorsv1alpha1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 		Expect(configv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
 
+		newCatalogSource := func(name, namespace string) *operatorsv1alpha1.CatalogSource {
+			return &operatorsv1alpha1.CatalogSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+			}
+		}
+		addonCatalog := newCatalogSource("addon-nvidia-gpu-addon-catalog", common.GlobalConfig.AddonNamespace)
+
 		var s operatorsv1alpha1.Subscription
 
 		It("should create the Subscription", func() {
 			c := fake.
 				NewClientBuilder().
 				WithScheme(scheme).
-				WithRuntimeObjects(clusterVersion).
+				WithRuntimeObjects(clusterVersion, addonCatalog).
 				Build()
 
 			_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
@@ -79,6 +89,89 @@ var _ = Describe("Subscription Resource Reconcile", Ordered, func() {
 				Name:      "gpu-operator-certified",
 			}, &s)
 			Expect(err).ShouldNot(HaveOccurred())
+			Expect(s.Spec.CatalogSource).To(Equal("addon-nvidia-gpu-addon-catalog"))
+			Expect(s.Spec.CatalogSourceNamespace).To(Equal(common.GlobalConfig.AddonNamespace))
+		})
+
+		It("should report a missing CatalogSource", func() {
+			c := fake.
+				NewClientBuilder().
+				WithScheme(scheme).
+				WithRuntimeObjects(clusterVersion).
+				Build()
+
+			conditions, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+			Expect(err).Should(HaveOccurred())
+			Expect(conditions).To(HaveLen(1))
+			Expect(conditions[0].Status).To(Equal(metav1.ConditionFalse))
+			Expect(conditions[0].Reason).To(Equal("CatalogSourceNotFound"))
+
+			err = c.Get(context.TODO(), types.NamespacedName{
+				Namespace: gpuAddon.Namespace,
+				Name:      "gpu-operator-certified",
+			}, &operatorsv1alpha1.Subscription{})
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		Context("with a configured CatalogSource", func() {
+			BeforeEach(func() {
+				common.GlobalConfig.GpuOperatorCatalogSource = "certified-operators"
+				common.GlobalConfig.GpuOperatorCatalogSourceNamespace = "openshift-marketplace"
+			})
+
+			AfterEach(func() {
+				common.GlobalConfig.GpuOperatorCatalogSource = ""
+				common.GlobalConfig.GpuOperatorCatalogSourceNamespace = ""
+			})
+
+			It("should subscribe from the configured CatalogSource", func() {
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					WithRuntimeObjects(clusterVersion, newCatalogSource("certified-operators", "openshift-marketplace")).
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Namespace: gpuAddon.Namespace,
+					Name:      "gpu-operator-certified",
+				}, &s)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(s.Spec.CatalogSource).To(Equal("certified-operators"))
+				Expect(s.Spec.CatalogSourceNamespace).To(Equal("openshift-marketplace"))
+			})
+		})
+
+		Context("on a Kubernetes cluster", func() {
+			BeforeEach(func() {
+				common.ClusterPlatform = common.PlatformKubernetes
+			})
+
+			AfterEach(func() {
+				common.ClusterPlatform = common.PlatformOpenShift
+			})
+
+			It("should subscribe to the default channel of the OperatorHub.io catalog", func() {
+				c := fake.
+					NewClientBuilder().
+					WithScheme(scheme).
+					WithRuntimeObjects(newCatalogSource("operatorhubio-catalog", "olm")).
+					Build()
+
+				_, err := rrec.Reconcile(context.TODO(), c, &gpuAddon)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = c.Get(context.TODO(), types.NamespacedName{
+					Namespace: gpuAddon.Namespace,
+					Name:      "gpu-operator-certified",
+				}, &s)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(s.Spec.Channel).To(Equal(DefaultGPUOperatorChannel))
+				Expect(s.Spec.CatalogSource).To(Equal("operatorhubio-catalog"))
+				Expect(s.Spec.CatalogSourceNamespace).To(Equal("olm"))
+			})
 		})
 	})
 
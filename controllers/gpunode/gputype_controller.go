@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+// GPUTypeReconciler labels the GPU nodes with their stable GPU type, mapped
+// from their GPU product and PCI device IDs, and warns about the GPU nodes of
+// unknown GPU types.
+type GPUTypeReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// unknown are the unknown GPU products already warned about, by node.
+	unknown map[string]string
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *GPUTypeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	g := &addonv1alpha1.GPUAddon{}
+	if err := r.Get(ctx, req.NamespacedName, g); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("could not get GPUAddon CR: %w", err)
+		}
+		// The labels are removed once the add-on is gone.
+		g = nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	if r.unknown == nil {
+		r.unknown = map[string]string{}
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+
+		t, known := "", false
+		if g != nil && g.DeletionTimestamp.IsZero() && node.Labels[common.GPUNodeLabel] == "true" {
+			t, known = gpuType(node, g.Spec.GPUTypes)
+			r.warnUnknown(ctx, node, known)
+		}
+
+		if node.Labels[common.GPUTypeLabel()] == t {
+			continue
+		}
+
+		// The patch fails on a conflicting change to the labels, and the node
+		// is reconciled again.
+		patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		if t == "" {
+			delete(node.Labels, common.GPUTypeLabel())
+		} else {
+			node.Labels[common.GPUTypeLabel()] = t
+		}
+
+		if err := r.Patch(ctx, node, patch); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return ctrl.Result{}, fmt.Errorf("failed to patch the GPU type label of node %s: %w", node.Name, err)
+		}
+
+		logger.Info("Reconciled GPU type label", "node", node.Name, "gpuType", t)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// warnUnknown warns once about the unknown GPU product of the given GPU node.
+// The GPU nodes not yet labeled by the GPU feature discovery are not warned
+// about.
+func (r *GPUTypeReconciler) warnUnknown(ctx context.Context, node *corev1.Node, known bool) {
+	product := node.Labels[common.GPUProductLabel]
+	if known || product == "" {
+		delete(r.unknown, node.Name)
+		return
+	}
+
+	if r.unknown[node.Name] == product {
+		return
+	}
+	r.unknown[node.Name] = product
+
+	log.FromContext(ctx).Info("Unknown GPU product, the node is not labeled with a GPU type",
+		"node", node.Name, "product", product)
+	r.Recorder.Eventf(node, corev1.EventTypeWarning, "UnknownGPUType",
+		"The GPU product %s is not mapped to a GPU type, add it to the GPU types of the GPUAddon", product)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GPUTypeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toGPUAddon := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gputype").
+		For(&addonv1alpha1.GPUAddon{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// The GPU types are only affected by the nodes coming and going, or
+		// by their GPU labels, e.g. once labeled by NFD and the GPU feature
+		// discovery.
+		Watches(&source.Kind{Type: &corev1.Node{}}, toGPUAddon,
+			builder.WithPredicates(common.GPUNodeLabelsChanged)).
+		Complete(health.Reconciles.Track("gputype", r))
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("GPUType Reconcile", func() {
+	common.ProcessConfig()
+
+	newNode := func(name string, labels map[string]string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+		}
+	}
+
+	newGPUNode := func(name, product string) *corev1.Node {
+		labels := map[string]string{common.GPUNodeLabel: "true"}
+		if product != "" {
+			labels[common.GPUProductLabel] = product
+		}
+		return newNode(name, labels)
+	}
+
+	reconcile := func(r *GPUTypeReconciler) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKey{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	gpuTypeOf := func(r *GPUTypeReconciler, name string) string {
+		node := &corev1.Node{}
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: name}, node)).ShouldNot(HaveOccurred())
+		return node.Labels[common.GPUTypeLabel()]
+	}
+
+	It("should label the GPU nodes with their built-in GPU type", func() {
+		r := newTestGPUTypeReconciler(
+			newGPUAddon(nil),
+			newGPUNode("a100", "NVIDIA-A100-SXM4-40GB"),
+			newGPUNode("a100-old-driver", "A100-SXM4-40GB"),
+			newGPUNode("a100-mig", "NVIDIA-A100-SXM4-40GB-MIG-1g.5gb"),
+			newGPUNode("t4", "Tesla-T4"),
+			newNode("cpu-node", nil))
+
+		reconcile(r)
+
+		Expect(gpuTypeOf(r, "a100")).To(Equal("a100-40gb"))
+		Expect(gpuTypeOf(r, "a100-old-driver")).To(Equal("a100-40gb"))
+		Expect(gpuTypeOf(r, "a100-mig")).To(Equal("a100-40gb"))
+		Expect(gpuTypeOf(r, "t4")).To(Equal("t4"))
+		Expect(gpuTypeOf(r, "cpu-node")).To(BeEmpty())
+		Expect(events(r)).To(BeEmpty())
+	})
+
+	It("should label the GPU nodes with the GPU type of their PCI device ID", func() {
+		r := newTestGPUTypeReconciler(
+			newGPUAddon(nil),
+			newNode("gpu-node", map[string]string{
+				common.GPUNodeLabel: "true",
+				"feature.node.kubernetes.io/pci-10de_2331.present": "true",
+			}))
+
+		reconcile(r)
+
+		Expect(gpuTypeOf(r, "gpu-node")).To(Equal("h100-80gb"))
+	})
+
+	It("should prefer the GPU types of the GPUAddon", func() {
+		g := newGPUAddon(nil)
+		g.Spec.GPUTypes = []addonv1alpha1.GPUTypeMapping{
+			{Type: "a100", Products: []string{"A100-SXM4-40GB"}},
+			{Type: "custom", Products: []string{"Custom-GPU"}},
+		}
+		r := newTestGPUTypeReconciler(g,
+			newGPUNode("a100", "NVIDIA-A100-SXM4-40GB"),
+			newGPUNode("custom", "NVIDIA-Custom-GPU"))
+
+		reconcile(r)
+
+		Expect(gpuTypeOf(r, "a100")).To(Equal("a100"))
+		Expect(gpuTypeOf(r, "custom")).To(Equal("custom"))
+	})
+
+	It("should warn once about the unknown GPU products", func() {
+		stale := newGPUNode("unknown", "NVIDIA-Unknown-GPU")
+		stale.Labels[common.GPUTypeLabel()] = "a100-40gb"
+		r := newTestGPUTypeReconciler(
+			newGPUAddon(nil),
+			stale,
+			newGPUNode("not-discovered", ""))
+
+		reconcile(r)
+		reconcile(r)
+
+		Expect(gpuTypeOf(r, "unknown")).To(BeEmpty())
+		Expect(gpuTypeOf(r, "not-discovered")).To(BeEmpty())
+		Expect(events(r)).To(ConsistOf(ContainSubstring("UnknownGPUType The GPU product NVIDIA-Unknown-GPU")))
+	})
+
+	It("should remove the labels once the add-on is uninstalled", func() {
+		g := newGPUAddon(nil)
+		g.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		g.Finalizers = []string{"test"}
+		node := newGPUNode("gpu-node", "Tesla-T4")
+		node.Labels[common.GPUTypeLabel()] = "t4"
+		r := newTestGPUTypeReconciler(g, node)
+
+		reconcile(r)
+
+		Expect(gpuTypeOf(r, "gpu-node")).To(BeEmpty())
+	})
+})
+
+func events(r *GPUTypeReconciler) []string {
+	recorder := r.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+
+	events := []string{}
+	for e := range recorder.Events {
+		events = append(events, e)
+	}
+	return events
+}
+
+func newTestGPUTypeReconciler(objs ...runtime.Object) *GPUTypeReconciler {
+	c := newTestClient(objs...)
+
+	return &GPUTypeReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
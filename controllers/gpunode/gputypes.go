@@ -0,0 +1,115 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// defaultGPUTypes are the built-in GPU types of the data center GPUs.
+var defaultGPUTypes = []addonv1alpha1.GPUTypeMapping{
+	{Type: "h100-80gb", Products: []string{"H100-80GB-HBM3", "H100-PCIe"}, PCIDeviceIDs: []string{"2330", "2331"}},
+	{Type: "a100-80gb", Products: []string{"A100-SXM4-80GB", "A100-80GB-PCIe"}, PCIDeviceIDs: []string{"20b2", "20b5"}},
+	{Type: "a100-40gb", Products: []string{"A100-SXM4-40GB", "A100-PCIE-40GB"}, PCIDeviceIDs: []string{"20b0", "20f1"}},
+	{Type: "a30", Products: []string{"A30"}, PCIDeviceIDs: []string{"20b7"}},
+	{Type: "a40", Products: []string{"A40"}, PCIDeviceIDs: []string{"2235"}},
+	{Type: "a10", Products: []string{"A10"}, PCIDeviceIDs: []string{"2236"}},
+	{Type: "a10g", Products: []string{"A10G"}, PCIDeviceIDs: []string{"2237"}},
+	{Type: "l40s", Products: []string{"L40S"}, PCIDeviceIDs: []string{"26b9"}},
+	{Type: "l4", Products: []string{"L4"}, PCIDeviceIDs: []string{"27b8"}},
+	{Type: "t4", Products: []string{"Tesla-T4"}, PCIDeviceIDs: []string{"1eb8"}},
+	{Type: "v100-32gb", Products: []string{"Tesla-V100-SXM2-32GB", "Tesla-V100-PCIE-32GB"}, PCIDeviceIDs: []string{"1db5", "1db6"}},
+	{Type: "v100-16gb", Products: []string{"Tesla-V100-SXM2-16GB", "Tesla-V100-PCIE-16GB"}, PCIDeviceIDs: []string{"1db1", "1db4"}},
+	{Type: "m60", Products: []string{"Tesla-M60"}, PCIDeviceIDs: []string{"13f2"}},
+	{Type: "k80", Products: []string{"Tesla-K80"}, PCIDeviceIDs: []string{"102d"}},
+}
+
+const (
+	nfdPCILabelPrefix = "feature.node.kubernetes.io/pci-"
+	nfdPCILabelSuffix = ".present"
+	nvidiaPCIVendorID = "10de"
+)
+
+// migProductSuffix matches the suffix of the GPU products of the MIG
+// partitioned GPUs, e.g. -MIG-1g.5gb.
+var migProductSuffix = regexp.MustCompile(`(?i)-MIG-.*$`)
+
+// normalizeProduct returns the given GPU product without the parts which
+// vary across driver versions and MIG configurations.
+func normalizeProduct(product string) string {
+	product = strings.ToLower(product)
+	product = strings.TrimPrefix(product, "nvidia-")
+	return migProductSuffix.ReplaceAllString(product, "")
+}
+
+// pciDeviceIDs returns the PCI device IDs of the NVIDIA devices of the given
+// node, as labeled with their vendor and device IDs by the NFD custom rule of
+// the add-on, e.g. feature.node.kubernetes.io/pci-10de_20b0.present.
+func pciDeviceIDs(node *corev1.Node) []string {
+	ids := []string{}
+	for label, value := range node.Labels {
+		if value != "true" || !strings.HasPrefix(label, nfdPCILabelPrefix) || !strings.HasSuffix(label, nfdPCILabelSuffix) {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(label, nfdPCILabelPrefix), nfdPCILabelSuffix), "_")
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == nvidiaPCIVendorID {
+				ids = append(ids, strings.ToLower(fields[i+1]))
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// gpuType returns the GPU type of the given GPU node from the given GPU types,
+// which take precedence over the built-in ones, and whether it is known. The
+// GPU product takes precedence over the PCI device IDs.
+func gpuType(node *corev1.Node, gpuTypes []addonv1alpha1.GPUTypeMapping) (string, bool) {
+	mappings := append(append([]addonv1alpha1.GPUTypeMapping{}, gpuTypes...), defaultGPUTypes...)
+
+	if product := node.Labels[common.GPUProductLabel]; product != "" {
+		product = normalizeProduct(product)
+		for _, m := range mappings {
+			for _, p := range m.Products {
+				if normalizeProduct(p) == product {
+					return m.Type, true
+				}
+			}
+		}
+	}
+
+	ids := pciDeviceIDs(node)
+	for _, m := range mappings {
+		for _, id := range m.PCIDeviceIDs {
+			for _, nodeID := range ids {
+				if strings.ToLower(id) == nodeID {
+					return m.Type, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
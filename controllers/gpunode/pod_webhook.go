@@ -0,0 +1,190 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// PodWebhookPath is the path the pod mutating webhook is served at.
+const PodWebhookPath = "/mutate-v1-pod"
+
+// GPUProductAnnotation is the pod annotation which requests the GPU product
+// the pod is scheduled on, overriding the one of the GPU node isolation.
+func GPUProductAnnotation() string {
+	return fmt.Sprintf("%s/gpu-product", common.GlobalConfig.AddonID)
+}
+
+// PodMutator steers the pods requesting GPUs or MIG devices to the GPU nodes,
+// as configured by the GPU node isolation of the GPUAddon. The pods are given
+// the toleration of the GPU node taint, and a node selector for the requested
+// GPU product, if any.
+type PodMutator struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+//+kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,timeoutSeconds=5,groups="",resources=pods,verbs=create,versions=v1,name=gpupods.nvidia.addons.rh-ecosystem-edge.io,admissionReviewVersions=v1
+
+var _ admission.Handler = &PodMutator{}
+var _ admission.DecoderInjector = &PodMutator{}
+
+// Handle mutates the pods requesting GPUs. The pods are admitted unchanged
+// when the GPU node isolation cannot be read, as the webhook must not block
+// the workloads of the cluster.
+func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if isPlatformNamespace(req.Namespace) {
+		return admission.Allowed("The add-on and platform namespaces are exempt")
+	}
+
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if !common.PodUsesGPUs(pod) {
+		return admission.Allowed("The pod does not request GPUs")
+	}
+
+	isolation, err := m.getIsolation(ctx)
+	if err != nil {
+		log.FromContext(ctx).Info("Unable to get the GPU node isolation", "error", err.Error())
+		return admission.Allowed("The GPU node isolation is not available")
+	}
+
+	if isolation == nil {
+		return admission.Allowed("The GPU node isolation is disabled")
+	}
+
+	for _, ns := range isolation.ExemptNamespaces {
+		if ns == req.Namespace {
+			return admission.Allowed("The namespace is exempt")
+		}
+	}
+
+	if !mutatePod(pod, isolation) {
+		return admission.Allowed("The pod is already steered to the GPU nodes")
+	}
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// isPlatformNamespace returns whether the given namespace is the add-on one or
+// a namespace of the platform, whose pods are never mutated. The webhook
+// configuration excludes them by name too, except the openshift-* ones which
+// cannot be selected by prefix.
+func isPlatformNamespace(ns string) bool {
+	return ns == common.GlobalConfig.AddonNamespace ||
+		ns == metav1.NamespaceSystem ||
+		strings.HasPrefix(ns, "openshift-")
+}
+
+// InjectDecoder injects the decoder of the admission requests.
+func (m *PodMutator) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
+
+// getIsolation returns the GPU node isolation of the GPUAddon, or nil when it
+// is disabled or the add-on is being uninstalled.
+func (m *PodMutator) getIsolation(ctx context.Context) (*addonv1alpha1.GPUNodeIsolationSpec, error) {
+	g := &addonv1alpha1.GPUAddon{}
+	if err := m.Client.Get(ctx, types.NamespacedName{
+		Name:      common.GlobalConfig.AddonID,
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}, g); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get GPUAddon CR: %w", err)
+	}
+
+	if !g.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	return g.Spec.GPUNodeIsolation, nil
+}
+
+// mutatePod adds the toleration of the GPU node taint and the GPU product node
+// selector to the given pod, and returns whether it changed. The toleration
+// is added regardless of the nodes being tainted, so that the pods keep
+// running once they are.
+func mutatePod(pod *corev1.Pod, isolation *addonv1alpha1.GPUNodeIsolationSpec) bool {
+	changed := false
+
+	tolerated := false
+	for i := range pod.Spec.Tolerations {
+		if pod.Spec.Tolerations[i].ToleratesTaint(&common.GPUNodeTaint) {
+			tolerated = true
+			break
+		}
+	}
+	if !tolerated {
+		pod.Spec.Tolerations = append(pod.Spec.Tolerations, corev1.Toleration{
+			Key:      common.GPUNodeTaint.Key,
+			Operator: corev1.TolerationOpExists,
+			Effect:   common.GPUNodeTaint.Effect,
+		})
+		changed = true
+	}
+
+	product := isolation.GPUProduct
+	if p, ok := pod.Annotations[GPUProductAnnotation()]; ok {
+		product = p
+	}
+
+	// The node selector set by the pod itself is kept.
+	if _, ok := pod.Spec.NodeSelector[common.GPUProductLabel]; product != "" && !ok {
+		if pod.Spec.NodeSelector == nil {
+			pod.Spec.NodeSelector = map[string]string{}
+		}
+		pod.Spec.NodeSelector[common.GPUProductLabel] = product
+		changed = true
+	}
+
+	return changed
+}
+
+// SetupWebhookWithManager registers the pod mutating webhook with the webhook
+// server of the Manager.
+func (m *PodMutator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(PodWebhookPath, &webhook.Admission{Handler: m})
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("PodMutator", func() {
+	common.ProcessConfig()
+
+	newPod := func(resourceName corev1.ResourceName) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "notebook",
+				Namespace: "team-a",
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "notebook",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{resourceName: resource.MustParse("1")},
+					},
+				}},
+			},
+		}
+	}
+
+	newRequest := func(pod *corev1.Pod) admission.Request {
+		raw, err := json.Marshal(pod)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Namespace: pod.Namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	newMutator := func(objs ...runtime.Object) *PodMutator {
+		decoder, err := admission.NewDecoder(scheme.Scheme)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		m := &PodMutator{Client: newTestClient(objs...)}
+		Expect(m.InjectDecoder(decoder)).ShouldNot(HaveOccurred())
+		return m
+	}
+
+	patchedPaths := func(resp admission.Response) []string {
+		Expect(resp.Allowed).To(BeTrue())
+		paths := []string{}
+		for _, p := range resp.Patches {
+			paths = append(paths, p.Path)
+		}
+		return paths
+	}
+
+	It("should steer the pods requesting GPUs to the GPU nodes", func() {
+		m := newMutator(newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{GPUProduct: "NVIDIA-A100-SXM4-40GB"}))
+
+		resp := m.Handle(context.TODO(), newRequest(newPod(common.GPUResourceName)))
+		Expect(patchedPaths(resp)).To(ConsistOf("/spec/tolerations", "/spec/nodeSelector"))
+	})
+
+	It("should steer the pods requesting MIG devices to the GPU nodes", func() {
+		m := newMutator(newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{}))
+
+		resp := m.Handle(context.TODO(), newRequest(newPod("nvidia.com/mig-1g.5gb")))
+		Expect(patchedPaths(resp)).To(ConsistOf("/spec/tolerations"))
+	})
+
+	It("should not mutate the other pods", func() {
+		m := newMutator(newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{}))
+
+		resp := m.Handle(context.TODO(), newRequest(newPod(corev1.ResourceCPU)))
+		Expect(patchedPaths(resp)).To(BeEmpty())
+	})
+
+	It("should not mutate the pods of the exempt namespaces", func() {
+		m := newMutator(newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{ExemptNamespaces: []string{"team-a"}}))
+
+		resp := m.Handle(context.TODO(), newRequest(newPod(common.GPUResourceName)))
+		Expect(patchedPaths(resp)).To(BeEmpty())
+
+		for _, ns := range []string{common.GlobalConfig.AddonNamespace, "kube-system", "openshift-monitoring"} {
+			pod := newPod(common.GPUResourceName)
+			pod.Namespace = ns
+			resp = m.Handle(context.TODO(), newRequest(pod))
+			Expect(patchedPaths(resp)).To(BeEmpty())
+		}
+	})
+
+	It("should not mutate the pods when the GPU node isolation is disabled", func() {
+		resp := newMutator(newGPUAddon(nil)).Handle(context.TODO(), newRequest(newPod(common.GPUResourceName)))
+		Expect(patchedPaths(resp)).To(BeEmpty())
+
+		resp = newMutator().Handle(context.TODO(), newRequest(newPod(common.GPUResourceName)))
+		Expect(patchedPaths(resp)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("mutatePod", func() {
+	common.ProcessConfig()
+
+	It("should add the toleration and the product node selector", func() {
+		pod := &corev1.Pod{}
+
+		Expect(mutatePod(pod, &addonv1alpha1.GPUNodeIsolationSpec{GPUProduct: "NVIDIA-A100-SXM4-40GB"})).To(BeTrue())
+		Expect(pod.Spec.Tolerations).To(ConsistOf(corev1.Toleration{
+			Key:      "nvidia.com/gpu",
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}))
+		Expect(pod.Spec.NodeSelector).To(HaveKeyWithValue(common.GPUProductLabel, "NVIDIA-A100-SXM4-40GB"))
+
+		// The pod is not mutated again.
+		Expect(mutatePod(pod, &addonv1alpha1.GPUNodeIsolationSpec{GPUProduct: "NVIDIA-A100-SXM4-40GB"})).To(BeFalse())
+	})
+
+	It("should honor the GPU product requested by the pod", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{GPUProductAnnotation(): "Tesla-T4"},
+			},
+		}
+
+		mutatePod(pod, &addonv1alpha1.GPUNodeIsolationSpec{GPUProduct: "NVIDIA-A100-SXM4-40GB"})
+		Expect(pod.Spec.NodeSelector).To(HaveKeyWithValue(common.GPUProductLabel, "Tesla-T4"))
+	})
+
+	It("should keep the tolerations and node selectors of the pod", func() {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Tolerations:  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+				NodeSelector: map[string]string{common.GPUProductLabel: "Tesla-T4"},
+			},
+		}
+
+		Expect(mutatePod(pod, &addonv1alpha1.GPUNodeIsolationSpec{GPUProduct: "NVIDIA-A100-SXM4-40GB"})).To(BeFalse())
+		Expect(pod.Spec.Tolerations).To(HaveLen(1))
+		Expect(pod.Spec.NodeSelector).To(HaveKeyWithValue(common.GPUProductLabel, "Tesla-T4"))
+	})
+})
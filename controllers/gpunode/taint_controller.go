@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+// GPUNodeTaintReconciler taints the GPU nodes with the GPU node taint when the
+// GPU node isolation of the GPUAddon asks for it, and removes the taint it
+// added otherwise.
+type GPUNodeTaintReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// ManagedTaintAnnotation is the annotation of the nodes whose GPU node taint
+// was added by the add-on, so that the taints added by the administrators are
+// left alone.
+func ManagedTaintAnnotation() string {
+	return fmt.Sprintf("%s/gpu-taint", common.GlobalConfig.AddonID)
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+
+func (r *GPUNodeTaintReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	g := &addonv1alpha1.GPUAddon{}
+	if err := r.Get(ctx, req.NamespacedName, g); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("could not get GPUAddon CR: %w", err)
+		}
+		// The taints are removed once the add-on is gone.
+		g = nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	taint := g != nil && g.DeletionTimestamp.IsZero() &&
+		g.Spec.GPUNodeIsolation != nil && g.Spec.GPUNodeIsolation.TaintGPUNodes
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+
+		// The patch fails on a conflicting change to the taints, and the node
+		// is reconciled again.
+		patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		if !reconcileTaint(node, taint && node.Labels[common.GPUNodeLabel] == "true") {
+			continue
+		}
+
+		if err := r.Patch(ctx, node, patch); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return ctrl.Result{}, fmt.Errorf("failed to patch the GPU node taint of node %s: %w", node.Name, err)
+		}
+
+		log.FromContext(ctx).Info("Reconciled GPU node taint", "node", node.Name, "tainted", isManaged(node))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileTaint adds the managed GPU node taint to the given node, or removes
+// it, and returns whether the node changed.
+func reconcileTaint(node *corev1.Node, taint bool) bool {
+	if taint == isManaged(node) {
+		return false
+	}
+
+	if taint {
+		// A GPU node taint already set by the administrators is not taken
+		// over.
+		for i := range node.Spec.Taints {
+			if node.Spec.Taints[i].MatchTaint(&common.GPUNodeTaint) {
+				return false
+			}
+		}
+
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[ManagedTaintAnnotation()] = "true"
+		node.Spec.Taints = append(node.Spec.Taints, common.GPUNodeTaint)
+		return true
+	}
+
+	delete(node.Annotations, ManagedTaintAnnotation())
+	taints := []corev1.Taint{}
+	for _, t := range node.Spec.Taints {
+		if !t.MatchTaint(&common.GPUNodeTaint) {
+			taints = append(taints, t)
+		}
+	}
+	node.Spec.Taints = taints
+
+	return true
+}
+
+func isManaged(node *corev1.Node) bool {
+	return node.Annotations[ManagedTaintAnnotation()] == "true"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GPUNodeTaintReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toGPUAddon := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gpunodetaint").
+		For(&addonv1alpha1.GPUAddon{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// The GPU nodes are only affected by the nodes coming and going, or
+		// by their GPU labels, e.g. once labeled by NFD.
+		Watches(&source.Kind{Type: &corev1.Node{}}, toGPUAddon,
+			builder.WithPredicates(common.GPUNodeLabelsChanged)).
+		Complete(health.Reconciles.Track("gpunodetaint", r))
+}
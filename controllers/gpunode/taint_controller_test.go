@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpunode
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("GPUNodeTaint Reconcile", func() {
+	common.ProcessConfig()
+
+	newNode := func(name string, gpu bool, taints ...corev1.Taint) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.NodeSpec{
+				Taints: taints,
+			},
+		}
+		if gpu {
+			node.Labels = map[string]string{common.GPUNodeLabel: "true"}
+		}
+		return node
+	}
+
+	reconcile := func(r *GPUNodeTaintReconciler) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKey{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	getNode := func(r *GPUNodeTaintReconciler, name string) *corev1.Node {
+		node := &corev1.Node{}
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: name}, node)).ShouldNot(HaveOccurred())
+		return node
+	}
+
+	otherTaint := corev1.Taint{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}
+
+	It("should taint the GPU nodes only", func() {
+		r := newTestGPUNodeTaintReconciler(
+			newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{TaintGPUNodes: true}),
+			newNode("gpu-node", true, otherTaint),
+			newNode("cpu-node", false))
+
+		reconcile(r)
+
+		gpuNode := getNode(r, "gpu-node")
+		Expect(gpuNode.Spec.Taints).To(ConsistOf(otherTaint, common.GPUNodeTaint))
+		Expect(gpuNode.Annotations).To(HaveKeyWithValue(ManagedTaintAnnotation(), "true"))
+
+		cpuNode := getNode(r, "cpu-node")
+		Expect(cpuNode.Spec.Taints).To(BeEmpty())
+		Expect(cpuNode.Annotations).ToNot(HaveKey(ManagedTaintAnnotation()))
+
+		// The nodes are only tainted once.
+		reconcile(r)
+		Expect(getNode(r, "gpu-node").Spec.Taints).To(HaveLen(2))
+	})
+
+	It("should not take over the GPU node taints set by the administrators", func() {
+		r := newTestGPUNodeTaintReconciler(
+			newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{TaintGPUNodes: true}),
+			newNode("gpu-node", true, common.GPUNodeTaint))
+
+		reconcile(r)
+
+		gpuNode := getNode(r, "gpu-node")
+		Expect(gpuNode.Spec.Taints).To(ConsistOf(common.GPUNodeTaint))
+		Expect(gpuNode.Annotations).ToNot(HaveKey(ManagedTaintAnnotation()))
+	})
+
+	It("should remove the managed taints once disabled", func() {
+		managed := newNode("managed", true, otherTaint, common.GPUNodeTaint)
+		managed.Annotations = map[string]string{ManagedTaintAnnotation(): "true"}
+		r := newTestGPUNodeTaintReconciler(
+			newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{}),
+			managed,
+			newNode("unmanaged", true, common.GPUNodeTaint))
+
+		reconcile(r)
+
+		node := getNode(r, "managed")
+		Expect(node.Spec.Taints).To(ConsistOf(otherTaint))
+		Expect(node.Annotations).ToNot(HaveKey(ManagedTaintAnnotation()))
+
+		Expect(getNode(r, "unmanaged").Spec.Taints).To(ConsistOf(common.GPUNodeTaint))
+	})
+
+	It("should remove the managed taints once the add-on is uninstalled", func() {
+		g := newGPUAddon(&addonv1alpha1.GPUNodeIsolationSpec{TaintGPUNodes: true})
+		g.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		g.Finalizers = []string{"test"}
+		managed := newNode("managed", true, common.GPUNodeTaint)
+		managed.Annotations = map[string]string{ManagedTaintAnnotation(): "true"}
+		r := newTestGPUNodeTaintReconciler(g, managed)
+
+		reconcile(r)
+		Expect(getNode(r, "managed").Spec.Taints).To(BeEmpty())
+	})
+})
+
+func newGPUAddon(isolation *addonv1alpha1.GPUNodeIsolationSpec) *addonv1alpha1.GPUAddon {
+	return &addonv1alpha1.GPUAddon{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+		Spec: addonv1alpha1.GPUAddonSpec{
+			GPUNodeIsolation: isolation,
+		},
+	}
+}
+
+func newTestClient(objs ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+}
+
+func newTestGPUNodeTaintReconciler(objs ...runtime.Object) *GPUNodeTaintReconciler {
+	c := newTestClient(objs...)
+
+	return &GPUNodeTaintReconciler{
+		Client: c,
+		Scheme: c.Scheme(),
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuquota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+const GPUQuotaEnforcedCondition = "GPUQuotaEnforced"
+
+// gpuQuotaResyncInterval is how often the usage of the ResourceQuotas is
+// refreshed, as they are not watched.
+const gpuQuotaResyncInterval = time.Minute
+
+// GPUQuotaReconciler materializes each GPUQuota as a ResourceQuota in each of
+// the namespaces it selects, and reports their aggregated usage.
+type GPUQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the namespaces and the ResourceQuotas of all the
+	// namespaces, which are not available in the namespaced cache of the
+	// manager.
+	APIReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuquotas,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuquotas/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;create;patch;delete
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.2/pkg/reconcile
+func (r *GPUQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	q := &addonv1alpha1.GPUQuota{}
+	if err := r.Get(ctx, req.NamespacedName, q); err != nil {
+		if k8serrors.IsNotFound(err) {
+			logger.Info("GPUQuota CR not found. Probably deleted.")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not get GPUQuota CR: %w", err)
+	}
+
+	// The ResourceQuotas are in other namespaces, and cannot be owned by
+	// the GPUQuota.
+	if !q.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, q)
+	}
+
+	if !controllerutil.ContainsFinalizer(q, common.GlobalConfig.AddonID) {
+		controllerutil.AddFinalizer(q, common.GlobalConfig.AddonID)
+		if err := r.Update(ctx, q); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	// The ResourceQuotas of the last valid spec stay enforced, and so does
+	// their reported usage.
+	if err := validateHard(q.Spec.Hard); err != nil {
+		return ctrl.Result{}, r.patchCondition(ctx, q, common.NewCondition(
+			GPUQuotaEnforcedCondition,
+			metav1.ConditionFalse,
+			"InvalidHard",
+			fmt.Sprintf("%s, the last valid GPU quota is still enforced", err)))
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&q.Spec.NamespaceSelector)
+	if err != nil {
+		return ctrl.Result{}, r.patchCondition(ctx, q, common.NewCondition(
+			GPUQuotaEnforcedCondition,
+			metav1.ConditionFalse,
+			"InvalidNamespaceSelector",
+			fmt.Sprintf("%s, the last valid GPU quota is still enforced", err)))
+	}
+
+	namespaces, err := r.selectNamespaces(ctx, selector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	keep := map[string]bool{}
+	quotas := []*corev1.ResourceQuota{}
+	conflicts := []string{}
+	for _, ns := range namespaces {
+		rq, err := r.reconcileResourceQuota(ctx, q, ns)
+		if errors.Is(err, errResourceQuotaNotOwned) {
+			conflicts = append(conflicts, ns)
+			continue
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		keep[ns] = true
+		quotas = append(quotas, rq)
+	}
+
+	if err := r.deleteStaleResourceQuotas(ctx, q, keep); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	condition := common.NewCondition(
+		GPUQuotaEnforcedCondition,
+		metav1.ConditionTrue,
+		"Enforced",
+		fmt.Sprintf("The GPU quota is enforced in %d namespaces", len(namespaces)))
+	if len(conflicts) > 0 {
+		condition = common.NewCondition(
+			GPUQuotaEnforcedCondition,
+			metav1.ConditionFalse,
+			"ResourceQuotaConflict",
+			fmt.Sprintf("The ResourceQuota %s already exists in namespaces %s without the %s label",
+				resourceQuotaName(q), strings.Join(conflicts, ", "), GPUQuotaLabel()))
+	}
+
+	if err := r.patchStatus(ctx, q, quotas, condition); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	recordQuotaMetrics(q)
+
+	return ctrl.Result{RequeueAfter: gpuQuotaResyncInterval}, nil
+}
+
+// finalize deletes the ResourceQuotas of the given GPUQuota, and removes its
+// finalizer.
+func (r *GPUQuotaReconciler) finalize(ctx context.Context, q *addonv1alpha1.GPUQuota) error {
+	if !controllerutil.ContainsFinalizer(q, common.GlobalConfig.AddonID) {
+		return nil
+	}
+
+	if err := r.deleteStaleResourceQuotas(ctx, q, nil); err != nil {
+		return err
+	}
+
+	deleteQuotaMetrics(q.Name)
+
+	controllerutil.RemoveFinalizer(q, common.GlobalConfig.AddonID)
+	if err := r.Update(ctx, q); err != nil {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+
+	return nil
+}
+
+// patchStatus reports the given condition and the aggregated usage of the
+// given ResourceQuotas.
+func (r *GPUQuotaReconciler) patchStatus(
+	ctx context.Context,
+	q *addonv1alpha1.GPUQuota,
+	quotas []*corev1.ResourceQuota,
+	condition metav1.Condition) error {
+
+	patch := client.MergeFrom(q.DeepCopy())
+	q.Status.ObservedGeneration = q.Generation
+	q.Status.Hard, q.Status.Used, q.Status.Namespaces = quotaStatus(q, quotas)
+	meta.SetStatusCondition(&q.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, q, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+// patchCondition reports the given condition, and leaves the usage reported
+// for the last valid spec as is.
+func (r *GPUQuotaReconciler) patchCondition(
+	ctx context.Context,
+	q *addonv1alpha1.GPUQuota,
+	condition metav1.Condition) error {
+
+	patch := client.MergeFrom(q.DeepCopy())
+	meta.SetStatusCondition(&q.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, q, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GPUQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toGPUQuotas := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		quotas := &addonv1alpha1.GPUQuotaList{}
+		if err := mgr.GetClient().List(context.TODO(), quotas,
+			client.InNamespace(common.GlobalConfig.AddonNamespace)); err != nil {
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, q := range quotas.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      q.Name,
+				Namespace: q.Namespace,
+			}})
+		}
+		return requests
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// The status updates do not trigger a new reconciliation.
+		For(&addonv1alpha1.GPUQuota{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// The selected namespaces are only affected by the namespaces coming
+		// and going, or by their labels.
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, toGPUQuotas,
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(health.Reconciles.Track("gpuquota", r))
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuquota
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("GPUQuota Reconcile", func() {
+	common.ProcessConfig()
+
+	newGPUQuota := func(gpus string) *addonv1alpha1.GPUQuota {
+		return &addonv1alpha1.GPUQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tenants",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUQuotaSpec{
+				NamespaceSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"tenant": "true"},
+				},
+				Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse(gpus)},
+			},
+		}
+	}
+
+	newNamespace := func(name string, tenant bool) *corev1.Namespace {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+		if tenant {
+			ns.Labels = map[string]string{"tenant": "true"}
+		}
+		return ns
+	}
+
+	reconcile := func(r *GPUQuotaReconciler, q *addonv1alpha1.GPUQuota) ctrl.Result {
+		res, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(q)})
+		Expect(err).ShouldNot(HaveOccurred())
+		return res
+	}
+
+	getQuota := func(r *GPUQuotaReconciler, q *addonv1alpha1.GPUQuota) *addonv1alpha1.GPUQuota {
+		got := &addonv1alpha1.GPUQuota{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(q), got)).ShouldNot(HaveOccurred())
+		return got
+	}
+
+	getResourceQuota := func(r *GPUQuotaReconciler, namespace string) (*corev1.ResourceQuota, error) {
+		rq := &corev1.ResourceQuota{}
+		err := r.Get(context.TODO(), client.ObjectKey{Name: "gpu-quota-tenants", Namespace: namespace}, rq)
+		return rq, err
+	}
+
+	It("should create a ResourceQuota in the selected namespaces", func() {
+		q := newGPUQuota("4")
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true), newNamespace("team-b", false))
+
+		res := reconcile(r, q)
+		Expect(res.RequeueAfter).To(Equal(gpuQuotaResyncInterval))
+
+		rq, err := getResourceQuota(r, "team-a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rq.Labels).To(HaveKeyWithValue(GPUQuotaLabel(), "tenants"))
+		Expect(rq.Spec.Hard).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("4")))
+
+		_, err = getResourceQuota(r, "team-b")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+		q = getQuota(r, q)
+		Expect(q.Finalizers).To(ContainElement(common.GlobalConfig.AddonID))
+		c := meta.FindStatusCondition(q.Status.Conditions, GPUQuotaEnforcedCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionTrue))
+		Expect(c.Message).To(Equal("The GPU quota is enforced in 1 namespaces"))
+		Expect(q.Status.Namespaces).To(HaveLen(1))
+		Expect(testutil.ToFloat64(GPUQuotaNamespaces.WithLabelValues("tenants"))).To(Equal(1.0))
+	})
+
+	It("should update the ResourceQuotas and report their usage", func() {
+		q := newGPUQuota("8")
+		rq := &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gpu-quota-tenants",
+				Namespace: "team-a",
+				Labels:    map[string]string{GPUQuotaLabel(): "tenants"},
+			},
+			Spec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+				Used: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("3")},
+			},
+		}
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true), rq)
+
+		reconcile(r, q)
+
+		rq, err := getResourceQuota(r, "team-a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rq.Spec.Hard).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("8")))
+
+		q = getQuota(r, q)
+		Expect(q.Status.Used).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("3")))
+		Expect(testutil.ToFloat64(GPUQuotaUsed.WithLabelValues("tenants", "requests.nvidia.com/gpu"))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(GPUQuotaHard.WithLabelValues("tenants", "requests.nvidia.com/gpu"))).To(Equal(4.0))
+	})
+
+	It("should delete the ResourceQuotas of the namespaces which are not selected anymore", func() {
+		q := newGPUQuota("4")
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", false), &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gpu-quota-tenants",
+				Namespace: "team-a",
+				Labels:    map[string]string{GPUQuotaLabel(): "tenants"},
+			},
+		})
+
+		reconcile(r, q)
+
+		_, err := getResourceQuota(r, "team-a")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(getQuota(r, q).Status.Namespaces).To(BeEmpty())
+	})
+
+	It("should report the hard limits which are not GPU resources", func() {
+		q := newGPUQuota("4")
+		q.Spec.Hard = corev1.ResourceList{"requests.cpu": resource.MustParse("4")}
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true))
+
+		reconcile(r, q)
+
+		c := meta.FindStatusCondition(getQuota(r, q).Status.Conditions, GPUQuotaEnforcedCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal("InvalidHard"))
+
+		_, err := getResourceQuota(r, "team-a")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should keep the last valid GPU quota enforced when the spec is invalid", func() {
+		q := newGPUQuota("4")
+		q.Spec.Hard = corev1.ResourceList{"requests.cpu": resource.MustParse("4")}
+		q.Status = addonv1alpha1.GPUQuotaStatus{
+			ObservedGeneration: 1,
+			Hard:               corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			Used:               corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("3")},
+			Namespaces:         []addonv1alpha1.NamespaceGPUQuota{{Namespace: "team-a"}},
+		}
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true), &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gpu-quota-tenants",
+				Namespace: "team-a",
+				Labels:    map[string]string{GPUQuotaLabel(): "tenants"},
+			},
+			Spec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			},
+		})
+
+		reconcile(r, q)
+
+		rq, err := getResourceQuota(r, "team-a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rq.Spec.Hard).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("4")))
+
+		q = getQuota(r, q)
+		Expect(q.Status.ObservedGeneration).To(Equal(int64(1)))
+		Expect(q.Status.Hard).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("4")))
+		Expect(q.Status.Used).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("3")))
+		Expect(q.Status.Namespaces).To(HaveLen(1))
+
+		c := meta.FindStatusCondition(q.Status.Conditions, GPUQuotaEnforcedCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal("InvalidHard"))
+		Expect(c.Message).To(ContainSubstring("the last valid GPU quota is still enforced"))
+	})
+
+	It("should not adopt the ResourceQuotas without the GPU quota label", func() {
+		q := newGPUQuota("4")
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true), newNamespace("team-b", true),
+			&corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gpu-quota-tenants",
+					Namespace: "team-a",
+				},
+				Spec: corev1.ResourceQuotaSpec{
+					Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("1")},
+				},
+			})
+
+		reconcile(r, q)
+
+		rq, err := getResourceQuota(r, "team-a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rq.Labels).ToNot(HaveKey(GPUQuotaLabel()))
+		Expect(rq.Spec.Hard).To(HaveKeyWithValue(corev1.ResourceName("requests.nvidia.com/gpu"), resource.MustParse("1")))
+
+		_, err = getResourceQuota(r, "team-b")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		q = getQuota(r, q)
+		Expect(q.Status.Namespaces).To(HaveLen(1))
+		Expect(q.Status.Namespaces[0].Namespace).To(Equal("team-b"))
+
+		c := meta.FindStatusCondition(q.Status.Conditions, GPUQuotaEnforcedCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal("ResourceQuotaConflict"))
+		Expect(c.Message).To(ContainSubstring("team-a"))
+	})
+
+	It("should delete the ResourceQuotas once deleted", func() {
+		q := newGPUQuota("4")
+		q.Finalizers = []string{common.GlobalConfig.AddonID}
+		q.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		r := newTestGPUQuotaReconciler(q, newNamespace("team-a", true), &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gpu-quota-tenants",
+				Namespace: "team-a",
+				Labels:    map[string]string{GPUQuotaLabel(): "tenants"},
+			},
+		})
+
+		reconcile(r, q)
+
+		_, err := getResourceQuota(r, "team-a")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+		got := &addonv1alpha1.GPUQuota{}
+		err = r.Get(context.TODO(), client.ObjectKeyFromObject(q), got)
+		if err == nil {
+			Expect(got.Finalizers).To(BeEmpty())
+		} else {
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		}
+	})
+})
+
+func newTestGPUQuotaReconciler(objs ...runtime.Object) *GPUQuotaReconciler {
+	s := scheme.Scheme
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &GPUQuotaReconciler{
+		Client:    c,
+		Scheme:    s,
+		APIReader: c,
+	}
+}
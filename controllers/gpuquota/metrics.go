@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuquota
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var (
+	GPUQuotaHard = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_gpu_quota_hard",
+			Help: "Reports the sum of the hard limits of the namespaces selected by a GPUQuota per resource",
+		},
+		[]string{"gpu_quota", "resource"},
+	)
+
+	GPUQuotaUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_gpu_quota_used",
+			Help: "Reports the sum of the resources requested by the namespaces selected by a GPUQuota per resource",
+		},
+		[]string{"gpu_quota", "resource"},
+	)
+
+	GPUQuotaNamespaces = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_gpu_quota_namespaces",
+			Help: "Reports the number of namespaces selected by a GPUQuota",
+		},
+		[]string{"gpu_quota"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		GPUQuotaHard,
+		GPUQuotaUsed,
+		GPUQuotaNamespaces,
+	)
+}
+
+// recordQuotaMetrics exports the aggregated status of the given GPUQuota.
+func recordQuotaMetrics(q *addonv1alpha1.GPUQuota) {
+	// The series of the resources which are not limited anymore do not
+	// linger.
+	deleteQuotaMetrics(q.Name)
+
+	for name, quantity := range q.Status.Hard {
+		GPUQuotaHard.WithLabelValues(q.Name, string(name)).Set(quantity.AsApproximateFloat64())
+	}
+	for name, quantity := range q.Status.Used {
+		GPUQuotaUsed.WithLabelValues(q.Name, string(name)).Set(quantity.AsApproximateFloat64())
+	}
+	GPUQuotaNamespaces.WithLabelValues(q.Name).Set(float64(len(q.Status.Namespaces)))
+}
+
+// deleteQuotaMetrics removes the series of the given GPUQuota.
+func deleteQuotaMetrics(name string) {
+	GPUQuotaHard.DeletePartialMatch(prometheus.Labels{"gpu_quota": name})
+	GPUQuotaUsed.DeletePartialMatch(prometheus.Labels{"gpu_quota": name})
+	GPUQuotaNamespaces.DeletePartialMatch(prometheus.Labels{"gpu_quota": name})
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuquota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// The GPU and MIG resources can only be limited by their requests in a
+// ResourceQuota, as they are extended resources.
+const requestsPrefix = "requests."
+
+// GPUQuotaLabel is the label of the ResourceQuotas materialized for a
+// GPUQuota, set to its name.
+func GPUQuotaLabel() string {
+	return fmt.Sprintf("%s/gpu-quota", common.GlobalConfig.AddonID)
+}
+
+// resourceQuotaName returns the name of the ResourceQuotas materialized for
+// the given GPUQuota.
+func resourceQuotaName(q *addonv1alpha1.GPUQuota) string {
+	return "gpu-quota-" + q.Name
+}
+
+// validateHard returns an error if the given hard limits are not the requests
+// of GPU or MIG resources.
+func validateHard(hard corev1.ResourceList) error {
+	if len(hard) == 0 {
+		return errors.New("no GPU resources are limited")
+	}
+
+	for name := range hard {
+		requested := strings.TrimPrefix(string(name), requestsPrefix)
+		if requested == string(name) ||
+			(requested != string(common.GPUResourceName) && !strings.HasPrefix(requested, common.MIGResourcePrefix)) {
+			return fmt.Errorf("%s is not the requests of a GPU or MIG resource", name)
+		}
+	}
+
+	return nil
+}
+
+// selectNamespaces returns the names of the namespaces matching the given
+// selector, sorted. The terminating namespaces are left out, as their
+// ResourceQuotas cannot be created.
+func (r *GPUQuotaReconciler) selectNamespaces(ctx context.Context, selector labels.Selector) ([]string, error) {
+	namespaces := &corev1.NamespaceList{}
+	if err := r.APIReader.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	names := []string{}
+	for _, ns := range namespaces.Items {
+		if ns.Status.Phase == corev1.NamespaceTerminating || !ns.DeletionTimestamp.IsZero() {
+			continue
+		}
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// errResourceQuotaNotOwned is returned when the ResourceQuota of a GPUQuota
+// already exists in a namespace without being labeled for it.
+var errResourceQuotaNotOwned = errors.New("the ResourceQuota is not labeled for the GPUQuota")
+
+// reconcileResourceQuota creates or updates the ResourceQuota of the given
+// GPUQuota in the given namespace, and returns it. The ResourceQuotas are
+// read with the API reader, as they are not in the namespaced cache of the
+// manager. An existing ResourceQuota which is not labeled for the GPUQuota is
+// left alone and errResourceQuotaNotOwned is returned.
+func (r *GPUQuotaReconciler) reconcileResourceQuota(
+	ctx context.Context,
+	q *addonv1alpha1.GPUQuota,
+	namespace string) (*corev1.ResourceQuota, error) {
+
+	rq := &corev1.ResourceQuota{}
+	key := types.NamespacedName{Name: resourceQuotaName(q), Namespace: namespace}
+
+	if err := r.APIReader.Get(ctx, key, rq); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get ResourceQuota %s: %w", key, err)
+		}
+
+		rq = &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{GPUQuotaLabel(): q.Name},
+			},
+			Spec: corev1.ResourceQuotaSpec{
+				Hard: q.Spec.Hard.DeepCopy(),
+			},
+		}
+		if err := r.Create(ctx, rq); err != nil {
+			return nil, fmt.Errorf("failed to create ResourceQuota %s: %w", key, err)
+		}
+
+		log.FromContext(ctx).Info("Created GPU ResourceQuota", "namespace", namespace)
+		return rq, nil
+	}
+
+	if rq.Labels[GPUQuotaLabel()] != q.Name {
+		return nil, fmt.Errorf("ResourceQuota %s: %w", key, errResourceQuotaNotOwned)
+	}
+
+	if equalResources(rq.Spec.Hard, q.Spec.Hard) {
+		return rq, nil
+	}
+
+	patch := client.MergeFrom(rq.DeepCopy())
+	rq.Spec.Hard = q.Spec.Hard.DeepCopy()
+	if err := r.Patch(ctx, rq, patch); err != nil {
+		return nil, fmt.Errorf("failed to patch ResourceQuota %s: %w", key, err)
+	}
+
+	return rq, nil
+}
+
+// deleteStaleResourceQuotas deletes the ResourceQuotas of the given GPUQuota
+// in the namespaces which are not in the given set anymore.
+func (r *GPUQuotaReconciler) deleteStaleResourceQuotas(
+	ctx context.Context,
+	q *addonv1alpha1.GPUQuota,
+	keep map[string]bool) error {
+
+	quotas := &corev1.ResourceQuotaList{}
+	if err := r.APIReader.List(ctx, quotas, client.MatchingLabels{GPUQuotaLabel(): q.Name}); err != nil {
+		return fmt.Errorf("failed to list ResourceQuotas: %w", err)
+	}
+
+	for i := range quotas.Items {
+		rq := &quotas.Items[i]
+		if keep[rq.Namespace] {
+			continue
+		}
+
+		if err := r.Delete(ctx, rq); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ResourceQuota %s/%s: %w", rq.Namespace, rq.Name, err)
+		}
+
+		log.FromContext(ctx).Info("Deleted GPU ResourceQuota", "namespace", rq.Namespace)
+	}
+
+	return nil
+}
+
+// quotaStatus aggregates the hard limits and usage of the given ResourceQuotas
+// of the given GPUQuota.
+func quotaStatus(q *addonv1alpha1.GPUQuota, quotas []*corev1.ResourceQuota) (
+	corev1.ResourceList, corev1.ResourceList, []addonv1alpha1.NamespaceGPUQuota) {
+
+	hard := corev1.ResourceList{}
+	used := corev1.ResourceList{}
+	namespaces := []addonv1alpha1.NamespaceGPUQuota{}
+
+	for _, rq := range quotas {
+		ns := addonv1alpha1.NamespaceGPUQuota{
+			Namespace: rq.Namespace,
+			Hard:      corev1.ResourceList{},
+			Used:      corev1.ResourceList{},
+		}
+
+		for name := range q.Spec.Hard {
+			// The ResourceQuota status is only set once the quota
+			// controller has observed it.
+			if h, ok := rq.Status.Hard[name]; ok {
+				ns.Hard[name] = h.DeepCopy()
+				addResource(hard, name, h)
+			}
+			if u, ok := rq.Status.Used[name]; ok {
+				ns.Used[name] = u.DeepCopy()
+				addResource(used, name, u)
+			}
+		}
+
+		namespaces = append(namespaces, ns)
+	}
+
+	return hard, used, namespaces
+}
+
+func addResource(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+	sum := list[name]
+	sum.Add(quantity)
+	list[name] = sum
+}
+
+// equalResources returns whether the given resource lists have the same
+// resources and quantities.
+func equalResources(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name, qa := range a {
+		qb, ok := b[name]
+		if !ok || qa.Cmp(qb) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
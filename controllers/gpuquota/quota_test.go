@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpuquota
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("GPU quotas", func() {
+	It("should only limit the requests of GPU and MIG resources", func() {
+		Expect(validateHard(corev1.ResourceList{
+			"requests.nvidia.com/gpu":        resource.MustParse("4"),
+			"requests.nvidia.com/mig-1g.5gb": resource.MustParse("2"),
+		})).To(Succeed())
+
+		Expect(validateHard(corev1.ResourceList{})).ToNot(Succeed())
+		Expect(validateHard(corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")})).
+			To(MatchError(ContainSubstring("nvidia.com/gpu is not the requests of a GPU or MIG resource")))
+		Expect(validateHard(corev1.ResourceList{"requests.cpu": resource.MustParse("4")})).ToNot(Succeed())
+	})
+
+	It("should aggregate the usage of the ResourceQuotas", func() {
+		q := &addonv1alpha1.GPUQuota{
+			Spec: addonv1alpha1.GPUQuotaSpec{
+				Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			},
+		}
+		newResourceQuota := func(namespace string, used string) *corev1.ResourceQuota {
+			return &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{
+						"requests.nvidia.com/gpu": resource.MustParse("4"),
+						"pods":                    resource.MustParse("10"),
+					},
+					Used: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse(used)},
+				},
+			}
+		}
+
+		hard, used, namespaces := quotaStatus(q, []*corev1.ResourceQuota{
+			newResourceQuota("team-a", "3"),
+			newResourceQuota("team-b", "1"),
+			// Not observed by the quota controller yet.
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "team-c"}},
+		})
+
+		Expect(hard).To(HaveLen(1))
+		Expect(hard.Name("requests.nvidia.com/gpu", resource.DecimalSI).String()).To(Equal("8"))
+		Expect(used.Name("requests.nvidia.com/gpu", resource.DecimalSI).String()).To(Equal("4"))
+		Expect(namespaces).To(HaveLen(3))
+		Expect(namespaces[0].Namespace).To(Equal("team-a"))
+		Expect(namespaces[0].Used.Name("requests.nvidia.com/gpu", resource.DecimalSI).String()).To(Equal("3"))
+		Expect(namespaces[2].Hard).To(BeEmpty())
+	})
+
+	It("should compare the resource quantities", func() {
+		Expect(equalResources(
+			corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("1000m")},
+			corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("1")})).To(BeTrue())
+		Expect(equalResources(
+			corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("1")},
+			corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("2")})).To(BeFalse())
+		Expect(equalResources(
+			corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("1")},
+			corev1.ResourceList{"requests.nvidia.com/mig-1g.5gb": resource.MustParse("1")})).To(BeFalse())
+	})
+})
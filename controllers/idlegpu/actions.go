@@ -0,0 +1,222 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+// PodEvicter evicts pods through the eviction API, which honors their
+// PodDisruptionBudgets.
+type PodEvicter interface {
+	Evict(ctx context.Context, pod *corev1.Pod) error
+}
+
+// applyActions takes the action the namespace of each of the given idle pods
+// opted in to, if any.
+func (r *IdleGPUReconciler) applyActions(
+	ctx context.Context,
+	idle []idlePod,
+	now time.Time) ([]addonv1alpha1.IdleGPUDecision, error) {
+
+	decisions := []addonv1alpha1.IdleGPUDecision{}
+	actions := map[string]addonv1alpha1.IdleGPUAction{}
+
+	for i := range idle {
+		pod := &idle[i].pod
+
+		// The pod is already on its way out.
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		action, ok := actions[pod.Namespace]
+		if !ok {
+			var err error
+			if action, err = r.namespaceAction(ctx, pod.Namespace); err != nil {
+				return nil, err
+			}
+			actions[pod.Namespace] = action
+		}
+
+		var decision *addonv1alpha1.IdleGPUDecision
+		switch action {
+		case addonv1alpha1.IdleGPUActionEvict:
+			decision = r.evict(ctx, pod, now)
+		case addonv1alpha1.IdleGPUActionScaleDown:
+			var err error
+			if decision, err = r.scaleDown(ctx, pod, now); err != nil {
+				return nil, err
+			}
+		}
+
+		if decision != nil {
+			decisions = append(decisions, *decision)
+		}
+	}
+
+	return decisions, nil
+}
+
+// namespaceAction returns the action the given namespace opted in to with the
+// idle GPU action label. An unknown action is ignored.
+func (r *IdleGPUReconciler) namespaceAction(ctx context.Context, name string) (addonv1alpha1.IdleGPUAction, error) {
+	ns := &corev1.Namespace{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return "", fmt.Errorf("failed to get namespace %s: %w", name, err)
+	}
+
+	switch action := addonv1alpha1.IdleGPUAction(ns.Labels[IdleGPUActionLabel()]); action {
+	case addonv1alpha1.IdleGPUActionEvict, addonv1alpha1.IdleGPUActionScaleDown:
+		return action, nil
+	case "":
+		return "", nil
+	default:
+		log.FromContext(ctx).Info("Ignoring unknown idle GPU action", "namespace", name, "action", action)
+		return "", nil
+	}
+}
+
+// evict evicts the given pod. A failed eviction, e.g. blocked by a
+// PodDisruptionBudget, is reported and retried at the next check.
+func (r *IdleGPUReconciler) evict(ctx context.Context, pod *corev1.Pod, now time.Time) *addonv1alpha1.IdleGPUDecision {
+	if err := r.Evicter.Evict(ctx, pod); err != nil {
+		log.FromContext(ctx).Info("Failed to evict idle GPU pod",
+			"pod", client.ObjectKeyFromObject(pod).String(),
+			"error", err.Error())
+		decision := newDecision(pod, addonv1alpha1.IdleGPUActionSkip, fmt.Sprintf("Failed to evict the pod: %v", err), now)
+		return &decision
+	}
+
+	r.Recorder.Event(pod, corev1.EventTypeWarning, "IdleGPUEvicted", "The pod was evicted as its GPUs are idle")
+
+	decision := newDecision(pod, addonv1alpha1.IdleGPUActionEvict, "The pod was evicted", now)
+	return &decision
+}
+
+// scaleDown scales the Deployment or the StatefulSet owning the given pod
+// down to zero replicas. Nothing is reported when it is already scaled down,
+// as its pods are terminating.
+func (r *IdleGPUReconciler) scaleDown(
+	ctx context.Context,
+	pod *corev1.Pod,
+	now time.Time) (*addonv1alpha1.IdleGPUDecision, error) {
+
+	workload, err := r.getScalableOwner(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	if workload == nil {
+		decision := newDecision(pod, addonv1alpha1.IdleGPUActionSkip,
+			"The pod is not owned by a Deployment or a StatefulSet, and cannot be scaled down", now)
+		return &decision, nil
+	}
+
+	var replicas **int32
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		replicas = &w.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = &w.Spec.Replicas
+	}
+
+	if *replicas != nil && **replicas == 0 {
+		return nil, nil
+	}
+
+	kind := workload.GetObjectKind().GroupVersionKind().Kind
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	zero := int32(0)
+	*replicas = &zero
+	if err := r.Patch(ctx, workload, patch); err != nil {
+		return nil, fmt.Errorf("failed to scale down %s %s/%s: %w", kind, workload.GetNamespace(), workload.GetName(), err)
+	}
+
+	message := fmt.Sprintf("%s %s was scaled down to 0 replicas", kind, workload.GetName())
+	r.Recorder.Event(workload, corev1.EventTypeWarning, "IdleGPUScaledDown",
+		fmt.Sprintf("Scaled down to 0 replicas as the GPUs of pod %s are idle", pod.Name))
+
+	decision := newDecision(pod, addonv1alpha1.IdleGPUActionScaleDown, message, now)
+	return &decision, nil
+}
+
+// getScalableOwner returns the Deployment or the StatefulSet owning the given
+// pod, or nil if it has none.
+func (r *IdleGPUReconciler) getScalableOwner(ctx context.Context, pod *corev1.Pod) (client.Object, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+
+	switch owner.Kind {
+	case "StatefulSet":
+		sts := &appsv1.StatefulSet{}
+		return r.getOwner(ctx, pod.Namespace, owner, sts)
+	case "ReplicaSet":
+		rs := &appsv1.ReplicaSet{}
+		if obj, err := r.getOwner(ctx, pod.Namespace, owner, rs); obj == nil || err != nil {
+			return nil, err
+		}
+
+		owner = metav1.GetControllerOf(rs)
+		if owner == nil || owner.Kind != "Deployment" {
+			return nil, nil
+		}
+
+		dp := &appsv1.Deployment{}
+		return r.getOwner(ctx, pod.Namespace, owner, dp)
+	}
+
+	return nil, nil
+}
+
+// getOwner gets the given apps/v1 owner into the given object, and returns it
+// with its kind set. It returns nil if the owner is gone.
+func (r *IdleGPUReconciler) getOwner(
+	ctx context.Context,
+	namespace string,
+	owner *metav1.OwnerReference,
+	obj client.Object) (client.Object, error) {
+
+	if owner.APIVersion != appsv1.SchemeGroupVersion.String() {
+		return nil, nil
+	}
+
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: namespace}, obj); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", owner.Kind, namespace, owner.Name, err)
+	}
+
+	// The kind is not set on the typed objects returned by the API reader.
+	obj.GetObjectKind().SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(owner.Kind))
+
+	return obj, nil
+}
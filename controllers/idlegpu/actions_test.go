@@ -0,0 +1,139 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("IdleGPU actions", func() {
+	common.ProcessConfig()
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "notebook",
+			Namespace: "team-a",
+			UID:       "deployment-uid",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(1),
+		},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "notebook-5d4f8",
+			Namespace: "team-a",
+			UID:       "replicaset-uid",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       deployment.Name,
+				UID:        deployment.UID,
+				Controller: pointer.Bool(true),
+			}},
+		},
+	}
+
+	newOwnedPod := func() *corev1.Pod {
+		pod := newGPUPod("notebook-5d4f8-x2k9q", "team-a", now.Add(-5*time.Hour))
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Name:       replicaSet.Name,
+			UID:        replicaSet.UID,
+			Controller: pointer.Bool(true),
+		}}
+		return pod
+	}
+
+	It("should take no action in the namespaces which did not opt in", func() {
+		pod := newOwnedPod()
+		r := newTestIdleGPUReconciler(nil, newNamespace("team-a", ""), deployment, replicaSet, pod)
+
+		decisions, err := r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(BeEmpty())
+		Expect(r.Evicter.(*fakePodEvicter).evicted).To(BeEmpty())
+	})
+
+	It("should evict the idle pods", func() {
+		pod := newOwnedPod()
+		r := newTestIdleGPUReconciler(nil, newNamespace("team-a", addonv1alpha1.IdleGPUActionEvict), pod)
+
+		decisions, err := r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(HaveLen(1))
+		Expect(decisions[0].Action).To(Equal(addonv1alpha1.IdleGPUActionEvict))
+		Expect(r.Evicter.(*fakePodEvicter).evicted).To(Equal([]string{"team-a/notebook-5d4f8-x2k9q"}))
+	})
+
+	It("should report the evictions which failed", func() {
+		pod := newOwnedPod()
+		r := newTestIdleGPUReconciler(nil, newNamespace("team-a", addonv1alpha1.IdleGPUActionEvict), pod)
+		r.Evicter = &fakePodEvicter{err: errors.New("blocked by PodDisruptionBudget")}
+
+		decisions, err := r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(HaveLen(1))
+		Expect(decisions[0].Action).To(Equal(addonv1alpha1.IdleGPUActionSkip))
+		Expect(decisions[0].Message).To(ContainSubstring("blocked by PodDisruptionBudget"))
+	})
+
+	It("should scale down the Deployment owning the idle pods once", func() {
+		pod := newOwnedPod()
+		r := newTestIdleGPUReconciler(nil, newNamespace("team-a", addonv1alpha1.IdleGPUActionScaleDown),
+			deployment, replicaSet, pod)
+
+		decisions, err := r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(HaveLen(1))
+		Expect(decisions[0].Action).To(Equal(addonv1alpha1.IdleGPUActionScaleDown))
+		Expect(decisions[0].Message).To(Equal("Deployment notebook was scaled down to 0 replicas"))
+
+		dp := &appsv1.Deployment{}
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(deployment), dp)).ShouldNot(HaveOccurred())
+		Expect(*dp.Spec.Replicas).To(Equal(int32(0)))
+
+		decisions, err = r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(BeEmpty())
+	})
+
+	It("should skip the idle pods which have no scalable owner", func() {
+		pod := newGPUPod("notebook", "team-a", now.Add(-5*time.Hour))
+		r := newTestIdleGPUReconciler(nil, newNamespace("team-a", addonv1alpha1.IdleGPUActionScaleDown), pod)
+
+		decisions, err := r.applyActions(context.TODO(), []idlePod{{pod: *pod}}, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(decisions).To(HaveLen(1))
+		Expect(decisions[0].Action).To(Equal(addonv1alpha1.IdleGPUActionSkip))
+	})
+})
@@ -0,0 +1,209 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	// gpuUtilizationMetric is the GPU utilization exposed by the DCGM
+	// exporter, labeled with the pod each GPU is allocated to.
+	gpuUtilizationMetric = "DCGM_FI_DEV_GPU_UTIL"
+
+	// The pod labels of the DCGM exporter are renamed when scraped, as they
+	// conflict with the labels of the scrape target.
+	namespaceLabel = "exported_namespace"
+	podLabel       = "exported_pod"
+)
+
+// idlePod is a pod all of whose GPUs have been idle for the configured time.
+type idlePod struct {
+	pod corev1.Pod
+	// utilization is the maximum utilization, in percent, of the GPUs of
+	// the pod over the idle time.
+	utilization float64
+}
+
+// IdleGPULabel is the label of the pods holding idle GPUs.
+func IdleGPULabel() string {
+	return fmt.Sprintf("%s/idle-gpu", common.GlobalConfig.AddonID)
+}
+
+// IdleGPUActionLabel is the namespace label which opts the namespace in to an
+// action on its pods holding idle GPUs, either evict or scale-down.
+func IdleGPUActionLabel() string {
+	return fmt.Sprintf("%s/idle-gpu-action", common.GlobalConfig.AddonID)
+}
+
+// idlePodsQuery returns the pods all of whose GPUs had a utilization below the
+// given threshold over the given time.
+func idlePodsQuery(idleFor time.Duration, threshold int32) string {
+	return fmt.Sprintf(`max by (%[1]s, %[2]s) (max_over_time(%[3]s{%[2]s!=""}[%[4]s])) < %[5]d`,
+		namespaceLabel, podLabel, gpuUtilizationMetric, model.Duration(idleFor), threshold)
+}
+
+// listIdlePods returns the running pods which hold GPUs and whose GPUs have
+// all been idle since at least the idle time of the given policy. The pods
+// which started more recently are ignored, as the GPUs of a previous pod of
+// the same name may have been idle. The pods are sorted by namespace and name.
+func (r *IdleGPUReconciler) listIdlePods(ctx context.Context, g *addonv1alpha1.GPUAddon, now time.Time) ([]idlePod, error) {
+	idleFor := g.Spec.IdleGPUFor()
+
+	address, err := monitoring.QueryAddress(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, err := r.Prometheus.Query(ctx, address,
+		idlePodsQuery(idleFor, g.Spec.IdleGPUUtilizationThreshold()), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the idle GPUs: %w", err)
+	}
+
+	utilization := map[types.NamespacedName]float64{}
+	for _, sample := range vector {
+		utilization[types.NamespacedName{
+			Namespace: string(sample.Metric[namespaceLabel]),
+			Name:      string(sample.Metric[podLabel]),
+		}] = float64(sample.Value)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	idle := []idlePod{}
+	for _, pod := range pods.Items {
+		value, ok := utilization[client.ObjectKeyFromObject(&pod)]
+		if !ok || pod.Namespace == common.GlobalConfig.AddonNamespace {
+			continue
+		}
+
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.StartTime == nil ||
+			pod.Status.StartTime.Time.After(now.Add(-idleFor)) {
+			continue
+		}
+
+		if common.PodUsesGPUs(&pod) {
+			idle = append(idle, idlePod{pod: pod, utilization: value})
+		}
+	}
+
+	sort.Slice(idle, func(i, j int) bool {
+		if idle[i].pod.Namespace != idle[j].pod.Namespace {
+			return idle[i].pod.Namespace < idle[j].pod.Namespace
+		}
+		return idle[i].pod.Name < idle[j].pod.Name
+	})
+
+	return idle, nil
+}
+
+// reconcileIdleLabels labels the given idle pods, and records an event on the
+// ones which were not labeled yet. The label is removed from the pods which
+// are not idle anymore.
+func (r *IdleGPUReconciler) reconcileIdleLabels(
+	ctx context.Context,
+	idle []idlePod,
+	idleFor time.Duration,
+	now time.Time) ([]addonv1alpha1.IdleGPUDecision, error) {
+
+	decisions := []addonv1alpha1.IdleGPUDecision{}
+	isIdle := map[types.NamespacedName]bool{}
+
+	for i := range idle {
+		pod := &idle[i].pod
+		isIdle[client.ObjectKeyFromObject(pod)] = true
+
+		if pod.Labels[IdleGPULabel()] == "true" {
+			continue
+		}
+
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[IdleGPULabel()] = "true"
+		if err := r.Patch(ctx, pod, patch); err != nil {
+			return nil, fmt.Errorf("failed to label idle pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		message := fmt.Sprintf("The GPUs of the pod have been idle for %s, with a utilization of at most %.0f%%",
+			model.Duration(idleFor), idle[i].utilization)
+		r.Recorder.Event(pod, corev1.EventTypeWarning, "IdleGPU", message)
+
+		decisions = append(decisions, newDecision(pod, addonv1alpha1.IdleGPUActionLabel, message, now))
+	}
+
+	return decisions, r.removeIdleLabels(ctx, isIdle)
+}
+
+// removeIdleLabels removes the idle GPU label from the pods which are not in
+// the given set.
+func (r *IdleGPUReconciler) removeIdleLabels(ctx context.Context, keep map[types.NamespacedName]bool) error {
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.MatchingLabels{IdleGPULabel(): "true"}); err != nil {
+		return fmt.Errorf("failed to list idle pods: %w", err)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if keep[client.ObjectKeyFromObject(pod)] {
+			continue
+		}
+
+		patch := client.MergeFrom(pod.DeepCopy())
+		delete(pod.Labels, IdleGPULabel())
+		if err := r.Patch(ctx, pod, patch); err != nil {
+			return fmt.Errorf("failed to unlabel pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func newDecision(
+	pod *corev1.Pod,
+	action addonv1alpha1.IdleGPUAction,
+	message string,
+	now time.Time) addonv1alpha1.IdleGPUDecision {
+
+	IdleGPUActions.WithLabelValues(string(action)).Inc()
+
+	return addonv1alpha1.IdleGPUDecision{
+		Time:      metav1.NewTime(now),
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Action:    action,
+		Message:   message,
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+const (
+	// idleGPUCheckInterval is how often the idle pods are checked.
+	idleGPUCheckInterval = 10 * time.Minute
+
+	// maxDecisions is the maximum number of decisions kept in the status.
+	maxDecisions = 20
+)
+
+// PrometheusQuerier evaluates PromQL instant queries.
+type PrometheusQuerier interface {
+	Query(ctx context.Context, address string, query string, at time.Time) (model.Vector, error)
+}
+
+// IdleGPUReconciler applies the idle GPU policy of the GPUAddon. It detects
+// the pods holding GPUs whose utilization, as exported by the DCGM exporter
+// to the Prometheus of the monitoring mode, has been below a threshold for a configured
+// time. The idle pods are labeled and reported, and the namespaces which
+// opted in have their idle pods evicted or their workloads scaled down.
+type IdleGPUReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// APIReader reads the pods of all namespaces, which are not available
+	// in the namespaced cache of the manager.
+	APIReader client.Reader
+	// Evicter evicts the idle pods of the namespaces which opted in to it.
+	Evicter PodEvicter
+	// Prometheus queries the Prometheus of the monitoring mode. The policy
+	// is not applied when it is nil.
+	Prometheus PrometheusQuerier
+
+	// now returns the current time, and is overridden by the tests.
+	now func() time.Time
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons,verbs=get;list;watch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuaddons/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get
+//+kubebuilder:rbac:groups=apps,resources={deployments,statefulsets},verbs=get;patch
+
+func (r *IdleGPUReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	g := &addonv1alpha1.GPUAddon{}
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name, Namespace: req.Namespace}, g); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not get GPUAddon CR: %w", err)
+	}
+
+	if !g.DeletionTimestamp.IsZero() || g.Spec.IdleGPUPolicy == nil {
+		return ctrl.Result{}, r.disable(ctx, g)
+	}
+
+	if r.Prometheus == nil {
+		// The GPU utilization cannot be queried, e.g. outside of the
+		// cluster.
+		return ctrl.Result{}, nil
+	}
+
+	now := r.currentTime()
+
+	idle, err := r.listIdlePods(ctx, g, now)
+	if err != nil {
+		// The add-on Prometheus may not be deployed yet, and the check is
+		// retried at the next interval. The failure is reported in the
+		// status, as the policy silently does nothing until then.
+		logger.Info("Unable to check the idle GPUs", "error", err.Error())
+		return ctrl.Result{RequeueAfter: idleGPUCheckInterval}, r.patchCheckError(ctx, g, err, now)
+	}
+
+	decisions, err := r.reconcileIdleLabels(ctx, idle, g.Spec.IdleGPUFor(), now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	actions, err := r.applyActions(ctx, idle, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	decisions = append(decisions, actions...)
+
+	for _, d := range decisions {
+		logger.Info("Idle GPU policy decision",
+			"namespace", d.Namespace,
+			"pod", d.Pod,
+			"action", d.Action,
+			"message", d.Message)
+	}
+
+	recordIdlePodsMetric(idle)
+
+	patch := client.MergeFrom(g.DeepCopy())
+	status := &addonv1alpha1.IdleGPUStatus{}
+	if g.Status.IdleGPUs != nil {
+		status = g.Status.IdleGPUs
+	}
+	status.LastCheckTime = metav1.NewTime(now)
+	status.Error = ""
+	status.IdlePods = int32(len(idle))
+	status.Decisions = append(status.Decisions, decisions...)
+	if len(status.Decisions) > maxDecisions {
+		status.Decisions = status.Decisions[len(status.Decisions)-maxDecisions:]
+	}
+	g.Status.IdleGPUs = status
+
+	if err := r.Status().Patch(ctx, g, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return ctrl.Result{RequeueAfter: idleGPUCheckInterval}, nil
+}
+
+// patchCheckError reports the given failure of the check of the idle pods in
+// the status of the idle GPU policy, keeping its last results.
+func (r *IdleGPUReconciler) patchCheckError(ctx context.Context, g *addonv1alpha1.GPUAddon, err error, now time.Time) error {
+	patch := client.MergeFrom(g.DeepCopy())
+	if g.Status.IdleGPUs == nil {
+		g.Status.IdleGPUs = &addonv1alpha1.IdleGPUStatus{}
+	}
+	g.Status.IdleGPUs.LastCheckTime = metav1.NewTime(now)
+	g.Status.IdleGPUs.Error = err.Error()
+
+	if err := r.Status().Patch(ctx, g, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+// disable removes the idle GPU labels and the status of the idle GPU policy,
+// e.g. once it is disabled.
+func (r *IdleGPUReconciler) disable(ctx context.Context, g *addonv1alpha1.GPUAddon) error {
+	IdleGPUPods.Reset()
+
+	if err := r.removeIdleLabels(ctx, nil); err != nil {
+		return err
+	}
+
+	if g.Status.IdleGPUs == nil {
+		return nil
+	}
+
+	patch := client.MergeFrom(g.DeepCopy())
+	g.Status.IdleGPUs = nil
+	if err := r.Status().Patch(ctx, g, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *IdleGPUReconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdleGPUReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("idlegpu").
+		// The status updates do not trigger a new check.
+		For(&addonv1alpha1.GPUAddon{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(health.Reconciles.Track("idlegpu", r))
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var now = time.Date(2022, 8, 2, 12, 0, 0, 0, time.UTC)
+
+var _ = Describe("IdleGPU Reconcile", func() {
+	common.ProcessConfig()
+
+	newGPUAddon := func(policy *addonv1alpha1.IdleGPUPolicySpec) *addonv1alpha1.GPUAddon {
+		return &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.GPUAddonSpec{
+				IdleGPUPolicy: policy,
+			},
+		}
+	}
+
+	reconcile := func(r *IdleGPUReconciler, g *addonv1alpha1.GPUAddon) ctrl.Result {
+		res, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(g)})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(g), g)).ShouldNot(HaveOccurred())
+		return res
+	}
+
+	getPod := func(r *IdleGPUReconciler, name string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		Expect(r.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "team-a"}, pod)).ShouldNot(HaveOccurred())
+		return pod
+	}
+
+	It("should label and report the pods holding idle GPUs", func() {
+		g := newGPUAddon(&addonv1alpha1.IdleGPUPolicySpec{})
+		recovered := newGPUPod("recovered", "team-a", now.Add(-10*time.Hour))
+		recovered.Labels = map[string]string{IdleGPULabel(): "true"}
+
+		prometheus := newIdleQuerier(4*time.Hour, 5, "notebook", "recent")
+		r := newTestIdleGPUReconciler(prometheus,
+			g,
+			newNamespace("team-a", ""),
+			newGPUPod("notebook", "team-a", now.Add(-5*time.Hour)),
+			newGPUPod("recent", "team-a", now.Add(-time.Hour)),
+			newGPUPod("training", "team-a", now.Add(-5*time.Hour)),
+			recovered)
+
+		res := reconcile(r, g)
+		Expect(res.RequeueAfter).To(Equal(idleGPUCheckInterval))
+		Expect(prometheus.address).To(Equal(monitoring.PrometheusAddress(common.GlobalConfig.AddonNamespace)))
+
+		Expect(getPod(r, "notebook").Labels).To(HaveKeyWithValue(IdleGPULabel(), "true"))
+		Expect(getPod(r, "recent").Labels).ToNot(HaveKey(IdleGPULabel()))
+		Expect(getPod(r, "training").Labels).ToNot(HaveKey(IdleGPULabel()))
+		Expect(getPod(r, "recovered").Labels).ToNot(HaveKey(IdleGPULabel()))
+
+		Expect(g.Status.IdleGPUs).ToNot(BeNil())
+		Expect(g.Status.IdleGPUs.IdlePods).To(Equal(int32(1)))
+		Expect(g.Status.IdleGPUs.LastCheckTime.Time).To(BeTemporally("==", now))
+		Expect(g.Status.IdleGPUs.Decisions).To(HaveLen(1))
+		Expect(g.Status.IdleGPUs.Decisions[0].Pod).To(Equal("notebook"))
+		Expect(g.Status.IdleGPUs.Decisions[0].Action).To(Equal(addonv1alpha1.IdleGPUActionLabel))
+
+		Expect(testutil.ToFloat64(IdleGPUPods.WithLabelValues("team-a"))).To(Equal(1.0))
+		Expect(events(r)).To(ConsistOf(ContainSubstring("IdleGPU The GPUs of the pod have been idle for 4h")))
+
+		// The already labeled pods are not reported again.
+		reconcile(r, g)
+		Expect(g.Status.IdleGPUs.Decisions).To(HaveLen(1))
+	})
+
+	It("should apply the idle time and threshold of the policy", func() {
+		g := newGPUAddon(&addonv1alpha1.IdleGPUPolicySpec{
+			UtilizationThreshold: pointer.Int32(10),
+			IdleFor:              &metav1.Duration{Duration: 2 * time.Hour},
+		})
+		r := newTestIdleGPUReconciler(newIdleQuerier(2*time.Hour, 10, "recent"),
+			g,
+			newNamespace("team-a", ""),
+			newGPUPod("recent", "team-a", now.Add(-3*time.Hour)))
+
+		reconcile(r, g)
+		Expect(getPod(r, "recent").Labels).To(HaveKeyWithValue(IdleGPULabel(), "true"))
+	})
+
+	It("should report when Prometheus cannot be queried", func() {
+		g := newGPUAddon(&addonv1alpha1.IdleGPUPolicySpec{})
+		g.Status.IdleGPUs = &addonv1alpha1.IdleGPUStatus{
+			IdlePods:  1,
+			Decisions: []addonv1alpha1.IdleGPUDecision{{Pod: "notebook"}},
+		}
+		r := newTestIdleGPUReconciler(&fakePrometheusQuerier{}, g)
+
+		res := reconcile(r, g)
+		Expect(res.RequeueAfter).To(Equal(idleGPUCheckInterval))
+		Expect(g.Status.IdleGPUs).ToNot(BeNil())
+		Expect(g.Status.IdleGPUs.Error).To(ContainSubstring("failed to query the idle GPUs"))
+		Expect(g.Status.IdleGPUs.LastCheckTime.Time).To(BeTemporally("==", now))
+		Expect(g.Status.IdleGPUs.IdlePods).To(Equal(int32(1)))
+		Expect(g.Status.IdleGPUs.Decisions).To(HaveLen(1))
+
+		// The error is cleared once the check succeeds.
+		r.Prometheus = newIdleQuerier(4*time.Hour, 5)
+		reconcile(r, g)
+		Expect(g.Status.IdleGPUs.Error).To(BeEmpty())
+	})
+
+	Context("in the cluster monitoring modes", func() {
+		newMonitoring := func() *addonv1alpha1.Monitoring {
+			return &addonv1alpha1.Monitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.GlobalConfig.AddonID,
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+				Spec: addonv1alpha1.MonitoringSpec{Mode: addonv1alpha1.MonitoringModePlatform},
+			}
+		}
+
+		It("should query the Thanos Querier", func() {
+			g := newGPUAddon(&addonv1alpha1.IdleGPUPolicySpec{})
+			prometheus := newIdleQuerier(4*time.Hour, 5, "notebook")
+			r := newTestIdleGPUReconciler(prometheus, g, newMonitoring(),
+				newNamespace("team-a", ""),
+				newGPUPod("notebook", "team-a", now.Add(-5*time.Hour)))
+
+			reconcile(r, g)
+			Expect(prometheus.address).To(Equal(monitoring.ThanosQuerier.Address()))
+			Expect(getPod(r, "notebook").Labels).To(HaveKeyWithValue(IdleGPULabel(), "true"))
+		})
+
+		It("should report the unsupported monitoring mode on Kubernetes", func() {
+			common.ClusterPlatform = common.PlatformKubernetes
+			defer func() { common.ClusterPlatform = common.PlatformOpenShift }()
+
+			g := newGPUAddon(&addonv1alpha1.IdleGPUPolicySpec{})
+			prometheus := newIdleQuerier(4*time.Hour, 5)
+			r := newTestIdleGPUReconciler(prometheus, g, newMonitoring())
+
+			res := reconcile(r, g)
+			Expect(res.RequeueAfter).To(Equal(idleGPUCheckInterval))
+			Expect(prometheus.address).To(BeEmpty())
+			Expect(g.Status.IdleGPUs.Error).To(ContainSubstring("unsupported monitoring mode"))
+		})
+	})
+
+	It("should remove the labels and the status once disabled", func() {
+		g := newGPUAddon(nil)
+		g.Status.IdleGPUs = &addonv1alpha1.IdleGPUStatus{IdlePods: 1}
+		notebook := newGPUPod("notebook", "team-a", now.Add(-5*time.Hour))
+		notebook.Labels = map[string]string{IdleGPULabel(): "true"}
+		r := newTestIdleGPUReconciler(newIdleQuerier(4*time.Hour, 5, "notebook"), g, notebook)
+
+		res := reconcile(r, g)
+		Expect(res.RequeueAfter).To(BeZero())
+		Expect(g.Status.IdleGPUs).To(BeNil())
+		Expect(getPod(r, "notebook").Labels).ToNot(HaveKey(IdleGPULabel()))
+	})
+})
+
+// fakePrometheusQuerier returns the configured vector of each query, and
+// fails the queries which are not configured.
+type fakePrometheusQuerier struct {
+	results map[string]model.Vector
+	address string
+}
+
+func (f *fakePrometheusQuerier) Query(_ context.Context, address string, query string, _ time.Time) (model.Vector, error) {
+	f.address = address
+	result, ok := f.results[query]
+	if !ok {
+		return nil, errors.New("unexpected query")
+	}
+	return result, nil
+}
+
+// newIdleQuerier returns a querier reporting the given pods of the team-a
+// namespace as idle.
+func newIdleQuerier(idleFor time.Duration, threshold int32, pods ...string) *fakePrometheusQuerier {
+	vector := model.Vector{}
+	for _, pod := range pods {
+		vector = append(vector, &model.Sample{
+			Metric: model.Metric{namespaceLabel: "team-a", podLabel: model.LabelValue(pod)},
+			Value:  1,
+		})
+	}
+	return &fakePrometheusQuerier{results: map[string]model.Vector{
+		idlePodsQuery(idleFor, threshold): vector,
+	}}
+}
+
+func newGPUPod(name, namespace string, startTime time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "main",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							common.GPUResourceName: resource.MustParse("1"),
+						},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &metav1.Time{Time: startTime},
+		},
+	}
+}
+
+func newNamespace(name string, action addonv1alpha1.IdleGPUAction) *corev1.Namespace {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	if action != "" {
+		ns.Labels = map[string]string{IdleGPUActionLabel(): string(action)}
+	}
+	return ns
+}
+
+func events(r *IdleGPUReconciler) []string {
+	recorder := r.Recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+
+	events := []string{}
+	for e := range recorder.Events {
+		events = append(events, e)
+	}
+	return events
+}
+
+func newTestIdleGPUReconciler(prometheus PrometheusQuerier, objs ...runtime.Object) *IdleGPUReconciler {
+	s := scheme.Scheme
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &IdleGPUReconciler{
+		Client:     c,
+		Scheme:     s,
+		Recorder:   record.NewFakeRecorder(10),
+		APIReader:  c,
+		Evicter:    &fakePodEvicter{},
+		Prometheus: prometheus,
+		now:        func() time.Time { return now },
+	}
+}
+
+type fakePodEvicter struct {
+	evicted []string
+	err     error
+}
+
+func (e *fakePodEvicter) Evict(_ context.Context, pod *corev1.Pod) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.evicted = append(e.evicted, client.ObjectKeyFromObject(pod).String())
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idlegpu
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// The namespace of the pods is not exported as the namespace label,
+	// which is the one of the operator once scraped.
+	IdleGPUPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nvidia_gpuaddon_idle_gpu_pods",
+			Help: "Reports the number of pods holding idle GPUs per namespace",
+		},
+		[]string{"workload_namespace"},
+	)
+
+	IdleGPUActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nvidia_gpuaddon_idle_gpu_actions_total",
+			Help: "Counts the actions taken by the idle GPU policy on the pods holding idle GPUs",
+		},
+		[]string{"action"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		IdleGPUPods,
+		IdleGPUActions,
+	)
+}
+
+// recordIdlePodsMetric exports the number of idle pods of each namespace.
+func recordIdlePodsMetric(pods []idlePod) {
+	// The gauge is reset so that the series of the namespaces without idle
+	// pods anymore do not linger.
+	IdleGPUPods.Reset()
+	for _, p := range pods {
+		IdleGPUPods.WithLabelValues(p.pod.Namespace).Inc()
+	}
+}
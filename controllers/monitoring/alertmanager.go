@@ -35,8 +35,13 @@ const (
 )
 
 var (
-	pagerdutyAlerts = []string{
+	// defaultPagerDutyAlerts are the alerts routed to PagerDuty unless the
+	// Monitoring spec lists others.
+	defaultPagerDutyAlerts = []string{
 		"NVIDIAGPUAddonGPUOperatorSubscriptionInstallationPending",
+		"NVIDIAGPUAddonFailed",
+		"NVIDIAGPUAddonInstallationStuck",
+		"NVIDIAGPUAddonClusterPolicyNotReady",
 	}
 )
 
@@ -175,7 +180,12 @@ func (r *MonitoringReconciler) reconcileAlertManagerConfig(
 		alertManagerConfig = existingAMC
 	}
 
-	if err := r.checkPagerDutyServiceKey(ctx, common.GlobalConfig.PagerDutySecretName, m.Namespace); err != nil {
+	pagerDutySecretName := common.GlobalConfig.PagerDutySecretName
+	if m.Spec.PagerDuty != nil && m.Spec.PagerDuty.SecretName != "" {
+		pagerDutySecretName = m.Spec.PagerDuty.SecretName
+	}
+
+	if err := r.checkPagerDutyServiceKey(ctx, pagerDutySecretName, m.Namespace); err != nil {
 		return err
 	}
 
@@ -184,10 +194,36 @@ func (r *MonitoringReconciler) reconcileAlertManagerConfig(
 		return err
 	}
 
-	pagerDutySecretName := common.GlobalConfig.PagerDutySecretName
+	// Invalid declared receivers and routes are left out, so that the
+	// managed ones keep working.
+	problems, err := r.validateAlerting(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	spec := m.Spec.DeepCopy()
+	condition := common.NewCondition(
+		AlertingConfigValidCondition,
+		metav1.ConditionTrue,
+		"ValidAlertingConfig",
+		"The declared receivers and routes are valid")
+	if len(problems) > 0 {
+		logger.Info("Ignoring the invalid declared receivers and routes", "problems", problems)
+		spec.Receivers = nil
+		spec.Routes = nil
+		condition = common.NewCondition(
+			AlertingConfigValidCondition,
+			metav1.ConditionFalse,
+			"InvalidAlertingConfig",
+			strings.Join(problems, "; "))
+	}
+
+	if err := r.patchStatus(ctx, m, condition); err != nil {
+		return err
+	}
 
 	res, err := controllerutil.CreateOrPatch(context.TODO(), r.Client, alertManagerConfig, func() error {
-		return r.setDesiredAlertManagerConfig(r.Client, alertManagerConfig, pagerDutySecretName, deadMansSnitchURL, m)
+		return r.setDesiredAlertManagerConfig(r.Client, alertManagerConfig, pagerDutySecretName, deadMansSnitchURL, spec, m)
 	})
 	if err != nil {
 		return err
@@ -206,6 +242,7 @@ func (r *MonitoringReconciler) setDesiredAlertManagerConfig(
 	alertManagerConfig *promv1alpha1.AlertmanagerConfig,
 	pagerDutySecretName string,
 	deadMansSnitchURL string,
+	spec *addonv1alpha1.MonitoringSpec,
 	m *addonv1alpha1.Monitoring) error {
 
 	if alertManagerConfig == nil {
@@ -214,17 +251,19 @@ func (r *MonitoringReconciler) setDesiredAlertManagerConfig(
 
 	pagerDutyRoute, err := convertToApiExtV1JSON(promv1alpha1.Route{
 		GroupBy:        []string{"alertname"},
-		GroupWait:      "30s",
-		GroupInterval:  "5m",
-		RepeatInterval: "12h",
+		GroupWait:      defaultGroupWait,
+		GroupInterval:  defaultGroupInterval,
+		RepeatInterval: defaultRepeatInterval,
 		Matchers: []promv1alpha1.Matcher{
 			{
 				Name:      "alertname",
-				Value:     getRegexMatcher(pagerdutyAlerts),
+				Value:     getRegexMatcher(pagerDutyAlerts(spec)),
 				MatchType: promv1alpha1.MatchRegexp,
 			},
 		},
-		Receiver: "pagerduty",
+		Receiver: pagerDutyReceiverName,
+		// The declared routes may route the same alerts elsewhere too.
+		Continue: len(spec.Routes) > 0,
 	})
 	if err != nil {
 		return err
@@ -242,27 +281,32 @@ func (r *MonitoringReconciler) setDesiredAlertManagerConfig(
 				MatchType: promv1alpha1.MatchEqual,
 			},
 		},
-		Receiver: "DeadMansSnitch",
+		Receiver: deadMansSnitchReceiverName,
 	})
 	if err != nil {
 		return err
 	}
 
+	routes, err := desiredRoutes(spec.Routes)
+	if err != nil {
+		return err
+	}
+
 	alertManagerConfig.Spec = promv1alpha1.AlertmanagerConfigSpec{}
 	alertManagerConfig.Spec.Route = &promv1alpha1.Route{
-		Receiver: "null",
-		Routes: []apiextensionsv1.JSON{
+		Receiver: nullReceiverName,
+		Routes: append([]apiextensionsv1.JSON{
 			pagerDutyRoute,
 			deadMansSnitchRoute,
-		},
+		}, routes...),
 	}
 
-	alertManagerConfig.Spec.Receivers = []promv1alpha1.Receiver{
+	alertManagerConfig.Spec.Receivers = append([]promv1alpha1.Receiver{
 		{
-			Name: "null",
+			Name: nullReceiverName,
 		},
 		{
-			Name: "pagerduty",
+			Name: pagerDutyReceiverName,
 			PagerDutyConfigs: []promv1alpha1.PagerDutyConfig{{
 				ServiceKey: &corev1.SecretKeySelector{
 					Key:                  pagerDutyKey,
@@ -271,10 +315,10 @@ func (r *MonitoringReconciler) setDesiredAlertManagerConfig(
 			}},
 		},
 		{
-			Name:           "DeadMansSnitch",
+			Name:           deadMansSnitchReceiverName,
 			WebhookConfigs: []promv1alpha1.WebhookConfig{{URL: &deadMansSnitchURL}},
 		},
-	}
+	}, desiredReceivers(spec.Receivers)...)
 
 	return ctrl.SetControllerReference(m, alertManagerConfig, c.Scheme())
 }
@@ -356,3 +400,11 @@ func convertToApiExtV1JSON(value interface{}) (apiextensionsv1.JSON, error) {
 func getRegexMatcher(alerts []string) string {
 	return "^" + strings.Join(alerts, "$|^") + "$"
 }
+
+// pagerDutyAlerts returns the names of the alerts routed to PagerDuty.
+func pagerDutyAlerts(spec *addonv1alpha1.MonitoringSpec) []string {
+	if spec.PagerDuty != nil && len(spec.PagerDuty.Alerts) > 0 {
+		return spec.PagerDuty.Alerts
+	}
+	return defaultPagerDutyAlerts
+}
@@ -136,7 +136,7 @@ var _ = Describe("AlertManagerConfig", Ordered, func() {
 			c := fake.
 				NewClientBuilder().
 				WithScheme(scheme).
-				WithRuntimeObjects(pagerDutySecret, deadMansSnitchSecret).
+				WithRuntimeObjects(m, pagerDutySecret, deadMansSnitchSecret).
 				Build()
 
 			r.Client = c
@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+const (
+	federationServiceMonitorName = "gpuaddon-federation"
+
+	// federationCAFile is the service CA bundle mounted in the OpenShift
+	// monitoring Prometheus instances.
+	federationCAFile = "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt"
+)
+
+// defaultFederatedMetrics are the metrics federated unless the Monitoring
+// spec lists others.
+var defaultFederatedMetrics = []addonv1alpha1.MetricName{
+	"DCGM_FI_DEV_GPU_UTIL",
+	"DCGM_FI_DEV_FB_USED",
+	"DCGM_FI_DEV_FB_FREE",
+	"DCGM_FI_DEV_POWER_USAGE",
+	"DCGM_FI_DEV_GPU_TEMP",
+	"nvidia_gpuaddon_condition",
+	"nvidia_gpuaddon_phase",
+}
+
+func (r *MonitoringReconciler) reconcileFederationServiceMonitor(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	if m.Spec.Federation == nil || !m.Spec.Federation.Enabled {
+		return r.deleteFederationServiceMonitor(ctx, m)
+	}
+
+	logger := log.FromContext(ctx, "Reconcile Step", "Federation ServiceMonitor")
+	logger.Info("Reconciling federation ServiceMonitor")
+
+	sm := &promv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      federationServiceMonitorName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, sm, func() error {
+		return r.setDesiredFederationServiceMonitor(r.Client, sm, m)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Federation ServiceMonitor reconciled successfully",
+		"name", sm.Name,
+		"namespace", sm.Namespace,
+		"result", res)
+
+	return nil
+}
+
+func (r *MonitoringReconciler) setDesiredFederationServiceMonitor(
+	c client.Client,
+	sm *promv1.ServiceMonitor,
+	m *addonv1alpha1.Monitoring) error {
+
+	if sm == nil {
+		return errors.New("serviceMonitor cannot be nil")
+	}
+
+	// The ServiceMonitor has no app label, so that the add-on Prometheus does
+	// not federate itself.
+	sm.Spec = promv1.ServiceMonitorSpec{
+		Selector: metav1.LabelSelector{
+			MatchLabels: prometheusServiceLabels,
+		},
+		Endpoints: []promv1.Endpoint{{
+			Port:            "https",
+			Scheme:          "https",
+			Path:            "/federate",
+			HonorLabels:     true,
+			BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			Params: map[string][]string{
+				"match[]": {federationMatcher(federatedMetrics(m.Spec.Federation))},
+			},
+			TLSConfig: &promv1.TLSConfig{
+				CAFile: federationCAFile,
+				SafeTLSConfig: promv1.SafeTLSConfig{
+					ServerName: fmt.Sprintf("%s.%s.svc", PrometheusServiceName, m.Namespace),
+				},
+			},
+		}},
+	}
+
+	return ctrl.SetControllerReference(m, sm, c.Scheme())
+}
+
+// federatedMetrics returns the names of the federated metrics.
+func federatedMetrics(spec *addonv1alpha1.FederationSpec) []addonv1alpha1.MetricName {
+	if len(spec.Metrics) > 0 {
+		return spec.Metrics
+	}
+	return defaultFederatedMetrics
+}
+
+// federationMatcher returns the series selector of the given metrics. The
+// metric names are validated by the CRD and thus need no escaping.
+func federationMatcher(metrics []addonv1alpha1.MetricName) string {
+	names := []string{}
+	for _, metric := range metrics {
+		names = append(names, string(metric))
+	}
+	return fmt.Sprintf(`{__name__=~"%s"}`, strings.Join(names, "|"))
+}
+
+func (r *MonitoringReconciler) deleteFederationServiceMonitor(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	sm := &promv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      federationServiceMonitorName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	err := r.Delete(ctx, sm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ServiceMonitor %s in %s: %w", sm.Name, sm.Namespace, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("Federation ServiceMonitor", Ordered, func() {
+	m := &addonv1alpha1.Monitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+		Spec: addonv1alpha1.MonitoringSpec{
+			Federation: &addonv1alpha1.FederationSpec{
+				Enabled: true,
+				Metrics: []addonv1alpha1.MetricName{"DCGM_FI_DEV_GPU_UTIL", "nvidia_gpuaddon_phase"},
+			},
+		},
+	}
+	r := &MonitoringReconciler{}
+
+	s := scheme.Scheme
+	Expect(promv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	key := types.NamespacedName{Name: federationServiceMonitorName, Namespace: m.Namespace}
+
+	It("should create the allow-listed federation ServiceMonitor", func() {
+		r.Client = fake.NewClientBuilder().WithScheme(s).Build()
+
+		Expect(r.reconcileFederationServiceMonitor(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		sm := &promv1.ServiceMonitor{}
+		Expect(r.Get(context.TODO(), key, sm)).ShouldNot(HaveOccurred())
+		Expect(sm.Labels).ToNot(HaveKey("app"))
+		Expect(sm.Spec.Selector.MatchLabels).To(Equal(prometheusServiceLabels))
+		Expect(sm.Spec.Endpoints).To(HaveLen(1))
+		Expect(sm.Spec.Endpoints[0].Path).To(Equal("/federate"))
+		Expect(sm.Spec.Endpoints[0].Params).To(HaveKeyWithValue("match[]",
+			[]string{`{__name__=~"DCGM_FI_DEV_GPU_UTIL|nvidia_gpuaddon_phase"}`}))
+	})
+
+	It("should delete the federation ServiceMonitor once disabled", func() {
+		m.Spec.Federation.Enabled = false
+
+		Expect(r.reconcileFederationServiceMonitor(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		err := r.Get(context.TODO(), key, &promv1.ServiceMonitor{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should default to the GPU utilization and add-on health metrics", func() {
+		Expect(federationMatcher(federatedMetrics(&addonv1alpha1.FederationSpec{Enabled: true}))).
+			To(ContainSubstring("DCGM_FI_DEV_GPU_UTIL|"))
+	})
+})
@@ -0,0 +1,311 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	operatorServiceMonitorName = "gpuaddon-operator-metrics"
+
+	monitoringModeLabel = "nvidia.addons.rh-ecosystem-edge.io/monitoring-mode"
+
+	// clusterMonitoringLabel enables the platform monitoring of a namespace.
+	clusterMonitoringLabel = "openshift.io/cluster-monitoring"
+
+	// clusterMonitoringManagedAnnotation marks the cluster monitoring label of
+	// the add-on namespace as set by the operator, so that it is only removed
+	// if it was.
+	clusterMonitoringManagedAnnotation = "nvidia.addons.rh-ecosystem-edge.io/cluster-monitoring-label"
+)
+
+// QueryService is a Service the add-on metrics are queried at, through a
+// kube-rbac-proxy which authorizes the bearer token of the client.
+type QueryService struct {
+	Name      string
+	Namespace string
+	Port      int32
+}
+
+// Address returns the address of the Service.
+func (s QueryService) Address() string {
+	return fmt.Sprintf("https://%s.%s.svc:%d", s.Name, s.Namespace, s.Port)
+}
+
+// ThanosQuerier is the Thanos Querier of the OpenShift cluster monitoring,
+// which queries both the platform and the user workload Prometheus.
+var ThanosQuerier = QueryService{
+	Name:      "thanos-querier",
+	Namespace: "openshift-monitoring",
+	Port:      9091,
+}
+
+// ErrUnsupportedMonitoringMode is returned when the add-on metrics cannot be
+// queried in the configured monitoring mode.
+var ErrUnsupportedMonitoringMode = errors.New("unsupported monitoring mode")
+
+// QueryAddress returns the address the add-on metrics are queried at in the
+// mode of the add-on Monitoring CR.
+func QueryAddress(ctx context.Context, c client.Reader) (string, error) {
+	s, err := GetQueryService(ctx, c)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Address(), nil
+}
+
+// GetQueryService returns the Service the add-on metrics are queried at in the
+// mode of the add-on Monitoring CR: the add-on Prometheus in the Dedicated
+// mode, which is also the default without a Monitoring CR, and the Thanos
+// Querier of the cluster monitoring in the other modes.
+func GetQueryService(ctx context.Context, c client.Reader) (QueryService, error) {
+	m := &addonv1alpha1.Monitoring{}
+	err := c.Get(ctx, types.NamespacedName{
+		Name:      common.GlobalConfig.AddonID,
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}, m)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return QueryService{}, fmt.Errorf("failed to get Monitoring CR %s: %w", common.GlobalConfig.AddonID, err)
+	}
+
+	switch mode := m.Spec.MonitoringMode(); mode {
+	case addonv1alpha1.MonitoringModeDedicated:
+		return PrometheusService(common.GlobalConfig.AddonNamespace), nil
+	case addonv1alpha1.MonitoringModeUserWorkload, addonv1alpha1.MonitoringModePlatform:
+		if !common.IsOpenShift() {
+			return QueryService{}, fmt.Errorf("%w: %s requires the OpenShift cluster monitoring", ErrUnsupportedMonitoringMode, mode)
+		}
+		return ThanosQuerier, nil
+	default:
+		return QueryService{}, fmt.Errorf("%w: %s", ErrUnsupportedMonitoringMode, mode)
+	}
+}
+
+// stackRuleLabels are the labels of the PrometheusRule for each monitoring
+// mode, on top of prometheusRuleLabels.
+var stackRuleLabels = map[addonv1alpha1.MonitoringMode]map[string]string{
+	addonv1alpha1.MonitoringModeDedicated: {},
+	// The rules only use the metrics of the add-on namespace, thus they are
+	// evaluated by the user workload Prometheus rather than by Thanos Ruler.
+	addonv1alpha1.MonitoringModeUserWorkload: {
+		"openshift.io/prometheus-rule-evaluation-scope": "leaf-prometheus",
+	},
+	addonv1alpha1.MonitoringModePlatform: {
+		"prometheus": "k8s",
+		"role":       "alert-rules",
+	},
+}
+
+// setStackLabels sets the labels of the given monitoring mode, and removes the
+// ones of the other modes.
+func setStackLabels(labels map[string]string, mode addonv1alpha1.MonitoringMode, stackLabels map[addonv1alpha1.MonitoringMode]map[string]string) {
+	for _, modeLabels := range stackLabels {
+		for k := range modeLabels {
+			delete(labels, k)
+		}
+	}
+	for k, v := range stackLabels[mode] {
+		labels[k] = v
+	}
+	labels[monitoringModeLabel] = string(mode)
+}
+
+// removeDedicatedStack deletes the Prometheus and the Alertmanager of the
+// Dedicated mode, along with the resources only they use.
+func (r *MonitoringReconciler) removeDedicatedStack(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	if err := r.deleteFederationServiceMonitor(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deleteAlertManager(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deletePrometheus(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deletePrometheusService(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deletePrometheusKubeRBACProxyConfigMap(ctx, m); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(m.DeepCopy())
+	m.Status.PrometheusSizing = nil
+	m.Status.RemoteWrite = nil
+	for _, t := range []string{PrometheusSizedCondition, RemoteWriteConfigValidCondition, RemoteWriteHealthyCondition} {
+		meta.RemoveStatusCondition(&m.Status.Conditions, t)
+	}
+
+	if err := r.Status().Patch(ctx, m, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MonitoringReconciler) reconcileOperatorServiceMonitor(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "Operator ServiceMonitor")
+	logger.Info("Reconciling operator ServiceMonitor")
+
+	sm := &promv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      operatorServiceMonitorName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, sm, func() error {
+		return r.setDesiredOperatorServiceMonitor(r.Client, sm, m)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Operator ServiceMonitor reconciled successfully",
+		"name", sm.Name,
+		"namespace", sm.Namespace,
+		"result", res)
+
+	return nil
+}
+
+func (r *MonitoringReconciler) setDesiredOperatorServiceMonitor(
+	c client.Client,
+	sm *promv1.ServiceMonitor,
+	m *addonv1alpha1.Monitoring) error {
+
+	if sm == nil {
+		return errors.New("serviceMonitor cannot be nil")
+	}
+
+	if sm.Labels == nil {
+		sm.Labels = map[string]string{}
+	}
+	// The add-on Prometheus selects the ServiceMonitors with an app label.
+	sm.Labels["app"] = operatorServiceMonitorName
+	setStackLabels(sm.Labels, m.Spec.MonitoringMode(), nil)
+
+	sm.Spec = promv1.ServiceMonitorSpec{
+		Selector: metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"control-plane": "controller-manager",
+			},
+		},
+		Endpoints: []promv1.Endpoint{{
+			Path:            "/metrics",
+			Port:            "https",
+			Scheme:          "https",
+			BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			TLSConfig: &promv1.TLSConfig{
+				SafeTLSConfig: promv1.SafeTLSConfig{
+					InsecureSkipVerify: true,
+				},
+			},
+		}},
+	}
+
+	return ctrl.SetControllerReference(m, sm, c.Scheme())
+}
+
+func (r *MonitoringReconciler) deleteOperatorServiceMonitor(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	sm := &promv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      operatorServiceMonitorName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	err := r.Delete(ctx, sm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ServiceMonitor %s in %s: %w", sm.Name, sm.Namespace, err)
+	}
+
+	return nil
+}
+
+// reconcileClusterMonitoringLabel labels the add-on namespace for the platform
+// monitoring in the Platform mode, and removes the label it set otherwise.
+func (r *MonitoringReconciler) reconcileClusterMonitoringLabel(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring,
+	platform bool) error {
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: m.Namespace}, ns); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get namespace %s: %w", m.Namespace, err)
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+
+	_, managed := ns.Annotations[clusterMonitoringManagedAnnotation]
+	switch {
+	case platform && ns.Labels[clusterMonitoringLabel] != "true":
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Labels[clusterMonitoringLabel] = "true"
+		ns.Annotations[clusterMonitoringManagedAnnotation] = "true"
+	case !platform && managed:
+		delete(ns.Labels, clusterMonitoringLabel)
+		delete(ns.Annotations, clusterMonitoringManagedAnnotation)
+	default:
+		return nil
+	}
+
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("failed to patch namespace %s: %w", ns.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	promv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("Monitoring mode", Ordered, func() {
+	common.ProcessConfig()
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+	monitoring := &addonv1alpha1.Monitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test",
+		},
+	}
+	pagerDutySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.PagerDutySecretName,
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"PAGERDUTY_KEY": []byte("some-service-key"),
+		},
+	}
+	deadMansSnitchSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.DeadMansSnitchSecretName,
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"SNITCH_URL": []byte("some-snitch-url"),
+		},
+	}
+	r := newTestMonitoringReconciler(namespace, monitoring, pagerDutySecret, deadMansSnitchSecret)
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: monitoring.Namespace,
+			Name:      monitoring.Name,
+		},
+	}
+
+	setMode := func(mode addonv1alpha1.MonitoringMode) {
+		m := &addonv1alpha1.Monitoring{}
+		Expect(r.Get(context.TODO(), req.NamespacedName, m)).ShouldNot(HaveOccurred())
+		m.Spec.Mode = mode
+		Expect(r.Update(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	exists := func(name string, obj client.Object) bool {
+		err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: monitoring.Namespace}, obj)
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ShouldNot(HaveOccurred())
+		return true
+	}
+
+	getNamespace := func() *corev1.Namespace {
+		ns := &corev1.Namespace{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: namespace.Name}, ns)).ShouldNot(HaveOccurred())
+		return ns
+	}
+
+	It("should deploy the dedicated stack by default", func() {
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(exists(prometheusName, &promv1.Prometheus{})).To(BeTrue())
+		Expect(exists(alertManagerName, &promv1.Alertmanager{})).To(BeTrue())
+
+		sm := &promv1.ServiceMonitor{}
+		Expect(exists(operatorServiceMonitorName, sm)).To(BeTrue())
+		Expect(sm.Labels).To(HaveKeyWithValue("app", operatorServiceMonitorName))
+		Expect(sm.Labels).To(HaveKeyWithValue(monitoringModeLabel, "Dedicated"))
+	})
+
+	It("should use the user workload monitoring in the UserWorkload mode", func() {
+		setMode(addonv1alpha1.MonitoringModeUserWorkload)
+
+		Expect(exists(prometheusName, &promv1.Prometheus{})).To(BeFalse())
+		Expect(exists(alertManagerName, &promv1.Alertmanager{})).To(BeFalse())
+		Expect(exists(PrometheusServiceName, &corev1.Service{})).To(BeFalse())
+		Expect(exists(prometheusKubeRBACProxyConfigMapName, &corev1.ConfigMap{})).To(BeFalse())
+		Expect(exists(alertManagerConfigName, &promv1alpha1.AlertmanagerConfig{})).To(BeTrue())
+
+		rule := &promv1.PrometheusRule{}
+		Expect(exists(prometheusRuleName, rule)).To(BeTrue())
+		Expect(rule.Labels).To(HaveKeyWithValue("openshift.io/prometheus-rule-evaluation-scope", "leaf-prometheus"))
+
+		Expect(getNamespace().Labels).ToNot(HaveKey(clusterMonitoringLabel))
+	})
+
+	It("should use the platform monitoring in the Platform mode", func() {
+		setMode(addonv1alpha1.MonitoringModePlatform)
+
+		Expect(exists(prometheusName, &promv1.Prometheus{})).To(BeFalse())
+		Expect(exists(alertManagerConfigName, &promv1alpha1.AlertmanagerConfig{})).To(BeFalse())
+
+		rule := &promv1.PrometheusRule{}
+		Expect(exists(prometheusRuleName, rule)).To(BeTrue())
+		Expect(rule.Labels).To(HaveKeyWithValue("prometheus", "k8s"))
+		Expect(rule.Labels).To(HaveKeyWithValue("role", "alert-rules"))
+		Expect(rule.Labels).ToNot(HaveKey("openshift.io/prometheus-rule-evaluation-scope"))
+
+		sm := &promv1.ServiceMonitor{}
+		Expect(exists(operatorServiceMonitorName, sm)).To(BeTrue())
+		Expect(sm.Labels).To(HaveKeyWithValue(monitoringModeLabel, "Platform"))
+
+		ns := getNamespace()
+		Expect(ns.Labels).To(HaveKeyWithValue(clusterMonitoringLabel, "true"))
+		Expect(ns.Annotations).To(HaveKey(clusterMonitoringManagedAnnotation))
+	})
+
+	It("should restore the dedicated stack in the Dedicated mode", func() {
+		setMode(addonv1alpha1.MonitoringModeDedicated)
+
+		Expect(exists(prometheusName, &promv1.Prometheus{})).To(BeTrue())
+		Expect(exists(alertManagerConfigName, &promv1alpha1.AlertmanagerConfig{})).To(BeTrue())
+
+		rule := &promv1.PrometheusRule{}
+		Expect(exists(prometheusRuleName, rule)).To(BeTrue())
+		Expect(rule.Labels).ToNot(HaveKey("prometheus"))
+
+		ns := getNamespace()
+		Expect(ns.Labels).ToNot(HaveKey(clusterMonitoringLabel))
+		Expect(ns.Annotations).ToNot(HaveKey(clusterMonitoringManagedAnnotation))
+	})
+
+	It("should keep a cluster monitoring label it did not set", func() {
+		ns := getNamespace()
+		ns.Labels = map[string]string{clusterMonitoringLabel: "true"}
+		Expect(r.Update(context.TODO(), ns)).ShouldNot(HaveOccurred())
+
+		setMode(addonv1alpha1.MonitoringModePlatform)
+		setMode(addonv1alpha1.MonitoringModeDedicated)
+
+		Expect(getNamespace().Labels).To(HaveKeyWithValue(clusterMonitoringLabel, "true"))
+	})
+})
@@ -24,30 +24,47 @@ import (
 	promv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/crd"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
 )
 
+const MonitoringDeployedCondition = "MonitoringDeployed"
+
 // MonitoringReconciler reconciles the monitoring stack used by the add-on operator.
 type MonitoringReconciler struct {
 	client.Client
 
 	Scheme *runtime.Scheme
+
+	// Prometheus reads the metrics of the add-on Prometheus, to report the
+	// remote write health. The health is not reported when it is nil.
+	Prometheus PrometheusReader
 }
 
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings/finalizers,verbs=update
 //+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=system,resources={alertmanagers,prometheuses,alertmanagerconfigs},verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=system,resources=prometheusrules,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=system,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=system,resources=podmonitors,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=system,resources=servicemonitors,verbs=get;list;watch;update;patch;create;delete
 //+kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=create;get;list;watch;update
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -72,69 +89,202 @@ func (r *MonitoringReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{Requeue: true}, fmt.Errorf("could not get Monitoring CR: %v", err)
 	}
 
+	missing := common.Capabilities.Missing(common.MonitoringCapabilities...)
+
 	if !monitoring.ObjectMeta.DeletionTimestamp.IsZero() {
+		// There can be no resources of an API which is not available.
+		if len(missing) > 0 {
+			return ctrl.Result{}, nil
+		}
 		if err := r.removeOwnedResources(ctx, &monitoring); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, nil
 	}
 
-	if err := r.reconcilePrometheusKubeRBACProxyConfigMap(ctx, &monitoring); err != nil {
+	if len(missing) > 0 {
+		logger.Info("Skipping reconciliation due to missing capabilities", "capabilities", missing)
+		return ctrl.Result{}, r.patchStatus(ctx, &monitoring,
+			common.NewCapabilityMissingCondition(MonitoringDeployedCondition, missing))
+	}
+
+	mode := monitoring.Spec.MonitoringMode()
+
+	if mode == addonv1alpha1.MonitoringModeDedicated {
+		if err := r.reconcileDedicatedStack(ctx, &monitoring); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err := r.removeDedicatedStack(ctx, &monitoring); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileClusterMonitoringLabel(ctx, &monitoring, mode == addonv1alpha1.MonitoringModePlatform); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if mode == addonv1alpha1.MonitoringModePlatform {
+		// The platform Alertmanager does not select the AlertmanagerConfigs
+		// of the platform namespaces.
+		if err := r.deleteAlertManagerConfig(ctx, &monitoring); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err := r.reconcileAlertManagerConfig(ctx, &monitoring); err != nil {
 		logger.Error(err, "Reconcilation failed",
-			"resource", prometheusKubeRBACProxyConfigMapName,
+			"resource", alertManagerConfigName,
 			"namespace", monitoring.Namespace)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcilePrometheusService(ctx, &monitoring); err != nil {
+	if err := r.reconcilePrometheusRule(ctx, &monitoring); err != nil {
 		logger.Error(err, "Reconcilation failed",
-			"resource", prometheusServiceName,
+			"resource", prometheusRuleName,
 			"namespace", monitoring.Namespace)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcilePrometheus(ctx, &monitoring); err != nil {
+	if err := r.reconcileOperatorServiceMonitor(ctx, &monitoring); err != nil {
 		logger.Error(err, "Reconcilation failed",
-			"resource", prometheusName,
+			"resource", operatorServiceMonitorName,
 			"namespace", monitoring.Namespace)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileAlertManager(ctx, &monitoring); err != nil {
+	result := ctrl.Result{}
+	if mode == addonv1alpha1.MonitoringModeDedicated && len(monitoring.Spec.RemoteWrite) > 0 {
+		result.RequeueAfter = remoteWriteCheckInterval
+	}
+
+	return result, r.patchStatus(ctx, &monitoring, common.NewCondition(
+		MonitoringDeployedCondition,
+		metav1.ConditionTrue,
+		"DeploySuccess",
+		fmt.Sprintf("Monitoring stack deployed successfully in %s mode", mode)))
+}
+
+// reconcileDedicatedStack reconciles the Prometheus and the Alertmanager of
+// the Dedicated mode, along with the resources only they use.
+func (r *MonitoringReconciler) reconcileDedicatedStack(ctx context.Context, monitoring *addonv1alpha1.Monitoring) error {
+	logger := log.FromContext(ctx)
+
+	if err := r.reconcilePrometheusKubeRBACProxyConfigMap(ctx, monitoring); err != nil {
+		logger.Error(err, "Reconcilation failed",
+			"resource", prometheusKubeRBACProxyConfigMapName,
+			"namespace", monitoring.Namespace)
+		return err
+	}
+
+	if err := r.reconcilePrometheusService(ctx, monitoring); err != nil {
+		logger.Error(err, "Reconcilation failed",
+			"resource", PrometheusServiceName,
+			"namespace", monitoring.Namespace)
+		return err
+	}
+
+	if err := r.reconcilePrometheus(ctx, monitoring); err != nil {
+		logger.Error(err, "Reconcilation failed",
+			"resource", prometheusName,
+			"namespace", monitoring.Namespace)
+		return err
+	}
+
+	if err := r.reconcileAlertManager(ctx, monitoring); err != nil {
 		logger.Error(err, "Reconcilation failed",
 			"resource", alertManagerName,
 			"namespace", monitoring.Namespace)
-		return ctrl.Result{}, err
+		return err
 	}
 
-	if err := r.reconcileAlertManagerConfig(ctx, &monitoring); err != nil {
+	if err := r.reconcileFederationServiceMonitor(ctx, monitoring); err != nil {
 		logger.Error(err, "Reconcilation failed",
-			"resource", alertManagerConfigName,
+			"resource", federationServiceMonitorName,
 			"namespace", monitoring.Namespace)
-		return ctrl.Result{}, err
+		return err
 	}
 
-	return ctrl.Result{}, nil
+	return r.reconcileRemoteWriteHealth(ctx, monitoring)
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *MonitoringReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+// SetupWithManager sets up the controller with the Manager. The watches on
+// the monitoring.coreos.com resources are registered with the given CRD
+// reconciler, so that they are only started once their CRDs are established.
+func (r *MonitoringReconciler) SetupWithManager(mgr ctrl.Manager, crds *crd.CRDReconciler) error {
+	toMonitoring := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}}}
+	})
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&addonv1alpha1.Monitoring{}).
 		Owns(&corev1.ConfigMap{}).
 		Owns(&corev1.Service{}).
-		Owns(&promv1.Prometheus{}).
-		Owns(&promv1.Alertmanager{}).
-		Owns(&promv1.PrometheusRule{}).
-		Owns(&promv1.ServiceMonitor{}).
-		Owns(&promv1alpha1.AlertmanagerConfig{}).
-		Complete(r)
+		// The Secrets referenced by the declared receivers are validated.
+		Watches(&source.Kind{Type: &corev1.Secret{}}, toMonitoring).
+		// Prometheus is sized for the number of GPU nodes, which is only
+		// affected by the nodes coming and going, or by their GPU labels.
+		Watches(&source.Kind{Type: &corev1.Node{}}, toMonitoring,
+			builder.WithPredicates(common.GPUNodeLabelsChanged)).
+		Build(health.Reconciles.Track("monitoring", r))
+	if err != nil {
+		return err
+	}
+
+	ownedByMonitoring := &handler.EnqueueRequestForOwner{
+		OwnerType:    &addonv1alpha1.Monitoring{},
+		IsController: true,
+	}
+
+	watches := []crd.Watch{
+		{Capability: common.CapabilityPrometheus, Source: &source.Kind{Type: &promv1.Prometheus{}}},
+		{Capability: common.CapabilityAlertmanager, Source: &source.Kind{Type: &promv1.Alertmanager{}}},
+		{Capability: common.CapabilityPrometheusRule, Source: &source.Kind{Type: &promv1.PrometheusRule{}}},
+		{Capability: common.CapabilityServiceMonitor, Source: &source.Kind{Type: &promv1.ServiceMonitor{}}},
+		{Capability: common.CapabilityAlertmanagerConfig, Source: &source.Kind{Type: &promv1alpha1.AlertmanagerConfig{}}},
+	}
+	for i := range watches {
+		watches[i].Handler = ownedByMonitoring
+	}
+
+	return crds.Register(c, &addonv1alpha1.Monitoring{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}, watches...)
+}
+
+func (r *MonitoringReconciler) patchStatus(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring,
+	condition metav1.Condition) error {
+
+	patch := client.MergeFrom(m.DeepCopy())
+	meta.SetStatusCondition(&m.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, m, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
 }
 
 func (r *MonitoringReconciler) removeOwnedResources(
 	ctx context.Context,
 	m *addonv1alpha1.Monitoring) error {
 
+	if err := r.deleteFederationServiceMonitor(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deleteOperatorServiceMonitor(ctx, m); err != nil {
+		return err
+	}
+
+	if err := r.deletePrometheusRule(ctx, m); err != nil {
+		return err
+	}
+
 	if err := r.deleteAlertManagerConfig(ctx, m); err != nil {
 		return err
 	}
@@ -155,5 +305,5 @@ func (r *MonitoringReconciler) removeOwnedResources(
 		return err
 	}
 
-	return nil
+	return r.reconcileClusterMonitoringLabel(ctx, m, false)
 }
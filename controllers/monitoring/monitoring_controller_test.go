@@ -79,7 +79,7 @@ var _ = Describe("Monitoring Reconcile", Ordered, func() {
 		It("should reconcile the Prometheus Service successfully", func() {
 			err := r.Client.Get(context.TODO(), types.NamespacedName{
 				Namespace: monitoring.Namespace,
-				Name:      prometheusServiceName,
+				Name:      PrometheusServiceName,
 			}, s)
 			Expect(err).ShouldNot(HaveOccurred())
 		})
@@ -112,6 +112,50 @@ var _ = Describe("Monitoring Reconcile", Ordered, func() {
 		})
 	})
 
+	Context("Create Reconcile with missing capabilities", func() {
+		monitoring := &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "test",
+			},
+		}
+		r := newTestMonitoringReconciler(monitoring)
+
+		BeforeAll(func() {
+			common.Capabilities.SetAvailable(common.CapabilityPrometheus, false)
+		})
+
+		It("should only report the missing capability", func() {
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: monitoring.Namespace,
+					Name:      monitoring.Name,
+				},
+			}
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = r.Client.Get(context.TODO(), types.NamespacedName{
+				Namespace: monitoring.Namespace,
+				Name:      prometheusKubeRBACProxyConfigMapName,
+			}, &corev1.ConfigMap{})
+			Expect(err).Should(HaveOccurred())
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+			m := &addonv1alpha1.Monitoring{}
+			err = r.Client.Get(context.TODO(), req.NamespacedName, m)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(m.Status.Conditions).To(HaveLen(1))
+			Expect(m.Status.Conditions[0].Type).To(Equal(MonitoringDeployedCondition))
+			Expect(m.Status.Conditions[0].Reason).To(Equal(common.CapabilityMissingConditionReason))
+		})
+
+		AfterAll(func() {
+			common.SetCapabilitiesAvailable(true)
+		})
+	})
+
 	Context("Delete Reconcile", func() {
 		now := metav1.NewTime(time.Now())
 		monitoring := &addonv1alpha1.Monitoring{
@@ -143,7 +187,7 @@ var _ = Describe("Monitoring Reconcile", Ordered, func() {
 		}
 		s := &corev1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:            prometheusServiceName,
+				Name:            PrometheusServiceName,
 				Namespace:       monitoring.Namespace,
 				OwnerReferences: []metav1.OwnerReference{ctrlRef},
 			},
@@ -193,7 +237,7 @@ var _ = Describe("Monitoring Reconcile", Ordered, func() {
 		It("should delete the Prometheus Service", func() {
 			s := &corev1.Service{}
 			err := r.Client.Get(context.TODO(), types.NamespacedName{
-				Name:      prometheusServiceName,
+				Name:      PrometheusServiceName,
 				Namespace: monitoring.Namespace,
 			}, s)
 			Expect(err).Should(HaveOccurred())
@@ -239,6 +283,8 @@ func newTestMonitoringReconciler(objs ...runtime.Object) *MonitoringReconciler {
 	Expect(promv1.AddToScheme(s)).ShouldNot(HaveOccurred())
 	Expect(promv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
 
+	common.SetCapabilitiesAvailable(true)
+
 	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
 
 	return &MonitoringReconciler{
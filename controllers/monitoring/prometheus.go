@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -19,6 +19,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
 )
 
 const (
@@ -28,11 +29,46 @@ const (
 
 	prometheusKubeRBACProxyConfigMapName = "prometheus-kube-rbac-proxy-config"
 
-	kubeRBACProxyPort = 9339
+	// operatorServiceAccountName is the service account of the operator, in
+	// the add-on namespace.
+	operatorServiceAccountName = "controller-manager"
+)
+
+// kubeRBACProxyConfig is the configuration of the kube-rbac-proxy of the
+// add-on Prometheus. The requests matching its static authorizations are
+// allowed without a SubjectAccessReview.
+type kubeRBACProxyConfig struct {
+	Authorization struct {
+		Static []kubeRBACProxyStaticAuthorization `json:"static"`
+	} `json:"authorization"`
+}
+
+type kubeRBACProxyStaticAuthorization struct {
+	User            *kubeRBACProxyUser `json:"user,omitempty"`
+	Path            string             `json:"path"`
+	ResourceRequest bool               `json:"resourceRequest"`
+	Verb            string             `json:"verb"`
+}
+
+type kubeRBACProxyUser struct {
+	Name string `json:"name"`
+}
+
+const (
+	// PrometheusServiceName is the Service of the add-on Prometheus.
+	PrometheusServiceName = "gpuaddon-prometheus-service"
 
-	prometheusServiceName = "gpuaddon-prometheus-service"
+	// KubeRBACProxyPort is the port on which the add-on Prometheus is
+	// exposed through kube-rbac-proxy.
+	KubeRBACProxyPort = 9339
 )
 
+// prometheusServiceLabels select the add-on Prometheus Service in the
+// federation ServiceMonitor.
+var prometheusServiceLabels = map[string]string{
+	"app.kubernetes.io/name": PrometheusServiceName,
+}
+
 func (r *MonitoringReconciler) reconcilePrometheus(
 	ctx context.Context,
 	m *addonv1alpha1.Monitoring) error {
@@ -63,13 +99,53 @@ func (r *MonitoringReconciler) reconcilePrometheus(
 		prometheus = existingPrometheus
 	}
 
+	gpuNodes, err := r.countGPUNodes(ctx)
+	if err != nil {
+		return err
+	}
+	recommended := recommendedResources(gpuNodes)
+
+	problems, err := r.validateRemoteWrite(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	remoteWrite := m.Spec.RemoteWrite
+	condition := common.NewCondition(
+		RemoteWriteConfigValidCondition,
+		metav1.ConditionTrue,
+		"ValidRemoteWriteConfig",
+		"The remote write endpoints are valid")
+	if len(problems) > 0 {
+		logger.Info("Ignoring the invalid remote write endpoints", "problems", problems)
+		remoteWrite = nil
+		condition = common.NewCondition(
+			RemoteWriteConfigValidCondition,
+			metav1.ConditionFalse,
+			"InvalidRemoteWriteConfig",
+			strings.Join(problems, "; "))
+	}
+
+	if err := r.patchStatus(ctx, m, condition); err != nil {
+		return err
+	}
+
 	res, err := controllerutil.CreateOrPatch(context.TODO(), r.Client, prometheus, func() error {
-		return r.setDesiredPrometheus(r.Client, prometheus, m)
+		return r.setDesiredPrometheus(r.Client, prometheus, m, recommended, remoteWrite)
 	})
 	if err != nil {
 		return err
 	}
 
+	sizing := &addonv1alpha1.PrometheusSizingStatus{
+		GPUNodes:             gpuNodes,
+		RecommendedResources: recommended,
+	}
+	condition = sizingCondition(prometheus.Spec.Resources, recommended, gpuNodes)
+	if err := r.patchSizingStatus(ctx, m, sizing, condition); err != nil {
+		return err
+	}
+
 	logger.Info("Prometheus reconciled successfully",
 		"name", prometheus.Name,
 		"namespace", prometheus.Namespace,
@@ -81,7 +157,9 @@ func (r *MonitoringReconciler) reconcilePrometheus(
 func (r *MonitoringReconciler) setDesiredPrometheus(
 	c client.Client,
 	prometheus *promv1.Prometheus,
-	m *addonv1alpha1.Monitoring) error {
+	m *addonv1alpha1.Monitoring,
+	recommended corev1.ResourceRequirements,
+	remoteWrite []addonv1alpha1.RemoteWriteSpec) error {
 
 	if prometheus == nil {
 		return errors.New("prometheus cannot be nil")
@@ -101,7 +179,29 @@ func (r *MonitoringReconciler) setDesiredPrometheus(
 			EnableAdminAPI:         false,
 			ListenLocal:            true,
 		},
-		RuleNamespaceSelector: &selector,
+		// The rules are only loaded from the namespace of the Prometheus.
+		RuleSelector: &metav1.LabelSelector{
+			MatchLabels: prometheusRuleLabels,
+		},
+		Retention:     promv1.Duration(m.Spec.Retention),
+		RetentionSize: promv1.ByteSize(m.Spec.RetentionSize),
+	}
+
+	if len(remoteWrite) > 0 {
+		prometheus.Spec.RemoteWrite = desiredRemoteWrite(remoteWrite)
+	}
+
+	if spec := m.Spec.Prometheus; spec != nil {
+		prometheus.Spec.Replicas = spec.Replicas
+		prometheus.Spec.ScrapeInterval = promv1.Duration(spec.ScrapeInterval)
+
+		if spec.VolumeClaimTemplate != nil {
+			prometheus.Spec.Storage = &promv1.StorageSpec{
+				VolumeClaimTemplate: promv1.EmbeddedPersistentVolumeClaim{
+					Spec: *spec.VolumeClaimTemplate.DeepCopy(),
+				},
+			}
+		}
 	}
 
 	prometheus.Spec.Alerting = &promv1.AlertingSpec{
@@ -112,16 +212,7 @@ func (r *MonitoringReconciler) setDesiredPrometheus(
 		}},
 	}
 
-	prometheus.Spec.Resources = corev1.ResourceRequirements{
-		Limits: corev1.ResourceList{
-			"cpu":    resource.MustParse("1"),
-			"memory": resource.MustParse("250Mi"),
-		},
-		Requests: corev1.ResourceList{
-			"cpu":    resource.MustParse("1"),
-			"memory": resource.MustParse("250Mi"),
-		},
-	}
+	prometheus.Spec.Resources = prometheusResources(m, recommended)
 
 	prometheus.Spec.Containers = []corev1.Container{
 		{
@@ -129,7 +220,7 @@ func (r *MonitoringReconciler) setDesiredPrometheus(
 			Image: "quay.io/openshift/origin-kube-rbac-proxy:4.10.0",
 			Name:  "kube-rbac-proxy",
 			Args: []string{
-				fmt.Sprintf("--secure-listen-address=0.0.0.0:%d", kubeRBACProxyPort),
+				fmt.Sprintf("--secure-listen-address=0.0.0.0:%d", KubeRBACProxyPort),
 				"--upstream=http://127.0.0.1:9090/",
 				"--logtostderr=true",
 				"--v=10",
@@ -140,7 +231,7 @@ func (r *MonitoringReconciler) setDesiredPrometheus(
 			},
 			Ports: []corev1.ContainerPort{{
 				Name:          "https",
-				ContainerPort: kubeRBACProxyPort,
+				ContainerPort: KubeRBACProxyPort,
 			}},
 			VolumeMounts: []corev1.VolumeMount{
 				{
@@ -261,25 +352,21 @@ func (r *MonitoringReconciler) setDesiredPrometheusKubeRBACProxyConfigMap(
 
 	cm.Data = map[string]string{
 		"config-file.json": (func() string {
-			config := struct {
-				Authorization struct {
-					Static [2]struct {
-						Path            string `json:"path"`
-						ResourceRequest bool   `json:"resourceRequest"`
-						Verb            string `json:"verb"`
-					} `json:"static"`
-				} `json:"authorization"`
-			}{}
-
-			item := &config.Authorization.Static[0]
-			item.Verb = "get"
-			item.Path = "/metrics"
-			item.ResourceRequest = false
-
-			item = &config.Authorization.Static[1]
-			item.Verb = "get"
-			item.Path = "/federate"
-			item.ResourceRequest = false
+			config := kubeRBACProxyConfig{}
+			config.Authorization.Static = []kubeRBACProxyStaticAuthorization{
+				{Verb: "get", Path: "/metrics"},
+				{Verb: "get", Path: "/federate"},
+				// The operator queries the GPU usage, e.g. for the usage
+				// reports and the idle GPU policy, without any RBAC on the
+				// Prometheus API.
+				{
+					User: &kubeRBACProxyUser{
+						Name: fmt.Sprintf("system:serviceaccount:%s:%s", m.Namespace, operatorServiceAccountName),
+					},
+					Verb: "get",
+					Path: "/api/v1/query",
+				},
+			}
 
 			raw, _ := json.Marshal(config)
 			return string(raw)
@@ -318,7 +405,7 @@ func (r *MonitoringReconciler) reconcilePrometheusService(
 	existingService := &corev1.Service{}
 
 	err := r.Get(ctx, types.NamespacedName{
-		Name:      prometheusServiceName,
+		Name:      PrometheusServiceName,
 		Namespace: m.Namespace,
 	}, existingService)
 
@@ -329,7 +416,7 @@ func (r *MonitoringReconciler) reconcilePrometheusService(
 
 	s := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      prometheusServiceName,
+			Name:      PrometheusServiceName,
 			Namespace: m.Namespace,
 		},
 	}
@@ -366,7 +453,7 @@ func (r *MonitoringReconciler) setDesiredPrometheusService(
 		{
 			Name:       "https",
 			Protocol:   corev1.ProtocolTCP,
-			Port:       int32(kubeRBACProxyPort),
+			Port:       int32(KubeRBACProxyPort),
 			TargetPort: intstr.FromString("https"),
 		},
 	}
@@ -375,6 +462,13 @@ func (r *MonitoringReconciler) setDesiredPrometheusService(
 		"app.kubernetes.io/name": "prometheus",
 	}
 
+	if s.Labels == nil {
+		s.Labels = map[string]string{}
+	}
+	for k, v := range prometheusServiceLabels {
+		s.Labels[k] = v
+	}
+
 	annotations := s.GetAnnotations()
 	if annotations == nil {
 		annotations = make(map[string]string)
@@ -393,7 +487,7 @@ func (r *MonitoringReconciler) deletePrometheusService(
 
 	s := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      prometheusServiceName,
+			Name:      PrometheusServiceName,
 			Namespace: m.Namespace,
 		},
 	}
@@ -2,13 +2,17 @@ package monitoring
 
 import (
 	"context"
+	"encoding/json"
 
 	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -40,7 +44,7 @@ var _ = Describe("Prometheus", Ordered, func() {
 			c := fake.
 				NewClientBuilder().
 				WithScheme(scheme).
-				WithRuntimeObjects().
+				WithRuntimeObjects(m).
 				Build()
 
 			r.Client = c
@@ -53,6 +57,87 @@ var _ = Describe("Prometheus", Ordered, func() {
 				Namespace: m.Namespace,
 			}, &p)
 			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(p.Spec.Retention).To(BeEmpty())
+			Expect(p.Spec.RetentionSize).To(BeEmpty())
+			Expect(p.Spec.Replicas).To(BeNil())
+			Expect(p.Spec.ScrapeInterval).To(BeEmpty())
+			Expect(p.Spec.Storage).To(BeNil())
+			Expect(p.Spec.Resources).To(Equal(recommendedResources(0)))
+		})
+	})
+
+	Context("Reconcile with a custom sizing", func() {
+		common.ProcessConfig()
+
+		m := &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "test",
+			},
+			Spec: addonv1alpha1.MonitoringSpec{
+				Retention:     "15d",
+				RetentionSize: "10GB",
+				Prometheus: &addonv1alpha1.PrometheusSpec{
+					Replicas:       pointer.Int32(2),
+					ScrapeInterval: "1m",
+					VolumeClaimTemplate: &corev1.PersistentVolumeClaimSpec{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceStorage: resource.MustParse("20Gi"),
+							},
+						},
+					},
+				},
+			},
+		}
+		gpuNode := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "gpu-node",
+				Labels: map[string]string{common.GPUNodeLabel: "true"},
+			},
+		}
+		r := &MonitoringReconciler{}
+
+		scheme := scheme.Scheme
+		Expect(promv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(addonv1alpha1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+
+		var p promv1.Prometheus
+
+		It("should create the Prometheus CR with the custom sizing", func() {
+			c := fake.
+				NewClientBuilder().
+				WithScheme(scheme).
+				WithRuntimeObjects(m, gpuNode).
+				Build()
+
+			r.Client = c
+
+			err := r.reconcilePrometheus(context.TODO(), m)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = c.Get(context.TODO(), types.NamespacedName{
+				Name:      "gpuaddon-prometheus",
+				Namespace: m.Namespace,
+			}, &p)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(p.Spec.Retention).To(Equal(promv1.Duration("15d")))
+			Expect(p.Spec.RetentionSize).To(Equal(promv1.ByteSize("10GB")))
+			Expect(*p.Spec.Replicas).To(Equal(int32(2)))
+			Expect(p.Spec.ScrapeInterval).To(Equal(promv1.Duration("1m")))
+			Expect(p.Spec.Storage.VolumeClaimTemplate.Spec.Resources.Requests.Storage().String()).To(Equal("20Gi"))
+			Expect(p.Spec.Resources.Requests.Memory().String()).To(Equal("576Mi"))
+		})
+
+		It("should report the recommended sizing", func() {
+			err := r.Get(context.TODO(), types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, m)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(m.Status.PrometheusSizing).ToNot(BeNil())
+			Expect(m.Status.PrometheusSizing.GPUNodes).To(Equal(int32(1)))
+			Expect(meta.IsStatusConditionTrue(m.Status.Conditions, PrometheusSizedCondition)).To(BeTrue())
 		})
 	})
 
@@ -132,6 +217,27 @@ var _ = Describe("Prometheus KubeRBACProxy ConfigMap", Ordered, func() {
 			}, &cm)
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+
+		It("should allow the operator to query the Prometheus API", func() {
+			config := kubeRBACProxyConfig{}
+			Expect(json.Unmarshal([]byte(cm.Data["config-file.json"]), &config)).To(Succeed())
+
+			Expect(config.Authorization.Static).To(ContainElements(
+				kubeRBACProxyStaticAuthorization{Verb: "get", Path: "/metrics"},
+				kubeRBACProxyStaticAuthorization{Verb: "get", Path: "/federate"},
+				kubeRBACProxyStaticAuthorization{
+					User: &kubeRBACProxyUser{Name: "system:serviceaccount:test:controller-manager"},
+					Verb: "get",
+					Path: "/api/v1/query",
+				}))
+
+			// Nobody else may query the Prometheus API without RBAC.
+			for _, s := range config.Authorization.Static {
+				if s.Path == "/api/v1/query" {
+					Expect(s.User).ToNot(BeNil())
+				}
+			}
+		})
 	})
 
 	Context("Delete", func() {
@@ -224,7 +330,7 @@ var _ = Describe("Prometheus Service", Ordered, func() {
 
 		s := &corev1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      prometheusServiceName,
+				Name:      PrometheusServiceName,
 				Namespace: m.Namespace,
 			},
 		}
@@ -242,7 +348,7 @@ var _ = Describe("Prometheus Service", Ordered, func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			err = c.Get(context.TODO(), types.NamespacedName{
-				Name:      prometheusServiceName,
+				Name:      PrometheusServiceName,
 				Namespace: m.Namespace,
 			}, s)
 			Expect(err).Should(HaveOccurred())
@@ -0,0 +1,370 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+const (
+	prometheusRuleName = "gpuaddon-prometheus-rules"
+
+	defaultTemperatureThreshold        = 85
+	defaultThermalThrottlingFor        = "5m"
+	defaultECCDoubleBitErrorsThreshold = 0
+	defaultNVLinkErrorsThreshold       = 0
+	defaultNotReadyFor                 = "15m"
+	defaultInstallingFor               = "30m"
+
+	// xidFallenOffBus is the XID error of a GPU which is no longer reachable
+	// by the driver.
+	xidFallenOffBus = 79
+
+	// clockThrottleSWThermalSlowdown is the clock throttle reason bit of the
+	// software thermal slowdown, followed by the hardware thermal slowdown
+	// one, as reported in DCGM_FI_DEV_CLOCK_THROTTLE_REASONS.
+	clockThrottleSWThermalSlowdown = 0x20
+)
+
+// prometheusRuleLabels select the add-on PrometheusRule in the add-on
+// Prometheus.
+var prometheusRuleLabels = map[string]string{
+	"app": prometheusRuleName,
+}
+
+// gpuAlertThresholds are the GPU health alert thresholds in effect.
+type gpuAlertThresholds struct {
+	temperature        int32
+	thermalThrottling  string
+	eccDoubleBitErrors int32
+	nvlinkErrors       int32
+	notReady           string
+	installing         string
+}
+
+// getGPUAlertThresholds returns the GPU health alert thresholds of the given
+// spec, falling back to their defaults.
+func getGPUAlertThresholds(spec *addonv1alpha1.GPUAlertsSpec) gpuAlertThresholds {
+	t := gpuAlertThresholds{
+		temperature:        defaultTemperatureThreshold,
+		thermalThrottling:  defaultThermalThrottlingFor,
+		eccDoubleBitErrors: defaultECCDoubleBitErrorsThreshold,
+		nvlinkErrors:       defaultNVLinkErrorsThreshold,
+		notReady:           defaultNotReadyFor,
+		installing:         defaultInstallingFor,
+	}
+
+	if spec == nil {
+		return t
+	}
+
+	if spec.TemperatureThreshold != nil {
+		t.temperature = *spec.TemperatureThreshold
+	}
+	if spec.ThermalThrottlingFor != "" {
+		t.thermalThrottling = spec.ThermalThrottlingFor
+	}
+	if spec.ECCDoubleBitErrorsThreshold != nil {
+		t.eccDoubleBitErrors = *spec.ECCDoubleBitErrorsThreshold
+	}
+	if spec.NVLinkErrorsThreshold != nil {
+		t.nvlinkErrors = *spec.NVLinkErrorsThreshold
+	}
+	if spec.NotReadyFor != "" {
+		t.notReady = spec.NotReadyFor
+	}
+	if spec.InstallingFor != "" {
+		t.installing = spec.InstallingFor
+	}
+
+	return t
+}
+
+func (r *MonitoringReconciler) reconcilePrometheusRule(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "PrometheusRule CR")
+	logger.Info("Reconciling PrometheusRule")
+
+	prometheusRule := &promv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prometheusRuleName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, prometheusRule, func() error {
+		return r.setDesiredPrometheusRule(r.Client, prometheusRule, m)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("PrometheusRule reconciled successfully",
+		"name", prometheusRule.Name,
+		"namespace", prometheusRule.Namespace,
+		"result", res)
+
+	return nil
+}
+
+func (r *MonitoringReconciler) setDesiredPrometheusRule(
+	c client.Client,
+	prometheusRule *promv1.PrometheusRule,
+	m *addonv1alpha1.Monitoring) error {
+
+	if prometheusRule == nil {
+		return errors.New("prometheusRule cannot be nil")
+	}
+
+	if prometheusRule.Labels == nil {
+		prometheusRule.Labels = map[string]string{}
+	}
+	for k, v := range prometheusRuleLabels {
+		prometheusRule.Labels[k] = v
+	}
+	setStackLabels(prometheusRule.Labels, m.Spec.MonitoringMode(), stackRuleLabels)
+
+	prometheusRule.Spec = promv1.PrometheusRuleSpec{
+		Groups: desiredRuleGroups(getGPUAlertThresholds(m.Spec.GPUAlerts)),
+	}
+
+	return ctrl.SetControllerReference(m, prometheusRule, c.Scheme())
+}
+
+// desiredRuleGroups returns the add-on alerting rules with the given GPU
+// health alert thresholds. Each alert has to be covered by the promtool rule
+// tests in testdata/rules_test.yaml.
+func desiredRuleGroups(t gpuAlertThresholds) []promv1.RuleGroup {
+	return []promv1.RuleGroup{
+		{
+			Name: "nvidia-gpuaddon-operator",
+			Rules: []promv1.Rule{
+				{
+					Alert: "NVIDIAGPUAddonGPUOperatorSubscriptionInstallationPending",
+					Expr:  intstr.FromString("nvidia_gpuaddon_gpu_operator_subscription_installed < 1"),
+					For:   "10m",
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": "The NVIDIA GPUAddon GPU Operator installation is still pending after 10 minutes",
+						"message": "The NVIDIA GPUAddon GPU Operator installation is still pending after 10 minutes, " +
+							"please check the operator Subscription and ClusterServiceVersion for more details.",
+					},
+				},
+			},
+		},
+		{
+			Name: "nvidia-gpuaddon-health",
+			Rules: []promv1.Rule{
+				{
+					Alert: "NVIDIAGPUAddonFailed",
+					Expr:  intstr.FromString(`nvidia_gpuaddon_phase{phase="Failed"} == 1`),
+					For:   "5m",
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+					Annotations: map[string]string{
+						"summary": "The NVIDIA GPUAddon has failed",
+						"description": "The NVIDIA GPUAddon has been in the Failed phase for 5m, " +
+							"check the GPUAddon conditions and the operator logs for more details.",
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonInstallationStuck",
+					Expr:  intstr.FromString(`nvidia_gpuaddon_phase{phase="Installing"} == 1`),
+					For:   t.installing,
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("The NVIDIA GPUAddon is still installing after %s", t.installing),
+						"description": "The NVIDIA GPUAddon components are not all deployed, " +
+							"check the GPUAddon conditions for the pending ones.",
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonClusterPolicyNotReady",
+					Expr: intstr.FromString(
+						`nvidia_gpuaddon_condition{type="ClusterPolicyReady",status!="True"} == 1`),
+					For: t.notReady,
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+					Annotations: map[string]string{
+						"summary": "The GPU operator ClusterPolicy is not ready: {{ $labels.reason }}",
+						"description": fmt.Sprintf("The GPU operator ClusterPolicy has not been ready for %s, "+
+							"check its status and the GPU operator pods.", t.notReady),
+					},
+				},
+			},
+		},
+		{
+			Name: "nvidia-gpuaddon-gpu-health",
+			Rules: []promv1.Rule{
+				{
+					Alert: "NVIDIAGPUAddonGPUXidError",
+					Expr: intstr.FromString(fmt.Sprintf(
+						"DCGM_FI_DEV_XID_ERRORS > 0 and DCGM_FI_DEV_XID_ERRORS != %d", xidFallenOffBus)),
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} reported XID error {{ $value }}",
+						"description": "An XID error reports a GPU driver or hardware problem, " +
+							"see https://docs.nvidia.com/deploy/xid-errors/ for its meaning.",
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonGPUFallenOffBus",
+					Expr:  intstr.FromString(fmt.Sprintf("DCGM_FI_DEV_XID_ERRORS == %d", xidFallenOffBus)),
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+					Annotations: map[string]string{
+						"summary": "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} has fallen off the bus",
+						"description": "The GPU is no longer reachable by the driver (XID 79). " +
+							"The node has to be drained and rebooted, and the GPU may have to be replaced.",
+					},
+				},
+				{
+					// PromQL has no bitwise operators, hence the bits are
+					// tested by integer division.
+					Alert: "NVIDIAGPUAddonGPUThermalThrottling",
+					Expr: intstr.FromString(fmt.Sprintf("floor(DCGM_FI_DEV_CLOCK_THROTTLE_REASONS / %d) %% 4 > 0",
+						clockThrottleSWThermalSlowdown)),
+					For: t.thermalThrottling,
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} is thermally throttled",
+						"description": fmt.Sprintf("The GPU clocks have been lowered by the thermal slowdown for %s. "+
+							"Check the cooling of the node.", t.thermalThrottling),
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonGPUHighTemperature",
+					Expr:  intstr.FromString(fmt.Sprintf("DCGM_FI_DEV_GPU_TEMP >= %d", t.temperature)),
+					For:   t.thermalThrottling,
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} is running hot",
+						"description": fmt.Sprintf("The GPU temperature has been at or above %dC for %s, "+
+							"which may get the GPU clocks lowered. Check the cooling of the node.", t.temperature, t.thermalThrottling),
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonGPUECCDoubleBitErrors",
+					Expr: intstr.FromString(fmt.Sprintf(
+						"increase(DCGM_FI_DEV_ECC_DBE_VOL_TOTAL[10m]) > %d", t.eccDoubleBitErrors)),
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+					Annotations: map[string]string{
+						"summary": "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} reported ECC double-bit errors",
+						"description": "Double-bit ECC errors cannot be corrected and corrupt the GPU memory. " +
+							"The GPU has to be reset and may have to be replaced.",
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonGPUNVLinkErrors",
+					Expr: intstr.FromString(fmt.Sprintf(
+						"increase(DCGM_FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_TOTAL[10m]) > %[1]d"+
+							" or increase(DCGM_FI_DEV_NVLINK_RECOVERY_ERROR_COUNT_TOTAL[10m]) > %[1]d"+
+							" or increase(DCGM_FI_DEV_NVLINK_REPLAY_ERROR_COUNT_TOTAL[10m]) > %[1]d", t.nvlinkErrors)),
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "GPU {{ $labels.gpu }} on node {{ $labels.Hostname }} reported NVLink errors",
+						"description": "NVLink errors degrade the bandwidth between the GPUs of the node.",
+					},
+				},
+			},
+		},
+		{
+			Name: "nvidia-gpuaddon-idle-gpus",
+			Rules: []promv1.Rule{
+				{
+					Alert: "NVIDIAGPUAddonIdleGPUPods",
+					Expr:  intstr.FromString("nvidia_gpuaddon_idle_gpu_pods > 0"),
+					Labels: map[string]string{
+						"severity": "info",
+					},
+					Annotations: map[string]string{
+						"summary": "{{ $value }} pods hold idle GPUs in namespace {{ $labels.workload_namespace }}",
+						"description": "The GPUs of these pods have been idle for the time set in the GPUAddon idle GPU policy, " +
+							"check the pods with the idle-gpu label and the GPUAddon status.",
+					},
+				},
+			},
+		},
+		{
+			Name: "nvidia-gpuaddon-gpu-operator",
+			Rules: []promv1.Rule{
+				{
+					Alert: "NVIDIAGPUAddonDriverNotReady",
+					Expr:  intstr.FromString("gpu_operator_node_driver_ready == 0"),
+					For:   t.notReady,
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+					Annotations: map[string]string{
+						"summary": "The NVIDIA driver is not ready on {{ $labels.instance }}",
+						"description": fmt.Sprintf("The NVIDIA driver validation has been failing for %s, "+
+							"check the nvidia-driver-daemonset pod of the node.", t.notReady),
+					},
+				},
+				{
+					Alert: "NVIDIAGPUAddonValidatorNotReady",
+					Expr: intstr.FromString(
+						`min by (instance) ({__name__=~"gpu_operator_node_(toolkit|cuda|device_plugin)_ready"}) == 0`),
+					For: t.notReady,
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary": "The GPU operator validations are failing on {{ $labels.instance }}",
+						"description": fmt.Sprintf("The container toolkit, CUDA or device plugin validation "+
+							"has been failing for %s, check the nvidia-operator-validator pod of the node.", t.notReady),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *MonitoringReconciler) deletePrometheusRule(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring) error {
+
+	pr := &promv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prometheusRuleName,
+			Namespace: m.Namespace,
+		},
+	}
+
+	err := r.Delete(ctx, pr)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete PrometheusRule %s in %s: %w", pr.Name, pr.Namespace, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	// The rules rendered with the default thresholds, which the promtool
+	// rule tests run against, see `make test-rules`. Set UPDATE_TESTDATA to
+	// regenerate them.
+	rulesFile     = "testdata/rules.yaml"
+	rulesTestFile = "testdata/rules_test.yaml"
+)
+
+var _ = Describe("PrometheusRule", Ordered, func() {
+	Context("Reconcile", func() {
+		common.ProcessConfig()
+
+		m := &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "test",
+			},
+			Spec: addonv1alpha1.MonitoringSpec{
+				GPUAlerts: &addonv1alpha1.GPUAlertsSpec{
+					TemperatureThreshold: pointer.Int32(80),
+					NotReadyFor:          "30m",
+				},
+			},
+		}
+		r := &MonitoringReconciler{}
+
+		scheme := scheme.Scheme
+		Expect(promv1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+
+		var pr promv1.PrometheusRule
+
+		It("should create the PrometheusRule CR with the configured thresholds", func() {
+			c := fake.
+				NewClientBuilder().
+				WithScheme(scheme).
+				Build()
+
+			r.Client = c
+
+			err := r.reconcilePrometheusRule(context.TODO(), m)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = c.Get(context.TODO(), types.NamespacedName{
+				Name:      prometheusRuleName,
+				Namespace: m.Namespace,
+			}, &pr)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pr.Labels).To(HaveKeyWithValue("app", prometheusRuleName))
+
+			rules := rulesByAlert(pr.Spec.Groups)
+			Expect(rules["NVIDIAGPUAddonGPUHighTemperature"].Expr.StrVal).To(Equal("DCGM_FI_DEV_GPU_TEMP >= 80"))
+			Expect(rules["NVIDIAGPUAddonGPUHighTemperature"].For).To(Equal(defaultThermalThrottlingFor))
+			Expect(rules["NVIDIAGPUAddonGPUThermalThrottling"].Expr.StrVal).
+				To(Equal("floor(DCGM_FI_DEV_CLOCK_THROTTLE_REASONS / 32) % 4 > 0"))
+			Expect(rules["NVIDIAGPUAddonGPUThermalThrottling"].For).To(Equal(defaultThermalThrottlingFor))
+			Expect(rules["NVIDIAGPUAddonDriverNotReady"].For).To(Equal("30m"))
+			Expect(rules["NVIDIAGPUAddonValidatorNotReady"].For).To(Equal("30m"))
+		})
+
+		It("should delete the PrometheusRule CR", func() {
+			err := r.deletePrometheusRule(context.TODO(), m)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = r.Get(context.TODO(), types.NamespacedName{
+				Name:      prometheusRuleName,
+				Namespace: m.Namespace,
+			}, &pr)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("Rule tests", func() {
+		rendered, err := yaml.Marshal(promv1.PrometheusRuleSpec{
+			Groups: desiredRuleGroups(getGPUAlertThresholds(nil)),
+		})
+
+		It("should render the rules the promtool rule tests run against", func() {
+			Expect(err).ShouldNot(HaveOccurred())
+
+			if os.Getenv("UPDATE_TESTDATA") != "" {
+				Expect(os.WriteFile(filepath.FromSlash(rulesFile), rendered, 0644)).ShouldNot(HaveOccurred())
+			}
+
+			existing, err := os.ReadFile(filepath.FromSlash(rulesFile))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(existing)).To(Equal(string(rendered)),
+				"%s is outdated, run the tests with UPDATE_TESTDATA=1", rulesFile)
+		})
+
+		It("should have a promtool rule test for each alert", func() {
+			content, err := os.ReadFile(filepath.FromSlash(rulesTestFile))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			tests := struct {
+				Tests []struct {
+					AlertRuleTest []struct {
+						AlertName string `json:"alertname"`
+						ExpAlerts []struct {
+							ExpLabels map[string]string `json:"exp_labels"`
+						} `json:"exp_alerts"`
+					} `json:"alert_rule_test"`
+				} `json:"tests"`
+			}{}
+			Expect(yaml.Unmarshal(content, &tests)).ShouldNot(HaveOccurred())
+
+			firing := map[string]bool{}
+			for _, t := range tests.Tests {
+				for _, a := range t.AlertRuleTest {
+					if len(a.ExpAlerts) > 0 {
+						firing[a.AlertName] = true
+					}
+				}
+			}
+
+			for alert := range rulesByAlert(desiredRuleGroups(getGPUAlertThresholds(nil))) {
+				Expect(firing).To(HaveKey(alert), "alert %s has no promtool rule test where it fires", alert)
+			}
+		})
+
+		It("should route existing alerts to PagerDuty by default", func() {
+			rules := rulesByAlert(desiredRuleGroups(getGPUAlertThresholds(nil)))
+			for _, alert := range defaultPagerDutyAlerts {
+				Expect(rules).To(HaveKey(alert))
+			}
+		})
+	})
+})
+
+func rulesByAlert(groups []promv1.RuleGroup) map[string]promv1.Rule {
+	rules := map[string]promv1.Rule{}
+	for _, g := range groups {
+		for _, rule := range g.Rules {
+			rules[rule.Alert] = rule
+		}
+	}
+	return rules
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	promv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+const AlertingConfigValidCondition = "AlertingConfigValid"
+
+const (
+	nullReceiverName           = "null"
+	pagerDutyReceiverName      = "pagerduty"
+	deadMansSnitchReceiverName = "DeadMansSnitch"
+
+	defaultGroupWait      = "30s"
+	defaultGroupInterval  = "5m"
+	defaultRepeatInterval = "12h"
+)
+
+// reservedReceiverNames are the names of the managed receivers, which the
+// declared receivers cannot use.
+var reservedReceiverNames = []string{
+	nullReceiverName,
+	pagerDutyReceiverName,
+	deadMansSnitchReceiverName,
+}
+
+// validateAlerting returns the problems of the receivers and routes declared
+// in the given Monitoring spec, including Secrets which are missing.
+func (r *MonitoringReconciler) validateAlerting(ctx context.Context, m *addonv1alpha1.Monitoring) ([]string, error) {
+	problems := validateReceivers(m.Spec.Receivers)
+	problems = append(problems, validateRoutes(m.Spec.Routes, m.Spec.Receivers)...)
+
+	for _, receiver := range m.Spec.Receivers {
+		for _, ref := range receiverSecretRefs(receiver) {
+			problem, err := r.checkSecretKey(ctx, m.Namespace, ref)
+			if err != nil {
+				return nil, err
+			}
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("receiver %q: %s", receiver.Name, problem))
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+func validateReceivers(receivers []addonv1alpha1.ReceiverSpec) []string {
+	problems := []string{}
+	names := map[string]bool{}
+
+	for _, receiver := range receivers {
+		switch {
+		case receiver.Name == "":
+			problems = append(problems, "a receiver has no name")
+		case isReservedReceiverName(receiver.Name):
+			problems = append(problems, fmt.Sprintf("receiver %q: the name is reserved", receiver.Name))
+		case names[receiver.Name]:
+			problems = append(problems, fmt.Sprintf("receiver %q: the name is not unique", receiver.Name))
+		}
+		names[receiver.Name] = true
+
+		integrations := 0
+		for _, set := range []bool{
+			receiver.PagerDuty != nil,
+			receiver.Slack != nil,
+			receiver.Email != nil,
+			receiver.Webhook != nil,
+			receiver.OpsGenie != nil,
+		} {
+			if set {
+				integrations++
+			}
+		}
+		if integrations != 1 {
+			problems = append(problems, fmt.Sprintf("receiver %q: exactly one integration must be set, found %d",
+				receiver.Name, integrations))
+		}
+
+		if pd := receiver.PagerDuty; pd != nil && (pd.RoutingKey == nil) == (pd.ServiceKey == nil) {
+			problems = append(problems, fmt.Sprintf("receiver %q: exactly one of routing_key and service_key must be set",
+				receiver.Name))
+		}
+
+		if e := receiver.Email; e != nil && (e.To == "" || e.From == "" || e.Smarthost == "") {
+			problems = append(problems, fmt.Sprintf("receiver %q: to, from and smarthost must be set", receiver.Name))
+		}
+	}
+
+	return problems
+}
+
+func validateRoutes(routes []addonv1alpha1.RouteSpec, receivers []addonv1alpha1.ReceiverSpec) []string {
+	problems := []string{}
+
+	declared := map[string]bool{}
+	for _, receiver := range receivers {
+		declared[receiver.Name] = true
+	}
+
+	for i, route := range routes {
+		if !declared[route.Receiver] {
+			problems = append(problems, fmt.Sprintf("route %d: receiver %q is not declared", i, route.Receiver))
+		}
+	}
+
+	return problems
+}
+
+// receiverSecretRefs returns the Secret keys the given receiver refers to.
+func receiverSecretRefs(receiver addonv1alpha1.ReceiverSpec) []*corev1.SecretKeySelector {
+	refs := []*corev1.SecretKeySelector{}
+
+	if pd := receiver.PagerDuty; pd != nil {
+		if pd.RoutingKey != nil {
+			refs = append(refs, pd.RoutingKey)
+		}
+		if pd.ServiceKey != nil {
+			refs = append(refs, pd.ServiceKey)
+		}
+	}
+	if receiver.Slack != nil {
+		refs = append(refs, &receiver.Slack.APIURL)
+	}
+	if receiver.Email != nil && receiver.Email.AuthPassword != nil {
+		refs = append(refs, receiver.Email.AuthPassword)
+	}
+	if receiver.Webhook != nil {
+		refs = append(refs, &receiver.Webhook.URL)
+	}
+	if receiver.OpsGenie != nil {
+		refs = append(refs, &receiver.OpsGenie.APIKey)
+	}
+
+	return refs
+}
+
+// checkSecretKey returns the problem with the given Secret key, if it is
+// missing from the given namespace.
+func (r *MonitoringReconciler) checkSecretKey(ctx context.Context, namespace string, ref *corev1.SecretKeySelector) (string, error) {
+	if ref.Name == "" || ref.Key == "" {
+		return "a Secret reference has no name or key", nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: namespace,
+	}, secret); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return fmt.Sprintf("Secret %s is missing", ref.Name), nil
+		}
+		return "", fmt.Errorf("unable to get Secret %s in %s: %w", ref.Name, namespace, err)
+	}
+
+	if _, ok := secret.Data[ref.Key]; !ok {
+		return fmt.Sprintf("entry %s is missing from Secret %s", ref.Key, ref.Name), nil
+	}
+
+	return "", nil
+}
+
+// desiredReceivers renders the given declared receivers.
+func desiredReceivers(receivers []addonv1alpha1.ReceiverSpec) []promv1alpha1.Receiver {
+	out := []promv1alpha1.Receiver{}
+
+	for _, receiver := range receivers {
+		rendered := promv1alpha1.Receiver{Name: receiver.Name}
+
+		if pd := receiver.PagerDuty; pd != nil {
+			rendered.PagerDutyConfigs = []promv1alpha1.PagerDutyConfig{{
+				RoutingKey: pd.RoutingKey,
+				ServiceKey: pd.ServiceKey,
+			}}
+		}
+		if slack := receiver.Slack; slack != nil {
+			rendered.SlackConfigs = []promv1alpha1.SlackConfig{{
+				APIURL:  slack.APIURL.DeepCopy(),
+				Channel: slack.Channel,
+			}}
+		}
+		if email := receiver.Email; email != nil {
+			rendered.EmailConfigs = []promv1alpha1.EmailConfig{{
+				To:           email.To,
+				From:         email.From,
+				Smarthost:    email.Smarthost,
+				AuthUsername: email.AuthUsername,
+				AuthPassword: email.AuthPassword,
+			}}
+		}
+		if webhook := receiver.Webhook; webhook != nil {
+			rendered.WebhookConfigs = []promv1alpha1.WebhookConfig{{
+				URLSecret: webhook.URL.DeepCopy(),
+			}}
+		}
+		if opsGenie := receiver.OpsGenie; opsGenie != nil {
+			rendered.OpsGenieConfigs = []promv1alpha1.OpsGenieConfig{{
+				APIKey: opsGenie.APIKey.DeepCopy(),
+				APIURL: opsGenie.APIURL,
+			}}
+		}
+
+		out = append(out, rendered)
+	}
+
+	return out
+}
+
+// desiredRoutes renders the given declared routes.
+func desiredRoutes(routes []addonv1alpha1.RouteSpec) ([]apiextensionsv1.JSON, error) {
+	out := []apiextensionsv1.JSON{}
+
+	for _, route := range routes {
+		rendered := promv1alpha1.Route{
+			Receiver:       route.Receiver,
+			GroupBy:        route.GroupBy,
+			GroupWait:      valueOrDefault(route.GroupWait, defaultGroupWait),
+			GroupInterval:  valueOrDefault(route.GroupInterval, defaultGroupInterval),
+			RepeatInterval: valueOrDefault(route.RepeatInterval, defaultRepeatInterval),
+			Continue:       route.Continue,
+		}
+
+		if len(rendered.GroupBy) == 0 {
+			rendered.GroupBy = []string{"alertname"}
+		}
+
+		if len(route.AlertNames) > 0 {
+			rendered.Matchers = append(rendered.Matchers, promv1alpha1.Matcher{
+				Name:      "alertname",
+				Value:     getRegexMatcher(route.AlertNames),
+				MatchType: promv1alpha1.MatchRegexp,
+			})
+		}
+
+		if len(route.Severities) > 0 {
+			rendered.Matchers = append(rendered.Matchers, promv1alpha1.Matcher{
+				Name:      "severity",
+				Value:     getRegexMatcher(route.Severities),
+				MatchType: promv1alpha1.MatchRegexp,
+			})
+		}
+
+		raw, err := convertToApiExtV1JSON(rendered)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, raw)
+	}
+
+	return out, nil
+}
+
+func isReservedReceiverName(name string) bool {
+	for _, reserved := range reservedReceiverNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
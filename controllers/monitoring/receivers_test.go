@@ -0,0 +1,214 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+
+	promv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("Declared receivers", func() {
+	common.ProcessConfig()
+
+	secretKey := func(name, key string) corev1.SecretKeySelector {
+		return corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+		}
+	}
+
+	newSecret := func(name, key string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+			},
+			Data: map[string][]byte{key: []byte("value")},
+		}
+	}
+
+	newMonitoring := func(spec addonv1alpha1.MonitoringSpec) *addonv1alpha1.Monitoring {
+		return &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "test",
+			},
+			Spec: spec,
+		}
+	}
+
+	reconcile := func(m *addonv1alpha1.Monitoring, objs ...runtime.Object) (*MonitoringReconciler, *promv1alpha1.AlertmanagerConfig) {
+		s := scheme.Scheme
+		Expect(promv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+		objs = append(objs, m,
+			newSecret(common.GlobalConfig.PagerDutySecretName, pagerDutyKey),
+			newSecret(common.GlobalConfig.DeadMansSnitchSecretName, snitchURLKey))
+		r := &MonitoringReconciler{
+			Client: fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build(),
+		}
+
+		Expect(r.reconcileAlertManagerConfig(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		amc := &promv1alpha1.AlertmanagerConfig{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{
+			Name:      alertManagerConfigName,
+			Namespace: m.Namespace,
+		}, amc)).ShouldNot(HaveOccurred())
+
+		return r, amc
+	}
+
+	receiverNames := func(amc *promv1alpha1.AlertmanagerConfig) []string {
+		names := []string{}
+		for _, receiver := range amc.Spec.Receivers {
+			names = append(names, receiver.Name)
+		}
+		return names
+	}
+
+	It("should render the declared receivers and routes", func() {
+		m := newMonitoring(addonv1alpha1.MonitoringSpec{
+			Receivers: []addonv1alpha1.ReceiverSpec{
+				{Name: "slack", Slack: &addonv1alpha1.SlackReceiver{APIURL: secretKey("slack", "url"), Channel: "#gpu"}},
+				{Name: "opsgenie", OpsGenie: &addonv1alpha1.OpsGenieReceiver{APIKey: secretKey("opsgenie", "key")}},
+			},
+			Routes: []addonv1alpha1.RouteSpec{
+				{Receiver: "opsgenie", Severities: []string{"critical"}, RepeatInterval: "1h", Continue: true},
+				{Receiver: "slack", AlertNames: []string{"GPUFellOffTheBus", "GPUXidError"}},
+			},
+		})
+
+		r, amc := reconcile(m, newSecret("slack", "url"), newSecret("opsgenie", "key"))
+
+		Expect(receiverNames(amc)).To(Equal([]string{
+			nullReceiverName, pagerDutyReceiverName, deadMansSnitchReceiverName, "slack", "opsgenie",
+		}))
+		Expect(amc.Spec.Receivers[3].SlackConfigs[0].Channel).To(Equal("#gpu"))
+		Expect(amc.Spec.Receivers[4].OpsGenieConfigs[0].APIKey.Name).To(Equal("opsgenie"))
+
+		routes, err := amc.Spec.Route.ChildRoutes()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(routes).To(HaveLen(4))
+		Expect(routes[0].Receiver).To(Equal(pagerDutyReceiverName))
+		Expect(routes[0].Continue).To(BeTrue())
+
+		Expect(routes[2].Receiver).To(Equal("opsgenie"))
+		Expect(routes[2].RepeatInterval).To(Equal("1h"))
+		Expect(routes[2].GroupWait).To(Equal(defaultGroupWait))
+		Expect(routes[2].Continue).To(BeTrue())
+		Expect(routes[2].Matchers).To(ConsistOf(promv1alpha1.Matcher{
+			Name:      "severity",
+			Value:     "^critical$",
+			MatchType: promv1alpha1.MatchRegexp,
+		}))
+
+		Expect(routes[3].Receiver).To(Equal("slack"))
+		Expect(routes[3].Matchers).To(ConsistOf(promv1alpha1.Matcher{
+			Name:      "alertname",
+			Value:     "^GPUFellOffTheBus$|^GPUXidError$",
+			MatchType: promv1alpha1.MatchRegexp,
+		}))
+
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, m)).ShouldNot(HaveOccurred())
+		Expect(meta.IsStatusConditionTrue(m.Status.Conditions, AlertingConfigValidCondition)).To(BeTrue())
+	})
+
+	It("should report invalid receivers and keep only the managed ones", func() {
+		m := newMonitoring(addonv1alpha1.MonitoringSpec{
+			Receivers: []addonv1alpha1.ReceiverSpec{
+				{Name: "webhook", Webhook: &addonv1alpha1.WebhookReceiver{URL: secretKey("webhook", "url")}},
+				{Name: "pagerduty", PagerDuty: &addonv1alpha1.PagerDutyReceiver{}},
+			},
+			Routes: []addonv1alpha1.RouteSpec{
+				{Receiver: "email"},
+			},
+		})
+
+		r, amc := reconcile(m)
+
+		Expect(receiverNames(amc)).To(Equal([]string{
+			nullReceiverName, pagerDutyReceiverName, deadMansSnitchReceiverName,
+		}))
+
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, m)).ShouldNot(HaveOccurred())
+		c := meta.FindStatusCondition(m.Status.Conditions, AlertingConfigValidCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal("InvalidAlertingConfig"))
+		Expect(c.Message).To(ContainSubstring(`receiver "webhook": Secret webhook is missing`))
+		Expect(c.Message).To(ContainSubstring(`receiver "pagerduty": the name is reserved`))
+		Expect(c.Message).To(ContainSubstring(`receiver "pagerduty": exactly one of routing_key and service_key must be set`))
+		Expect(c.Message).To(ContainSubstring(`route 0: receiver "email" is not declared`))
+	})
+
+	It("should route the configured alerts to PagerDuty", func() {
+		m := newMonitoring(addonv1alpha1.MonitoringSpec{
+			PagerDuty: &addonv1alpha1.PagerDutySpec{
+				Alerts: []string{"NVIDIAGPUAddonFailed", "NVIDIAGPUAddonGPUFallenOffBus"},
+			},
+		})
+
+		_, amc := reconcile(m)
+
+		routes, err := amc.Spec.Route.ChildRoutes()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(routes[0].Receiver).To(Equal(pagerDutyReceiverName))
+		Expect(routes[0].Matchers).To(ConsistOf(promv1alpha1.Matcher{
+			Name:      "alertname",
+			Value:     "^NVIDIAGPUAddonFailed$|^NVIDIAGPUAddonGPUFallenOffBus$",
+			MatchType: promv1alpha1.MatchRegexp,
+		}))
+	})
+
+	Context("validateReceivers", func() {
+		It("should require exactly one integration and unique names", func() {
+			problems := validateReceivers([]addonv1alpha1.ReceiverSpec{
+				{Name: "none"},
+				{
+					Name:    "both",
+					Slack:   &addonv1alpha1.SlackReceiver{APIURL: secretKey("slack", "url")},
+					Webhook: &addonv1alpha1.WebhookReceiver{URL: secretKey("webhook", "url")},
+				},
+				{Name: "email", Email: &addonv1alpha1.EmailReceiver{To: "gpu@example.com"}},
+				{Name: "email", Webhook: &addonv1alpha1.WebhookReceiver{URL: secretKey("webhook", "url")}},
+			})
+
+			Expect(problems).To(ConsistOf(
+				`receiver "none": exactly one integration must be set, found 0`,
+				`receiver "both": exactly one integration must be set, found 2`,
+				`receiver "email": to, from and smarthost must be set`,
+				`receiver "email": the name is not unique`,
+			))
+		})
+	})
+})
@@ -0,0 +1,316 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	RemoteWriteConfigValidCondition = "RemoteWriteConfigValid"
+	RemoteWriteHealthyCondition     = "RemoteWriteHealthy"
+)
+
+const (
+	samplesPendingMetric = "prometheus_remote_storage_samples_pending"
+	samplesFailedMetric  = "prometheus_remote_storage_samples_failed_total"
+	samplesSentMetric    = "prometheus_remote_storage_samples_total"
+
+	// remoteWriteCheckInterval is how often the remote write health is
+	// checked.
+	remoteWriteCheckInterval = 5 * time.Minute
+)
+
+// PrometheusReader reads the metrics a Prometheus exposes about itself.
+type PrometheusReader interface {
+	Metrics(ctx context.Context, address string) (map[string]*dto.MetricFamily, error)
+}
+
+// PrometheusService is the kube-rbac-proxy of the add-on Prometheus in the
+// given namespace.
+func PrometheusService(namespace string) QueryService {
+	return QueryService{Name: PrometheusServiceName, Namespace: namespace, Port: KubeRBACProxyPort}
+}
+
+// PrometheusAddress is the address of the kube-rbac-proxy of the add-on
+// Prometheus in the given namespace.
+func PrometheusAddress(namespace string) string {
+	return PrometheusService(namespace).Address()
+}
+
+// validateRemoteWrite returns the problems of the remote write endpoints
+// declared in the given Monitoring spec, including Secrets which are missing.
+func (r *MonitoringReconciler) validateRemoteWrite(ctx context.Context, m *addonv1alpha1.Monitoring) ([]string, error) {
+	problems := []string{}
+	names := map[string]bool{}
+
+	for _, rw := range m.Spec.RemoteWrite {
+		if names[rw.Name] {
+			problems = append(problems, fmt.Sprintf("remote write %q: the name is not unique", rw.Name))
+		}
+		names[rw.Name] = true
+
+		if rw.BasicAuth != nil && rw.BearerToken != nil {
+			problems = append(problems, fmt.Sprintf("remote write %q: at most one of basic_auth and bearer_token can be set",
+				rw.Name))
+		}
+
+		if tls := rw.TLS; tls != nil && (tls.Cert == nil) != (tls.Key == nil) {
+			problems = append(problems, fmt.Sprintf("remote write %q: cert and key must be set together", rw.Name))
+		}
+
+		for _, ref := range remoteWriteSecretRefs(rw) {
+			problem, err := r.checkSecretKey(ctx, m.Namespace, ref)
+			if err != nil {
+				return nil, err
+			}
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("remote write %q: %s", rw.Name, problem))
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// remoteWriteSecretRefs returns the Secret keys the given remote write
+// endpoint refers to.
+func remoteWriteSecretRefs(rw addonv1alpha1.RemoteWriteSpec) []*corev1.SecretKeySelector {
+	refs := []*corev1.SecretKeySelector{}
+
+	if rw.BasicAuth != nil {
+		refs = append(refs, &rw.BasicAuth.Username, &rw.BasicAuth.Password)
+	}
+	if rw.BearerToken != nil {
+		refs = append(refs, rw.BearerToken)
+	}
+	if tls := rw.TLS; tls != nil {
+		for _, ref := range []*corev1.SecretKeySelector{tls.CA, tls.Cert, tls.Key} {
+			if ref != nil {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	return refs
+}
+
+// desiredRemoteWrite renders the given remote write endpoints.
+func desiredRemoteWrite(specs []addonv1alpha1.RemoteWriteSpec) []promv1.RemoteWriteSpec {
+	out := []promv1.RemoteWriteSpec{}
+
+	for _, rw := range specs {
+		rendered := promv1.RemoteWriteSpec{
+			Name: rw.Name,
+			URL:  rw.URL,
+		}
+
+		if auth := rw.BasicAuth; auth != nil {
+			rendered.BasicAuth = &promv1.BasicAuth{
+				Username: *auth.Username.DeepCopy(),
+				Password: *auth.Password.DeepCopy(),
+			}
+		}
+		if rw.BearerToken != nil {
+			rendered.Authorization = &promv1.Authorization{
+				SafeAuthorization: promv1.SafeAuthorization{
+					Type:        "Bearer",
+					Credentials: rw.BearerToken.DeepCopy(),
+				},
+			}
+		}
+		if tls := rw.TLS; tls != nil {
+			rendered.TLSConfig = &promv1.TLSConfig{
+				SafeTLSConfig: promv1.SafeTLSConfig{
+					KeySecret:          tls.Key.DeepCopy(),
+					ServerName:         tls.ServerName,
+					InsecureSkipVerify: tls.InsecureSkipVerify,
+				},
+			}
+			if tls.CA != nil {
+				rendered.TLSConfig.CA = promv1.SecretOrConfigMap{Secret: tls.CA.DeepCopy()}
+			}
+			if tls.Cert != nil {
+				rendered.TLSConfig.Cert = promv1.SecretOrConfigMap{Secret: tls.Cert.DeepCopy()}
+			}
+		}
+
+		for _, relabel := range rw.WriteRelabelConfigs {
+			rendered.WriteRelabelConfigs = append(rendered.WriteRelabelConfigs, promv1.RelabelConfig{
+				SourceLabels: toLabelNames(relabel.SourceLabels),
+				Regex:        relabel.Regex,
+				Action:       relabel.Action,
+				TargetLabel:  relabel.TargetLabel,
+				Replacement:  relabel.Replacement,
+			})
+		}
+
+		out = append(out, rendered)
+	}
+
+	return out
+}
+
+func toLabelNames(labels []string) []promv1.LabelName {
+	names := []promv1.LabelName{}
+	for _, label := range labels {
+		names = append(names, promv1.LabelName(label))
+	}
+	return names
+}
+
+// reconcileRemoteWriteHealth reports the health of the remote write endpoints
+// as exposed by the add-on Prometheus.
+func (r *MonitoringReconciler) reconcileRemoteWriteHealth(ctx context.Context, m *addonv1alpha1.Monitoring) error {
+	logger := log.FromContext(ctx, "Reconcile Step", "Remote write health")
+
+	patch := client.MergeFrom(m.DeepCopy())
+
+	switch {
+	case len(m.Spec.RemoteWrite) == 0:
+		m.Status.RemoteWrite = nil
+		meta.RemoveStatusCondition(&m.Status.Conditions, RemoteWriteHealthyCondition)
+	case r.Prometheus == nil:
+		// The health cannot be checked, e.g. outside of the cluster.
+		return nil
+	default:
+		families, err := r.Prometheus.Metrics(ctx, PrometheusAddress(m.Namespace))
+		if err != nil {
+			logger.Info("Unable to check the remote write health", "error", err.Error())
+			meta.SetStatusCondition(&m.Status.Conditions, common.NewCondition(
+				RemoteWriteHealthyCondition,
+				metav1.ConditionUnknown,
+				"CheckFailed",
+				err.Error()))
+			break
+		}
+
+		statuses, condition := remoteWriteHealth(families, m.Spec.RemoteWrite, m.Status.RemoteWrite, metav1.Now())
+		m.Status.RemoteWrite = statuses
+		meta.SetStatusCondition(&m.Status.Conditions, condition)
+	}
+
+	if err := r.Status().Patch(ctx, m, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
+
+// remoteWriteHealth returns the health of the given remote write endpoints
+// from the given Prometheus metrics. An endpoint is unhealthy when it failed
+// to write samples since the previous check.
+func remoteWriteHealth(
+	families map[string]*dto.MetricFamily,
+	specs []addonv1alpha1.RemoteWriteSpec,
+	previous []addonv1alpha1.RemoteWriteStatus,
+	now metav1.Time) ([]addonv1alpha1.RemoteWriteStatus, metav1.Condition) {
+
+	statuses := []addonv1alpha1.RemoteWriteStatus{}
+	failing := []string{}
+	missing := []string{}
+
+	for _, rw := range specs {
+		pending, found := remoteWriteValue(families[samplesPendingMetric], rw.Name)
+		if !found {
+			// Prometheus has not loaded the endpoint yet.
+			missing = append(missing, rw.Name)
+			continue
+		}
+		failed, _ := remoteWriteValue(families[samplesFailedMetric], rw.Name)
+		sent, _ := remoteWriteValue(families[samplesSentMetric], rw.Name)
+
+		status := addonv1alpha1.RemoteWriteStatus{
+			Name:           rw.Name,
+			SamplesPending: pending,
+			SamplesFailed:  failed,
+			SamplesSent:    sent,
+			LastCheckTime:  now,
+		}
+
+		for _, p := range previous {
+			// The counters are reset when Prometheus restarts.
+			if p.Name == rw.Name && status.SamplesFailed > p.SamplesFailed {
+				failing = append(failing, rw.Name)
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	if len(failing) > 0 {
+		return statuses, common.NewCondition(
+			RemoteWriteHealthyCondition,
+			metav1.ConditionFalse,
+			"SamplesFailing",
+			fmt.Sprintf("Samples failed to be written to %s", strings.Join(failing, ", ")))
+	}
+
+	if len(missing) > 0 {
+		return statuses, common.NewCondition(
+			RemoteWriteHealthyCondition,
+			metav1.ConditionUnknown,
+			"NotReported",
+			fmt.Sprintf("Prometheus does not report %s yet", strings.Join(missing, ", ")))
+	}
+
+	return statuses, common.NewCondition(
+		RemoteWriteHealthyCondition,
+		metav1.ConditionTrue,
+		"SamplesWritten",
+		"The samples are written to all remote write endpoints")
+}
+
+// remoteWriteValue returns the value of the given metric family for the given
+// remote write endpoint.
+func remoteWriteValue(family *dto.MetricFamily, name string) (int64, bool) {
+	if family == nil {
+		return 0, false
+	}
+
+	for _, metric := range family.GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() != "remote_name" || label.GetValue() != name {
+				continue
+			}
+			switch {
+			case metric.Gauge != nil:
+				return int64(metric.GetGauge().GetValue()), true
+			case metric.Counter != nil:
+				return int64(metric.GetCounter().GetValue()), true
+			case metric.Untyped != nil:
+				return int64(metric.GetUntyped().GetValue()), true
+			}
+		}
+	}
+
+	return 0, false
+}
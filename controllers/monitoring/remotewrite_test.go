@@ -0,0 +1,222 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"strings"
+
+	promv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+type fakePrometheusReader struct {
+	metrics string
+}
+
+func (f *fakePrometheusReader) Metrics(context.Context, string) (map[string]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(strings.NewReader(f.metrics))
+}
+
+var _ = Describe("Remote write", func() {
+	common.ProcessConfig()
+
+	secretKey := func(name, key string) corev1.SecretKeySelector {
+		return corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+		}
+	}
+
+	central := addonv1alpha1.RemoteWriteSpec{
+		Name: "central",
+		URL:  "https://central.example.com/api/v1/write",
+		BasicAuth: &addonv1alpha1.RemoteWriteBasicAuth{
+			Username: secretKey("central", "username"),
+			Password: secretKey("central", "password"),
+		},
+		TLS: &addonv1alpha1.RemoteWriteTLS{
+			CA: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "central"},
+				Key:                  "ca.crt",
+			},
+		},
+		WriteRelabelConfigs: []addonv1alpha1.RelabelConfig{{
+			SourceLabels: []string{"__name__"},
+			Regex:        "DCGM_FI_PROF_.*",
+			Action:       "drop",
+		}},
+	}
+
+	newMonitoring := func(remoteWrite ...addonv1alpha1.RemoteWriteSpec) *addonv1alpha1.Monitoring {
+		return &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "test",
+			},
+			Spec: addonv1alpha1.MonitoringSpec{
+				RemoteWrite: remoteWrite,
+			},
+		}
+	}
+
+	centralSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "central",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"username": []byte("gpu"),
+			"password": []byte("secret"),
+			"ca.crt":   []byte("ca"),
+		},
+	}
+
+	newReconciler := func(m *addonv1alpha1.Monitoring, metrics string) *MonitoringReconciler {
+		s := scheme.Scheme
+		Expect(promv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+		Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+		return &MonitoringReconciler{
+			Client:     fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(m, centralSecret).Build(),
+			Prometheus: &fakePrometheusReader{metrics: metrics},
+		}
+	}
+
+	It("should configure the remote write endpoints of the Prometheus CR", func() {
+		m := newMonitoring(central)
+		r := newReconciler(m, "")
+
+		Expect(r.reconcilePrometheus(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		p := &promv1.Prometheus{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: prometheusName, Namespace: m.Namespace}, p)).ShouldNot(HaveOccurred())
+		Expect(p.Spec.RemoteWrite).To(HaveLen(1))
+
+		rw := p.Spec.RemoteWrite[0]
+		Expect(rw.Name).To(Equal("central"))
+		Expect(rw.BasicAuth.Password.Key).To(Equal("password"))
+		Expect(rw.TLSConfig.CA.Secret.Key).To(Equal("ca.crt"))
+		Expect(rw.WriteRelabelConfigs).To(ConsistOf(promv1.RelabelConfig{
+			SourceLabels: []promv1.LabelName{"__name__"},
+			Regex:        "DCGM_FI_PROF_.*",
+			Action:       "drop",
+		}))
+
+		Expect(meta.IsStatusConditionTrue(m.Status.Conditions, RemoteWriteConfigValidCondition)).To(BeTrue())
+	})
+
+	It("should ignore invalid remote write endpoints", func() {
+		invalid := addonv1alpha1.RemoteWriteSpec{
+			Name:        "invalid",
+			URL:         "https://invalid.example.com/api/v1/write",
+			BasicAuth:   central.BasicAuth,
+			BearerToken: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token"},
+			TLS:         &addonv1alpha1.RemoteWriteTLS{Key: &corev1.SecretKeySelector{}},
+		}
+		m := newMonitoring(central, invalid)
+		r := newReconciler(m, "")
+
+		Expect(r.reconcilePrometheus(context.TODO(), m)).ShouldNot(HaveOccurred())
+
+		p := &promv1.Prometheus{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: prometheusName, Namespace: m.Namespace}, p)).ShouldNot(HaveOccurred())
+		Expect(p.Spec.RemoteWrite).To(BeEmpty())
+
+		c := meta.FindStatusCondition(m.Status.Conditions, RemoteWriteConfigValidCondition)
+		Expect(c).ToNot(BeNil())
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Message).To(ContainSubstring(`remote write "invalid": at most one of basic_auth and bearer_token can be set`))
+		Expect(c.Message).To(ContainSubstring(`remote write "invalid": cert and key must be set together`))
+		Expect(c.Message).To(ContainSubstring(`remote write "invalid": Secret token is missing`))
+	})
+
+	Context("Health", func() {
+		metrics := `# TYPE prometheus_remote_storage_samples_pending gauge
+prometheus_remote_storage_samples_pending{remote_name="central",url="https://central.example.com/api/v1/write"} 120
+# TYPE prometheus_remote_storage_samples_failed_total counter
+prometheus_remote_storage_samples_failed_total{remote_name="central",url="https://central.example.com/api/v1/write"} 7
+# TYPE prometheus_remote_storage_samples_total counter
+prometheus_remote_storage_samples_total{remote_name="central",url="https://central.example.com/api/v1/write"} 5000
+`
+
+		It("should report the samples of each endpoint", func() {
+			m := newMonitoring(central)
+			r := newReconciler(m, metrics)
+
+			Expect(r.reconcileRemoteWriteHealth(context.TODO(), m)).ShouldNot(HaveOccurred())
+			Expect(r.Get(context.TODO(), types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, m)).ShouldNot(HaveOccurred())
+
+			Expect(m.Status.RemoteWrite).To(HaveLen(1))
+			Expect(m.Status.RemoteWrite[0].Name).To(Equal("central"))
+			Expect(m.Status.RemoteWrite[0].SamplesPending).To(Equal(int64(120)))
+			Expect(m.Status.RemoteWrite[0].SamplesFailed).To(Equal(int64(7)))
+			Expect(m.Status.RemoteWrite[0].SamplesSent).To(Equal(int64(5000)))
+			Expect(meta.IsStatusConditionTrue(m.Status.Conditions, RemoteWriteHealthyCondition)).To(BeTrue())
+		})
+
+		It("should report the endpoints failing since the previous check", func() {
+			families, err := (&fakePrometheusReader{metrics: metrics}).Metrics(context.TODO(), "")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			previous := []addonv1alpha1.RemoteWriteStatus{{Name: "central", SamplesFailed: 3}}
+			statuses, c := remoteWriteHealth(families, []addonv1alpha1.RemoteWriteSpec{central}, previous, metav1.Now())
+
+			Expect(statuses).To(HaveLen(1))
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal("SamplesFailing"))
+			Expect(c.Message).To(Equal("Samples failed to be written to central"))
+		})
+
+		It("should report the endpoints Prometheus has not loaded yet", func() {
+			statuses, c := remoteWriteHealth(map[string]*dto.MetricFamily{}, []addonv1alpha1.RemoteWriteSpec{central}, nil, metav1.Now())
+
+			Expect(statuses).To(BeEmpty())
+			Expect(c.Status).To(Equal(metav1.ConditionUnknown))
+			Expect(c.Reason).To(Equal("NotReported"))
+		})
+
+		It("should clear the health without remote write endpoints", func() {
+			m := newMonitoring()
+			m.Status.RemoteWrite = []addonv1alpha1.RemoteWriteStatus{{Name: "central"}}
+			m.Status.Conditions = []metav1.Condition{
+				common.NewCondition(RemoteWriteHealthyCondition, metav1.ConditionTrue, "SamplesWritten", ""),
+			}
+			r := newReconciler(m, metrics)
+
+			Expect(r.reconcileRemoteWriteHealth(context.TODO(), m)).ShouldNot(HaveOccurred())
+			Expect(r.Get(context.TODO(), types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, m)).ShouldNot(HaveOccurred())
+
+			Expect(m.Status.RemoteWrite).To(BeEmpty())
+			Expect(meta.FindStatusCondition(m.Status.Conditions, RemoteWriteHealthyCondition)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,129 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const PrometheusSizedCondition = "PrometheusSized"
+
+var (
+	// The base resources of Prometheus, scraping the operator and the GPU
+	// operator components.
+	baseCPU    = resource.MustParse("250m")
+	baseMemory = resource.MustParse("512Mi")
+
+	// The resources added by each GPU node, scraping its DCGM exporter and
+	// GPU operator validators with the default scrape interval.
+	perGPUNodeCPU    = resource.MustParse("25m")
+	perGPUNodeMemory = resource.MustParse("64Mi")
+)
+
+// countGPUNodes returns the number of nodes with an NVIDIA GPU.
+func (r *MonitoringReconciler) countGPUNodes(ctx context.Context) (int32, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{common.GPUNodeLabel: "true"}); err != nil {
+		return 0, fmt.Errorf("failed to list the GPU nodes: %w", err)
+	}
+	return int32(len(nodes.Items)), nil
+}
+
+// recommendedResources returns the recommended compute resources of the
+// Prometheus container scraping the given number of GPU nodes.
+func recommendedResources(gpuNodes int32) corev1.ResourceRequirements {
+	cpu := baseCPU.DeepCopy()
+	memory := baseMemory.DeepCopy()
+
+	for i := int32(0); i < gpuNodes; i++ {
+		cpu.Add(perGPUNodeCPU)
+		memory.Add(perGPUNodeMemory)
+	}
+
+	return corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: memory,
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    cpu,
+			corev1.ResourceMemory: memory.DeepCopy(),
+		},
+	}
+}
+
+// prometheusResources returns the compute resources of the Prometheus
+// container, which default to the given recommendation.
+func prometheusResources(m *addonv1alpha1.Monitoring, recommended corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if m.Spec.Prometheus != nil && m.Spec.Prometheus.Resources != nil {
+		return *m.Spec.Prometheus.Resources.DeepCopy()
+	}
+	return recommended
+}
+
+// sizingCondition reports whether the memory of the Prometheus container meets
+// the given recommendation.
+func sizingCondition(resources, recommended corev1.ResourceRequirements, gpuNodes int32) metav1.Condition {
+	want := recommended.Requests[corev1.ResourceMemory]
+
+	got, ok := resources.Requests[corev1.ResourceMemory]
+	if !ok {
+		got, ok = resources.Limits[corev1.ResourceMemory]
+	}
+	if ok && got.Cmp(want) < 0 {
+		return common.NewCondition(
+			PrometheusSizedCondition,
+			metav1.ConditionFalse,
+			"BelowRecommendation",
+			fmt.Sprintf("Prometheus has %s of memory, %s is recommended for %d GPU nodes",
+				got.String(), want.String(), gpuNodes))
+	}
+
+	return common.NewCondition(
+		PrometheusSizedCondition,
+		metav1.ConditionTrue,
+		"MeetsRecommendation",
+		fmt.Sprintf("Prometheus is sized for %d GPU nodes", gpuNodes))
+}
+
+// patchSizingStatus reports the recommended sizing of Prometheus and whether
+// it is met.
+func (r *MonitoringReconciler) patchSizingStatus(
+	ctx context.Context,
+	m *addonv1alpha1.Monitoring,
+	sizing *addonv1alpha1.PrometheusSizingStatus,
+	condition metav1.Condition) error {
+
+	patch := client.MergeFrom(m.DeepCopy())
+	m.Status.PrometheusSizing = sizing
+	meta.SetStatusCondition(&m.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, m, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
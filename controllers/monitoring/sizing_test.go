@@ -0,0 +1,67 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("Prometheus sizing", func() {
+	It("should grow the recommendation with the GPU nodes", func() {
+		resources := recommendedResources(8)
+
+		Expect(resources.Requests.Cpu().String()).To(Equal("450m"))
+		Expect(resources.Requests.Memory().String()).To(Equal("1Gi"))
+		Expect(resources.Limits.Memory().String()).To(Equal("1Gi"))
+	})
+
+	It("should default to the recommendation", func() {
+		m := &addonv1alpha1.Monitoring{}
+		recommended := recommendedResources(2)
+
+		Expect(prometheusResources(m, recommended)).To(Equal(recommended))
+	})
+
+	It("should report the configured memory below the recommendation", func() {
+		configured := corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		}
+		m := &addonv1alpha1.Monitoring{
+			Spec: addonv1alpha1.MonitoringSpec{
+				Prometheus: &addonv1alpha1.PrometheusSpec{Resources: &configured},
+			},
+		}
+		recommended := recommendedResources(4)
+
+		resources := prometheusResources(m, recommended)
+		Expect(resources).To(Equal(configured))
+
+		c := sizingCondition(resources, recommended, 4)
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal("BelowRecommendation"))
+		Expect(c.Message).To(Equal("Prometheus has 256Mi of memory, 768Mi is recommended for 4 GPU nodes"))
+	})
+})
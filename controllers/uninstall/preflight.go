@@ -0,0 +1,325 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uninstall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	podKind                    = "Pod"
+	virtualMachineInstanceKind = "VirtualMachineInstance"
+
+	// maxStatusWorkloads is the maximum number of GPU workloads listed in
+	// the GPUAddon status.
+	maxStatusWorkloads = 50
+
+	reportKey = "report.json"
+)
+
+var virtualMachineInstanceListGVK = schema.GroupVersionKind{
+	Group:   "kubevirt.io",
+	Version: "v1",
+	Kind:    "VirtualMachineInstanceList",
+}
+
+// PodEvicter evicts pods through the eviction API, which respects their
+// PodDisruptionBudgets.
+type PodEvicter interface {
+	Evict(ctx context.Context, pod *corev1.Pod) error
+}
+
+type clientsetPodEvicter struct {
+	clientset kubernetes.Interface
+}
+
+// NewPodEvicter returns a PodEvicter using the given clientset.
+func NewPodEvicter(clientset kubernetes.Interface) PodEvicter {
+	return &clientsetPodEvicter{clientset: clientset}
+}
+
+func (e *clientsetPodEvicter) Evict(ctx context.Context, pod *corev1.Pod) error {
+	return e.clientset.CoreV1().Pods(pod.Namespace).EvictV1(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	})
+}
+
+// UninstallModeAnnotation is the GPUAddon annotation which selects the
+// uninstall mode.
+func UninstallModeAnnotation() string {
+	return fmt.Sprintf("%s/uninstall-mode", common.GlobalConfig.AddonID)
+}
+
+// ReportConfigMapName returns the name of the ConfigMap which lists all the
+// workloads still using GPUs during the uninstallation.
+func ReportConfigMapName() string {
+	return fmt.Sprintf("%s-uninstall-report", common.GlobalConfig.AddonID)
+}
+
+// uninstallMode returns the uninstall mode selected by the annotation of the
+// given GPUAddon. An unknown mode falls back to the default one and is
+// returned as an error.
+func uninstallMode(g *addonv1alpha1.GPUAddon) (addonv1alpha1.UninstallMode, error) {
+	v, ok := g.Annotations[UninstallModeAnnotation()]
+	if !ok {
+		return addonv1alpha1.UninstallModeWait, nil
+	}
+
+	switch mode := addonv1alpha1.UninstallMode(v); mode {
+	case addonv1alpha1.UninstallModeWait,
+		addonv1alpha1.UninstallModeBlock,
+		addonv1alpha1.UninstallModeDrain,
+		addonv1alpha1.UninstallModeForce:
+		return mode, nil
+	}
+
+	return addonv1alpha1.UninstallModeWait, fmt.Errorf(
+		"unknown uninstall mode %q in annotation %s, falling back to %q",
+		v, UninstallModeAnnotation(), addonv1alpha1.UninstallModeWait)
+}
+
+// listGPUWorkloads returns the pods and the virtual machines which use GPUs
+// and have not terminated, along with the pods themselves.
+func (r *UninstallReconciler) listGPUWorkloads(ctx context.Context) ([]corev1.Pod, []addonv1alpha1.GPUWorkload, error) {
+	pods, err := r.listGPUPods(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workloads := []addonv1alpha1.GPUWorkload{}
+	for _, pod := range pods {
+		workloads = append(workloads, addonv1alpha1.GPUWorkload{
+			Kind:      podKind,
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			NodeName:  pod.Spec.NodeName,
+		})
+	}
+
+	vms, err := r.listGPUVirtualMachines(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pods, append(workloads, vms...), nil
+}
+
+// listGPUPods returns the pods which use GPUs and have not terminated. The
+// pods of the add-on namespace, i.e. the GPU operator validators, are
+// ignored.
+func (r *UninstallReconciler) listGPUPods(ctx context.Context) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	gpuPods := []corev1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Namespace == common.GlobalConfig.AddonNamespace {
+			continue
+		}
+
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		if common.PodUsesGPUs(&pod) {
+			gpuPods = append(gpuPods, pod)
+		}
+	}
+
+	return gpuPods, nil
+}
+
+// listGPUVirtualMachines returns the KubeVirt virtual machine instances which
+// have GPUs or NVIDIA host devices assigned and have not terminated. There
+// are none if KubeVirt is not installed.
+func (r *UninstallReconciler) listGPUVirtualMachines(ctx context.Context) ([]addonv1alpha1.GPUWorkload, error) {
+	vmis := &unstructured.UnstructuredList{}
+	vmis.SetGroupVersionKind(virtualMachineInstanceListGVK)
+
+	if err := r.APIReader.List(ctx, vmis); err != nil {
+		if meta.IsNoMatchError(err) || k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list VirtualMachineInstances: %w", err)
+	}
+
+	workloads := []addonv1alpha1.GPUWorkload{}
+	for _, vmi := range vmis.Items {
+		phase, _, _ := unstructured.NestedString(vmi.Object, "status", "phase")
+		if phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+
+		if !vmiUsesGPUs(&vmi) {
+			continue
+		}
+
+		nodeName, _, _ := unstructured.NestedString(vmi.Object, "status", "nodeName")
+		workloads = append(workloads, addonv1alpha1.GPUWorkload{
+			Kind:      virtualMachineInstanceKind,
+			Namespace: vmi.GetNamespace(),
+			Name:      vmi.GetName(),
+			NodeName:  nodeName,
+		})
+	}
+
+	return workloads, nil
+}
+
+// reconcileReport publishes all the given GPU workloads in the uninstall
+// report ConfigMap.
+func (r *UninstallReconciler) reconcileReport(ctx context.Context, workloads []addonv1alpha1.GPUWorkload) error {
+	logger := log.FromContext(ctx, "Reconcile Step", "Uninstall Report")
+
+	report, err := json.MarshalIndent(struct {
+		Time      string                      `json:"time"`
+		Workloads []addonv1alpha1.GPUWorkload `json:"workloads"`
+	}{
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		Workloads: workloads,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the uninstall report: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ReportConfigMapName(),
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, cm, func() error {
+		cm.Data = map[string]string{
+			reportKey: string(report),
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile the uninstall report ConfigMap %s: %w", cm.Name, err)
+	}
+
+	logger.Info("Uninstall report reconciled successfully",
+		"name", cm.Name,
+		"workloads", len(workloads),
+		"result", res)
+
+	return nil
+}
+
+// evictPods evicts the given pods unless they are already terminating. An
+// eviction refused, e.g. by a PodDisruptionBudget, is retried on the next
+// reconciliation.
+func (r *UninstallReconciler) evictPods(ctx context.Context, pods []corev1.Pod) {
+	logger := log.FromContext(ctx, "Reconcile Step", "Drain GPU pods")
+
+	for i := range pods {
+		pod := &pods[i]
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if err := r.Evicter.Evict(ctx, pod); err != nil && !k8serrors.IsNotFound(err) {
+			logger.Info("Failed to evict GPU pod", "pod", client.ObjectKeyFromObject(pod).String(), "error", err.Error())
+			continue
+		}
+
+		logger.Info("Evicted GPU pod", "pod", client.ObjectKeyFromObject(pod).String())
+	}
+}
+
+// vmiUsesGPUs returns whether the given virtual machine instance has GPUs or
+// NVIDIA host devices assigned.
+func vmiUsesGPUs(vmi *unstructured.Unstructured) bool {
+	gpus, _, _ := unstructured.NestedSlice(vmi.Object, "spec", "domain", "devices", "gpus")
+	if len(gpus) > 0 {
+		return true
+	}
+
+	hostDevices, _, _ := unstructured.NestedSlice(vmi.Object, "spec", "domain", "devices", "hostDevices")
+	for _, d := range hostDevices {
+		device, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := device["deviceName"].(string); strings.HasPrefix(name, "nvidia.com/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeWorkloads returns a summary of the given GPU workloads for
+// conditions and events.
+func describeWorkloads(workloads []addonv1alpha1.GPUWorkload) string {
+	pods, vms := countWorkloads(workloads)
+
+	names := []string{}
+	for i, w := range workloads {
+		if i == maxReportedWorkloads {
+			names = append(names, fmt.Sprintf("and %d more", len(workloads)-maxReportedWorkloads))
+			break
+		}
+		names = append(names, fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name))
+	}
+
+	return fmt.Sprintf("%d pods and %d virtual machines (%s)", pods, vms, strings.Join(names, ", "))
+}
+
+func countWorkloads(workloads []addonv1alpha1.GPUWorkload) (int32, int32) {
+	var pods, vms int32
+	for _, w := range workloads {
+		if w.Kind == podKind {
+			pods++
+		} else {
+			vms++
+		}
+	}
+	return pods, vms
+}
+
+func truncateWorkloads(workloads []addonv1alpha1.GPUWorkload) []addonv1alpha1.GPUWorkload {
+	if len(workloads) > maxStatusWorkloads {
+		return workloads[:maxStatusWorkloads]
+	}
+	return workloads
+}
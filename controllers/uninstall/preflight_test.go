@@ -0,0 +1,237 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uninstall
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("Uninstall preflight", func() {
+	common.ProcessConfig()
+
+	configmap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	key := types.NamespacedName{
+		Name:      "test",
+		Namespace: common.GlobalConfig.AddonNamespace,
+	}
+
+	newGPUAddon := func(mode string) *addonv1alpha1.GPUAddon {
+		g := &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       key.Name,
+				Namespace:  key.Namespace,
+				Finalizers: []string{common.GlobalConfig.AddonID},
+			},
+		}
+		if mode != "" {
+			g.Annotations = map[string]string{UninstallModeAnnotation(): mode}
+		}
+		return g
+	}
+
+	events := func(r *UninstallReconciler) []string {
+		recorder := r.Recorder.(*record.FakeRecorder)
+		close(recorder.Events)
+
+		events := []string{}
+		for e := range recorder.Events {
+			events = append(events, e)
+		}
+		return events
+	}
+
+	training := newPod("training", "workloads", corev1.PodRunning, common.GPUResourceName)
+
+	Context("report", func() {
+		It("should report the GPU pods and virtual machines", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon(""), training,
+				newVMI("gpu-vm", "vms", "gpus", "nvidia.com/GA100_A100_PCIE_40GB"),
+				newVMI("passthrough-vm", "vms", "hostDevices", "nvidia.com/GV100GL_Tesla_V100"),
+				newVMI("cpu-vm", "vms", "", ""))
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Status.Uninstall.Mode).To(Equal(addonv1alpha1.UninstallModeWait))
+			Expect(g.Status.Uninstall.GPUPods).To(Equal(int32(1)))
+			Expect(g.Status.Uninstall.GPUVirtualMachines).To(Equal(int32(2)))
+			Expect(g.Status.Uninstall.GPUWorkloads).To(ConsistOf(
+				addonv1alpha1.GPUWorkload{Kind: "Pod", Namespace: "workloads", Name: "training"},
+				addonv1alpha1.GPUWorkload{Kind: "VirtualMachineInstance", Namespace: "vms", Name: "gpu-vm"},
+				addonv1alpha1.GPUWorkload{Kind: "VirtualMachineInstance", Namespace: "vms", Name: "passthrough-vm"},
+			))
+
+			cm := &corev1.ConfigMap{}
+			Expect(r.Get(context.TODO(), types.NamespacedName{
+				Name:      ReportConfigMapName(),
+				Namespace: common.GlobalConfig.AddonNamespace,
+			}, cm)).ShouldNot(HaveOccurred())
+
+			report := struct {
+				Workloads []addonv1alpha1.GPUWorkload `json:"workloads"`
+			}{}
+			Expect(json.Unmarshal([]byte(cm.Data[reportKey]), &report)).To(Succeed())
+			Expect(report.Workloads).To(HaveLen(3))
+
+			Expect(events(r)).To(ConsistOf(ContainSubstring("GPUWorkloadsRunning")))
+		})
+
+		It("should not emit an event when the GPU workloads are unchanged", func() {
+			g := newGPUAddon("")
+			g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+				StartTime: metav1.Now(),
+				GPUPods:   1,
+			}
+			r := newTestUninstallReconciler(configmap, g, training)
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(events(r)).To(BeEmpty())
+		})
+	})
+
+	Context("modes", func() {
+		It("should block the uninstallation regardless of the grace period", func() {
+			g := newGPUAddon(string(addonv1alpha1.UninstallModeBlock))
+			g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+				StartTime: metav1.NewTime(time.Now().Add(-20 * time.Minute)),
+			}
+			r := newTestUninstallReconciler(configmap, g, training)
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(gpuWorkloadsRequeueInterval))
+
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.DeletionTimestamp).To(BeNil())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal("BlockedByGPUWorkloads"))
+		})
+
+		It("should stop blocking the uninstallation after the force deadline", func() {
+			g := newGPUAddon(string(addonv1alpha1.UninstallModeBlock))
+			g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+				StartTime: metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+			}
+			r := newTestUninstallReconciler(configmap, g, training)
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.DeletionTimestamp).ToNot(BeNil())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionTrue))
+			Expect(c.Reason).To(Equal("BlockDeadlineExpired"))
+		})
+
+		It("should evict the GPU pods and wait for them when draining", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon(string(addonv1alpha1.UninstallModeDrain)), training)
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(gpuWorkloadsRequeueInterval))
+
+			Expect(r.Evicter.(*fakePodEvicter).evicted).To(Equal([]string{"workloads/training"}))
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.DeletionTimestamp).To(BeNil())
+			Expect(g.Status.Uninstall.Mode).To(Equal(addonv1alpha1.UninstallModeDrain))
+		})
+
+		It("should uninstall right away when forced", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon(string(addonv1alpha1.UninstallModeForce)), training)
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.DeletionTimestamp).ToNot(BeNil())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Reason).To(Equal("Forced"))
+
+			Expect(r.Evicter.(*fakePodEvicter).evicted).To(BeEmpty())
+		})
+
+		It("should fall back to waiting on an unknown mode", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon("now"))
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := &addonv1alpha1.GPUAddon{}
+			Expect(r.Get(context.TODO(), key, g)).ShouldNot(HaveOccurred())
+			Expect(g.Status.Uninstall.Mode).To(Equal(addonv1alpha1.UninstallModeWait))
+
+			Expect(events(r)).To(ConsistOf(ContainSubstring("InvalidUninstallMode")))
+		})
+	})
+})
+
+func newVMI(name, namespace, devices, deviceName string) *unstructured.Unstructured {
+	vmi := &unstructured.Unstructured{}
+	vmi.SetGroupVersionKind(virtualMachineInstanceListGVK.GroupVersion().WithKind(virtualMachineInstanceKind))
+	vmi.SetName(name)
+	vmi.SetNamespace(namespace)
+
+	if devices != "" {
+		Expect(unstructured.SetNestedSlice(vmi.Object, []interface{}{
+			map[string]interface{}{
+				"name":       "gpu1",
+				"deviceName": deviceName,
+			},
+		}, "spec", "domain", "devices", devices)).To(Succeed())
+	}
+	Expect(unstructured.SetNestedField(vmi.Object, "Running", "status", "phase")).To(Succeed())
+
+	return vmi
+}
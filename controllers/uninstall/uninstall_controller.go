@@ -0,0 +1,404 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uninstall
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+const (
+	UninstallReadyCondition = "UninstallReady"
+	// UninstallDetachedCondition reports that the GPU stack is uninstalled
+	// although detaching it was requested.
+	UninstallDetachedCondition = "UninstallDetached"
+
+	// maxReportedWorkloads is the maximum number of GPU workloads named in
+	// the UninstallReady condition message and in events.
+	maxReportedWorkloads = 5
+
+	// gpuWorkloadsRequeueInterval is how often the GPU workloads are
+	// checked while waiting for them to complete.
+	gpuWorkloadsRequeueInterval = 30 * time.Second
+)
+
+// UninstallReconciler uninstalls the add-on once the add-on ConfigMap, which
+// signals the uninstallation, is created. It first reports the workloads
+// still using GPUs and handles them according to the uninstall mode, e.g. by
+// waiting for them to complete for up to the configured grace period. It then
+// deletes the GPUAddon CR, whose finalizer removes the add-on components.
+type UninstallReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// APIReader reads the GPU workloads of all namespaces, which are not
+	// available in the namespaced cache of the manager.
+	APIReader client.Reader
+	// Evicter evicts the GPU pods in the drain uninstall mode.
+	Evicter PodEvicter
+}
+
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+//+kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+//+kubebuilder:rbac:groups="",namespace=system,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstances,verbs=get;list
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;delete;deletecollection
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuquotas,verbs=get;list;delete;deletecollection
+
+func (r *UninstallReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	requested, err := common.IsUninstallRequested(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	gpuAddons := addonv1alpha1.GPUAddonList{}
+	if err := r.List(ctx, &gpuAddons, client.InNamespace(common.GlobalConfig.AddonNamespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list GPUAddon CRs: %w", err)
+	}
+
+	if !requested {
+		return ctrl.Result{}, r.cancelUninstall(ctx, gpuAddons.Items)
+	}
+
+	if len(gpuAddons.Items) > 1 {
+		logger.Info(fmt.Sprintf("In namespace %s there are multiple (%v) GPUAddon CRs.",
+			common.GlobalConfig.AddonNamespace, len(gpuAddons.Items)))
+	}
+
+	result := ctrl.Result{}
+	for i := range gpuAddons.Items {
+		g := &gpuAddons.Items[i]
+
+		// The GPUAddon finalizer takes over once the CR is deleted.
+		if !g.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		requeueAfter, err := r.uninstall(ctx, g)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if requeueAfter > 0 && (result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = requeueAfter
+		}
+	}
+
+	return result, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UninstallReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("uninstall").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(configMapFilter())).
+		Watches(&source.Kind{Type: &addonv1alpha1.GPUAddon{}},
+			handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+				return []reconcile.Request{uninstallRequest()}
+			})).
+		Complete(health.Reconciles.Track("uninstall", r))
+}
+
+// uninstall marks the given GPUAddon as uninstalling, reports the workloads
+// still using GPUs and deletes the GPUAddon once the uninstall mode allows
+// it. In the detach mode, the GPU workloads are left running and the GPUAddon
+// is deleted right away. It returns when to check the GPU workloads again if it is still waiting
+// for them.
+func (r *UninstallReconciler) uninstall(ctx context.Context, g *addonv1alpha1.GPUAddon) (time.Duration, error) {
+	logger := log.FromContext(ctx).WithValues("Reconcile Step", "Uninstall", "name", g.Name)
+
+	patch := client.MergeFrom(g.DeepCopy())
+
+	if g.Status.Uninstall == nil {
+		logger.Info("Starting the add-on uninstallation")
+		g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+			StartTime: metav1.Now(),
+		}
+
+		if err := g.Spec.ValidateUninstall(); err != nil {
+			r.Recorder.Event(g, corev1.EventTypeWarning, "InvalidUninstallSpec", err.Error())
+		}
+	}
+	status := g.Status.Uninstall
+	g.Status.Phase = addonv1alpha1.GPUAddonPhaseUninstalling
+
+	mode, err := uninstallMode(g)
+	if err != nil && status.Mode != mode {
+		r.Recorder.Event(g, corev1.EventTypeWarning, "InvalidUninstallMode", err.Error())
+	}
+	status.Mode = mode
+
+	detach := g.Spec.UninstallDetach()
+	if detach {
+		managed, err := common.IsManagedAddonNamespace(ctx, r.APIReader)
+		if err != nil {
+			return 0, err
+		}
+
+		// The GPU operator and NFD run in the add-on namespace, so they
+		// are removed along with it anyway.
+		if managed {
+			logger.Info("Cannot detach from the managed add-on namespace, uninstalling the GPU stack",
+				"namespace", common.GlobalConfig.AddonNamespace)
+
+			meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+				UninstallDetachedCondition,
+				metav1.ConditionFalse,
+				"ManagedAddonNamespace",
+				fmt.Sprintf("The GPU stack is uninstalled rather than detached: the GPU operator and NFD run in "+
+					"namespace %s, which is deleted with the managed add-on", common.GlobalConfig.AddonNamespace)))
+			detach = false
+		}
+	}
+
+	// The GPU stack keeps serving the GPU workloads once detached.
+	if detach {
+		logger.Info("Detaching the GPU stack from the add-on")
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionTrue,
+			"Detaching",
+			"The GPU stack is detached from the add-on and keeps running the GPU workloads"))
+
+		return 0, r.deleteAddonCRs(ctx, g, patch)
+	}
+
+	pods, workloads, err := r.listGPUWorkloads(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.reconcileReport(ctx, workloads); err != nil {
+		return 0, err
+	}
+
+	podCount, vmCount := countWorkloads(workloads)
+	if len(workloads) > 0 && (podCount != status.GPUPods || vmCount != status.GPUVirtualMachines) {
+		r.Recorder.Event(g, corev1.EventTypeWarning, "GPUWorkloadsRunning",
+			fmt.Sprintf("Uninstalling the add-on (mode %s) while %s still use GPUs, see ConfigMap %s",
+				mode, describeWorkloads(workloads), ReportConfigMapName()))
+	}
+	status.GPUPods = podCount
+	status.GPUVirtualMachines = vmCount
+	status.GPUWorkloads = truncateWorkloads(workloads)
+
+	graceEnd := status.StartTime.Add(g.Spec.UninstallGracePeriod())
+	untilGraceEnd := time.Until(graceEnd)
+
+	// The block mode is bounded by the force deadline, measured from the
+	// start of the block. The teardown gets a force deadline of its own.
+	blockEnd := status.StartTime.Add(g.Spec.UninstallForceDeadline())
+	untilBlockEnd := time.Until(blockEnd)
+
+	switch {
+	case len(workloads) == 0:
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionTrue,
+			"NoGPUWorkloads",
+			"No workloads are using GPUs"))
+
+	case mode == addonv1alpha1.UninstallModeForce:
+		logger.Info("Uninstalling despite the GPU workloads", "mode", mode, "workloads", len(workloads))
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionTrue,
+			"Forced",
+			fmt.Sprintf("Uninstalling regardless of %s using GPUs", describeWorkloads(workloads))))
+
+	case mode == addonv1alpha1.UninstallModeBlock && untilBlockEnd > 0:
+		logger.Info("Uninstallation blocked by the GPU workloads", "workloads", len(workloads), "blockEnd", blockEnd)
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionFalse,
+			"BlockedByGPUWorkloads",
+			fmt.Sprintf("Waiting until %s for %s using GPUs to complete",
+				blockEnd.UTC().Format(time.RFC3339), describeWorkloads(workloads))))
+
+		if err := r.patchStatus(ctx, g, patch); err != nil {
+			return 0, err
+		}
+
+		if untilBlockEnd < gpuWorkloadsRequeueInterval {
+			return untilBlockEnd, nil
+		}
+		return gpuWorkloadsRequeueInterval, nil
+
+	case mode == addonv1alpha1.UninstallModeBlock:
+		logger.Info("Block deadline expired, uninstalling despite the GPU workloads", "workloads", len(workloads))
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionTrue,
+			"BlockDeadlineExpired",
+			fmt.Sprintf("The block deadline expired with %s still using GPUs", describeWorkloads(workloads))))
+
+	case untilGraceEnd > 0:
+		if mode == addonv1alpha1.UninstallModeDrain {
+			r.evictPods(ctx, pods)
+		}
+
+		logger.Info("Waiting for the GPU workloads to complete", "workloads", len(workloads), "gracePeriodEnd", graceEnd)
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionFalse,
+			"GPUWorkloadsRunning",
+			fmt.Sprintf("Waiting until %s for %s using GPUs to complete",
+				graceEnd.UTC().Format(time.RFC3339), describeWorkloads(workloads))))
+
+		if err := r.patchStatus(ctx, g, patch); err != nil {
+			return 0, err
+		}
+
+		if untilGraceEnd < gpuWorkloadsRequeueInterval {
+			return untilGraceEnd, nil
+		}
+		return gpuWorkloadsRequeueInterval, nil
+
+	default:
+		logger.Info("Grace period expired, uninstalling despite the GPU workloads", "workloads", len(workloads))
+
+		meta.SetStatusCondition(&g.Status.Conditions, common.NewCondition(
+			UninstallReadyCondition,
+			metav1.ConditionTrue,
+			"GracePeriodExpired",
+			fmt.Sprintf("The grace period expired with %s still using GPUs", describeWorkloads(workloads))))
+	}
+
+	return 0, r.deleteAddonCRs(ctx, g, patch)
+}
+
+// deleteAddonCRs patches the status of the given GPUAddon and deletes it
+// along with the Monitoring and GPUQuota CRs, whose finalizers remove the
+// add-on components, the monitoring stack and the GPU ResourceQuotas
+// respectively.
+func (r *UninstallReconciler) deleteAddonCRs(ctx context.Context, g *addonv1alpha1.GPUAddon, patch client.Patch) error {
+	logger := log.FromContext(ctx).WithValues("Reconcile Step", "Uninstall", "name", g.Name)
+
+	if err := r.patchStatus(ctx, g, patch); err != nil {
+		return err
+	}
+
+	if err := r.Delete(ctx, g); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete GPUAddon CR %s: %w", g.Name, err)
+	}
+
+	logger.Info("Successfully deleted GPUAddon CR")
+
+	err := r.DeleteAllOf(ctx, &addonv1alpha1.Monitoring{}, client.InNamespace(common.GlobalConfig.AddonNamespace))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Monitoring CRs: %w", err)
+	}
+
+	err = r.DeleteAllOf(ctx, &addonv1alpha1.GPUQuota{}, client.InNamespace(common.GlobalConfig.AddonNamespace))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete GPUQuota CRs: %w", err)
+	}
+
+	return nil
+}
+
+// cancelUninstall resets the uninstall status of the given GPUAddons which
+// are still waiting for the GPU pods to complete, as the add-on ConfigMap is
+// gone.
+func (r *UninstallReconciler) cancelUninstall(ctx context.Context, gpuAddons []addonv1alpha1.GPUAddon) error {
+	for i := range gpuAddons {
+		g := &gpuAddons[i]
+		if !g.DeletionTimestamp.IsZero() || g.Status.Uninstall == nil {
+			continue
+		}
+
+		log.FromContext(ctx).Info("Add-on ConfigMap is gone, cancelling the uninstallation", "name", g.Name)
+
+		patch := client.MergeFrom(g.DeepCopy())
+		g.Status.Uninstall = nil
+		meta.RemoveStatusCondition(&g.Status.Conditions, UninstallReadyCondition)
+
+		if err := r.patchStatus(ctx, g, patch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *UninstallReconciler) patchStatus(ctx context.Context, g *addonv1alpha1.GPUAddon, patch client.Patch) error {
+	if err := r.Status().Patch(ctx, g, patch); err != nil {
+		return fmt.Errorf("failed to patch GPUAddon %s status: %w", g.Name, err)
+	}
+	return nil
+}
+
+func uninstallRequest() reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+}
+
+func configMapFilter() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isAddonConfigMap(e.ObjectNew)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isAddonConfigMap(e.Object)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+	}
+}
+
+func isAddonConfigMap(object client.Object) bool {
+	return object.GetName() == common.GlobalConfig.AddonID && object.GetNamespace() == common.GlobalConfig.AddonNamespace
+}
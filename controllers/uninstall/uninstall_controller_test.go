@@ -0,0 +1,305 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package uninstall
+
+import (
+	"context"
+	"time"
+
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("UninstallReconciler", func() {
+	common.ProcessConfig()
+
+	configmap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.GlobalConfig.AddonID,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		},
+	}
+
+	newGPUAddon := func(name string) *addonv1alpha1.GPUAddon {
+		return &addonv1alpha1.GPUAddon{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: common.GlobalConfig.AddonNamespace,
+				// Keeps the deleted GPUAddon around, as its controller would.
+				Finalizers: []string{common.GlobalConfig.AddonID},
+			},
+		}
+	}
+
+	get := func(r *UninstallReconciler, name string) *addonv1alpha1.GPUAddon {
+		g := &addonv1alpha1.GPUAddon{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{
+			Name:      name,
+			Namespace: common.GlobalConfig.AddonNamespace,
+		}, g)).ShouldNot(HaveOccurred())
+		return g
+	}
+
+	Context("without the add-on ConfigMap", func() {
+		It("should not delete the GPUAddon CR", func() {
+			r := newTestUninstallReconciler(newGPUAddon("test"))
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g := get(r, "test")
+			Expect(g.DeletionTimestamp).To(BeNil())
+			Expect(g.Status.Uninstall).To(BeNil())
+		})
+
+		It("should cancel a pending uninstallation", func() {
+			g := newGPUAddon("test")
+			g.Status.Phase = addonv1alpha1.GPUAddonPhaseUninstalling
+			g.Status.Uninstall = &addonv1alpha1.UninstallStatus{StartTime: metav1.Now()}
+			g.Status.Conditions = []metav1.Condition{
+				common.NewCondition(UninstallReadyCondition, metav1.ConditionFalse, "GPUWorkloadsRunning", ""),
+			}
+			r := newTestUninstallReconciler(g)
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			g = get(r, "test")
+			Expect(g.Status.Uninstall).To(BeNil())
+			Expect(meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)).To(BeNil())
+		})
+	})
+
+	Context("with the add-on ConfigMap", func() {
+		It("should delete all the GPUAddon CRs when no pods use GPUs", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon("first"), newGPUAddon("second"),
+				newPod("completed", "workloads", corev1.PodSucceeded, common.GPUResourceName),
+				newPod("validator", common.GlobalConfig.AddonNamespace, corev1.PodRunning, common.GPUResourceName),
+				newPod("cpu", "workloads", corev1.PodRunning, corev1.ResourceCPU))
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			for _, name := range []string{"first", "second"} {
+				g := get(r, name)
+				Expect(g.DeletionTimestamp).ToNot(BeNil())
+				Expect(g.Status.Phase).To(Equal(addonv1alpha1.GPUAddonPhaseUninstalling))
+				Expect(g.Status.Uninstall).ToNot(BeNil())
+				Expect(g.Status.Uninstall.GPUPods).To(BeZero())
+
+				c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+				Expect(c).ToNot(BeNil())
+				Expect(c.Status).To(Equal(metav1.ConditionTrue))
+				Expect(c.Reason).To(Equal("NoGPUWorkloads"))
+			}
+		})
+
+		It("should wait for the pods using GPUs during the grace period", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon("test"),
+				newPod("training", "workloads", corev1.PodRunning, common.GPUResourceName),
+				newPod("inference", "workloads", corev1.PodPending, "nvidia.com/mig-1g.5gb"))
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(gpuWorkloadsRequeueInterval))
+
+			g := get(r, "test")
+			Expect(g.DeletionTimestamp).To(BeNil())
+			Expect(g.Status.Phase).To(Equal(addonv1alpha1.GPUAddonPhaseUninstalling))
+			Expect(g.Status.Uninstall.GPUPods).To(Equal(int32(2)))
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal("GPUWorkloadsRunning"))
+			Expect(c.Message).To(ContainSubstring("workloads/training"))
+			Expect(c.Message).To(ContainSubstring("workloads/inference"))
+		})
+
+		It("should delete the GPUAddon CR once the grace period has expired", func() {
+			g := newGPUAddon("test")
+			g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{
+				GracePeriod: &metav1.Duration{Duration: time.Minute},
+			}
+			g.Status.Uninstall = &addonv1alpha1.UninstallStatus{
+				StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			}
+			r := newTestUninstallReconciler(configmap, g,
+				newPod("training", "workloads", corev1.PodRunning, common.GPUResourceName))
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			g = get(r, "test")
+			Expect(g.DeletionTimestamp).ToNot(BeNil())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionTrue))
+			Expect(c.Reason).To(Equal("GracePeriodExpired"))
+		})
+
+		It("should delete the GPUAddon CR right away when detaching the GPU stack", func() {
+			g := newGPUAddon("test")
+			g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{Detach: true}
+			r := newTestUninstallReconciler(configmap, g,
+				newPod("training", "workloads", corev1.PodRunning, common.GPUResourceName))
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			g = get(r, "test")
+			Expect(g.DeletionTimestamp).ToNot(BeNil())
+			Expect(g.Status.Uninstall.GPUPods).To(BeZero())
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionTrue))
+			Expect(c.Reason).To(Equal("Detaching"))
+		})
+
+		It("should uninstall rather than detach the GPU stack in the managed add-on namespace", func() {
+			g := newGPUAddon("test")
+			g.Spec.Uninstall = &addonv1alpha1.UninstallSpec{Detach: true}
+			r := newTestUninstallReconciler(configmap, g, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.GlobalConfig.AddonNamespace,
+					Labels: map[string]string{
+						"api.openshift.com/addon-" + common.GlobalConfig.AddonID: "true",
+					},
+				},
+			}, newPod("training", "workloads", corev1.PodRunning, common.GPUResourceName))
+
+			res, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(gpuWorkloadsRequeueInterval))
+
+			g = get(r, "test")
+			Expect(g.DeletionTimestamp).To(BeNil())
+			Expect(g.Status.Uninstall.GPUPods).To(Equal(int32(1)))
+
+			c := meta.FindStatusCondition(g.Status.Conditions, UninstallDetachedCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Status).To(Equal(metav1.ConditionFalse))
+			Expect(c.Reason).To(Equal("ManagedAddonNamespace"))
+			Expect(c.Message).To(ContainSubstring(common.GlobalConfig.AddonNamespace))
+
+			c = meta.FindStatusCondition(g.Status.Conditions, UninstallReadyCondition)
+			Expect(c).ToNot(BeNil())
+			Expect(c.Reason).To(Equal("GPUWorkloadsRunning"))
+		})
+
+		It("should delete the Monitoring and GPUQuota CRs", func() {
+			r := newTestUninstallReconciler(configmap, newGPUAddon("test"), &addonv1alpha1.Monitoring{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.GlobalConfig.AddonID,
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+			}, &addonv1alpha1.GPUQuota{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "team-a",
+					Namespace: common.GlobalConfig.AddonNamespace,
+				},
+			})
+
+			_, err := r.Reconcile(context.TODO(), uninstallRequest())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = r.Get(context.TODO(), types.NamespacedName{
+				Name:      common.GlobalConfig.AddonID,
+				Namespace: common.GlobalConfig.AddonNamespace,
+			}, &addonv1alpha1.Monitoring{})
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+			err = r.Get(context.TODO(), types.NamespacedName{
+				Name:      "team-a",
+				Namespace: common.GlobalConfig.AddonNamespace,
+			}, &addonv1alpha1.GPUQuota{})
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
+
+func newPod(name, namespace string, phase corev1.PodPhase, resourceName corev1.ResourceName) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "main",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							resourceName: resource.MustParse("1"),
+						},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func newTestUninstallReconciler(objs ...runtime.Object) *UninstallReconciler {
+	s := scheme.Scheme
+
+	Expect(operatorsv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(corev1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+
+	return &UninstallReconciler{
+		Client:    c,
+		Scheme:    s,
+		Recorder:  record.NewFakeRecorder(10),
+		APIReader: c,
+		Evicter:   &fakePodEvicter{},
+	}
+}
+
+type fakePodEvicter struct {
+	evicted []string
+}
+
+func (e *fakePodEvicter) Evict(_ context.Context, pod *corev1.Pod) error {
+	e.evicted = append(e.evicted, client.ObjectKeyFromObject(pod).String())
+	return nil
+}
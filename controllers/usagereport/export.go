@@ -0,0 +1,119 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+const usageReportCSVKey = "usage.csv"
+
+var usageReportCSVHeader = []string{
+	"namespace",
+	"allocated_gpu_hours",
+	"allocated_gpus",
+	"average_utilization_percent",
+	"idle_pods",
+	"window_start",
+	"window_end",
+}
+
+// reconcileExport writes the given GPU usage as CSV to the ConfigMap the
+// report is exported to.
+func (r *GPUUsageReportReconciler) reconcileExport(
+	ctx context.Context,
+	report *addonv1alpha1.GPUUsageReport,
+	usage []addonv1alpha1.NamespaceGPUUsage,
+	start, end metav1.Time) error {
+
+	logger := log.FromContext(ctx, "Reconcile Step", "GPUUsageReport export")
+
+	data, err := renderUsageCSV(usage, start, end)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      report.Spec.Export.ConfigMapName,
+			Namespace: report.Namespace,
+		},
+	}
+
+	res, err := controllerutil.CreateOrPatch(ctx, r.Client, cm, func() error {
+		cm.Data = map[string]string{
+			usageReportCSVKey: data,
+		}
+		return ctrl.SetControllerReference(report, cm, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export the GPU usage report to ConfigMap %s: %w", cm.Name, err)
+	}
+
+	logger.Info("GPUUsageReport exported successfully",
+		"name", cm.Name,
+		"namespace", cm.Namespace,
+		"result", res)
+
+	return nil
+}
+
+// renderUsageCSV renders the given GPU usage as CSV, with a row per
+// namespace. The idle pods of a namespace are separated by spaces.
+func renderUsageCSV(usage []addonv1alpha1.NamespaceGPUUsage, start, end metav1.Time) (string, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	if err := w.Write(usageReportCSVHeader); err != nil {
+		return "", fmt.Errorf("failed to render the GPU usage report: %w", err)
+	}
+
+	for _, u := range usage {
+		if err := w.Write([]string{
+			u.Namespace,
+			u.AllocatedGPUHours,
+			strconv.Itoa(int(u.AllocatedGPUs)),
+			strconv.Itoa(int(u.AverageUtilization)),
+			strings.Join(u.IdlePods, " "),
+			start.UTC().Format(time.RFC3339),
+			end.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return "", fmt.Errorf("failed to render the GPU usage report: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to render the GPU usage report: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+)
+
+var _ = Describe("GPU usage export", func() {
+	It("should render a CSV row per namespace", func() {
+		start := metav1.NewTime(time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC))
+		end := metav1.NewTime(time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC))
+
+		data, err := renderUsageCSV([]addonv1alpha1.NamespaceGPUUsage{
+			{
+				Namespace:          "team-a",
+				AllocatedGPUHours:  "12.50",
+				AverageUtilization: 72,
+			},
+			{
+				Namespace:          "team-b",
+				AllocatedGPUHours:  "48.00",
+				AllocatedGPUs:      4,
+				AverageUtilization: 2,
+				IdlePods:           []string{"notebook-0", "notebook-1"},
+			},
+		}, start, end)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(data).To(Equal(
+			"namespace,allocated_gpu_hours,allocated_gpus,average_utilization_percent,idle_pods,window_start,window_end\n" +
+				"team-a,12.50,0,72,,2022-08-01T00:00:00Z,2022-08-02T00:00:00Z\n" +
+				"team-b,48.00,4,2,notebook-0 notebook-1,2022-08-01T00:00:00Z,2022-08-02T00:00:00Z\n"))
+	})
+})
@@ -0,0 +1,217 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+const (
+	// gpuUtilizationMetric is the GPU utilization exposed by the DCGM
+	// exporter. The exporter labels the series of each GPU with the pod it
+	// is allocated to, as reported by the kubelet.
+	gpuUtilizationMetric = "DCGM_FI_DEV_GPU_UTIL"
+
+	// The pod labels of the DCGM exporter are renamed when scraped, as they
+	// conflict with the labels of the scrape target.
+	namespaceLabel = "exported_namespace"
+	podLabel       = "exported_pod"
+
+	// usageResolution is the resolution the allocation is sampled at over
+	// the window.
+	usageResolution = 5 * time.Minute
+)
+
+// PrometheusQuerier evaluates PromQL instant queries.
+type PrometheusQuerier interface {
+	Query(ctx context.Context, address string, query string, at time.Time) (model.Vector, error)
+}
+
+// allocatedGPUHoursQuery returns the GPU hours allocated to each namespace
+// over the given window, counting each GPU attributed to a pod at each step
+// of the usage resolution.
+func allocatedGPUHoursQuery(window string) string {
+	return fmt.Sprintf(`sum by (%[1]s) (count_over_time(%[2]s{%[3]s!=""}[%[4]s:%[5]s])) * %[6]g`,
+		namespaceLabel, gpuUtilizationMetric, podLabel, window,
+		model.Duration(usageResolution), usageResolution.Hours())
+}
+
+// averageUtilizationQuery returns the average utilization of the GPUs
+// allocated to each namespace over the given window.
+func averageUtilizationQuery(window string) string {
+	return fmt.Sprintf(`avg by (%[1]s) (avg_over_time(%[2]s{%[3]s!=""}[%[4]s]))`,
+		namespaceLabel, gpuUtilizationMetric, podLabel, window)
+}
+
+// idlePodsQuery returns the pods whose GPUs have an average utilization below
+// the given threshold over the given window.
+func idlePodsQuery(window string, threshold int32) string {
+	return fmt.Sprintf(`avg by (%[1]s, %[2]s) (avg_over_time(%[3]s{%[2]s!=""}[%[4]s])) < %[5]d`,
+		namespaceLabel, podLabel, gpuUtilizationMetric, window, threshold)
+}
+
+// podAllocation is the GPUs allocated by Kubernetes to a running pod.
+type podAllocation struct {
+	namespace string
+	gpus      int64
+	// hours is the number of GPU hours allocated to the pod over the
+	// window.
+	hours float64
+}
+
+// listAllocations returns the GPUs allocated by Kubernetes to the running
+// pods, at the given time and over the given window.
+func listAllocations(ctx context.Context, reader client.Reader, window time.Duration, at time.Time) ([]podAllocation, error) {
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	allocations := []podAllocation{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.StartTime == nil {
+			continue
+		}
+
+		gpus := common.PodGPUs(pod)
+		if gpus == 0 {
+			continue
+		}
+
+		running := at.Sub(pod.Status.StartTime.Time)
+		if running > window {
+			running = window
+		}
+
+		allocations = append(allocations, podAllocation{
+			namespace: pod.Namespace,
+			gpus:      gpus,
+			hours:     float64(gpus) * running.Hours(),
+		})
+	}
+
+	return allocations, nil
+}
+
+// queryUsage returns the GPU usage of each namespace over the window of the
+// given report, at the given time. The DCGM exporter metrics are joined with
+// the GPUs allocated by Kubernetes to the running pods, as read from the
+// given reader.
+func queryUsage(
+	ctx context.Context,
+	prometheus PrometheusQuerier,
+	reader client.Reader,
+	address string,
+	spec *addonv1alpha1.GPUUsageReportSpec,
+	at time.Time) ([]addonv1alpha1.NamespaceGPUUsage, error) {
+
+	window := spec.UsageReportWindow()
+
+	duration, err := model.ParseDuration(window)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window %s: %w", window, err)
+	}
+
+	hours, err := prometheus.Query(ctx, address, allocatedGPUHoursQuery(window), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the allocated GPU hours: %w", err)
+	}
+
+	utilization, err := prometheus.Query(ctx, address, averageUtilizationQuery(window), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the GPU utilization: %w", err)
+	}
+
+	idle, err := prometheus.Query(ctx, address, idlePodsQuery(window, spec.IdleThreshold()), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the idle pods: %w", err)
+	}
+
+	allocations, err := listAllocations(ctx, reader, time.Duration(duration), at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the allocated GPUs: %w", err)
+	}
+
+	return namespaceUsage(hours, utilization, idle, allocations), nil
+}
+
+// namespaceUsage joins the results of the usage queries and the allocated
+// GPUs by namespace. The DCGM exporter only samples the GPUs it attributes to
+// pods, e.g. not while it is down, so the GPU hours allocated by Kubernetes to
+// the running pods take precedence when larger. The namespaces are sorted by
+// name.
+func namespaceUsage(hours, utilization, idle model.Vector, allocations []podAllocation) []addonv1alpha1.NamespaceGPUUsage {
+	usage := map[string]*addonv1alpha1.NamespaceGPUUsage{}
+	sampledHours := map[string]float64{}
+	allocatedHours := map[string]float64{}
+
+	get := func(namespace string) *addonv1alpha1.NamespaceGPUUsage {
+		if _, ok := usage[namespace]; !ok {
+			usage[namespace] = &addonv1alpha1.NamespaceGPUUsage{Namespace: namespace}
+		}
+		return usage[namespace]
+	}
+
+	for _, sample := range hours {
+		namespace := string(sample.Metric[namespaceLabel])
+		get(namespace)
+		sampledHours[namespace] = float64(sample.Value)
+	}
+
+	for _, sample := range utilization {
+		get(string(sample.Metric[namespaceLabel])).AverageUtilization = int32(math.Round(float64(sample.Value)))
+	}
+
+	for _, sample := range idle {
+		u := get(string(sample.Metric[namespaceLabel]))
+		u.IdlePods = append(u.IdlePods, string(sample.Metric[podLabel]))
+	}
+
+	for _, a := range allocations {
+		get(a.namespace).AllocatedGPUs += int32(a.gpus)
+		allocatedHours[a.namespace] += a.hours
+	}
+
+	out := []addonv1alpha1.NamespaceGPUUsage{}
+	for namespace, u := range usage {
+		u.AllocatedGPUHours = formatGPUHours(math.Max(sampledHours[namespace], allocatedHours[namespace]))
+		sort.Strings(u.IdlePods)
+		out = append(out, *u)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Namespace < out[j].Namespace
+	})
+
+	return out
+}
+
+func formatGPUHours(hours float64) string {
+	return fmt.Sprintf("%.2f", hours)
+}
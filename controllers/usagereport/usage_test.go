@@ -0,0 +1,189 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+// fakePrometheusQuerier returns the configured vector of each query, and
+// fails the queries which are not configured.
+type fakePrometheusQuerier struct {
+	results map[string]model.Vector
+	queries int
+	address string
+}
+
+func (f *fakePrometheusQuerier) Query(_ context.Context, address string, query string, _ time.Time) (model.Vector, error) {
+	f.queries++
+	f.address = address
+	result, ok := f.results[query]
+	if !ok {
+		return nil, errors.New("unexpected query")
+	}
+	return result, nil
+}
+
+func sample(value float64, labels ...string) *model.Sample {
+	metric := model.Metric{}
+	for i := 0; i < len(labels); i += 2 {
+		metric[model.LabelName(labels[i])] = model.LabelValue(labels[i+1])
+	}
+	return &model.Sample{Metric: metric, Value: model.SampleValue(value)}
+}
+
+// newUsageQuerier returns a querier reporting two namespaces over the given
+// window, one of them holding an idle pod.
+func newUsageQuerier(window string, threshold int32) *fakePrometheusQuerier {
+	return &fakePrometheusQuerier{results: map[string]model.Vector{
+		allocatedGPUHoursQuery(window): {
+			sample(48, namespaceLabel, "team-b"),
+			sample(12.5, namespaceLabel, "team-a"),
+		},
+		averageUtilizationQuery(window): {
+			sample(2.4, namespaceLabel, "team-b"),
+			sample(71.6, namespaceLabel, "team-a"),
+		},
+		idlePodsQuery(window, threshold): {
+			sample(0, namespaceLabel, "team-b", podLabel, "notebook-1"),
+			sample(1, namespaceLabel, "team-b", podLabel, "notebook-0"),
+		},
+	}}
+}
+
+// newGPUPod returns a pod of the given namespace holding the given number of
+// GPUs, running since the given time.
+func newGPUPod(namespace, name string, gpus int64, since time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						common.GPUResourceName: *resource.NewQuantity(gpus, resource.DecimalSI),
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &metav1.Time{Time: since},
+		},
+	}
+}
+
+var _ = Describe("GPU usage", func() {
+	now := time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	newReader := func(objs ...runtime.Object) client.Reader {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).Build()
+	}
+
+	It("should sample the allocation at the usage resolution", func() {
+		Expect(allocatedGPUHoursQuery("7d")).To(Equal(
+			`sum by (exported_namespace) (count_over_time(DCGM_FI_DEV_GPU_UTIL{exported_pod!=""}[7d:5m])) * 0.08333333333333333`))
+		Expect(idlePodsQuery("24h", 5)).To(Equal(
+			`avg by (exported_namespace, exported_pod) (avg_over_time(DCGM_FI_DEV_GPU_UTIL{exported_pod!=""}[24h])) < 5`))
+	})
+
+	It("should join the usage by namespace", func() {
+		spec := &addonv1alpha1.GPUUsageReportSpec{Window: "7d"}
+
+		usage, err := queryUsage(context.TODO(), newUsageQuerier("7d", 5), newReader(), "", spec, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(usage).To(Equal([]addonv1alpha1.NamespaceGPUUsage{
+			{
+				Namespace:          "team-a",
+				AllocatedGPUHours:  "12.50",
+				AverageUtilization: 72,
+			},
+			{
+				Namespace:          "team-b",
+				AllocatedGPUHours:  "48.00",
+				AverageUtilization: 2,
+				IdlePods:           []string{"notebook-0", "notebook-1"},
+			},
+		}))
+	})
+
+	It("should join the usage with the GPUs allocated to the running pods", func() {
+		spec := &addonv1alpha1.GPUUsageReportSpec{Window: "7d"}
+
+		pending := newGPUPod("team-a", "pending", 8, now)
+		pending.Status.Phase = corev1.PodPending
+		reader := newReader(
+			// 2 GPUs for 5h, which is less than sampled.
+			newGPUPod("team-a", "training", 2, now.Add(-5*time.Hour)),
+			pending,
+			// 4 GPUs over the whole window, which is more than sampled.
+			newGPUPod("team-b", "notebook-0", 4, now.Add(-30*24*time.Hour)),
+			// Not sampled at all.
+			newGPUPod("team-c", "inference", 1, now.Add(-90*time.Minute)),
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-d"},
+				Status: corev1.PodStatus{Phase: corev1.PodRunning, StartTime: &metav1.Time{Time: now}}})
+
+		usage, err := queryUsage(context.TODO(), newUsageQuerier("7d", 5), reader, "", spec, now)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(usage).To(Equal([]addonv1alpha1.NamespaceGPUUsage{
+			{
+				Namespace:          "team-a",
+				AllocatedGPUHours:  "12.50",
+				AllocatedGPUs:      2,
+				AverageUtilization: 72,
+			},
+			{
+				Namespace:          "team-b",
+				AllocatedGPUHours:  "672.00",
+				AllocatedGPUs:      4,
+				AverageUtilization: 2,
+				IdlePods:           []string{"notebook-0", "notebook-1"},
+			},
+			{
+				Namespace:         "team-c",
+				AllocatedGPUHours: "1.50",
+				AllocatedGPUs:     1,
+			},
+		}))
+	})
+
+	It("should fail when a query fails", func() {
+		spec := &addonv1alpha1.GPUUsageReportSpec{Window: "7d"}
+
+		_, err := queryUsage(context.TODO(), newUsageQuerier("24h", 5), newReader(), "", spec, now)
+		Expect(err).To(MatchError(ContainSubstring("failed to query the allocated GPU hours")))
+	})
+})
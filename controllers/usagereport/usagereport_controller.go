@@ -0,0 +1,222 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+)
+
+const UsageReportGeneratedCondition = "UsageReportGenerated"
+
+// GPUUsageReportReconciler reports the GPU usage of each namespace from the
+// DCGM exporter metrics, queried from the Prometheus of the monitoring mode.
+type GPUUsageReportReconciler struct {
+	client.Client
+
+	Scheme *runtime.Scheme
+
+	// APIReader reads the pods of all namespaces, which are not available
+	// in the namespaced cache of the manager.
+	APIReader client.Reader
+	// Prometheus queries the Prometheus of the monitoring mode. The reports
+	// are not generated when it is nil.
+	Prometheus PrometheusQuerier
+
+	// now returns the current time, and is overridden by the tests.
+	now func() time.Time
+}
+
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuusagereports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuusagereports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=gpuusagereports/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+//+kubebuilder:rbac:groups=nvidia.addons.rh-ecosystem-edge.io,namespace=system,resources=monitorings,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.2/pkg/reconcile
+func (r *GPUUsageReportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	report := addonv1alpha1.GPUUsageReport{}
+
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Name:      req.Name,
+		Namespace: req.Namespace,
+	}, &report); err != nil {
+
+		if k8serrors.IsNotFound(err) {
+			logger.Info("GPUUsageReport CR not found. Probably deleted.")
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{Requeue: true}, fmt.Errorf("could not get GPUUsageReport CR: %v", err)
+	}
+
+	if !report.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if r.Prometheus == nil {
+		// The add-on Prometheus cannot be queried, e.g. outside of the
+		// cluster.
+		return ctrl.Result{}, r.patchStatus(ctx, &report, common.NewCondition(
+			UsageReportGeneratedCondition,
+			metav1.ConditionFalse,
+			"PrometheusUnavailable",
+			"The add-on Prometheus cannot be queried"))
+	}
+
+	window, err := model.ParseDuration(report.Spec.UsageReportWindow())
+	if err != nil {
+		return ctrl.Result{}, r.patchStatus(ctx, &report, common.NewCondition(
+			UsageReportGeneratedCondition,
+			metav1.ConditionFalse,
+			"InvalidWindow",
+			err.Error()))
+	}
+
+	interval, err := model.ParseDuration(report.Spec.UsageReportInterval())
+	if err != nil {
+		return ctrl.Result{}, r.patchStatus(ctx, &report, common.NewCondition(
+			UsageReportGeneratedCondition,
+			metav1.ConditionFalse,
+			"InvalidInterval",
+			err.Error()))
+	}
+
+	now := r.currentTime()
+
+	// The report is only refreshed at its interval, or when its spec
+	// changes, as the queries over long windows are expensive.
+	if next := nextRefresh(&report, time.Duration(interval)); now.Before(next) {
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	address, err := monitoring.QueryAddress(ctx, r.Client)
+	if errors.Is(err, monitoring.ErrUnsupportedMonitoringMode) {
+		return ctrl.Result{RequeueAfter: time.Duration(interval)}, r.patchStatus(ctx, &report, common.NewCondition(
+			UsageReportGeneratedCondition,
+			metav1.ConditionFalse,
+			"UnsupportedMonitoringMode",
+			err.Error()))
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	usage, err := queryUsage(ctx, r.Prometheus, r.APIReader, address, &report.Spec, now)
+	if err != nil {
+		logger.Info("Unable to generate the GPU usage report", "error", err.Error())
+		return ctrl.Result{RequeueAfter: time.Duration(interval)}, r.patchStatus(ctx, &report, common.NewCondition(
+			UsageReportGeneratedCondition,
+			metav1.ConditionFalse,
+			"QueryFailed",
+			err.Error()))
+	}
+
+	start := metav1.NewTime(now.Add(-time.Duration(window)))
+	end := metav1.NewTime(now)
+
+	if report.Spec.Export != nil {
+		if err := r.reconcileExport(ctx, &report, usage, start, end); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	patch := client.MergeFrom(report.DeepCopy())
+	report.Status.ObservedGeneration = report.Generation
+	report.Status.StartTime = &start
+	report.Status.EndTime = &end
+	report.Status.Namespaces = usage
+	meta.SetStatusCondition(&report.Status.Conditions, common.NewCondition(
+		UsageReportGeneratedCondition,
+		metav1.ConditionTrue,
+		"Generated",
+		fmt.Sprintf("The GPU usage of %d namespaces is reported", len(usage))))
+
+	if err := r.Status().Patch(ctx, &report, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return ctrl.Result{RequeueAfter: time.Duration(interval)}, nil
+}
+
+// nextRefresh returns when the given report is next refreshed.
+func nextRefresh(report *addonv1alpha1.GPUUsageReport, interval time.Duration) time.Time {
+	if report.Status.EndTime == nil || report.Status.ObservedGeneration != report.Generation {
+		return time.Time{}
+	}
+
+	return report.Status.EndTime.Add(interval)
+}
+
+func (r *GPUUsageReportReconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GPUUsageReportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// The status updates do not trigger a new report.
+		For(&addonv1alpha1.GPUUsageReport{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.ConfigMap{}).
+		Complete(health.Reconciles.Track("gpuusagereport", r))
+}
+
+func (r *GPUUsageReportReconciler) patchStatus(
+	ctx context.Context,
+	report *addonv1alpha1.GPUUsageReport,
+	condition metav1.Condition) error {
+
+	patch := client.MergeFrom(report.DeepCopy())
+	meta.SetStatusCondition(&report.Status.Conditions, condition)
+
+	if err := r.Status().Patch(ctx, report, patch); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usagereport
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	addonv1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+)
+
+var _ = Describe("GPUUsageReport Reconcile", func() {
+	common.ProcessConfig()
+
+	now := time.Date(2022, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	newMonitoring := func(mode addonv1alpha1.MonitoringMode) *addonv1alpha1.Monitoring {
+		return &addonv1alpha1.Monitoring{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      common.GlobalConfig.AddonID,
+				Namespace: common.GlobalConfig.AddonNamespace,
+			},
+			Spec: addonv1alpha1.MonitoringSpec{Mode: mode},
+		}
+	}
+
+	newReport := func() *addonv1alpha1.GPUUsageReport {
+		return &addonv1alpha1.GPUUsageReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test",
+				Namespace:  "test",
+				Generation: 1,
+			},
+			Spec: addonv1alpha1.GPUUsageReportSpec{
+				Window: "24h",
+				Export: &addonv1alpha1.UsageReportExportSpec{
+					ConfigMapName: "gpu-usage",
+				},
+			},
+		}
+	}
+
+	reconcile := func(r *GPUUsageReportReconciler, report *addonv1alpha1.GPUUsageReport) ctrl.Result {
+		res, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(report)})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(report), report)).ShouldNot(HaveOccurred())
+		return res
+	}
+
+	It("should report the usage and export it", func() {
+		report := newReport()
+		prometheus := newUsageQuerier("24h", addonv1alpha1.DefaultIdleUtilizationThreshold)
+		r := newTestGPUUsageReportReconciler(prometheus, now, report)
+
+		res := reconcile(r, report)
+		Expect(res.RequeueAfter).To(Equal(time.Hour))
+		Expect(prometheus.address).To(Equal(monitoring.PrometheusAddress(common.GlobalConfig.AddonNamespace)))
+
+		Expect(report.Status.StartTime.Time).To(BeTemporally("==", now.Add(-24*time.Hour)))
+		Expect(report.Status.EndTime.Time).To(BeTemporally("==", now))
+		Expect(report.Status.ObservedGeneration).To(Equal(int64(1)))
+		Expect(report.Status.Namespaces).To(HaveLen(2))
+		Expect(report.Status.Namespaces[1].IdlePods).To(Equal([]string{"notebook-0", "notebook-1"}))
+
+		condition := meta.FindStatusCondition(report.Status.Conditions, UsageReportGeneratedCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{Name: "gpu-usage", Namespace: "test"}, cm)).ShouldNot(HaveOccurred())
+		Expect(cm.Data[usageReportCSVKey]).To(ContainSubstring("team-b,48.00,0,2,notebook-0 notebook-1,"))
+		Expect(metav1.IsControlledBy(cm, report)).To(BeTrue())
+	})
+
+	It("should only refresh the report at its interval or when its spec changes", func() {
+		report := newReport()
+		prometheus := newUsageQuerier("24h", addonv1alpha1.DefaultIdleUtilizationThreshold)
+		r := newTestGPUUsageReportReconciler(prometheus, now, report)
+
+		reconcile(r, report)
+		Expect(prometheus.queries).To(Equal(3))
+
+		r.now = func() time.Time { return now.Add(20 * time.Minute) }
+		res := reconcile(r, report)
+		Expect(prometheus.queries).To(Equal(3))
+		Expect(res.RequeueAfter).To(Equal(40 * time.Minute))
+
+		report.Generation = 2
+		report.Spec.Export = nil
+		Expect(r.Update(context.TODO(), report)).ShouldNot(HaveOccurred())
+		reconcile(r, report)
+		Expect(prometheus.queries).To(Equal(6))
+		Expect(report.Status.ObservedGeneration).To(Equal(int64(2)))
+
+		r.now = func() time.Time { return now.Add(80 * time.Minute) }
+		reconcile(r, report)
+		Expect(prometheus.queries).To(Equal(9))
+	})
+
+	It("should report the query failures", func() {
+		report := newReport()
+		report.Spec.Window = "7d"
+		r := newTestGPUUsageReportReconciler(newUsageQuerier("24h", 5), now, report)
+
+		res := reconcile(r, report)
+		Expect(res.RequeueAfter).To(Equal(time.Hour))
+
+		condition := meta.FindStatusCondition(report.Status.Conditions, UsageReportGeneratedCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("QueryFailed"))
+		Expect(report.Status.EndTime).To(BeNil())
+	})
+
+	It("should query the Thanos Querier in the cluster monitoring modes", func() {
+		report := newReport()
+		prometheus := newUsageQuerier("24h", addonv1alpha1.DefaultIdleUtilizationThreshold)
+		r := newTestGPUUsageReportReconciler(prometheus, now, report, newMonitoring(addonv1alpha1.MonitoringModeUserWorkload))
+
+		reconcile(r, report)
+		Expect(prometheus.address).To(Equal(monitoring.ThanosQuerier.Address()))
+
+		condition := meta.FindStatusCondition(report.Status.Conditions, UsageReportGeneratedCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	Context("on a Kubernetes cluster", func() {
+		BeforeEach(func() {
+			common.ClusterPlatform = common.PlatformKubernetes
+		})
+
+		AfterEach(func() {
+			common.ClusterPlatform = common.PlatformOpenShift
+		})
+
+		It("should report that the cluster monitoring modes are unsupported", func() {
+			report := newReport()
+			prometheus := newUsageQuerier("24h", addonv1alpha1.DefaultIdleUtilizationThreshold)
+			r := newTestGPUUsageReportReconciler(prometheus, now, report, newMonitoring(addonv1alpha1.MonitoringModePlatform))
+
+			res := reconcile(r, report)
+			Expect(res.RequeueAfter).To(Equal(time.Hour))
+			Expect(prometheus.queries).To(BeZero())
+
+			condition := meta.FindStatusCondition(report.Status.Conditions, UsageReportGeneratedCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("UnsupportedMonitoringMode"))
+		})
+	})
+
+	It("should report that Prometheus cannot be queried", func() {
+		report := newReport()
+		r := newTestGPUUsageReportReconciler(nil, now, report)
+
+		res := reconcile(r, report)
+		Expect(res.RequeueAfter).To(BeZero())
+
+		condition := meta.FindStatusCondition(report.Status.Conditions, UsageReportGeneratedCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Reason).To(Equal("PrometheusUnavailable"))
+	})
+})
+
+func newTestGPUUsageReportReconciler(
+	prometheus *fakePrometheusQuerier,
+	now time.Time,
+	objs ...runtime.Object) *GPUUsageReportReconciler {
+
+	s := scheme.Scheme
+	Expect(addonv1alpha1.AddToScheme(s)).ShouldNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+	r := &GPUUsageReportReconciler{
+		Client:    c,
+		Scheme:    s,
+		APIReader: c,
+		now:       func() time.Time { return now },
+	}
+	// A nil querier must not be wrapped in a non-nil interface.
+	if prometheus != nil {
+		r.Prometheus = prometheus
+	}
+
+	return r
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Capability is an optional API the add-on depends on, identified by the name
+// of the CustomResourceDefinition that provides it.
+type Capability string
+
+const (
+	CapabilityClusterPolicy        Capability = "clusterpolicies.nvidia.com"
+	CapabilityNodeFeatureDiscovery Capability = "nodefeaturediscoveries.nfd.openshift.io"
+	CapabilityConsolePlugin        Capability = "consoleplugins.console.openshift.io"
+	CapabilityPrometheus           Capability = "prometheuses.monitoring.coreos.com"
+	CapabilityAlertmanager         Capability = "alertmanagers.monitoring.coreos.com"
+	CapabilityAlertmanagerConfig   Capability = "alertmanagerconfigs.monitoring.coreos.com"
+	CapabilityPrometheusRule       Capability = "prometheusrules.monitoring.coreos.com"
+	CapabilityServiceMonitor       Capability = "servicemonitors.monitoring.coreos.com"
+
+	// CapabilitySubscription is provided by OLM, which plain Kubernetes
+	// clusters may not run.
+	CapabilitySubscription Capability = "subscriptions.operators.coreos.com"
+)
+
+// CapabilityMissingConditionReason is the condition reason reported by the
+// reconcilers which are disabled until their APIs become available.
+const CapabilityMissingConditionReason = "CapabilityMissing"
+
+// MonitoringCapabilities are the monitoring.coreos.com APIs required by the
+// monitoring stack.
+var MonitoringCapabilities = []Capability{
+	CapabilityPrometheus,
+	CapabilityAlertmanager,
+	CapabilityAlertmanagerConfig,
+	CapabilityPrometheusRule,
+	CapabilityServiceMonitor,
+}
+
+// CapabilityRegistry keeps track of which optional APIs are currently served
+// by the cluster.
+type CapabilityRegistry struct {
+	mu        sync.RWMutex
+	available map[Capability]bool
+}
+
+// NewCapabilityRegistry returns a registry with no capabilities available.
+func NewCapabilityRegistry() *CapabilityRegistry {
+	return &CapabilityRegistry{
+		available: map[Capability]bool{},
+	}
+}
+
+// Capabilities is the registry shared by the add-on controllers. It is kept
+// up to date by the CRD controller.
+var Capabilities = NewCapabilityRegistry()
+
+// SetAvailable records whether the given capability is available.
+func (r *CapabilityRegistry) SetAvailable(c Capability, available bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.available[c] = available
+}
+
+// IsAvailable reports whether all the given capabilities are available.
+func (r *CapabilityRegistry) IsAvailable(capabilities ...Capability) bool {
+	return len(r.Missing(capabilities...)) == 0
+}
+
+// Missing returns the given capabilities which are not available.
+func (r *CapabilityRegistry) Missing(capabilities ...Capability) []Capability {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	missing := []Capability{}
+	for _, c := range capabilities {
+		if !r.available[c] {
+			missing = append(missing, c)
+		}
+	}
+
+	return missing
+}
+
+// NewCapabilityMissingCondition returns a condition of the given type
+// reporting that the given capabilities are not available.
+func NewCapabilityMissingCondition(conditionType string, missing []Capability) metav1.Condition {
+	names := make([]string, len(missing))
+	for i, c := range missing {
+		names[i] = string(c)
+	}
+
+	return NewCondition(
+		conditionType,
+		metav1.ConditionFalse,
+		CapabilityMissingConditionReason,
+		fmt.Sprintf("Waiting for the following CRDs to be established: %s", strings.Join(names, ", ")))
+}
@@ -3,10 +3,13 @@ package common
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	utilversion "k8s.io/apimachinery/pkg/util/version"
@@ -38,6 +41,12 @@ type config struct {
 	// NFD_CR_NAME
 	NfdCrName string `envconfig:"NFD_CR_NAME" default:"ocp-gpu-addon"`
 
+	// GPU_OPERATOR_CATALOG_SOURCE, defaults to the catalog of the platform
+	GpuOperatorCatalogSource string `envconfig:"GPU_OPERATOR_CATALOG_SOURCE"`
+
+	// GPU_OPERATOR_CATALOG_SOURCE_NAMESPACE, defaults to the catalog of the platform
+	GpuOperatorCatalogSourceNamespace string `envconfig:"GPU_OPERATOR_CATALOG_SOURCE_NAMESPACE"`
+
 	// RELATED_IMAGE_PLUGIN_IMAGE
 	ConsolePluginImage string `envconfig:"RELATED_IMAGE_CONSOLE_PLUGIN" default:"quay.io/edge-infrastructure/console-plugin-nvidia-gpu@sha256:cec17462944cb2f800e7477101e0470c5f7a07998c012ef7470e14993ebebf40"`
 
@@ -73,6 +82,53 @@ func SliceRemoveString(slice []string, str string) []string {
 	return result
 }
 
+// IsAddonLabel returns whether the given label key belongs to the add-on,
+// i.e. it is prefixed with the add-on ID or it is the managed add-on label.
+func IsAddonLabel(key string) bool {
+	return strings.HasPrefix(key, GlobalConfig.AddonID) ||
+		strings.HasPrefix(key, "api.openshift.com/addon-"+GlobalConfig.AddonID)
+}
+
+// IsUninstallRequested returns whether the add-on uninstallation has been
+// requested by the creation of the add-on ConfigMap. A ConfigMap being deleted
+// cancels the uninstallation.
+func IsUninstallRequested(ctx context.Context, c client.Reader) (bool, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{
+		Name:      GlobalConfig.AddonID,
+		Namespace: GlobalConfig.AddonNamespace,
+	}, cm)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get ConfigMap %s: %w", GlobalConfig.AddonID, err)
+	}
+
+	return cm.DeletionTimestamp.IsZero(), nil
+}
+
+// IsManagedAddonNamespace returns whether the add-on namespace is managed by
+// the add-on, i.e. whether it carries the add-on labels. Such a namespace is
+// deleted along with the managed add-on, thus nothing can be detached in it.
+func IsManagedAddonNamespace(ctx context.Context, c client.Reader) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: GlobalConfig.AddonNamespace}, ns); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get namespace %s: %w", GlobalConfig.AddonNamespace, err)
+	}
+
+	for k := range ns.Labels {
+		if IsAddonLabel(k) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func NewCondition(cond_type string, cond_status metav1.ConditionStatus, reason string, message string) metav1.Condition {
 	return metav1.Condition{
 		Type:               cond_type,
@@ -84,6 +140,10 @@ func NewCondition(cond_type string, cond_status metav1.ConditionStatus, reason s
 }
 
 func GetOpenShiftVersion(client client.Client) (string, error) {
+	if !IsOpenShift() {
+		return "", ErrNotOpenShift
+	}
+
 	clusterVersion := &configv1.ClusterVersion{}
 	err := client.Get(context.TODO(), types.NamespacedName{Name: "version"}, clusterVersion)
 	if err != nil {
@@ -107,6 +167,10 @@ func GetOpenShiftVersion(client client.Client) (string, error) {
 }
 
 func IsOpenShiftVersionAtLeast(client client.Client, v string) (bool, error) {
+	if !IsOpenShift() {
+		return false, ErrNotOpenShift
+	}
+
 	version, err := utilversion.ParseGeneric(v)
 	if err != nil {
 		return false, err
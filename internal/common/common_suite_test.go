@@ -1,15 +1,21 @@
 package common
 
 import (
+	"context"
 	"testing"
 
 	configv1 "github.com/openshift/api/config/v1"
 	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -56,6 +62,40 @@ var _ = Describe("common.go | Common utils", func() {
 		})
 	})
 
+	Context("IsUninstallRequested", func() {
+		newConfigMap := func() *corev1.ConfigMap {
+			return &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       GlobalConfig.AddonID,
+					Namespace:  GlobalConfig.AddonNamespace,
+					Finalizers: []string{"test"},
+				},
+			}
+		}
+
+		It("should return false without the add-on ConfigMap", func() {
+			requested, err := IsUninstallRequested(context.TODO(), fake.NewClientBuilder().Build())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(requested).To(BeFalse())
+		})
+
+		It("should return true with the add-on ConfigMap", func() {
+			requested, err := IsUninstallRequested(context.TODO(), fake.NewClientBuilder().WithRuntimeObjects(newConfigMap()).Build())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(requested).To(BeTrue())
+		})
+
+		It("should return false while the add-on ConfigMap is being deleted", func() {
+			cm := newConfigMap()
+			c := fake.NewClientBuilder().WithRuntimeObjects(cm).Build()
+			Expect(c.Delete(context.TODO(), cm)).ShouldNot(HaveOccurred())
+
+			requested, err := IsUninstallRequested(context.TODO(), c)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(requested).To(BeFalse())
+		})
+	})
+
 	Context("NewCondition function tests", func() {
 		It("Should populate time and data", func() {
 			condition := NewCondition("TestCondition", "True", "", "")
@@ -157,6 +197,162 @@ var _ = Describe("common.go | Common utils", func() {
 	})
 })
 
+var _ = Describe("platform.go | Platform detection", func() {
+	Context("DetectPlatform", func() {
+		It("should detect OpenShift when ClusterVersions are served", func() {
+			dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+			dc.Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: configv1.GroupVersion.String(),
+					APIResources: []metav1.APIResource{
+						{Name: "clusterversions", Kind: "ClusterVersion"},
+					},
+				},
+			}
+
+			p, err := DetectPlatform(dc)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p).To(Equal(PlatformOpenShift))
+		})
+
+		It("should detect Kubernetes when config.openshift.io is not served", func() {
+			dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+
+			p, err := DetectPlatform(dc)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p).To(Equal(PlatformKubernetes))
+		})
+	})
+
+	Context("on a Kubernetes cluster", func() {
+		BeforeEach(func() {
+			ClusterPlatform = PlatformKubernetes
+		})
+
+		AfterEach(func() {
+			ClusterPlatform = PlatformOpenShift
+		})
+
+		It("should not look up the OpenShift version", func() {
+			c := fake.NewClientBuilder().Build()
+
+			_, err := GetOpenShiftVersion(c)
+			Expect(err).To(MatchError(ErrNotOpenShift))
+
+			_, err = IsOpenShiftVersionAtLeast(c, "4.9")
+			Expect(err).To(MatchError(ErrNotOpenShift))
+		})
+	})
+})
+
+var _ = Describe("capabilities.go | Capability registry", func() {
+	It("should report no capabilities as available by default", func() {
+		r := NewCapabilityRegistry()
+
+		Expect(r.IsAvailable(CapabilityClusterPolicy)).To(BeFalse())
+		Expect(r.Missing(CapabilityClusterPolicy, CapabilityPrometheus)).To(
+			Equal([]Capability{CapabilityClusterPolicy, CapabilityPrometheus}))
+	})
+
+	It("should only report the unavailable capabilities as missing", func() {
+		r := NewCapabilityRegistry()
+		r.SetAvailable(CapabilityClusterPolicy, true)
+
+		Expect(r.IsAvailable(CapabilityClusterPolicy)).To(BeTrue())
+		Expect(r.IsAvailable(CapabilityClusterPolicy, CapabilityPrometheus)).To(BeFalse())
+		Expect(r.Missing(CapabilityClusterPolicy, CapabilityPrometheus)).To(
+			Equal([]Capability{CapabilityPrometheus}))
+
+		r.SetAvailable(CapabilityClusterPolicy, false)
+		Expect(r.IsAvailable(CapabilityClusterPolicy)).To(BeFalse())
+	})
+
+	It("should create a CapabilityMissing condition", func() {
+		c := NewCapabilityMissingCondition("Deployed", []Capability{CapabilityClusterPolicy})
+
+		Expect(c.Type).To(Equal("Deployed"))
+		Expect(c.Status).To(Equal(metav1.ConditionFalse))
+		Expect(c.Reason).To(Equal(CapabilityMissingConditionReason))
+		Expect(c.Message).To(ContainSubstring(string(CapabilityClusterPolicy)))
+	})
+})
+
+var _ = Describe("gpu.go | GPU pods", func() {
+	newPod := func(name corev1.ResourceName, quantity string) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{name: resource.MustParse(quantity)},
+					},
+				}},
+			},
+		}
+	}
+
+	It("should detect the pods using GPUs or MIG devices", func() {
+		Expect(PodUsesGPUs(newPod(GPUResourceName, "1"))).To(BeTrue())
+		Expect(PodUsesGPUs(newPod("nvidia.com/mig-1g.5gb", "2"))).To(BeTrue())
+		Expect(PodUsesGPUs(newPod(GPUResourceName, "0"))).To(BeFalse())
+		Expect(PodUsesGPUs(newPod(corev1.ResourceCPU, "1"))).To(BeFalse())
+	})
+
+	It("should count the GPUs and MIG devices allocated to the pods", func() {
+		Expect(PodGPUs(newPod(GPUResourceName, "2"))).To(Equal(int64(2)))
+		Expect(PodGPUs(newPod(corev1.ResourceCPU, "1"))).To(BeZero())
+
+		pod := newPod("nvidia.com/mig-1g.5gb", "2")
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{GPUResourceName: resource.MustParse("1")},
+			},
+		})
+		pod.Spec.InitContainers = []corev1.Container{{
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{GPUResourceName: resource.MustParse("4")},
+			},
+		}}
+		Expect(PodGPUs(pod)).To(Equal(int64(4)))
+
+		pod.Spec.InitContainers = nil
+		Expect(PodGPUs(pod)).To(Equal(int64(3)))
+	})
+})
+
+var _ = Describe("gpu.go | GPU node labels", func() {
+	newNode := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels}}
+	}
+
+	It("should only pass the changes of the GPU node labels", func() {
+		old := newNode(map[string]string{
+			GPUNodeLabel:               "true",
+			"nvidia.com/gfd.timestamp": "1660000000",
+		})
+
+		timestamp := newNode(map[string]string{
+			GPUNodeLabel:               "true",
+			"nvidia.com/gfd.timestamp": "1660000060",
+		})
+		Expect(GPUNodeLabelsChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: timestamp})).To(BeFalse())
+
+		product := newNode(map[string]string{
+			GPUNodeLabel:    "true",
+			GPUProductLabel: "NVIDIA-A100-SXM4-40GB",
+		})
+		Expect(GPUNodeLabelsChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: product})).To(BeTrue())
+
+		gpuType := newNode(map[string]string{
+			GPUNodeLabel:   "true",
+			GPUTypeLabel(): "a100-40gb",
+		})
+		Expect(GPUNodeLabelsChanged.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: gpuType})).To(BeTrue())
+
+		Expect(GPUNodeLabelsChanged.Create(event.CreateEvent{Object: old})).To(BeTrue())
+		Expect(GPUNodeLabelsChanged.Delete(event.DeleteEvent{Object: old})).To(BeTrue())
+	})
+})
+
 var _ = Describe("CSV Utils", func() {
 	Context("Fetching CSV", func() {
 		It("Should return an error when not found", func() {
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// GPUResourceName is the extended resource of full GPUs, while MIG
+	// devices are exposed as extended resources with MIGResourcePrefix.
+	GPUResourceName   = corev1.ResourceName("nvidia.com/gpu")
+	MIGResourcePrefix = "nvidia.com/mig-"
+
+	// GPUNodeLabel is the NFD label of the nodes with an NVIDIA PCI device.
+	GPUNodeLabel = "feature.node.kubernetes.io/pci-10de.present"
+	// GPUProductLabel is the GPU feature discovery label of the product of
+	// the GPUs of a node.
+	GPUProductLabel = "nvidia.com/gpu.product"
+)
+
+// GPUNodeTaint is the taint which keeps the pods not requesting GPUs off the
+// GPU nodes. The GPU operator operands tolerate it.
+var GPUNodeTaint = corev1.Taint{
+	Key:    string(GPUResourceName),
+	Effect: corev1.TaintEffectNoSchedule,
+}
+
+// GPUTypeLabel is the node label of the stable type of the GPUs of a node,
+// e.g. a100-40gb, which does not vary with the driver version unlike the GPU
+// product.
+func GPUTypeLabel() string {
+	return fmt.Sprintf("%s/gpu-type", GlobalConfig.AddonID)
+}
+
+// GPUNodeLabelsChanged filters the Node events down to the nodes coming and
+// going, and to the changes of the GPU node labels. The other labels, e.g. the
+// timestamp the GPU feature discovery rewrites every minute, are ignored.
+var GPUNodeLabelsChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return false
+		}
+		oldLabels, newLabels := e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()
+		for _, key := range []string{GPUNodeLabel, GPUProductLabel, GPUTypeLabel()} {
+			if oldLabels[key] != newLabels[key] {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// PodGPUs returns the number of GPUs and MIG devices allocated to the given
+// pod, i.e. those of its containers, or of its largest init container.
+func PodGPUs(pod *corev1.Pod) int64 {
+	count := func(c corev1.Container) int64 {
+		gpus := int64(0)
+		// The extended resources cannot be overcommitted, their requests
+		// default to their limits.
+		resources := c.Resources.Limits
+		if len(resources) == 0 {
+			resources = c.Resources.Requests
+		}
+		for name, quantity := range resources {
+			if name == GPUResourceName || strings.HasPrefix(string(name), MIGResourcePrefix) {
+				gpus += quantity.Value()
+			}
+		}
+		return gpus
+	}
+
+	gpus := int64(0)
+	for _, c := range pod.Spec.Containers {
+		gpus += count(c)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if n := count(c); n > gpus {
+			gpus = n
+		}
+	}
+
+	return gpus
+}
+
+// PodUsesGPUs returns whether any container of the given pod requests or is
+// limited to GPUs or MIG devices.
+func PodUsesGPUs(pod *corev1.Pod) bool {
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+
+	for _, c := range containers {
+		for _, resources := range []corev1.ResourceList{c.Resources.Requests, c.Resources.Limits} {
+			for name, quantity := range resources {
+				if quantity.IsZero() {
+					continue
+				}
+				if name == GPUResourceName || strings.HasPrefix(string(name), MIGResourcePrefix) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+)
+
+// Platform is the flavour of Kubernetes cluster the addon is running on.
+type Platform string
+
+const (
+	PlatformOpenShift  Platform = "OpenShift"
+	PlatformKubernetes Platform = "Kubernetes"
+)
+
+// ClusterPlatform is the platform detected at startup. It defaults to
+// OpenShift, which is where the addon is primarily deployed.
+var ClusterPlatform = PlatformOpenShift
+
+// ErrNotOpenShift is returned by the OpenShift specific helpers when the
+// cluster is not an OpenShift cluster.
+var ErrNotOpenShift = fmt.Errorf("cluster platform is not %s", PlatformOpenShift)
+
+// DetectPlatform uses the discovery API to determine whether the cluster is
+// an OpenShift cluster, i.e. whether it serves config.openshift.io ClusterVersions.
+func DetectPlatform(dc discovery.DiscoveryInterface) (Platform, error) {
+	resources, err := dc.ServerResourcesForGroupVersion(configv1.GroupVersion.String())
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return PlatformKubernetes, nil
+		}
+		return "", fmt.Errorf("failed to discover %s resources: %w", configv1.GroupVersion.String(), err)
+	}
+
+	for _, r := range resources.APIResources {
+		if r.Kind == "ClusterVersion" {
+			return PlatformOpenShift, nil
+		}
+	}
+
+	return PlatformKubernetes, nil
+}
+
+// IsOpenShift reports whether the detected cluster platform is OpenShift.
+func IsOpenShift() bool {
+	return ClusterPlatform == PlatformOpenShift
+}
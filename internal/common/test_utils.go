@@ -23,3 +23,18 @@ func NewCsv(namespace string, name string, almExample string) *operatorsv1alpha1
 	}
 	return csv
 }
+
+// SetCapabilitiesAvailable marks all the optional APIs the add-on depends on
+// as available or not.
+func SetCapabilitiesAvailable(available bool) {
+	capabilities := append([]Capability{
+		CapabilityClusterPolicy,
+		CapabilityNodeFeatureDiscovery,
+		CapabilityConsolePlugin,
+		CapabilitySubscription,
+	}, MonitoringCapabilities...)
+
+	for _, c := range capabilities {
+		Capabilities.SetAvailable(c, available)
+	}
+}
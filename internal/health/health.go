@@ -0,0 +1,265 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	statusPath    = "/debug/status"
+
+	checkTimeout    = 5 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// CheckFunc returns an error if whatever it checks is not healthy.
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named health check.
+type Check struct {
+	Name  string
+	Check CheckFunc
+}
+
+// CheckStatus is the result of a single Check.
+type CheckStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// Status is the overall health of the manager, as served by the status
+// endpoint.
+type Status struct {
+	Healthy     bool               `json:"healthy"`
+	Checks      []CheckStatus      `json:"checks"`
+	Controllers []ControllerStatus `json:"controllers"`
+}
+
+// Server serves the liveness and readiness probes of the manager, as well as
+// a JSON status endpoint running all the checks. It implements
+// manager.Runnable and runs regardless of leader election.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// LivenessChecks are run by the liveness probe.
+	LivenessChecks []Check
+	// ReadinessChecks are run by the readiness probe.
+	ReadinessChecks []Check
+	// Reconciles is the tracker reported by the status endpoint.
+	Reconciles *ReconcileTracker
+}
+
+// Start serves the probes until the given context is done.
+func (s *Server) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("health").WithValues("addr", s.Addr)
+
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.Addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: checkTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "failed to shut down health probe server")
+		}
+	}()
+
+	logger.Info("Starting health probe server")
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the HTTP handler serving the probes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(livenessPath, s.probeHandler(s.LivenessChecks))
+	mux.HandleFunc(readinessPath, s.probeHandler(s.ReadinessChecks))
+	mux.HandleFunc(statusPath, s.statusHandler)
+
+	return mux
+}
+
+func (s *Server) probeHandler(checks []Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		statuses, healthy := runChecks(req.Context(), checks)
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		b := strings.Builder{}
+		for _, cs := range statuses {
+			if cs.Healthy {
+				fmt.Fprintf(&b, "[+]%s ok\n", cs.Name)
+			} else {
+				fmt.Fprintf(&b, "[-]%s failed: %s\n", cs.Name, cs.Message)
+			}
+		}
+
+		if healthy {
+			b.WriteString("ok\n")
+		} else {
+			b.WriteString("failed\n")
+		}
+
+		fmt.Fprint(w, b.String())
+	}
+}
+
+func (s *Server) statusHandler(w http.ResponseWriter, req *http.Request) {
+	checks := append(append([]Check{}, s.LivenessChecks...), s.ReadinessChecks...)
+	statuses, healthy := runChecks(req.Context(), checks)
+
+	status := Status{
+		Healthy:     healthy,
+		Checks:      statuses,
+		Controllers: []ControllerStatus{},
+	}
+	if s.Reconciles != nil {
+		status.Controllers = s.Reconciles.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(status)
+}
+
+func runChecks(ctx context.Context, checks []Check) ([]CheckStatus, bool) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	healthy := true
+	statuses := make([]CheckStatus, 0, len(checks))
+
+	// Checks which are registered for both probes are only run once.
+	seen := map[string]bool{}
+	for _, c := range checks {
+		if seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+
+		cs := CheckStatus{Name: c.Name, Healthy: true}
+		if err := c.Check(ctx); err != nil {
+			cs.Healthy = false
+			cs.Message = err.Error()
+			healthy = false
+		}
+		statuses = append(statuses, cs)
+	}
+
+	return statuses, healthy
+}
+
+// CacheSynced checks that the informers of the given cache have synced.
+func CacheSynced(c cache.Cache) Check {
+	return Check{
+		Name: "cache-sync",
+		Check: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+
+			if !c.WaitForCacheSync(ctx) {
+				return errors.New("informer caches have not synced")
+			}
+			return nil
+		},
+	}
+}
+
+// APIServerReachable checks that the API server answers version requests.
+func APIServerReachable(rc rest.Interface) Check {
+	return Check{
+		Name: "api-server",
+		Check: func(ctx context.Context) error {
+			if err := rc.Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+				return fmt.Errorf("API server is not reachable: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// CRDsEstablished checks that the CRDs with the given names exist and are
+// established.
+func CRDsEstablished(reader client.Reader, names ...string) Check {
+	return Check{
+		Name: "crds",
+		Check: func(ctx context.Context) error {
+			for _, name := range names {
+				crd := &apiextensionsv1.CustomResourceDefinition{}
+				if err := reader.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+					return fmt.Errorf("failed to get CRD %s: %w", name, err)
+				}
+
+				if !isEstablished(crd) {
+					return fmt.Errorf("CRD %s is not established", name)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// ReconcilesHealthy checks that none of the failing controllers tracked by the
+// given tracker has last reconciled successfully too long ago. It is meant
+// for the readiness probe only, as restarting the manager does not fix
+// whatever the controllers keep failing on.
+func ReconcilesHealthy(t *ReconcileTracker) Check {
+	return Check{
+		Name:  "reconciles",
+		Check: t.Check,
+	}
+}
+
+func isEstablished(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == apiextensionsv1.Established {
+			return c.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHealth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Health Suite")
+}
+
+var _ = Describe("reconciles.go | ReconcileTracker", func() {
+	var (
+		t      *ReconcileTracker
+		now    time.Time
+		failed bool
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		failed = false
+
+		t = NewReconcileTracker(10 * time.Minute)
+		t.now = func() time.Time { return now }
+	})
+
+	reconciler := reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		if failed {
+			return reconcile.Result{}, errors.New("boom")
+		}
+		return reconcile.Result{}, nil
+	})
+
+	It("should report registered controllers which have not reconciled yet", func() {
+		t.Track("test", reconciler)
+
+		Expect(t.Status()).To(HaveLen(1))
+		Expect(t.Status()[0].Name).To(Equal("test"))
+		Expect(t.Status()[0].LastReconcileTime).To(BeNil())
+		Expect(t.Check(context.TODO())).To(Succeed())
+	})
+
+	It("should record the last successful reconcile and its age", func() {
+		r := t.Track("test", reconciler)
+
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		now = now.Add(time.Minute)
+
+		s := t.Status()[0]
+		Expect(*s.LastSuccessTime).To(Equal(now.Add(-time.Minute)))
+		Expect(s.LastSuccessAge).To(Equal("1m0s"))
+		Expect(s.ConsecutiveFailures).To(BeZero())
+	})
+
+	It("should only fail the check when the last success of a failing controller is too old", func() {
+		r := t.Track("test", reconciler)
+
+		failed = true
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{})
+		Expect(err).Should(HaveOccurred())
+
+		Expect(t.Check(context.TODO())).To(Succeed())
+
+		now = now.Add(11 * time.Minute)
+		_, _ = r.Reconcile(context.TODO(), reconcile.Request{})
+
+		s := t.Status()[0]
+		Expect(s.ConsecutiveFailures).To(Equal(2))
+		Expect(s.LastError).To(Equal("boom"))
+
+		err = t.Check(context.TODO())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("controller test has not reconciled successfully for 11m0s"))
+
+		failed = false
+		_, err = r.Reconcile(context.TODO(), reconcile.Request{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(t.Check(context.TODO())).To(Succeed())
+		Expect(t.Status()[0].ConsecutiveFailures).To(BeZero())
+	})
+
+	It("should measure the age of the last success from the last successful reconcile", func() {
+		r := t.Track("test", reconciler)
+
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		now = now.Add(5 * time.Minute)
+		failed = true
+		_, _ = r.Reconcile(context.TODO(), reconcile.Request{})
+		Expect(t.Check(context.TODO())).To(Succeed())
+
+		now = now.Add(6 * time.Minute)
+		_, _ = r.Reconcile(context.TODO(), reconcile.Request{})
+
+		err = t.Check(context.TODO())
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("for 11m0s"))
+		Expect(t.Status()[0].LastSuccessAge).To(Equal("11m0s"))
+	})
+})
+
+var _ = Describe("health.go | Server", func() {
+	healthy := Check{
+		Name:  "healthy",
+		Check: func(context.Context) error { return nil },
+	}
+	unhealthy := Check{
+		Name:  "unhealthy",
+		Check: func(context.Context) error { return errors.New("something is wrong") },
+	}
+
+	get := func(s *Server, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body, err := io.ReadAll(rec.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return rec.Code, string(body)
+	}
+
+	It("should succeed when all checks succeed", func() {
+		s := &Server{
+			LivenessChecks:  []Check{healthy},
+			ReadinessChecks: []Check{healthy},
+		}
+
+		code, body := get(s, "/readyz")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("[+]healthy ok\nok\n"))
+
+		code, _ = get(s, "/healthz")
+		Expect(code).To(Equal(http.StatusOK))
+	})
+
+	It("should fail readiness with a diagnostic body", func() {
+		s := &Server{
+			LivenessChecks:  []Check{healthy},
+			ReadinessChecks: []Check{healthy, unhealthy},
+		}
+
+		code, body := get(s, "/readyz")
+		Expect(code).To(Equal(http.StatusInternalServerError))
+		Expect(body).To(ContainSubstring("[+]healthy ok"))
+		Expect(body).To(ContainSubstring("[-]unhealthy failed: something is wrong"))
+
+		code, _ = get(s, "/healthz")
+		Expect(code).To(Equal(http.StatusOK))
+	})
+
+	It("should expose all checks and controllers as JSON", func() {
+		t := NewReconcileTracker(time.Minute)
+		t.Track("test", reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, nil
+		}))
+
+		s := &Server{
+			LivenessChecks:  []Check{healthy},
+			ReadinessChecks: []Check{healthy, unhealthy},
+			Reconciles:      t,
+		}
+
+		code, body := get(s, "/debug/status")
+		Expect(code).To(Equal(http.StatusInternalServerError))
+
+		status := Status{}
+		Expect(json.Unmarshal([]byte(body), &status)).To(Succeed())
+
+		Expect(status.Healthy).To(BeFalse())
+		Expect(status.Checks).To(Equal([]CheckStatus{
+			{Name: "healthy", Healthy: true},
+			{Name: "unhealthy", Healthy: false, Message: "something is wrong"},
+		}))
+		Expect(status.Controllers).To(HaveLen(1))
+		Expect(status.Controllers[0].Name).To(Equal("test"))
+	})
+})
+
+var _ = Describe("health.go | Checks", func() {
+	Context("CRDsEstablished", func() {
+		crd := &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "gpuaddons.nvidia.addons.rh-ecosystem-edge.io",
+			},
+		}
+
+		It("should fail when the CRD is missing", func() {
+			c := newTestClient()
+
+			err := CRDsEstablished(c, crd.Name).Check(context.TODO())
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail when the CRD is not established", func() {
+			c := newTestClient(crd)
+
+			err := CRDsEstablished(c, crd.Name).Check(context.TODO())
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not established"))
+		})
+
+		It("should succeed when the CRD is established", func() {
+			established := crd.DeepCopy()
+			established.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+				{
+					Type:   apiextensionsv1.Established,
+					Status: apiextensionsv1.ConditionTrue,
+				},
+			}
+			c := newTestClient(established)
+
+			Expect(CRDsEstablished(c, crd.Name).Check(context.TODO())).To(Succeed())
+		})
+	})
+
+	Context("APIServerReachable", func() {
+		It("should depend on the API server response", func() {
+			status := http.StatusOK
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal("/version"))
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte("{}"))
+			}))
+			defer srv.Close()
+
+			rc, err := rest.RESTClientFor(&rest.Config{
+				Host: srv.URL,
+				ContentConfig: rest.ContentConfig{
+					GroupVersion:         &metav1.SchemeGroupVersion,
+					NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(APIServerReachable(rc).Check(context.TODO())).To(Succeed())
+
+			status = http.StatusServiceUnavailable
+			Expect(APIServerReachable(rc).Check(context.TODO())).ToNot(Succeed())
+		})
+	})
+})
+
+func newTestClient(objs ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	Expect(apiextensionsv1.AddToScheme(s)).ShouldNot(HaveOccurred())
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DefaultMaxFailureDuration is how old the last successful reconcile of a
+// failing controller may be before it is considered unhealthy.
+const DefaultMaxFailureDuration = 10 * time.Minute
+
+// ControllerStatus is the reconciliation status of a single controller.
+type ControllerStatus struct {
+	Name                string     `json:"name"`
+	LastReconcileTime   *time.Time `json:"lastReconcileTime,omitempty"`
+	LastSuccessTime     *time.Time `json:"lastSuccessTime,omitempty"`
+	LastSuccessAge      string     `json:"lastSuccessAge,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+
+	// lastSuccess is the time of the last successful reconcile, or of the
+	// registration of the controller until it first succeeds.
+	lastSuccess time.Time
+}
+
+// ReconcileTracker records the outcome of the reconciliations of the
+// controllers it tracks.
+type ReconcileTracker struct {
+	// MaxFailureDuration is how old the last successful reconcile of a
+	// failing controller may be before the tracker's check fails.
+	MaxFailureDuration time.Duration
+
+	mu          sync.Mutex
+	controllers map[string]*ControllerStatus
+	now         func() time.Time
+}
+
+// NewReconcileTracker returns a tracker with the given failure threshold.
+func NewReconcileTracker(maxFailureDuration time.Duration) *ReconcileTracker {
+	return &ReconcileTracker{
+		MaxFailureDuration: maxFailureDuration,
+		controllers:        map[string]*ControllerStatus{},
+		now:                time.Now,
+	}
+}
+
+// Reconciles is the tracker shared by the add-on controllers.
+var Reconciles = NewReconcileTracker(DefaultMaxFailureDuration)
+
+// Track returns a reconciler which records the outcome of every
+// reconciliation of the given reconciler under the given controller name.
+func (t *ReconcileTracker) Track(name string, r reconcile.Reconciler) reconcile.Reconciler {
+	t.mu.Lock()
+	if _, ok := t.controllers[name]; !ok {
+		t.controllers[name] = &ControllerStatus{Name: name, lastSuccess: t.now()}
+	}
+	t.mu.Unlock()
+
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		res, err := r.Reconcile(ctx, req)
+		t.record(name, err)
+		return res, err
+	})
+}
+
+func (t *ReconcileTracker) record(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	s, ok := t.controllers[name]
+	if !ok {
+		s = &ControllerStatus{Name: name, lastSuccess: now}
+		t.controllers[name] = s
+	}
+
+	s.LastReconcileTime = &now
+
+	if err != nil {
+		s.ConsecutiveFailures++
+		s.LastError = err.Error()
+		return
+	}
+
+	s.lastSuccess = now
+	s.LastSuccessTime = &now
+	s.ConsecutiveFailures = 0
+	s.LastError = ""
+}
+
+// Status returns the status of all tracked controllers, sorted by name.
+func (t *ReconcileTracker) Status() []ControllerStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	statuses := make([]ControllerStatus, 0, len(t.controllers))
+	for _, s := range t.controllers {
+		status := *s
+		if s.LastSuccessTime != nil {
+			status.LastSuccessAge = now.Sub(*s.LastSuccessTime).Round(time.Second).String()
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// Check fails if any of the tracked controllers is failing to reconcile and
+// its last successful reconcile is older than MaxFailureDuration.
+func (t *ReconcileTracker) Check(ctx context.Context) error {
+	now := t.now()
+
+	for _, s := range t.Status() {
+		if s.ConsecutiveFailures == 0 {
+			continue
+		}
+
+		age := now.Sub(s.lastSuccess)
+		if age > t.MaxFailureDuration {
+			return fmt.Errorf("controller %s has not reconciled successfully for %s: %s",
+				s.Name, age.Round(time.Second), s.LastError)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package promclient reads from the add-on Prometheus through its
+// kube-rbac-proxy, authenticating with the operator service account.
+package promclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// serviceCAFile is the CA of the OpenShift service serving certificates,
+	// which the kube-rbac-proxy certificate is issued by.
+	serviceCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+
+	requestTimeout = 30 * time.Second
+)
+
+// Client reads from a Prometheus behind kube-rbac-proxy.
+type Client struct {
+	tokenFile string
+	http      *http.Client
+}
+
+// NewInClusterClient returns a Client authenticating with the token of the
+// pod service account, and trusting the OpenShift service CA.
+func NewInClusterClient() (*Client, error) {
+	ca, err := os.ReadFile(serviceCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the service CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificate found in the service CA")
+	}
+
+	return &Client{
+		tokenFile: serviceAccountTokenFile,
+		http: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:    pool,
+					MinVersion: tls.VersionTLS12,
+				},
+			},
+		},
+	}, nil
+}
+
+// Metrics returns the metrics Prometheus exposes about itself at the given
+// address.
+func (c *Client) Metrics(ctx context.Context, address string) (map[string]*dto.MetricFamily, error) {
+	req, err := c.newRequest(ctx, strings.TrimSuffix(address, "/")+"/metrics")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Prometheus metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the Prometheus metrics: %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Prometheus metrics: %w", err)
+	}
+
+	return families, nil
+}
+
+// queryResponse is the response of the Prometheus instant query API.
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Query evaluates the given PromQL instant query at the given time against
+// the Prometheus at the given address. Only queries returning an instant
+// vector are supported.
+func (c *Client) Query(ctx context.Context, address string, query string, at time.Time) (model.Vector, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatFloat(float64(at.UnixMilli())/1000, 'f', -1, 64))
+
+	req, err := c.newRequest(ctx, strings.TrimSuffix(address, "/")+"/api/v1/query?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Prometheus reports the query errors in the body, along with a
+	// non-200 status.
+	body := queryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to query Prometheus: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("failed to parse the Prometheus response: %w", err)
+	}
+
+	if body.Status != "success" {
+		return nil, fmt.Errorf("failed to query Prometheus: %s", body.Error)
+	}
+
+	if body.Data.ResultType != model.ValVector.String() {
+		return nil, fmt.Errorf("unexpected Prometheus result type %q", body.Data.ResultType)
+	}
+
+	vector := model.Vector{}
+	if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+		return nil, fmt.Errorf("failed to parse the Prometheus result: %w", err)
+	}
+
+	return vector, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	// The token is read on each request, as it is rotated.
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	return req, nil
+}
@@ -0,0 +1,117 @@
+package promclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPromClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PromClient Suite")
+}
+
+var _ = Describe("promclient.go | Client", func() {
+	var (
+		server *httptest.Server
+		c      *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer some-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`# TYPE prometheus_remote_storage_samples_pending gauge
+prometheus_remote_storage_samples_pending{remote_name="central",url="https://central/api/v1/write"} 42
+`))
+		}))
+
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("some-token\n"), 0600)).To(Succeed())
+
+		c = &Client{tokenFile: tokenFile, http: server.Client()}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should parse the metrics", func() {
+		families, err := c.Metrics(context.TODO(), server.URL+"/")
+		Expect(err).ToNot(HaveOccurred())
+
+		pending := families["prometheus_remote_storage_samples_pending"]
+		Expect(pending).ToNot(BeNil())
+		Expect(pending.GetMetric()[0].GetGauge().GetValue()).To(Equal(42.0))
+	})
+
+	It("should fail when unauthorized", func() {
+		c.tokenFile = filepath.Join(GinkgoT().TempDir(), "other-token")
+		Expect(os.WriteFile(c.tokenFile, []byte("other-token"), 0600)).To(Succeed())
+
+		_, err := c.Metrics(context.TODO(), server.URL)
+		Expect(err).To(MatchError(ContainSubstring("401 Unauthorized")))
+	})
+})
+
+var _ = Describe("promclient.go | Query", func() {
+	var (
+		server *httptest.Server
+		c      *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/query" || r.URL.Query().Get("time") != "1660000000" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.URL.Query().Get("query") {
+			case "up":
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+					`{"metric":{"namespace":"team-a"},"value":[1660000000,"12.5"]}]}}`))
+			case "up[5m]":
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+			}
+		}))
+
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("some-token"), 0600)).To(Succeed())
+
+		c = &Client{tokenFile: tokenFile, http: server.Client()}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the instant vector", func() {
+		vector, err := c.Query(context.TODO(), server.URL, "up", time.Unix(1660000000, 0))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vector).To(HaveLen(1))
+		Expect(string(vector[0].Metric["namespace"])).To(Equal("team-a"))
+		Expect(float64(vector[0].Value)).To(Equal(12.5))
+	})
+
+	It("should reject other result types", func() {
+		_, err := c.Query(context.TODO(), server.URL, "up[5m]", time.Unix(1660000000, 0))
+		Expect(err).To(MatchError(ContainSubstring(`unexpected Prometheus result type "matrix"`)))
+	})
+
+	It("should report the query errors", func() {
+		_, err := c.Query(context.TODO(), server.URL, "up(", time.Unix(1660000000, 0))
+		Expect(err).To(MatchError(ContainSubstring("parse error")))
+	})
+})
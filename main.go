@@ -17,11 +17,9 @@ limitations under the License.
 package main
 
 import (
-	"context"
 	"flag"
-	"fmt"
 	"os"
-	"time"
+	"path/filepath"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,23 +28,15 @@ import (
 
 	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/openshift/api/operator/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	gpuv1 "github.com/NVIDIA/gpu-operator/api/v1"
 	consolev1alpha1 "github.com/openshift/api/console/v1alpha1"
@@ -57,10 +47,19 @@ import (
 	promv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
 
 	nvidiav1alpha1 "github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/api/v1alpha1"
-	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/configmap"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/autoscaling"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/bootstrap"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/crd"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/gpuaddon"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/gpunode"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/gpuquota"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/idlegpu"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/monitoring"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/uninstall"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/controllers/usagereport"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/common"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/health"
+	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/promclient"
 	"github.com/rh-ecosystem-edge/nvidia-gpu-addon-operator/internal/version"
 	//+kubebuilder:scaffold:imports
 )
@@ -70,17 +69,18 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// webhookCertDir is where the serving certificate of the webhooks is mounted.
+const webhookCertDir = "/tmp/k8s-webhook-server/serving-certs"
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(nvidiav1alpha1.AddToScheme(scheme))
 	utilruntime.Must(gpuv1.AddToScheme(scheme))
 	utilruntime.Must(nfdv1.AddToScheme(scheme))
 	utilruntime.Must(operatorsv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(operatorsv1.AddToScheme(scheme))
-	utilruntime.Must(consolev1alpha1.AddToScheme(scheme))
-	utilruntime.Must(configv1.AddToScheme(scheme))
-	utilruntime.Must(operatorv1.AddToScheme(scheme))
 	utilruntime.Must(promv1.AddToScheme(scheme))
 	utilruntime.Must(promv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
@@ -105,184 +105,200 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Namespace:              common.GlobalConfig.AddonNamespace,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "f75da35c.addons.rh-ecosystem-edge.io",
-	})
+	cfg := ctrl.GetConfigOrDie()
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
-		setupLog.Error(err, "unable to start manager")
+		setupLog.Error(err, "unable to create discovery client")
 		os.Exit(1)
 	}
 
-	gpuAddonController, err := (&gpuaddon.GPUAddonReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr)
+	common.ClusterPlatform, err = common.DetectPlatform(dc)
 	if err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GPUAddon")
+		setupLog.Error(err, "unable to detect cluster platform")
 		os.Exit(1)
 	}
 
-	go func() {
-		if err := watchForOwnClusterPoliciesWhenAvailable(gpuAddonController); err != nil {
-			setupLog.Error(err, "unable to wait and watch for ClusterPolicy CRD")
-			return
-		}
-	}()
+	// The console and cluster configuration APIs are only served by OpenShift.
+	if common.IsOpenShift() {
+		utilruntime.Must(consolev1alpha1.AddToScheme(scheme))
+		utilruntime.Must(configv1.AddToScheme(scheme))
+		utilruntime.Must(operatorv1.AddToScheme(scheme))
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: metricsAddr,
+		Namespace:          common.GlobalConfig.AddonNamespace,
+		Port:               9443,
+		CertDir:            webhookCertDir,
+		LeaderElection:     enableLeaderElection,
+		LeaderElectionID:   "f75da35c.addons.rh-ecosystem-edge.io",
+		// The CatalogSource of the GPU operator may be outside of the add-on
+		// namespace, thus of the namespaced cache.
+		ClientDisableCacheFor: []client.Object{&operatorsv1alpha1.CatalogSource{}},
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
 
-	if err = (&configmap.ConfigMapReconciler{
+	crdReconciler := &crd.CRDReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+	}
+
+	if err = (&gpuaddon.GPUAddonReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr, crdReconciler); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GPUAddon")
+		os.Exit(1)
+	}
+	evicter := uninstall.NewPodEvicter(kubernetes.NewForConfigOrDie(cfg))
+	if err = (&uninstall.UninstallReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("gpu-addon-uninstall"),
+		APIReader: mgr.GetAPIReader(),
+		Evicter:   evicter,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+		setupLog.Error(err, "unable to create controller", "controller", "Uninstall")
 		os.Exit(1)
 	}
-	if err = (&monitoring.MonitoringReconciler{
+	monitoringReconciler := &monitoring.MonitoringReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	}
+	usageReportReconciler := &usagereport.GPUUsageReportReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}
+	idleGPUReconciler := &idlegpu.IdleGPUReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("gpu-addon-idle-gpu"),
+		APIReader: mgr.GetAPIReader(),
+		Evicter:   evicter,
+	}
+	if promClient, err := promclient.NewInClusterClient(); err != nil {
+		setupLog.Info("Remote write health, GPU usage reports and the idle GPU policy are not available",
+			"reason", err.Error())
+	} else {
+		monitoringReconciler.Prometheus = promClient
+		usageReportReconciler.Prometheus = promClient
+		idleGPUReconciler.Prometheus = promClient
+	}
+	if err = monitoringReconciler.SetupWithManager(mgr, crdReconciler); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Monitoring")
 		os.Exit(1)
 	}
-	//+kubebuilder:scaffold:builder
-
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
+	if err = usageReportReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GPUUsageReport")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	if err = idleGPUReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "IdleGPU")
 		os.Exit(1)
 	}
-
-	c, err := client.New(ctrlconfig.GetConfigOrDie(), client.Options{Scheme: scheme})
-	if err != nil {
-		setupLog.Error(err, "failed to create client to jumpstart addon")
+	if err = (&gpuquota.GPUQuotaReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GPUQuota")
 		os.Exit(1)
 	}
-	if err := jumpstartAddon(c); err != nil {
-		setupLog.Error(err, "failed to jumpstart addon")
+	if err = (&autoscaling.AutoscalingReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Autoscaling")
 		os.Exit(1)
 	}
-	if err := jumpstartMonitoring(c); err != nil {
-		setupLog.Error(err, "failed to jumpstart monitoring")
+	if err = (&gpunode.GPUNodeTaintReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GPUNodeTaint")
 		os.Exit(1)
 	}
-
-	setupLog.Info("starting manager", "version", version.Version(), "config", common.GlobalConfig)
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
+	if err = (&gpunode.GPUTypeReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("gpu-addon-gpu-type"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GPUType")
 		os.Exit(1)
 	}
-}
-
-func watchForOwnClusterPoliciesWhenAvailable(c controller.Controller) error {
-	if err := wait.PollInfinite(time.Second, isClusterPolicyAvailable()); err != nil {
-		return fmt.Errorf("unable to wait for ClusterPolicy CRD: %w", err)
-	}
-
-	err := c.Watch(
-		&source.Kind{Type: &gpuv1.ClusterPolicy{}},
-		&handler.EnqueueRequestForObject{})
-
-	if err != nil {
-		return fmt.Errorf("unable to watch for owned ClusterPolicy CRs: %w", err)
-	}
-
-	return nil
-}
-
-func isClusterPolicyAvailable() wait.ConditionFunc {
-	return func() (bool, error) {
-		client, err := client.New(ctrlconfig.GetConfigOrDie(), client.Options{Scheme: scheme})
-		if err != nil {
-			return false, err
-		}
-
-		clusterPolicyList := &gpuv1.ClusterPolicyList{}
-
-		err = client.List(context.TODO(), clusterPolicyList)
-		unavailable := meta.IsNoMatchError(err)
-		if err != nil && !unavailable {
-			return false, err
+	// The webhooks are disabled when running outside of the cluster, without
+	// a serving certificate. The OpenShift service CA issues it, while on other
+	// platforms it has to be provided, e.g. by OLM or cert-manager.
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if common.IsOpenShift() || webhookCertProvided() {
+			if err = (&gpunode.PodMutator{
+				Client: mgr.GetClient(),
+			}).SetupWebhookWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
+				os.Exit(1)
+			}
+		} else {
+			setupLog.Info("Webhooks are disabled as no serving certificate is provided",
+				"certDir", webhookCertDir,
+				"platform", common.ClusterPlatform)
 		}
-
-		return !unavailable, nil
 	}
-}
-
-func jumpstartAddon(client client.Client) error {
-	gpuAddon := &nvidiav1alpha1.GPUAddon{}
-	err := client.Get(context.TODO(), types.NamespacedName{
-		Name:      common.GlobalConfig.AddonID,
-		Namespace: common.GlobalConfig.AddonNamespace,
-	}, gpuAddon)
-	isNotFound := k8serrors.IsNotFound(err)
-	if err != nil && !isNotFound {
-		return fmt.Errorf("failed to fetch GPUAddon CR %s in %s: %w", common.GlobalConfig.AddonID, common.GlobalConfig.AddonNamespace, err)
+	if err = (&bootstrap.BootstrapReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Bootstrap")
+		os.Exit(1)
 	}
-	if isNotFound {
-		gpuAddon.Spec = nvidiav1alpha1.GPUAddonSpec{
-			ConsolePluginEnabled: true,
-		}
+	if err = crdReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CustomResourceDefinition")
+		os.Exit(1)
 	}
+	//+kubebuilder:scaffold:builder
 
-	gpuAddon.ObjectMeta = metav1.ObjectMeta{
-		Name:      common.GlobalConfig.AddonID,
-		Namespace: common.GlobalConfig.AddonNamespace,
+	if err := mgr.Add(&health.Server{
+		Addr: probeAddr,
+		ReadinessChecks: []health.Check{
+			health.CacheSynced(mgr.GetCache()),
+			health.APIServerReachable(dc.RESTClient()),
+			health.CRDsEstablished(mgr.GetAPIReader(),
+				"gpuaddons."+nvidiav1alpha1.GroupVersion.Group,
+				"monitorings."+nvidiav1alpha1.GroupVersion.Group,
+				"gpuusagereports."+nvidiav1alpha1.GroupVersion.Group,
+				"gpuquotas."+nvidiav1alpha1.GroupVersion.Group),
+			health.ReconcilesHealthy(health.Reconciles),
+		},
+		Reconciles: health.Reconciles,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up health probes")
+		os.Exit(1)
 	}
 
-	result, err := controllerutil.CreateOrPatch(context.TODO(), client, gpuAddon, func() error {
-		versionLabel := fmt.Sprintf("%v-version", common.GlobalConfig.AddonID)
-		gpuAddon.ObjectMeta.Labels = map[string]string{
-			versionLabel: version.Version(),
-		}
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to reconcile GPUAddon CR %s in %s, result: %v. error: %w", common.GlobalConfig.AddonID, common.GlobalConfig.AddonNamespace, result, err)
+	setupLog.Info("starting manager",
+		"version", version.Version(),
+		"platform", common.ClusterPlatform,
+		"config", common.GlobalConfig)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
 	}
-
-	return nil
 }
 
-func jumpstartMonitoring(client client.Client) error {
-	m := &nvidiav1alpha1.Monitoring{}
-	err := client.Get(context.TODO(), types.NamespacedName{
-		Name:      common.GlobalConfig.AddonID,
-		Namespace: common.GlobalConfig.AddonNamespace,
-	}, m)
-	isNotFound := k8serrors.IsNotFound(err)
-	if err != nil && !isNotFound {
-		return fmt.Errorf("failed to fetch Monitoring CR %s in %s: %w", common.GlobalConfig.AddonID, common.GlobalConfig.AddonNamespace, err)
-	}
-	if isNotFound {
-		m.Spec = nvidiav1alpha1.MonitoringSpec{}
-	}
-
-	m.ObjectMeta = metav1.ObjectMeta{
-		Name:      common.GlobalConfig.AddonID,
-		Namespace: common.GlobalConfig.AddonNamespace,
-	}
-
-	result, err := controllerutil.CreateOrPatch(context.TODO(), client, m, func() error {
-		// versionLabel := fmt.Sprintf("%v-version", common.GlobalConfig.AddonLabel)
-		// m.ObjectMeta.Labels = map[string]string{
-		// 	versionLabel: version.Version(),
-		// }
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to reconcile Monitoring CR %s in %s, result: %v. error: %w",
-			common.GlobalConfig.AddonID, common.GlobalConfig.AddonNamespace, result, err)
+// webhookCertProvided returns whether the serving certificate of the webhooks
+// is mounted.
+func webhookCertProvided() bool {
+	for _, f := range []string{"tls.crt", "tls.key"} {
+		if _, err := os.Stat(filepath.Join(webhookCertDir, f)); err != nil {
+			return false
+		}
 	}
-
-	return nil
+	return true
 }